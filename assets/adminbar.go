@@ -0,0 +1,106 @@
+package assets
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// adminBarIDs are element ids WordPress gives the admin bar markup and its enqueued assets
+var adminBarIDs = map[string]bool{
+	"wpadminbar":           true,
+	"admin-bar-css":        true,
+	"admin-bar-js":         true,
+	"admin-bar-inline-css": true,
+}
+
+// adminBarAssetPaths identify admin bar assets that were enqueued without their usual id
+var adminBarAssetPaths = []string{
+	"/wp-includes/css/admin-bar",
+	"/wp-includes/js/admin-bar",
+}
+
+// isAdminBarNode reports whether an element belongs to the logged-in admin bar
+func isAdminBarNode(n *html.Node) bool {
+	if adminBarIDs[getAttr(n, "id")] {
+		return true
+	}
+
+	var ref string
+	switch n.Data {
+	case "link":
+		ref = getAttr(n, "href")
+	case "script":
+		ref = getAttr(n, "src")
+	default:
+		return false
+	}
+	for _, assetPath := range adminBarAssetPaths {
+		if strings.Contains(ref, assetPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripAdminBar removes the WordPress admin bar and its assets from a logged-in scrape so they
+// are never collected for download, returning the href/src of the removed stylesheets and scripts.
+// The HTML is returned unchanged when no admin bar is present.
+func stripAdminBar(htmlContent string) (string, []string, error) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", nil, err
+	}
+
+	var removed []*html.Node
+	var body *html.Node
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if isAdminBarNode(n) {
+				removed = append(removed, n)
+				return
+			}
+			if n.Data == "body" {
+				body = n
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+
+	traverse(doc)
+
+	if len(removed) == 0 {
+		return htmlContent, nil, nil
+	}
+	var removedRefs []string
+	for _, n := range removed {
+		switch {
+		case n.Data == "link" && getAttr(n, "href") != "":
+			removedRefs = append(removedRefs, getAttr(n, "href"))
+		case n.Data == "script" && getAttr(n, "src") != "":
+			removedRefs = append(removedRefs, getAttr(n, "src"))
+		}
+		n.Parent.RemoveChild(n)
+	}
+
+	// Drop the body class that reserves space for the bar
+	if body != nil {
+		var classes []string
+		for _, class := range strings.Fields(getAttr(body, "class")) {
+			if class != "admin-bar" {
+				classes = append(classes, class)
+			}
+		}
+		setAttr(body, "class", strings.Join(classes, " "))
+	}
+
+	var buf strings.Builder
+	if err := html.Render(&buf, doc); err != nil {
+		return "", nil, err
+	}
+	return buf.String(), removedRefs, nil
+}
@@ -0,0 +1,39 @@
+package assets
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestStripAdminBarSkipsAssets(t *testing.T) {
+	chdirOutput(t)
+
+	origin, rec := newRecordingProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("asset"))
+	})
+	base, _ := url.Parse(origin.URL + "/")
+	page := `<html><head>` +
+		`<link rel="stylesheet" id="admin-bar-css" href="` + origin.URL + `/wp-includes/css/admin-bar.min.css">` +
+		`<link rel="stylesheet" href="` + origin.URL + `/wp-content/themes/site/style.css">` +
+		`</head><body class="home admin-bar"><div id="wpadminbar"><img src="` + origin.URL + `/avatar.png"></div>` +
+		`<script src="` + origin.URL + `/wp-includes/js/admin-bar.min.js"></script></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 2, StripAdminBar: true})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	for _, requested := range rec.seen() {
+		if strings.Contains(requested, "admin-bar") || strings.Contains(requested, "avatar") {
+			t.Errorf("admin bar asset should not be downloaded: %s", requested)
+		}
+	}
+	if strings.Contains(result, "wpadminbar") || strings.Contains(result, "admin-bar") {
+		t.Errorf("admin bar markup should be removed, got %s", result)
+	}
+	if !strings.Contains(result, `href="assets/style.css"`) {
+		t.Errorf("theme stylesheet should still be localized, got %s", result)
+	}
+}
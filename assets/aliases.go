@@ -0,0 +1,77 @@
+package assets
+
+import (
+	"net/url"
+	"strings"
+)
+
+// canonicalizeURL rewrites a URL whose origin is aliased to its canonical origin.
+// Alias keys and values are either bare hosts ("cdn1.example.com") or origins ("https://cdn1.example.com").
+func canonicalizeURL(rawURL string, aliases map[string]string) string {
+	if len(aliases) == 0 {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+
+	for from, to := range aliases {
+		fromOrigin, err := parseOrigin(from)
+		if err != nil || !strings.EqualFold(fromOrigin.Host, u.Host) {
+			continue
+		}
+		if fromOrigin.Scheme != "" && fromOrigin.Scheme != u.Scheme {
+			continue
+		}
+
+		toOrigin, err := parseOrigin(to)
+		if err != nil || toOrigin.Host == "" {
+			return rawURL
+		}
+		u.Host = toOrigin.Host
+		if toOrigin.Scheme != "" {
+			u.Scheme = toOrigin.Scheme
+		}
+		return u.String()
+	}
+	return rawURL
+}
+
+// parseOrigin parses an alias side, accepting a bare host in place of a full origin
+func parseOrigin(origin string) (*url.URL, error) {
+	if !strings.Contains(origin, "://") {
+		return &url.URL{Host: origin}, nil
+	}
+	return url.Parse(origin)
+}
+
+// applyOriginAliases points every job at its canonical origin; dedupeJobs then drops the duplicates
+func applyOriginAliases(jobs []DownloadJob, aliases map[string]string) []DownloadJob {
+	for i := range jobs {
+		jobs[i].URL = canonicalizeURL(jobs[i].URL, aliases)
+	}
+	return jobs
+}
+
+// dedupeJobs keeps one job per source URL, so a file referenced by several element types (an SVG
+// used as both an image and a font) downloads once to the location of its first reference.
+// The returned map sends each dropped job's OriginalPath to the OriginalPath of the job kept in its place.
+// URLs are compared with assetKey under the given case policy.
+func dedupeJobs(jobs []DownloadJob, casePolicy string) ([]DownloadJob, map[string]string) {
+	kept := make([]DownloadJob, 0, len(jobs))
+	keptByURL := make(map[string]string)
+	aliasPaths := make(map[string]string)
+
+	for _, job := range jobs {
+		key := assetKey(job.URL, casePolicy)
+		if keptPath, ok := keptByURL[key]; ok {
+			aliasPaths[job.OriginalPath] = keptPath
+			continue
+		}
+		keptByURL[key] = job.OriginalPath
+		kept = append(kept, job)
+	}
+
+	return kept, aliasPaths
+}
@@ -0,0 +1,68 @@
+package assets
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOriginAliasesDedupeDownloads(t *testing.T) {
+	chdirOutput(t)
+
+	origin, rec := newRecordingProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("console.log(1);"))
+	})
+	canonical := strings.TrimPrefix(origin.URL, "http://")
+	base, _ := url.Parse(origin.URL + "/")
+	page := `<html><head>` +
+		`<script src="http://cdn1.invalid/js/app.js"></script>` +
+		`<script src="http://cdn2.invalid/js/app.js"></script>` +
+		`</head><body></body></html>`
+
+	opts := Options{
+		Concurrency:   2,
+		OriginAliases: map[string]string{"cdn1.invalid": canonical, "http://cdn2.invalid": "http://" + canonical},
+	}
+	result, err := LocalizeAssets(page, base, opts)
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	if got := rec.seen(); len(got) != 1 {
+		t.Errorf("aliased origins should dedupe to a single download, got %v", got)
+	}
+	if strings.Count(result, `src="assets/app.js"`) != 2 {
+		t.Errorf("both aliased references should point at the local copy, got %s", result)
+	}
+}
+
+func TestSharedURLDownloadsOnce(t *testing.T) {
+	var hits int64
+	server, base := newTestSiteHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/logo.svg" {
+			atomic.AddInt64(&hits, 1)
+		}
+		w.Write([]byte("<svg></svg>"))
+	}))
+	page := `<html><head><style>@font-face{font-family:Logo;src:url(./logo.svg)}</style></head>` +
+		`<body><img src="` + server.URL + `/logo.svg"></body></html>`
+	updated, err := LocalizeAssets(page, base, Options{Concurrency: 4})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	if got := atomic.LoadInt64(&hits); got != 1 {
+		t.Errorf("logo.svg fetched %d times; want 1", got)
+	}
+	if _, err := os.Stat("output/assets/fonts/logo.svg"); !os.IsNotExist(err) {
+		t.Error("a second copy should not be saved to the fonts directory")
+	}
+	for _, want := range []string{`src="assets/images/logo.svg"`, `url(assets/images/logo.svg)`} {
+		if !strings.Contains(updated, want) {
+			t.Errorf("expected %s in output: %s", want, updated)
+		}
+	}
+}
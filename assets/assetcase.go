@@ -0,0 +1,37 @@
+package assets
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Filename case policies for assets served under differing case (Image.JPG vs image.jpg)
+const (
+	AssetCasePreserve = ""      // Save files as named by the origin
+	AssetCaseLower    = "lower" // Lowercase saved filenames and treat URLs differing only in case as one asset
+	AssetCaseUpper    = "upper" // Uppercase saved filenames and treat URLs differing only in case as one asset
+)
+
+// applyAssetCase normalizes the filename of a local path under a case policy; directories are left alone
+func applyAssetCase(localPath, policy string) string {
+	dir, file := path.Split(filepath.ToSlash(localPath))
+	switch policy {
+	case AssetCaseLower:
+		file = strings.ToLower(file)
+	case AssetCaseUpper:
+		file = strings.ToUpper(file)
+	default:
+		return localPath
+	}
+	return dir + file
+}
+
+// assetKey identifies a source URL for deduplication. Under a case policy, URLs that differ only in
+// case are the same asset, so they download once and share one file.
+func assetKey(rawURL, policy string) string {
+	if policy == AssetCasePreserve {
+		return rawURL
+	}
+	return strings.ToLower(rawURL)
+}
@@ -0,0 +1,65 @@
+package assets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"wp-static-scraper/utils"
+)
+
+func TestNormalizeAssetCase(t *testing.T) {
+	var hits int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.Write([]byte("jpg"))
+	}))
+	defer server.Close()
+
+	base, _ := url.Parse(server.URL + "/")
+	page := `<html><body><img src="/img/Image.JPG"><img src="/img/image.jpg"></body></html>`
+
+	for _, tc := range []struct {
+		policy string
+		files  []string
+		hits   int64
+	}{
+		{AssetCasePreserve, []string{"Image.JPG", "image.jpg"}, 2},
+		{AssetCaseLower, []string{"image.jpg"}, 1},
+		{AssetCaseUpper, []string{"IMAGE.JPG"}, 1},
+	} {
+		t.Run("policy="+tc.policy, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			if err := utils.EnsureDirectories(); err != nil {
+				t.Fatalf("Failed to create directories: %v", err)
+			}
+			atomic.StoreInt64(&hits, 0)
+
+			result, err := LocalizeAssets(page, base, Options{Concurrency: 2, AssetCase: tc.policy})
+			if err != nil {
+				t.Fatalf("LocalizeAssets returned error: %v", err)
+			}
+			if got := atomic.LoadInt64(&hits); got != tc.hits {
+				t.Errorf("fetched %d times; want %d", got, tc.hits)
+			}
+			entries, err := os.ReadDir("output/assets/images")
+			if err != nil {
+				t.Fatalf("Failed to read images: %v", err)
+			}
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			if strings.Join(names, ",") != strings.Join(tc.files, ",") {
+				t.Errorf("saved files = %v; want %v", names, tc.files)
+			}
+			if tc.policy != AssetCasePreserve && strings.Count(result, `src="assets/images/`+tc.files[0]+`"`) != 2 {
+				t.Errorf("both references should point at the single file: %s", result)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package assets
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// assetRuleTypes are the job types an asset rule may download its URLs as
+var assetRuleTypes = map[string]bool{
+	"image": true,
+	"media": true,
+	"font":  true,
+	"css":   true,
+	"js":    true,
+	"file":  true,
+}
+
+// AssetRule declares a theme- or plugin-specific place to find asset URLs: every element
+// matching Selector has its Attr value downloaded as an asset of Type
+type AssetRule struct {
+	Selector string
+	Attr     string
+	Type     string
+	compiled compoundSelector
+}
+
+// compoundSelector is a single compound CSS selector: an optional tag name (or *), an optional
+// #id, and any number of .classes, with no combinators, attribute matchers, or pseudo-classes
+type compoundSelector struct {
+	tag     string
+	id      string
+	classes []string
+}
+
+// ParseAssetRule parses a selector@attr[:type] rule such as div.hero@data-bg or
+// video.bg@data-video:media. The type defaults to image.
+func ParseAssetRule(spec string) (AssetRule, error) {
+	at := strings.LastIndex(spec, "@")
+	if at < 0 {
+		return AssetRule{}, fmt.Errorf("asset rule %q: expected selector@attr[:type]", spec)
+	}
+	rule := AssetRule{Selector: strings.TrimSpace(spec[:at]), Type: "image"}
+	attr, jobType, hasType := strings.Cut(spec[at+1:], ":")
+	rule.Attr = strings.ToLower(strings.TrimSpace(attr))
+	if hasType {
+		rule.Type = strings.ToLower(strings.TrimSpace(jobType))
+	}
+	if rule.Attr == "" {
+		return AssetRule{}, fmt.Errorf("asset rule %q: missing attribute", spec)
+	}
+	if !assetRuleTypes[rule.Type] {
+		return AssetRule{}, fmt.Errorf("asset rule %q: unknown type %q (use image, media, font, css, js, or file)", spec, rule.Type)
+	}
+	compiled, err := parseCompoundSelector(rule.Selector)
+	if err != nil {
+		return AssetRule{}, fmt.Errorf("asset rule %q: %w", spec, err)
+	}
+	rule.compiled = compiled
+	return rule, nil
+}
+
+// parseCompoundSelector parses tag, *, .class, and #id parts, e.g. div.hero.wide or #banner
+func parseCompoundSelector(selector string) (compoundSelector, error) {
+	var sel compoundSelector
+	if selector == "" {
+		return sel, fmt.Errorf("empty selector")
+	}
+	i := 0
+	readName := func() string {
+		start := i
+		for i < len(selector) && isCSSNameByte(selector[i]) {
+			i++
+		}
+		return selector[start:i]
+	}
+	if selector[0] == '*' {
+		i++
+	} else if isCSSNameByte(selector[0]) {
+		sel.tag = strings.ToLower(readName())
+	}
+	for i < len(selector) {
+		prefix := selector[i]
+		i++
+		name := readName()
+		switch {
+		case name == "":
+			return sel, fmt.Errorf("unsupported selector %q (only tag, .class, and #id are supported)", selector)
+		case prefix == '.':
+			sel.classes = append(sel.classes, name)
+		case prefix == '#' && sel.id == "":
+			sel.id = name
+		default:
+			return sel, fmt.Errorf("unsupported selector %q (only tag, .class, and #id are supported)", selector)
+		}
+	}
+	return sel, nil
+}
+
+// matches reports whether an element satisfies the selector
+func (sel compoundSelector) matches(n *html.Node) bool {
+	if sel.tag != "" && n.Data != sel.tag {
+		return false
+	}
+	if sel.id != "" && getAttr(n, "id") != sel.id {
+		return false
+	}
+	classes := strings.Fields(getAttr(n, "class"))
+	for _, want := range sel.classes {
+		found := false
+		for _, class := range classes {
+			if class == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// collectAssetRuleJobs collects the URLs found in the attributes named by user-defined asset rules
+func collectAssetRuleJobs(htmlContent string, base *url.URL, rules []AssetRule) []DownloadJob {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil
+	}
+
+	var jobs []DownloadJob
+	urlSeen := make(map[string]bool)
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for _, rule := range rules {
+				if !rule.compiled.matches(n) {
+					continue
+				}
+				ref := strings.TrimSpace(getAttr(n, rule.Attr))
+				resolvedURL, ok := resolveAssetURL(base, ref)
+				if !ok || urlSeen[resolvedURL] {
+					continue
+				}
+				urlSeen[resolvedURL] = true
+				jobs = append(jobs, DownloadJob{
+					URL:          resolvedURL,
+					Type:         rule.Type,
+					OriginalPath: ref,
+					BaseURL:      base,
+				})
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+
+	traverse(doc)
+	return jobs
+}
@@ -0,0 +1,51 @@
+package assets
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAssetRuleDownloadsCustomAttribute(t *testing.T) {
+	_, base := newTestSite(t, map[string]string{
+		"/img/hero-bg.jpg":  "hero",
+		"/img/other-bg.jpg": "other",
+		"/video/loop.mp4":   "video",
+	})
+	page := `<html><body>` +
+		`<div class="hero wide" data-bg="/img/hero-bg.jpg"></div>` +
+		`<div class="promo" data-bg="/img/other-bg.jpg"></div>` +
+		`<section id="intro" data-video="/video/loop.mp4"></section>` +
+		`</body></html>`
+
+	var rules []AssetRule
+	for _, spec := range []string{"div.hero@data-bg", "#intro@data-video:media"} {
+		rule, err := ParseAssetRule(spec)
+		if err != nil {
+			t.Fatalf("ParseAssetRule(%q) returned error: %v", spec, err)
+		}
+		rules = append(rules, rule)
+	}
+	for _, bad := range []string{"div.hero", "div > p@src", "div@data-bg:nope"} {
+		if _, err := ParseAssetRule(bad); err == nil {
+			t.Errorf("ParseAssetRule(%q) should fail", bad)
+		}
+	}
+
+	html, err := LocalizeAssets(page, base, Options{Concurrency: 2, AssetRules: rules})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	if !strings.Contains(html, `data-bg="assets/images/hero-bg.jpg"`) {
+		t.Errorf("matching element should point at the local image: %s", html)
+	}
+	if !strings.Contains(html, `data-bg="/img/other-bg.jpg"`) {
+		t.Errorf("non-matching element should be left alone: %s", html)
+	}
+	if !strings.Contains(html, `data-video="assets/media/loop.mp4"`) {
+		t.Errorf("media rule should save to assets/media: %s", html)
+	}
+	if _, err := os.Stat("output/assets/images/other-bg.jpg"); !os.IsNotExist(err) {
+		t.Errorf("unmatched attribute should not be downloaded")
+	}
+}
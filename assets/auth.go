@@ -0,0 +1,35 @@
+package assets
+
+import (
+	"net/http"
+	"strings"
+)
+
+// setBasicAuth adds an Authorization: Basic header from credentials given as user:pass, for staging
+// sites behind HTTP basic auth. It is a no-op when credentials are empty or the request goes to a
+// host outside hosts (see Options.credentialHosts), so third-party CDNs never receive them.
+func setBasicAuth(req *http.Request, credentials string, hosts []string) {
+	if len(hosts) > 0 && !hostListed(req.URL, hosts) {
+		return
+	}
+	if user, pass, ok := strings.Cut(credentials, ":"); ok {
+		req.SetBasicAuth(user, pass)
+	}
+}
+
+// credentialHosts lists the hosts the site's credentials are sent to: SiteHosts, CrawlHosts, and
+// every host named in OriginAliases. Without SiteHosts it is empty and nothing is restricted.
+func (o Options) credentialHosts() []string {
+	if len(o.SiteHosts) == 0 {
+		return nil
+	}
+	hosts := append(append([]string{}, o.SiteHosts...), o.CrawlHosts...)
+	for alias, canonical := range o.OriginAliases {
+		for _, origin := range []string{alias, canonical} {
+			if u, err := parseOrigin(origin); err == nil && u.Host != "" {
+				hosts = append(hosts, u.Host)
+			}
+		}
+	}
+	return hosts
+}
@@ -1,11 +1,14 @@
 package assets
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -14,11 +17,51 @@ import (
 	"wp-static-scraper/utils"
 )
 
+// ErrBudgetExceeded is reported for jobs skipped after the download byte budget was used up
+var ErrBudgetExceeded = errors.New("download byte budget exceeded")
+
+// ErrTooManyRedirects is reported for assets whose redirect chain exceeds the per-asset cap
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// DefaultMaxRedirects is the per-asset redirect cap used unless SetMaxRedirects overrides it
+const DefaultMaxRedirects = 10
+
+// ErrBadStatus is reported for asset responses other than 200 OK
+var ErrBadStatus = errors.New("bad status")
+
+// statusError is a response other than 200 OK; it matches ErrBadStatus
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string { return ErrBadStatus.Error() + ": " + e.status }
+
+func (e *statusError) Unwrap() error { return ErrBadStatus }
+
+// permanent reports whether every attempt gets the same answer: a 4xx other than 408 Request
+// Timeout and 429 Too Many Requests
+func (e *statusError) permanent() bool {
+	return e.code >= 400 && e.code < 500 && e.code != http.StatusRequestTimeout && e.code != http.StatusTooManyRequests
+}
+
+// ErrEmptyBody is reported for image, media, and file 200 responses without content when
+// RetryEmpty is set
+var ErrEmptyBody = errors.New("empty response body")
+
+// ErrExcluded is reported for URLs whose extension is excluded with SetExcludedExtensions
+var ErrExcluded = errors.New("extension excluded")
+
+// ErrTooLarge is reported for downloadable files bigger than MaxFileSize
+var ErrTooLarge = errors.New("file exceeds max size")
+
+// ErrRuntimeExceeded aborts a run that is still downloading when its deadline passes
+var ErrRuntimeExceeded = errors.New("max runtime exceeded")
 
 // DownloadJob represents a single download task
 type DownloadJob struct {
 	URL          string
-	Type         string // "css", "js", "image", "font"
+	Type         string // "css", "js", "json", "image", "font", "media", "data", "file"
 	OriginalPath string // for HTML replacement
 	BaseURL      *url.URL
 	RetryCount   int    // Number of times this job has been retried
@@ -30,17 +73,80 @@ type DownloadResult struct {
 	LocalPath string
 	Success   bool
 	Error     error
+	Size      int64 // Bytes saved, filled in by GetResults (0 for failures and inlined data)
 }
 
 // ConcurrentDownloader manages parallel downloads with a worker pool
 type ConcurrentDownloader struct {
-	MaxWorkers    int
-	jobs          chan DownloadJob
-	results       chan DownloadResult
-	wg            sync.WaitGroup
-	totalJobs     int64
-	completedJobs int64
-	client        *http.Client
+	MaxWorkers      int
+	MaxTotalBytes   int64  // Stop downloading once this many bytes were fetched (0 means unlimited)
+	MaxFileSize     int64  // Skip <a download> files larger than this many bytes (0 means unlimited)
+	FailFast        bool   // Cancel all remaining downloads on the first primary (non-font) failure
+	SkipExisting    bool   // Reuse files already saved at the computed local path instead of fetching
+	ParseSourceMaps bool   // Download images and fonts referenced only inside JS source maps
+	Dedupe          bool   // Collapse downloads with identical content onto the first saved file
+	DedupeReport    bool   // Count downloads with identical content, collapsed or not, for DedupeStats
+	ConcurrentCSS   bool   // Queue assets referenced by CSS into the pool and rewrite stylesheets once they resolve
+	LineEndings     string // Normalize text assets to "lf" or "crlf" before saving (empty leaves them alone)
+	CaptureHeaders  bool   // Record the status and response headers of every fetch
+	WriteLimit      int    // Limit concurrent disk writes independently of MaxWorkers (0 means unlimited)
+	CSSURLBase      string // Rewrite local url() references in CSS to absolute paths under this base (empty keeps them relative)
+	FontURLPrefix   string // Rewrite CSS references to localized fonts to this absolute prefix (empty keeps them relative)
+	RequestIDHeader string // Send a unique ID under this header name with every request (empty disables)
+	BasicAuth       string // HTTP basic auth credentials sent to the CredentialHosts, as user:pass (empty disables)
+	UserAgent       string // Replaces Go's default User-Agent on every request (empty keeps it)
+	AcceptEncoding  string // "br" also requests and decodes Brotli responses (empty keeps gzip only)
+	InlineBelow     int64  // Keep a data: URI for images smaller than this many bytes so references can inline them (0 disables)
+	ImageQuality    int    // Re-encode JPEGs at this quality (1-100) when that makes them smaller (0 disables)
+	AssetCase       string // Filename case policy (AssetCaseLower/AssetCaseUpper); URLs differing only in case share one download
+	PreserveMTime   bool   // Set each saved file's modification time from the response's Last-Modified header
+	RetryEmpty      bool   // Treat an empty 200 image, media, or file body as a transient failure instead of saving a zero-byte file
+	OutDir          string // Directory assets are saved under (empty means utils.DefaultOutDir)
+	PreservePaths   bool   // Mirror each asset's URL path under assets/ instead of flattening it by basename
+	HashNames       bool   // Name each saved file by a short hash of its content plus its extension
+	RequestHeaders  http.Header
+	CredentialHosts []string      // Hosts BasicAuth and RequestHeaders are sent to (empty sends them to every host)
+	Output          *utils.Output // Writes saved files and directories with the configured permissions (nil uses the defaults)
+	Log             io.Writer     // Receives failed assets and the download summary (nil prints them to standard output)
+	ctx             context.Context
+	cancel          context.CancelFunc
+	abortOnce       sync.Once
+	abortErr        error
+	jobs            chan DownloadJob
+	results         chan DownloadResult
+	wg              sync.WaitGroup
+	pendingJobs     sync.WaitGroup // Jobs queued but not yet reported, including ones discovered by workers
+	totalJobs       int64
+	completedJobs   int64
+	collected       []DownloadResult
+	downloadedBytes int64
+	reusedFiles     int64
+	claimedURLs     sync.Map // Source URLs already queued by the page, stylesheets, or source maps
+	finalURLs       sync.Map // Final URL after redirects -> the source URL downloading it
+	inlined         sync.Map // Local path -> data: URI of images under InlineBelow
+	hashedFiles     sync.Map // Content-hashed paths already written under HashNames
+	aliasMu         sync.Mutex
+	aliases         map[string]string // Original path -> content-hashed path under HashNames ("" once two files share the original)
+	assetFetches    sync.Map // Type and URL key -> *assetFetch shared by every job and stylesheet referencing an image or font
+	redirectMu      sync.Mutex
+	redirectOwners  map[string]*redirectOwner // Source URL -> its outcome and the redirect duplicates waiting on it
+	sheetMu         sync.Mutex
+	sheetFetches    map[string]*sheetFetch // Stylesheet URL key -> the download shared by every job and @import reaching it
+	cssMu           sync.Mutex
+	cssRewrites     []cssRewrite
+	registry        *contentRegistry
+	headers         *headerLog
+	writeSem        chan struct{}
+	activeWrites    int64
+	peakWrites      int64
+	throttle        *adaptiveThrottle
+	extSems         map[string]chan struct{}
+	excludedExts    map[string]bool // Extensions never downloaded, wherever they are referenced
+	limiter         *RequestLimiter // Global request cap shared with other pages and downloaders
+	cssPurge        *CSSPurge       // Drops unused rules from stylesheets before they are saved (nil keeps them whole)
+	progress        *progressStream // Newline-delimited JSON progress events (nil disables)
+	deadline        *time.Timer
+	client          *http.Client
 }
 
 // NewConcurrentDownloader creates a new concurrent downloader
@@ -55,16 +161,180 @@ func NewConcurrentDownloader(maxWorkers int) *ConcurrentDownloader {
 		},
 	}
 	
-	return &ConcurrentDownloader{
+	ctx, cancel := context.WithCancel(context.Background())
+	
+	cd := &ConcurrentDownloader{
 		MaxWorkers: maxWorkers,
+		ctx:        ctx,
+		cancel:     cancel,
 		jobs:       make(chan DownloadJob, maxWorkers*4), // Buffer for better performance
 		results:    make(chan DownloadResult, maxWorkers*4),
 		client:     client,
+		registry:   newContentRegistry(),
+		headers:    newHeaderLog(),
+	}
+	client.CheckRedirect = cd.checkRedirect(DefaultMaxRedirects)
+	return cd
+}
+
+// logWriter returns where failed assets and the download summary are printed
+func (cd *ConcurrentDownloader) logWriter() io.Writer {
+	if cd.Log == nil {
+		return os.Stdout
+	}
+	return cd.Log
+}
+
+// outputDir returns the directory assets are saved under
+func (cd *ConcurrentDownloader) outputDir() string {
+	if cd.OutDir == "" {
+		return utils.DefaultOutDir
+	}
+	return cd.OutDir
+}
+
+// layout returns where this downloader saves assets
+func (cd *ConcurrentDownloader) layout() assetLayout {
+	return assetLayout{outDir: cd.outputDir(), preservePaths: cd.PreservePaths, hashNames: cd.HashNames, output: cd.Output, recordAlias: cd.recordAlias}
+}
+
+// localPathFor computes where an asset is saved under this downloader's layout, before any
+// content naming
+func (cd *ConcurrentDownloader) localPathFor(jobType, rawURL string) (string, bool, error) {
+	return cd.layout().pathFor(jobType, rawURL)
+}
+
+// SetProxy routes all asset downloads through the given proxy
+func (cd *ConcurrentDownloader) SetProxy(proxyURL *url.URL) {
+	if transport, ok := cd.client.Transport.(*http.Transport); ok {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+}
+
+// SetRequestLimiter makes every fetch take a slot from a limiter shared with other downloaders,
+// so concurrency stays under one global cap across pages. Call before Start.
+func (cd *ConcurrentDownloader) SetRequestLimiter(limiter *RequestLimiter) {
+	cd.limiter = limiter
+}
+
+// EnableBackoff lowers effective concurrency while 429/5xx responses spike and ramps it back
+// up as requests succeed again. Call before Start.
+func (cd *ConcurrentDownloader) EnableBackoff(cfg BackoffConfig) {
+	cd.throttle = newAdaptiveThrottle(cd.MaxWorkers, cfg, cd.logWriter())
+}
+
+// SetCSSPurge removes unused rules from every stylesheet before it is saved. Call before Start.
+func (cd *ConcurrentDownloader) SetCSSPurge(purge *CSSPurge) {
+	cd.cssPurge = purge
+}
+
+// SetExtensionLimits caps concurrent downloads per file extension ("mp4" -> 2), on top of
+// MaxWorkers. Extensions without a limit share the global worker pool. Call before Start.
+func (cd *ConcurrentDownloader) SetExtensionLimits(limits map[string]int) {
+	cd.extSems = make(map[string]chan struct{}, len(limits))
+	for ext, limit := range limits {
+		if limit > 0 {
+			cd.extSems[normalizeExt(ext)] = make(chan struct{}, limit)
+		}
+	}
+}
+
+// SetExcludedExtensions leaves URLs with these file extensions (".mp4" or "mp4") remote, including
+// assets found in stylesheets and source maps. Call before Start.
+func (cd *ConcurrentDownloader) SetExcludedExtensions(extensions []string) {
+	cd.excludedExts = make(map[string]bool, len(extensions))
+	for _, ext := range extensions {
+		cd.excludedExts[normalizeExt(ext)] = true
+	}
+}
+
+// excluded reports whether a URL's file extension was excluded with SetExcludedExtensions
+func (cd *ConcurrentDownloader) excluded(rawURL string) bool {
+	if len(cd.excludedExts) == 0 {
+		return false
+	}
+	u, err := url.Parse(rawURL)
+	return err == nil && cd.excludedExts[normalizeExt(path.Ext(u.Path))]
+}
+
+// extensionSemaphore returns the sub-limit for a URL's file extension, or nil when it has none
+func (cd *ConcurrentDownloader) extensionSemaphore(rawURL string) chan struct{} {
+	if len(cd.extSems) == 0 {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	return cd.extSems[normalizeExt(path.Ext(u.Path))]
+}
+
+// normalizeExt lowercases an extension and drops its leading dot
+func normalizeExt(ext string) string {
+	return strings.ToLower(strings.TrimPrefix(ext, "."))
+}
+
+// EffectiveConcurrency returns how many requests may currently be in flight
+func (cd *ConcurrentDownloader) EffectiveConcurrency() int {
+	if cd.throttle == nil {
+		return cd.MaxWorkers
 	}
+	return cd.throttle.current()
+}
+
+// ConcurrencyStats reports how EnableBackoff varied concurrency over the run, or nil when it is off
+func (cd *ConcurrentDownloader) ConcurrencyStats() *ConcurrencyStats {
+	return cd.throttle.stats()
+}
+
+// SetMaxRedirects caps how many redirects one asset fetch follows before it fails with
+// ErrTooManyRedirects and the reference is left remote
+func (cd *ConcurrentDownloader) SetMaxRedirects(limit int) {
+	cd.client.CheckRedirect = cd.checkRedirect(limit)
+}
+
+// redirectLimit stops a redirect chain (including loops bouncing between CDNs) after limit hops
+func redirectLimit(limit int) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > limit {
+			return fmt.Errorf("%w: stopped after %d", ErrTooManyRedirects, limit)
+		}
+		return nil
+	}
+}
+
+// SetDeadline aborts the run with ErrRuntimeExceeded at t: queued downloads are skipped and in-flight
+// ones cancelled, while everything already saved is kept. Call before Start.
+func (cd *ConcurrentDownloader) SetDeadline(t time.Time) {
+	cd.deadline = time.AfterFunc(time.Until(t), func() {
+		cd.abort(ErrRuntimeExceeded)
+	})
+}
+
+// Err returns the primary failure that aborted the run when FailFast is set, or ErrRuntimeExceeded
+// when the deadline passed
+func (cd *ConcurrentDownloader) Err() error {
+	select {
+	case <-cd.ctx.Done():
+		return cd.abortErr
+	default:
+		return nil
+	}
+}
+
+// abort records the first failure and cancels all in-flight and queued downloads
+func (cd *ConcurrentDownloader) abort(err error) {
+	cd.abortOnce.Do(func() {
+		cd.abortErr = err
+		cd.cancel()
+	})
 }
 
 // Start initializes and starts the worker pool
 func (cd *ConcurrentDownloader) Start() {
+	if cd.WriteLimit > 0 {
+		cd.writeSem = make(chan struct{}, cd.WriteLimit)
+	}
 	for i := 0; i < cd.MaxWorkers; i++ {
 		cd.wg.Add(1)
 		go cd.worker()
@@ -73,13 +343,39 @@ func (cd *ConcurrentDownloader) Start() {
 
 // AddJob queues a download job
 func (cd *ConcurrentDownloader) AddJob(job DownloadJob) {
+	cd.claimedURLs.Store(assetKey(job.URL, cd.AssetCase), true)
 	atomic.AddInt64(&cd.totalJobs, 1)
+	cd.pendingJobs.Add(1)
 	cd.jobs <- job
 }
 
-// FinishJobs signals that no more jobs will be added
+// claimURL reports whether a URL discovered while downloading is new. Each source URL is fetched
+// once and stored in one place, whichever element type (image, font, ...) referenced it first.
+// Excluded extensions are never claimed, so they are not queued and stay remote.
+func (cd *ConcurrentDownloader) claimURL(rawURL string) bool {
+	if cd.excluded(rawURL) {
+		return false
+	}
+	_, seen := cd.claimedURLs.LoadOrStore(assetKey(rawURL, cd.AssetCase), true)
+	return !seen
+}
+
+// enqueue queues a job discovered by a worker without blocking it on a full queue
+func (cd *ConcurrentDownloader) enqueue(job DownloadJob) {
+	atomic.AddInt64(&cd.totalJobs, 1)
+	cd.pendingJobs.Add(1)
+	go func() {
+		cd.jobs <- job
+	}()
+}
+
+// FinishJobs signals that no more jobs will be added by the caller.
+// The queue is closed once every queued job, including ones discovered by workers, has been reported.
 func (cd *ConcurrentDownloader) FinishJobs() {
-	close(cd.jobs)
+	go func() {
+		cd.pendingJobs.Wait()
+		close(cd.jobs)
+	}()
 }
 
 // GetResults collects all download results
@@ -87,72 +383,247 @@ func (cd *ConcurrentDownloader) GetResults() map[string]string {
 	// Wait for all workers to finish
 	go func() {
 		cd.wg.Wait()
+		if cd.deadline != nil {
+			cd.deadline.Stop()
+		}
 		close(cd.results)
+		cd.cancel()
 	}()
 
 	urlMap := make(map[string]string)
 	
 	// Collect results
-	var successCount, failCount int
+	var successCount, failCount, budgetSkipped int
 	for result := range cd.results {
+		cd.collected = append(cd.collected, result)
 		if result.Success {
 			urlMap[result.Job.OriginalPath] = result.LocalPath
 			successCount++
 		} else {
 			failCount++
+			if errors.Is(result.Error, ErrBudgetExceeded) {
+				budgetSkipped++
+				continue
+			}
+			if errors.Is(result.Error, context.Canceled) {
+				// Skipped because the run was aborted; the cause is reported once via Err
+				continue
+			}
+			if isRedirectDuplicate(result.Error) {
+				// Resolved to the other job's download below
+				continue
+			}
 			if result.Error != nil {
 				// Only print failures for primary assets (not fonts which we expect to fail)
 				if result.Job.Type != "font" {
-					fmt.Printf("PRIMARY ASSET FAILED: %s (type: %s): %v\n", result.Job.URL, result.Job.Type, result.Error)
+					fmt.Fprintf(cd.logWriter(), "PRIMARY ASSET FAILED: %s (type: %s): %v\n", result.Job.URL, result.Job.Type, result.Error)
 				}
 			}
 		}
 	}
 	
+	cd.resolveRedirectDuplicates(urlMap)
+	
+	if len(cd.cssRewrites) > 0 {
+		cd.finalizeCSSRewrites(urlMap)
+	}
+	
+	// Sizes are read once everything is written, including stylesheets rewritten above
+	for i, result := range cd.collected {
+		if !result.Success {
+			continue
+		}
+		if size, err := cd.Output.Size(result.LocalPath); err == nil {
+			cd.collected[i].Size = size
+		}
+	}
+	
+	if reused := atomic.LoadInt64(&cd.reusedFiles); reused > 0 {
+		fmt.Fprintf(cd.logWriter(), "Reused %d existing files without downloading\n", reused)
+	}
+	
+	if cd.DedupeReport {
+		stats := cd.DedupeStats()
+		if cd.Dedupe {
+			fmt.Fprintf(cd.logWriter(), "Dedupe: %d duplicate downloads collapsed, %d bytes saved\n", stats.Duplicates, stats.BytesSaved)
+		} else {
+			fmt.Fprintf(cd.logWriter(), "Dedupe: %d duplicate downloads found, %d bytes could be saved with -dedupe\n", stats.Duplicates, stats.BytesSaved)
+		}
+	}
+	
+	if budgetSkipped > 0 {
+		fmt.Fprintf(cd.logWriter(), "Download budget of %d bytes reached: %d assets left remote\n", cd.MaxTotalBytes, budgetSkipped)
+	}
+	
 	return urlMap
 }
 
+// Results returns every final download result once GetResults has completed
+func (cd *ConcurrentDownloader) Results() []DownloadResult {
+	return cd.collected
+}
+
 // GetProgress returns current download progress
 func (cd *ConcurrentDownloader) GetProgress() (completed, total int64) {
 	return atomic.LoadInt64(&cd.completedJobs), atomic.LoadInt64(&cd.totalJobs)
 }
 
+// DedupeStats returns how many identical downloads were found and the bytes collapsing them saves
+// (or, without Dedupe, would save)
+func (cd *ConcurrentDownloader) DedupeStats() DedupeStats {
+	return cd.registry.Stats()
+}
+
+// Headers returns the response headers captured when CaptureHeaders is set
+func (cd *ConcurrentDownloader) Headers() map[string]HeaderRecord {
+	return cd.headers.snapshot()
+}
+
+// InlinedImages maps the local path of every image under InlineBelow to its data: URI
+func (cd *ConcurrentDownloader) InlinedImages() map[string]string {
+	inlined := make(map[string]string)
+	cd.inlined.Range(func(key, value any) bool {
+		inlined[key.(string)] = value.(string)
+		return true
+	})
+	return inlined
+}
+
+// PeakParallelWrites returns the highest number of disk writes that were in flight at once
+func (cd *ConcurrentDownloader) PeakParallelWrites() int64 {
+	return atomic.LoadInt64(&cd.peakWrites)
+}
+
+// GetDownloadedBytes returns the cumulative number of bytes fetched so far
+func (cd *ConcurrentDownloader) GetDownloadedBytes() int64 {
+	return atomic.LoadInt64(&cd.downloadedBytes)
+}
+
+// budgetExceeded reports whether the download byte budget has been used up
+func (cd *ConcurrentDownloader) budgetExceeded() bool {
+	return cd.MaxTotalBytes > 0 && atomic.LoadInt64(&cd.downloadedBytes) >= cd.MaxTotalBytes
+}
+
 // worker processes download jobs from the job queue
 func (cd *ConcurrentDownloader) worker() {
 	defer cd.wg.Done()
 	
 	for job := range cd.jobs {
-		result := cd.processJob(job)
+		var result DownloadResult
+		if err := cd.ctx.Err(); err != nil {
+			// Run was aborted - drain remaining jobs without fetching
+			result = DownloadResult{Job: job, Success: false, Error: err}
+		} else if cd.budgetExceeded() {
+			// Leave the reference remote instead of fetching past the budget
+			result = DownloadResult{Job: job, Success: false, Error: ErrBudgetExceeded}
+		} else {
+			if job.RetryCount == 0 {
+				cd.emitProgress(job, nil)
+			}
+			result = cd.processJob(job)
+		}
 		
 		// Handle retry logic without blocking
-		if !result.Success && job.RetryCount < 3 {
+		if cd.shouldRetry(job, result) {
 			job.RetryCount++
-			// Re-queue the job for retry
-			go func(retryJob DownloadJob) {
-				// Small delay before retry
-				time.Sleep(time.Duration(retryJob.RetryCount) * 200 * time.Millisecond)
-				cd.jobs <- retryJob
-			}(job)
+			cd.requeue(job)
 			continue
 		}
 		
-		atomic.AddInt64(&cd.completedJobs, 1)
-		cd.results <- result
+		if cd.holdRedirectDuplicate(job, result) {
+			continue
+		}
+		
+		// Only a failure that will not be retried aborts the run; assets left remote on purpose
+		// are not failures
+		if !result.Success && cd.FailFast && job.Type != "font" && !isDeliberateSkip(result.Error) && !isRedirectDuplicate(result.Error) && cd.ctx.Err() == nil {
+			cd.abort(fmt.Errorf("primary asset %s (type: %s) failed: %w", job.URL, job.Type, result.Error))
+		}
+		
+		cd.report(job, result)
+	}
+}
+
+// report hands a job's final result to GetResults, settles the redirect duplicates waiting on it,
+// and releases the job's pendingJobs slot
+func (cd *ConcurrentDownloader) report(job DownloadJob, result DownloadResult) {
+	atomic.AddInt64(&cd.completedJobs, 1)
+	cd.emitProgress(job, &result)
+	cd.results <- result
+	cd.settleRedirectDuplicates(job, result)
+	cd.pendingJobs.Done()
+}
+
+// requeue sends a failed job back to the queue after a backoff delay. The job still holds its
+// pendingJobs slot, which is only released once a final result is reported, so FinishJobs cannot
+// close the queue while a retry is waiting. An aborted run skips the delay; the worker then reports
+// the job as failed.
+func (cd *ConcurrentDownloader) requeue(job DownloadJob) {
+	go func() {
+		delay := time.NewTimer(time.Duration(job.RetryCount) * 200 * time.Millisecond)
+		defer delay.Stop()
+		select {
+		case <-delay.C:
+		case <-cd.ctx.Done():
+		}
+		cd.jobs <- job
+	}()
+}
+
+// shouldRetry reports whether a failed job is worth another attempt
+func (cd *ConcurrentDownloader) shouldRetry(job DownloadJob, result DownloadResult) bool {
+	if result.Success || job.RetryCount >= 3 || cd.ctx.Err() != nil {
+		return false
+	}
+	// A missing file or a redirect loop fails the same way every time
+	var statusErr *statusError
+	if errors.As(result.Error, &statusErr) && statusErr.permanent() {
+		return false
 	}
+	return !isDeliberateSkip(result.Error) && !errors.Is(result.Error, ErrTooManyRedirects) && !isRedirectDuplicate(result.Error)
+}
+
+// isDeliberateSkip reports whether err leaves an asset remote by configuration (-max-total-bytes,
+// -max-file-size, -exclude-ext) rather than because its download failed
+func isDeliberateSkip(err error) bool {
+	return errors.Is(err, ErrBudgetExceeded) || errors.Is(err, ErrTooLarge) || errors.Is(err, ErrExcluded)
 }
 
 // processJob handles a single download job
 func (cd *ConcurrentDownloader) processJob(job DownloadJob) DownloadResult {
+	if cd.SkipExisting {
+		if localPath, ok := cd.existingLocalPath(job); ok {
+			atomic.AddInt64(&cd.reusedFiles, 1)
+			return DownloadResult{
+				Job:       job,
+				LocalPath: localPath,
+				Success:   true,
+			}
+		}
+	}
+	
+	// Per-extension sub-limits hold a worker until a slot for that extension frees up
+	if sem := cd.extensionSemaphore(job.URL); sem != nil {
+		sem <- struct{}{}
+		defer func() { <-sem }()
+	}
+	
 	var localPath string
 	var err error
 	
 	switch job.Type {
 	case "css", "js", "json":
-		localPath, err = cd.downloadResource(job.URL, job.Type, job.BaseURL)
+		localPath, err = cd.downloadResource(job, job.Type, job.BaseURL)
 	case "image":
-		localPath, err = cd.downloadImage(job.URL)
+		localPath, err = cd.downloadOnce(job.Type, job.URL, cd.downloadImage)
 	case "font":
-		localPath, err = cd.downloadFont(job.URL)
+		localPath, err = cd.downloadOnce(job.Type, job.URL, cd.downloadFont)
+	case "media":
+		localPath, err = cd.downloadMedia(job.URL)
+	case "data":
+		localPath, err = cd.downloadData(job.URL)
+	case "file":
+		localPath, err = cd.downloadFile(job.URL)
 	default:
 		err = fmt.Errorf("unknown job type: %s", job.Type)
 	}
@@ -172,146 +643,301 @@ func (cd *ConcurrentDownloader) processJob(job DownloadJob) DownloadResult {
 	}
 }
 
-// downloadFont downloads a font file using the shared HTTP client
-func (cd *ConcurrentDownloader) downloadFont(fontURL string) (string, error) {
-	resp, err := cd.client.Get(fontURL)
+// existingLocalPath reports whether a job's target file was already saved by an earlier run
+func (cd *ConcurrentDownloader) existingLocalPath(job DownloadJob) (string, bool) {
+	localPath, named, err := cd.localPathFor(job.Type, job.URL)
+	if err != nil || !named {
+		return "", false
+	}
+	localPath = applyAssetCase(localPath, cd.AssetCase)
+	info, err := os.Stat(localPath)
+	if err != nil || info.IsDir() {
+		return "", false
+	}
+	return localPath, true
+}
+
+// fetch downloads a URL with the shared HTTP client and counts its bytes against the budget
+func (cd *ConcurrentDownloader) fetch(rawURL string) ([]byte, http.Header, error) {
+	return cd.fetchLimited(rawURL, 0)
+}
+
+// fetchLimited is fetch that fails with ErrTooLarge instead of reading more than limit bytes
+// (0 means unlimited)
+func (cd *ConcurrentDownloader) fetchLimited(rawURL string, limit int64) ([]byte, http.Header, error) {
+	// Stylesheets fetch their images, fonts, and imports directly rather than through claimURL
+	if cd.excluded(rawURL) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrExcluded, rawURL)
+	}
+	req, err := http.NewRequestWithContext(cd.ctx, http.MethodGet, rawURL, nil)
 	if err != nil {
-		return "", err
+		return nil, nil, err
+	}
+	setUserAgent(req, cd.UserAgent)
+	setHeaders(req, cd.RequestHeaders, cd.CredentialHosts)
+	setRequestID(req, cd.RequestIDHeader)
+	setAcceptEncoding(req, cd.AcceptEncoding)
+	setBasicAuth(req, cd.BasicAuth, cd.CredentialHosts)
+	
+	if err := cd.limiter.acquire(cd.ctx); err != nil {
+		return nil, nil, err
+	}
+	defer cd.limiter.release()
+	
+	cd.throttle.acquire()
+	defer cd.throttle.release()
+	
+	resp, err := cd.client.Do(req)
+	if err != nil {
+		cd.throttle.observe(cd.ctx.Err() == nil)
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
+	cd.throttle.observe(resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500)
+	
+	if cd.CaptureHeaders {
+		cd.headers.record(rawURL, resp)
+	}
 	
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("bad status: %s", resp.Status)
+		return nil, nil, &statusError{code: resp.StatusCode, status: resp.Status}
 	}
 	
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+	// Source URLs redirecting to the same canonical file download it once
+	if err := cd.claimFinalURL(req.URL.String(), resp.Request.URL.String()); err != nil {
+		return nil, nil, err
 	}
 	
-	u, err := url.Parse(fontURL)
+	if limit > 0 && resp.ContentLength > limit {
+		return nil, nil, fmt.Errorf("%w: %d bytes", ErrTooLarge, resp.ContentLength)
+	}
+	body, err := decodeBody(resp)
 	if err != nil {
-		return "", err
+		return nil, nil, err
+	}
+	if limit > 0 {
+		body = io.LimitReader(body, limit+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	atomic.AddInt64(&cd.downloadedBytes, int64(len(data)))
+	if limit > 0 && int64(len(data)) > limit {
+		return nil, nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, limit)
 	}
 	
-	segments := strings.Split(u.Path, "/")
-	filename := segments[len(segments)-1]
+	return data, resp.Header, nil
+}
+
+// fetchBinary is fetchLimited for images, media, and files. Flaky CDNs occasionally answer 200
+// with nothing, which is never a valid binary, so under RetryEmpty it fails and the job is retried.
+// Stylesheets and scripts can legitimately be empty and are fetched without the check.
+func (cd *ConcurrentDownloader) fetchBinary(rawURL string, limit int64) ([]byte, http.Header, error) {
+	data, header, err := cd.fetchLimited(rawURL, limit)
+	if err == nil && cd.RetryEmpty && len(data) == 0 {
+		return nil, nil, ErrEmptyBody
+	}
+	return data, header, err
+}
+
+// writeFile saves downloaded data, reusing an identical earlier file when dedupe is enabled
+func (cd *ConcurrentDownloader) writeFile(localPath string, data []byte) (_ string, err error) {
+	localPath = applyAssetCase(localPath, cd.AssetCase)
+	if cd.HashNames {
+		original := localPath
+		localPath = hashedPath(localPath, data)
+		// Identical content from several URLs maps to one name; only the first download writes it
+		if _, written := cd.hashedFiles.LoadOrStore(localPath, true); written {
+			cd.recordAlias(original, localPath)
+			return localPath, nil
+		}
+		defer func() {
+			if err == nil {
+				cd.recordAlias(original, localPath)
+			}
+		}()
+	}
+	if cd.Dedupe {
+		existing, duplicate, settle := cd.registry.claim(data, localPath)
+		if duplicate {
+			return existing, nil
+		}
+		// Claimed only once written, so duplicates never point at a file whose write failed
+		defer func() { settle(err == nil) }()
+	}
 	
-	// Ensure output/assets/fonts directory exists
-	fontDir := "output/assets/fonts/"
-	os.MkdirAll(fontDir, 0755)
+	// Throttle disk writes separately so network parallelism stays high on slow disks
+	if cd.writeSem != nil {
+		cd.writeSem <- struct{}{}
+		defer func() { <-cd.writeSem }()
+	}
+	active := atomic.AddInt64(&cd.activeWrites, 1)
+	defer atomic.AddInt64(&cd.activeWrites, -1)
+	for {
+		peak := atomic.LoadInt64(&cd.peakWrites)
+		if active <= peak || atomic.CompareAndSwapInt64(&cd.peakWrites, peak, active) {
+			break
+		}
+	}
 	
-	localPath := fontDir + filename
+	// Mirrored paths nest arbitrarily deep under assets/
+	if cd.PreservePaths {
+		if err := cd.Output.MkdirAll(path.Dir(localPath)); err != nil {
+			return "", err
+		}
+	}
 	
-	err = os.WriteFile(localPath, data, 0644)
+	err = cd.Output.WriteFile(localPath, data)
 	if err != nil {
 		return "", err
 	}
+	if cd.DedupeReport && !cd.Dedupe {
+		cd.registry.record(data, localPath)
+	}
 	
 	return localPath, nil
 }
 
-// downloadImage downloads an image using the shared HTTP client
-func (cd *ConcurrentDownloader) downloadImage(imageURL string) (string, error) {
-	resp, err := cd.client.Get(imageURL)
+// writeFetched saves a downloaded file and, with PreserveMTime, stamps it with the response's
+// Last-Modified time
+func (cd *ConcurrentDownloader) writeFetched(localPath string, data []byte, header http.Header) (string, error) {
+	localPath, err := cd.writeFile(localPath, data)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("bad status: %s", resp.Status)
+	if cd.PreserveMTime {
+		setModTime(localPath, header.Get("Last-Modified"))
+	}
+	return localPath, nil
+}
+
+// setModTime sets a file's access and modification times to an HTTP Last-Modified value. Files
+// without the header keep the time they were written, and files that were only uploaded are skipped.
+func setModTime(localPath, lastModified string) {
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return
+	}
+	os.Chtimes(localPath, modified, modified)
+}
+
+// downloadFont downloads a font file using the shared HTTP client
+func (cd *ConcurrentDownloader) downloadFont(fontURL string) (string, error) {
+	data, header, err := cd.fetch(fontURL)
+	if err != nil {
+		return "", err
 	}
 	
-	data, err := io.ReadAll(resp.Body)
+	localPath, _, err := cd.localPathFor("font", fontURL)
 	if err != nil {
 		return "", err
 	}
 	
-	u, err := url.Parse(imageURL)
+	// Ensure output/assets/fonts directory exists
+	fontDir := cd.outputDir() + "/assets/fonts/"
+	cd.Output.MkdirAll(fontDir)
+	
+	return cd.writeFetched(localPath, data, header)
+}
+
+// downloadMedia downloads an audio or video file using the shared HTTP client
+func (cd *ConcurrentDownloader) downloadMedia(mediaURL string) (string, error) {
+	data, header, err := cd.fetchBinary(mediaURL, 0)
 	if err != nil {
 		return "", err
 	}
 	
-	segments := strings.Split(u.Path, "/")
-	filename := segments[len(segments)-1]
-	
-	// Handle images without extensions
-	if !strings.Contains(filename, ".") {
-		// Try to determine extension from content type
-		contentType := resp.Header.Get("Content-Type")
-		switch contentType {
-		case "image/jpeg":
-			filename += ".jpg"
-		case "image/png":
-			filename += ".png"
-		case "image/gif":
-			filename += ".gif"
-		case "image/webp":
-			filename += ".webp"
-		case "image/svg+xml":
-			filename += ".svg"
-		default:
-			filename += ".jpg" // default fallback
-		}
+	localPath, _, err := cd.localPathFor("media", mediaURL)
+	if err != nil {
+		return "", err
 	}
 	
-	localPath := "output/assets/images/" + filename
+	// Ensure output/assets/media directory exists
+	cd.Output.MkdirAll(cd.outputDir() + "/assets/media/")
 	
-	err = os.WriteFile(localPath, data, 0644)
+	return cd.writeFetched(localPath, data, header)
+}
+
+// downloadFile downloads an <a download> target, such as a PDF or ZIP, capped at MaxFileSize
+func (cd *ConcurrentDownloader) downloadFile(fileURL string) (string, error) {
+	data, header, err := cd.fetchBinary(fileURL, cd.MaxFileSize)
 	if err != nil {
 		return "", err
 	}
 	
-	return localPath, nil
+	localPath, _, err := cd.localPathFor("file", fileURL)
+	if err != nil {
+		return "", err
+	}
+	
+	// Ensure output/assets/files directory exists
+	cd.Output.MkdirAll(cd.outputDir() + "/assets/files/")
+	
+	return cd.writeFetched(localPath, data, header)
 }
 
-// downloadResource downloads a resource (CSS, JS) using the shared HTTP client
-func (cd *ConcurrentDownloader) downloadResource(resourceURL, ext string, base *url.URL) (string, error) {
-	resp, err := cd.client.Get(resourceURL)
+// downloadImage downloads an image using the shared HTTP client
+func (cd *ConcurrentDownloader) downloadImage(imageURL string) (string, error) {
+	data, header, err := cd.fetchBinary(imageURL, 0)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 	
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("bad status: %s", resp.Status)
+	localPath, named, err := cd.localPathFor("image", imageURL)
+	if err != nil {
+		return "", err
 	}
 	
-	data, err := io.ReadAll(resp.Body)
+	// Handle images without extensions by using the content type
+	if !named {
+		localPath += imageExtensionFor(header.Get("Content-Type"))
+	}
+	
+	data = recompressJPEG(data, cd.ImageQuality)
+	localPath, err = cd.writeFetched(localPath, data, header)
 	if err != nil {
 		return "", err
 	}
+	if int64(len(data)) < cd.InlineBelow {
+		cd.inlined.Store(localPath, imageDataURI(data, header.Get("Content-Type"), localPath))
+	}
+	return localPath, nil
+}
+
+// downloadResource downloads a resource (CSS, JS) using the shared HTTP client
+func (cd *ConcurrentDownloader) downloadResource(job DownloadJob, ext string, base *url.URL) (string, error) {
+	resourceURL := job.URL
+	// Stylesheets localized here are shared with every sheet that @imports them
+	if ext == "css" && !cd.ConcurrentCSS {
+		return cd.downloadSheet(resourceURL, newImportChain())
+	}
 	
-	u, err := url.Parse(resourceURL)
+	data, header, err := cd.fetch(resourceURL)
 	if err != nil {
 		return "", err
 	}
 	
-	segments := strings.Split(u.Path, "/")
-	filename := segments[len(segments)-1]
-	if !strings.HasSuffix(filename, "."+ext) {
-		filename = filename + "." + ext
+	localPath, _, err := cd.localPathFor(ext, resourceURL)
+	if err != nil {
+		return "", err
 	}
-	localPath := "output/assets/" + filename
 	
-	// If CSS, also localize font URLs and remove source maps
-	if ext == "css" {
-		cssContent := string(data)
-		cssContent, err = LocalizeFontURLs(cssContent, base)
-		if err != nil {
-			return "", err
-		}
-		// Remove source map references
-		cssContent = utils.RemoveSourceMapReferences(cssContent)
-		data = []byte(cssContent)
+	// Hand CSS sub-assets to the pool; the stylesheet is written once they resolve
+	if ext == "css" && cd.ConcurrentCSS {
+		cssContent := utils.RemoveSourceMapReferences(string(data))
+		cd.queueCSSAssets(job, localPath, cssContent, header.Get("Last-Modified"))
+		return localPath, nil
 	}
 	
 	// If JS, process embedded URLs and remove source map references
 	if ext == "js" {
 		jsContent := string(data)
+		if cd.ParseSourceMaps {
+			cd.discoverSourceMapAssets(resourceURL, jsContent)
+		}
 		// Process JavaScript for embedded resource URLs (like template CSS files)
-		jsContent, err = LocalizeJavaScriptURLs(jsContent, base)
+		jsContent, err = localizeJavaScriptURLs(jsContent, base, cd.layout(), cd.fetchBody)
 		if err != nil {
 			return "", err
 		}
@@ -320,25 +946,112 @@ func (cd *ConcurrentDownloader) downloadResource(resourceURL, ext string, base *
 		data = []byte(jsContent)
 	}
 	
-	err = os.WriteFile(localPath, data, 0644)
-	if err != nil {
-		return "", err
-	}
+	data = utils.NormalizeLineEndings(data, cd.LineEndings)
 	
-	return localPath, nil
+	return cd.writeFetched(localPath, data, header)
+}
+
+// fetchBody is fetch for the serial helpers that only need the body
+func (cd *ConcurrentDownloader) fetchBody(rawURL string) ([]byte, error) {
+	data, _, err := cd.fetch(rawURL)
+	return data, err
 }
 
-// ProgressReporter provides real-time progress updates
+// assetFetch is one image or font download shared by every job and stylesheet referencing its URL
+type assetFetch struct {
+	done      chan struct{}
+	localPath string
+	err       error
+}
+
+// downloadOnce runs download (downloadImage or downloadFont) once per URL of jobType, however many
+// jobs and stylesheets reference it. Callers arriving while it downloads wait for that result. A
+// failed download is forgotten, so a retry fetches it again.
+func (cd *ConcurrentDownloader) downloadOnce(jobType, rawURL string, download func(string) (string, error)) (string, error) {
+	key := jobType + " " + assetKey(rawURL, cd.AssetCase)
+	fetch := &assetFetch{done: make(chan struct{})}
+	if existing, loaded := cd.assetFetches.LoadOrStore(key, fetch); loaded {
+		shared := existing.(*assetFetch)
+		<-shared.done
+		return shared.localPath, shared.err
+	}
+
+	fetch.localPath, fetch.err = download(rawURL)
+	if fetch.err != nil {
+		cd.assetFetches.Delete(key)
+	}
+	close(fetch.done)
+	return fetch.localPath, fetch.err
+}
+
+// downloadNow runs a job discovered by a worker that needs its result right away through process
+// in the calling worker, and reports it like a queued job so it reaches Results, the manifest, and
+// the reports
+func (cd *ConcurrentDownloader) downloadNow(job DownloadJob, process func(DownloadJob) DownloadResult) DownloadResult {
+	atomic.AddInt64(&cd.totalJobs, 1)
+	cd.pendingJobs.Add(1)
+	var result DownloadResult
+	if err := cd.ctx.Err(); err != nil {
+		result = DownloadResult{Job: job, Success: false, Error: err}
+	} else if cd.budgetExceeded() {
+		result = DownloadResult{Job: job, Success: false, Error: ErrBudgetExceeded}
+	} else {
+		cd.emitProgress(job, nil)
+		result = process(job)
+	}
+	if !cd.holdRedirectDuplicate(job, result) {
+		cd.report(job, result)
+	}
+	return result
+}
+
+// downloadCSSImage saves an image referenced from a stylesheet downloaded by downloadResource. An
+// image the page or another stylesheet already claimed shares that job's download; a new one is
+// downloaded now and reported as its own result. Images under InlineBelow come back as their
+// data: URI.
+func (cd *ConcurrentDownloader) downloadCSSImage(imageURL string) (string, error) {
+	return cd.downloadCSSAsset(DownloadJob{URL: imageURL, Type: "image", OriginalPath: imageURL})
+}
+
+// downloadCSSFont is downloadCSSImage for a font referenced from a stylesheet
+func (cd *ConcurrentDownloader) downloadCSSFont(fontURL string) (string, error) {
+	return cd.downloadCSSAsset(DownloadJob{URL: fontURL, Type: "font", OriginalPath: fontURL})
+}
+
+// downloadCSSAsset runs a job for a stylesheet reference, sharing the download of a URL already
+// claimed and otherwise downloading it now and reporting it as its own result
+func (cd *ConcurrentDownloader) downloadCSSAsset(job DownloadJob) (string, error) {
+	var result DownloadResult
+	if cd.claimURL(job.URL) {
+		result = cd.downloadNow(job, cd.processJob)
+	} else {
+		result = cd.processJob(job)
+	}
+	if !result.Success {
+		return "", result.Error
+	}
+	if dataURI, ok := cd.inlined.Load(result.LocalPath); ok {
+		return dataURI.(string), nil
+	}
+	return result.LocalPath, nil
+}
+
+// ProgressReporter prints a live "Downloaded X/Y assets (Z%)" line while a downloader runs
 type ProgressReporter struct {
 	downloader *ConcurrentDownloader
+	out        io.Writer // Where the progress line is written (nil disables reporting)
 	ticker     *time.Ticker
 	done       chan struct{}
+	wg         sync.WaitGroup
+	width      int // Length of the last line printed, so a shorter one still covers it
 }
 
-// NewProgressReporter creates a progress reporter that updates every interval
-func NewProgressReporter(downloader *ConcurrentDownloader, interval time.Duration) *ProgressReporter {
+// NewProgressReporter creates a progress reporter that rewrites its line on out every interval
+// (nil out disables it)
+func NewProgressReporter(downloader *ConcurrentDownloader, interval time.Duration, out io.Writer) *ProgressReporter {
 	return &ProgressReporter{
 		downloader: downloader,
+		out:        out,
 		ticker:     time.NewTicker(interval),
 		done:       make(chan struct{}),
 	}
@@ -346,11 +1059,13 @@ func NewProgressReporter(downloader *ConcurrentDownloader, interval time.Duratio
 
 // Start begins progress reporting
 func (pr *ProgressReporter) Start() {
+	pr.wg.Add(1)
 	go func() {
+		defer pr.wg.Done()
 		for {
 			select {
 			case <-pr.ticker.C:
-				// Progress reporting disabled for better performance
+				pr.print("")
 			case <-pr.done:
 				return
 			}
@@ -358,10 +1073,25 @@ func (pr *ProgressReporter) Start() {
 	}()
 }
 
-// Stop stops progress reporting
+// Stop stops progress reporting and leaves the final counts on their own line
 func (pr *ProgressReporter) Stop() {
 	pr.ticker.Stop()
 	close(pr.done)
-	// Print final newline
-	fmt.Println()
+	pr.wg.Wait()
+	pr.print("\n")
+}
+
+// print overwrites the progress line with the downloader's current counts, followed by end
+func (pr *ProgressReporter) print(end string) {
+	if pr.out == nil {
+		return
+	}
+	completed, total := pr.downloader.GetProgress()
+	percent := int64(100)
+	if total > 0 {
+		percent = completed * 100 / total
+	}
+	line := fmt.Sprintf("Downloaded %d/%d assets (%d%%)", completed, total, percent)
+	fmt.Fprintf(pr.out, "\r%-*s%s", pr.width, line, end)
+	pr.width = len(line)
 }
\ No newline at end of file
@@ -0,0 +1,546 @@
+package assets
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"wp-static-scraper/internal/testsite"
+	"wp-static-scraper/utils"
+)
+
+func TestRetryAfterQueueFinished(t *testing.T) {
+	chdirOutput(t)
+
+	var hits int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail twice so the second retry is queued well after FinishJobs
+		if atomic.AddInt64(&hits, 1) <= 2 {
+			http.Error(w, "busy", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("png"))
+	}))
+	defer server.Close()
+
+	downloader := NewConcurrentDownloader(2)
+	downloader.Start()
+	imageURL := server.URL + "/img/flaky.png"
+	downloader.AddJob(DownloadJob{URL: imageURL, Type: "image", OriginalPath: imageURL})
+	downloader.FinishJobs()
+
+	urlMap := downloader.GetResults()
+	if got := atomic.LoadInt64(&hits); got != 3 {
+		t.Errorf("transient 500s should be retried until the download succeeds, got %d requests", got)
+	}
+	if urlMap[imageURL] != "output/assets/images/flaky.png" {
+		t.Errorf("retried job should succeed, got url map %v", urlMap)
+	}
+	if data, err := os.ReadFile("output/assets/images/flaky.png"); err != nil || string(data) != "png" {
+		t.Errorf("image should be saved after the retry, got %q (%v)", data, err)
+	}
+}
+
+// Run with -race: the reporter reads the counters while workers update them
+func TestProgressReporterOutput(t *testing.T) {
+	chdirOutput(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.Write([]byte("img"))
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	downloader := NewConcurrentDownloader(4)
+	downloader.Start()
+	reporter := NewProgressReporter(downloader, time.Millisecond, &out)
+	reporter.Start()
+	go func() {
+		for i := 0; i < 40; i++ {
+			downloader.AddJob(DownloadJob{
+				URL:          fmt.Sprintf("%s/img/%d.png", server.URL, i),
+				Type:         "image",
+				OriginalPath: fmt.Sprintf("img/%d.png", i),
+			})
+		}
+		downloader.FinishJobs()
+	}()
+	downloader.GetResults()
+	reporter.Stop()
+
+	lines := strings.Split(out.String(), "\r")
+	if len(lines) < 3 {
+		t.Fatalf("progress should be rewritten while downloading, got %q", out.String())
+	}
+	if last := lines[len(lines)-1]; last != "Downloaded 40/40 assets (100%)\n" {
+		t.Errorf("Stop should leave a final summary line, got %q", last)
+	}
+
+}
+
+func TestFailFastWaitsForRetries(t *testing.T) {
+	chdirOutput(t)
+
+	var hits int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&hits, 1) == 1 {
+			http.Error(w, "busy", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("png"))
+	}))
+	defer server.Close()
+
+	downloader := NewConcurrentDownloader(2)
+	downloader.FailFast = true
+	downloader.Start()
+	imageURL := server.URL + "/img/flaky.png"
+	downloader.AddJob(DownloadJob{URL: imageURL, Type: "image", OriginalPath: imageURL})
+	downloader.FinishJobs()
+
+	urlMap := downloader.GetResults()
+	if err := downloader.Err(); err != nil {
+		t.Errorf("a transient failure that succeeds on retry should not abort the run: %v", err)
+	}
+	if urlMap[imageURL] != "output/assets/images/flaky.png" {
+		t.Errorf("retried job should succeed, got url map %v", urlMap)
+	}
+}
+
+func TestRedirectDuplicateRefetchedWhenOwnerFails(t *testing.T) {
+	chdirOutput(t)
+
+	var canonicalHits int64
+	redirected := make(chan struct{}, 2)
+	mux := http.NewServeMux()
+	for _, name := range []string{"/img/a.png", "/img/b.png"} {
+		mux.HandleFunc(name, func(w http.ResponseWriter, r *http.Request) {
+			redirected <- struct{}{}
+			http.Redirect(w, r, "/img/canonical.png", http.StatusFound)
+		})
+	}
+	mux.HandleFunc("/img/canonical.png", func(w http.ResponseWriter, r *http.Request) {
+		// The first download fails once both URLs were redirected, after it claimed the final URL
+		if atomic.AddInt64(&canonicalHits, 1) == 1 {
+			<-redirected
+			<-redirected
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("canonical"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	downloader := NewConcurrentDownloader(2)
+	downloader.Start()
+	for _, name := range []string{"/img/a.png", "/img/b.png"} {
+		downloader.AddJob(DownloadJob{URL: server.URL + name, Type: "image", OriginalPath: name})
+	}
+	downloader.FinishJobs()
+	urlMap := downloader.GetResults()
+
+	var saved, failed int
+	for _, result := range downloader.Results() {
+		switch {
+		case result.Success:
+			saved++
+			if data, err := os.ReadFile(result.LocalPath); err != nil || string(data) != "canonical" {
+				t.Errorf("%s should be saved by fetching it again, got %q (%v)", result.Job.URL, data, err)
+			}
+			if urlMap[result.Job.OriginalPath] != result.LocalPath {
+				t.Errorf("%s should be rewritten to its download, got %v", result.Job.URL, urlMap)
+			}
+		case isRedirectDuplicate(result.Error):
+			t.Errorf("%s should not stay a duplicate of a failed download", result.Job.URL)
+		default:
+			failed++
+		}
+	}
+	if saved != 1 || failed != 1 {
+		t.Errorf("want one failed owner and one refetched duplicate, got %d saved and %d failed: %+v", saved, failed, downloader.Results())
+	}
+}
+
+func TestMaxTotalBytesHaltsDownloads(t *testing.T) {
+	var hits int64
+	server, base := newTestSiteHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	var page strings.Builder
+	page.WriteString("<html><body>")
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		page.WriteString(`<img src="` + server.URL + `/img/` + name + `.png">`)
+	}
+	page.WriteString("</body></html>")
+
+	result, err := LocalizeAssets(page.String(), base, Options{Concurrency: 1, MaxTotalBytes: 150})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	if got := atomic.LoadInt64(&hits); got != 2 {
+		t.Errorf("expected downloads to halt after 2 requests, got %d", got)
+	}
+	if !strings.Contains(result, server.URL+"/img/e.png") {
+		t.Error("assets beyond the budget should be left remote")
+	}
+}
+
+func TestFailFastAbortsOnPrimaryFailure(t *testing.T) {
+	var imageHits int64
+	server, base := newTestSiteHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".css") {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt64(&imageHits, 1)
+		select {
+		case <-time.After(200 * time.Millisecond):
+			w.Write([]byte("img"))
+		case <-r.Context().Done():
+		}
+	}))
+	var page strings.Builder
+	page.WriteString(`<html><head><link rel="stylesheet" href="` + server.URL + `/missing.css"></head><body>`)
+	for i := 0; i < 20; i++ {
+		page.WriteString(`<img src="` + server.URL + `/img/` + string(rune('a'+i)) + `.png">`)
+	}
+	page.WriteString("</body></html>")
+
+	start := time.Now()
+	_, err := LocalizeAssets(page.String(), base, Options{Concurrency: 2, FailFast: true})
+	elapsed := time.Since(start)
+
+	if err == nil || !strings.Contains(err.Error(), "missing.css") {
+		t.Fatalf("expected the primary failure to be returned, got %v", err)
+	}
+	if elapsed > time.Second {
+		t.Errorf("fail-fast run took %v; expected it to stop promptly", elapsed)
+	}
+	if got := atomic.LoadInt64(&imageHits); got >= 20 {
+		t.Errorf("expected remaining downloads to be cancelled, got %d image requests", got)
+	}
+}
+
+func TestFailFastIgnoresByteBudget(t *testing.T) {
+	server, base := newTestSiteHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	var page strings.Builder
+	page.WriteString("<html><body>")
+	for _, name := range []string{"a", "b", "c", "d"} {
+		page.WriteString(`<img src="` + server.URL + `/img/` + name + `.png">`)
+	}
+	page.WriteString("</body></html>")
+
+	result, err := LocalizeAssets(page.String(), base, Options{Concurrency: 1, FailFast: true, MaxTotalBytes: 150})
+	if err != nil {
+		t.Fatalf("assets left remote by the byte budget should not abort a fail-fast run: %v", err)
+	}
+	if !strings.Contains(result, `src="assets/images/a.png"`) || !strings.Contains(result, server.URL+"/img/d.png") {
+		t.Errorf("downloads within the budget should be localized and the rest left remote: %s", result)
+	}
+}
+
+func TestSkipExistingReusesFiles(t *testing.T) {
+	chdirOutput(t)
+	os.WriteFile("output/assets/images/cached.png", []byte("from-disk"), 0644)
+
+	var hits int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.Write([]byte("from-network"))
+	}))
+	defer server.Close()
+
+	base, _ := url.Parse(server.URL + "/")
+	page := `<html><body><img src="` + server.URL + `/img/cached.png"></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 1, SkipExisting: true})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	if got := atomic.LoadInt64(&hits); got != 0 {
+		t.Errorf("existing file should not be re-downloaded, got %d requests", got)
+	}
+	if !strings.Contains(result, `src="assets/images/cached.png"`) {
+		t.Errorf("skipped file should still be referenced locally, got %s", result)
+	}
+	if data, _ := os.ReadFile("output/assets/images/cached.png"); string(data) != "from-disk" {
+		t.Errorf("existing file should be left untouched, got %q", data)
+	}
+}
+
+func TestParallelWritesLimit(t *testing.T) {
+	chdirOutput(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 64*1024)))
+	}))
+	defer server.Close()
+
+	downloader := NewConcurrentDownloader(20)
+	downloader.WriteLimit = 2
+	downloader.Start()
+	go func() {
+		for i := 0; i < 60; i++ {
+			downloader.AddJob(DownloadJob{
+				URL:          fmt.Sprintf("%s/img/%d.png", server.URL, i),
+				Type:         "image",
+				OriginalPath: fmt.Sprintf("img/%d.png", i),
+			})
+		}
+		downloader.FinishJobs()
+	}()
+
+	urlMap := downloader.GetResults()
+	if len(urlMap) != 60 {
+		t.Fatalf("all downloads should succeed, got %d", len(urlMap))
+	}
+	if peak := downloader.PeakParallelWrites(); peak < 1 || peak > 2 {
+		t.Errorf("concurrent writes should never exceed the limit of 2, peak was %d", peak)
+	}
+}
+
+func TestRetryOnEmptyBody(t *testing.T) {
+	var hits int64
+	server, base := newTestSiteHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first response is an empty 200 from a flaky edge
+		if atomic.AddInt64(&hits, 1) == 1 {
+			return
+		}
+		w.Write([]byte("png"))
+	}))
+	page := `<html><body><img src="` + server.URL + `/img/flaky.png"></body></html>`
+
+	if _, err := LocalizeAssets(page, base, Options{Concurrency: 1, RetryEmpty: true}); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	if got := atomic.LoadInt64(&hits); got != 2 {
+		t.Errorf("empty response should be retried once, got %d requests", got)
+	}
+	img, err := os.ReadFile("output/assets/images/flaky.png")
+	if err != nil || len(img) == 0 {
+		t.Errorf("image should be saved with content after the retry, got %q (%v)", img, err)
+	}
+}
+
+func TestRetryOnEmptyBodyKeepsEmptyStylesheetsAndScripts(t *testing.T) {
+	var hits int64
+	server, base := newTestSiteHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+	}))
+	page := `<html><head><link rel="stylesheet" href="` + server.URL + `/css/empty.css">` +
+		`<script src="` + server.URL + `/js/empty.js"></script></head><body></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 1, RetryEmpty: true})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	if got := atomic.LoadInt64(&hits); got != 2 {
+		t.Errorf("empty stylesheets and scripts should be fetched once each, got %d requests", got)
+	}
+	for _, file := range []string{"output/assets/empty.css", "output/assets/empty.js"} {
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("%s should be saved empty: %v", file, err)
+		}
+	}
+	if !strings.Contains(result, `href="assets/empty.css"`) || !strings.Contains(result, `src="assets/empty.js"`) {
+		t.Errorf("empty stylesheets and scripts should be localized: %s", result)
+	}
+}
+
+func TestOutputPermissions(t *testing.T) {
+	t.Chdir(t.TempDir())
+	output := utils.NewOutput(0600, 0700)
+
+	if _, err := utils.ParseFileMode("0789"); err == nil {
+		t.Error("ParseFileMode should reject non-octal input")
+	}
+	if mode, err := utils.ParseFileMode("640"); err != nil || mode != 0640 {
+		t.Errorf("ParseFileMode(\"640\") = %o, %v", mode, err)
+	}
+
+	if err := output.EnsureDirectories(utils.DefaultOutDir); err != nil {
+		t.Fatalf("Failed to create directories: %v", err)
+	}
+	server := testsite.New(t, map[string]string{"/img/logo.png": "png"})
+	base, _ := url.Parse(server.URL + "/")
+	page := `<html><body><img src="` + server.URL + `/img/logo.png"></body></html>`
+	if _, err := LocalizeAssets(page, base, Options{Concurrency: 1, Output: output}); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	info, err := os.Stat("output/assets/images/logo.png")
+	if err != nil {
+		t.Fatalf("image should be saved: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("file mode = %o; want 600", info.Mode().Perm())
+	}
+	info, err = os.Stat("output/assets/images")
+	if err != nil {
+		t.Fatalf("image directory should exist: %v", err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Errorf("directory mode = %o; want 700", info.Mode().Perm())
+	}
+}
+
+func TestDownloadConcurrencyPerExtension(t *testing.T) {
+	chdirOutput(t)
+
+	// track counts a request as in flight and records the highest concurrency seen
+	track := func(active, peak *int64) func() {
+		current := atomic.AddInt64(active, 1)
+		for {
+			old := atomic.LoadInt64(peak)
+			if current <= old || atomic.CompareAndSwapInt64(peak, old, current) {
+				break
+			}
+		}
+		return func() { atomic.AddInt64(active, -1) }
+	}
+	var videoActive, videoPeak, imageActive, imagePeak int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".mp4") {
+			defer track(&videoActive, &videoPeak)()
+		} else {
+			defer track(&imageActive, &imagePeak)()
+		}
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("data"))
+	}))
+	defer server.Close()
+
+	downloader := NewConcurrentDownloader(8)
+	downloader.SetExtensionLimits(map[string]int{".MP4": 2})
+	downloader.Start()
+	go func() {
+		for i := 0; i < 6; i++ {
+			video := fmt.Sprintf("%s/video/%d.mp4", server.URL, i)
+			image := fmt.Sprintf("%s/img/%d.jpg", server.URL, i)
+			downloader.AddJob(DownloadJob{URL: video, Type: "media", OriginalPath: video})
+			downloader.AddJob(DownloadJob{URL: image, Type: "image", OriginalPath: image})
+		}
+		downloader.FinishJobs()
+	}()
+	urlMap := downloader.GetResults()
+
+	if len(urlMap) != 12 {
+		t.Fatalf("expected 12 downloads, got %d", len(urlMap))
+	}
+	if peak := atomic.LoadInt64(&videoPeak); peak > 2 {
+		t.Errorf(".mp4 downloads peaked at %d; want at most 2", peak)
+	}
+	if peak := atomic.LoadInt64(&imagePeak); peak <= 2 {
+		t.Errorf(".jpg downloads should use the global pool, peaked at %d", peak)
+	}
+}
+
+func TestMaxRedirectsPerAsset(t *testing.T) {
+	var hops int64
+	server, base := newTestSiteHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// /loop/a.png and /loop/b.png redirect to each other forever
+		atomic.AddInt64(&hops, 1)
+		if r.URL.Path == "/loop/a.png" {
+			http.Redirect(w, r, "/loop/b.png", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, "/loop/a.png", http.StatusFound)
+	}))
+	page := `<html><body><img src="` + server.URL + `/loop/a.png"></body></html>`
+	done := make(chan struct{})
+	var updated string
+	var err error
+	go func() {
+		defer close(done)
+		updated, err = LocalizeAssets(page, base, Options{Concurrency: 1, MaxRedirects: 3})
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("redirect loop stalled the scrape")
+	}
+
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	if !strings.Contains(updated, server.URL+"/loop/a.png") {
+		t.Errorf("looping asset should stay remote: %s", updated)
+	}
+	// One initial request plus three followed redirects, and no retries
+	if got := atomic.LoadInt64(&hops); got != 4 {
+		t.Errorf("server saw %d requests; want 4", got)
+	}
+}
+
+func TestPreserveMTime(t *testing.T) {
+	modified := time.Date(2019, time.March, 4, 5, 6, 7, 0, time.UTC)
+	_, base := newTestSiteHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/img/old.png" {
+			w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		}
+		w.Write([]byte("png"))
+	}))
+	page := `<html><body><img src="/img/old.png"><img src="/img/new.png"></body></html>`
+	started := time.Now().Add(-time.Second)
+	if _, err := LocalizeAssets(page, base, Options{Concurrency: 2, PreserveMTime: true}); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	info, err := os.Stat("output/assets/images/old.png")
+	if err != nil {
+		t.Fatalf("image should be downloaded: %v", err)
+	}
+	if !info.ModTime().Equal(modified) {
+		t.Errorf("mtime = %v, want Last-Modified %v", info.ModTime().UTC(), modified)
+	}
+	info, err = os.Stat("output/assets/images/new.png")
+	if err != nil {
+		t.Fatalf("image should be downloaded: %v", err)
+	}
+	if info.ModTime().Before(started) {
+		t.Errorf("files without Last-Modified should keep their write time, got %v", info.ModTime())
+	}
+}
+
+func TestExcludeExtensionsLeavesRemote(t *testing.T) {
+	var videoRequests int32
+	_, base := newTestSiteHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".MP4") {
+			atomic.AddInt32(&videoRequests, 1)
+		}
+		w.Write([]byte("data"))
+	}))
+	page := `<html><head><link rel="preload" as="video" href="/media/intro.MP4"></head>` +
+		`<body><img src="/img/poster.png"></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 2, ExcludeExtensions: []string{".mp4", "zip"}})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	if !strings.Contains(result, `href="/media/intro.MP4"`) {
+		t.Errorf("excluded .mp4 should stay remote: %s", result)
+	}
+	if n := atomic.LoadInt32(&videoRequests); n != 0 {
+		t.Errorf("excluded .mp4 should not be requested, got %d requests", n)
+	}
+	if !strings.Contains(result, `src="assets/images/poster.png"`) {
+		t.Errorf("other assets should still be localized: %s", result)
+	}
+}
@@ -0,0 +1,172 @@
+package assets
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"wp-static-scraper/utils"
+)
+
+// CrawlPages follows <a href> links breadth-first from an already fetched start page, up to depth
+// links away from it, staying on the start host and opts.CrawlHosts. The start page keeps its
+// OutPath; every other page is saved at its URL path (see crawlOutPath). Each page is fetched once
+// however many pages link to it, so link cycles end.
+func CrawlPages(first Page, depth int, opts Options) []Page {
+	start := first.URL
+	pages := []Page{first}
+	seen := map[string]bool{pageKey(start): true}
+	taken := map[string]bool{first.OutPath: true}
+
+	level := []Page{first}
+	for d := 0; d < depth && len(level) > 0; d++ {
+		var next []Page
+		for _, page := range level {
+			for _, link := range FindPageLinks(string(page.Body), page.URL) {
+				if !opts.Deadline.IsZero() && time.Now().After(opts.Deadline) {
+					return pages
+				}
+				if seen[pageKey(link)] || !inCrawlScope(link, start, opts.CrawlHosts) {
+					continue
+				}
+				seen[pageKey(link)] = true
+
+				// Two URLs mirroring to the same file (/about and /about/) keep the first one
+				outPath, ok := crawlOutPath(link)
+				if !ok || taken[outPath] {
+					continue
+				}
+
+				body, contentType, err := FetchDocument(link.String(), opts)
+				if err != nil {
+					fmt.Fprintf(opts.logWriter(), "Failed to fetch page %s: %v\n", link, err)
+					continue
+				}
+				if !isHTMLType(contentType) {
+					continue
+				}
+				taken[outPath] = true
+				crawled := Page{URL: link, OutPath: outPath, Body: body}
+				pages = append(pages, crawled)
+				next = append(next, crawled)
+			}
+		}
+		level = next
+	}
+
+	return pages
+}
+
+// FindPageLinks returns the distinct http(s) pages a document links to with <a href>, without
+// fragments. In-page anchors, mailto:/tel:/javascript: links, and <a download> files are skipped.
+func FindPageLinks(htmlContent string, base *url.URL) []*url.URL {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil
+	}
+
+	var links []*url.URL
+	linkSeen := make(map[string]bool)
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" && !hasAttr(n, "download") {
+			href := strings.TrimSpace(getAttr(n, "href"))
+			if href != "" && !strings.HasPrefix(href, "#") {
+				link, err := url.Parse(utils.ResolveURL(base, href))
+				if err == nil && (link.Scheme == "http" || link.Scheme == "https") {
+					link.Fragment = ""
+					if !linkSeen[link.String()] {
+						linkSeen[link.String()] = true
+						links = append(links, link)
+					}
+				}
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+
+	traverse(doc)
+	return links
+}
+
+// crawlOutPath mirrors a page URL's path under the output directory: /about/ and /about are saved
+// to about/index.html and /old-page.html to old-page.html. Links with a query string (?p=123,
+// search results) and links to other files (.pdf, .xml, .php) are not crawled.
+func crawlOutPath(u *url.URL) (string, bool) {
+	if u.RawQuery != "" {
+		return "", false
+	}
+	p := path.Clean("/" + u.Path)
+	switch strings.ToLower(path.Ext(p)) {
+	case ".html", ".htm":
+		return strings.TrimPrefix(p, "/"), true
+	case "":
+		return strings.TrimPrefix(path.Join(p, "index.html"), "/"), true
+	}
+	return "", false
+}
+
+// isHTMLType reports whether a response Content-Type is an HTML document; a missing type is
+// assumed to be HTML
+func isHTMLType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// RewriteInternalLinks points <a href> links to other pages on the page's own host at where those
+// pages are saved in the crawl layout (see crawlOutPath), relative to fromPath, so navigation stays
+// inside the snapshot whether it is opened from disk or through serve. Absolute, protocol-relative,
+// and root-relative links are rewritten; relative links, in-page anchors (#section), query-only
+// links (?page=2), mailto:/tel: links, other hosts, and links with a query string or to files are
+// left as written.
+func RewriteInternalLinks(htmlContent string, base *url.URL, fromPath string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", err
+	}
+
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			href := strings.TrimSpace(getAttr(n, "href"))
+			lower := strings.ToLower(href)
+			if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(href, "/") {
+				target, err := url.Parse(utils.ResolveURL(base, href))
+				if err == nil && strings.EqualFold(target.Host, base.Host) {
+					if outPath, ok := crawlOutPath(target); ok {
+						rel := relativePagePath(fromPath, outPath)
+						if target.Fragment != "" {
+							rel += "#" + target.Fragment
+						}
+						setAttr(n, "href", rel)
+					}
+				}
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+
+	traverse(doc)
+
+	var buf strings.Builder
+	if err := html.Render(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,129 @@
+package assets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCrawlPagesDepthAndCycles(t *testing.T) {
+	var externalHits int64
+	external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&externalHits, 1)
+		w.Write([]byte(`<html><body>external</body></html>`))
+	}))
+	defer external.Close()
+
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	routes := map[string]string{
+		"/": `<a href="/about/">About</a> <a href="/blog/#latest">Blog</a> <a href="` + external.URL + `/partner/">Partner</a>` +
+			` <a href="mailto:hi@example.com">Mail</a> <a href="#top">Top</a> <a href="/?s=search">Search</a> <a href="/brochure.pdf">PDF</a>`,
+		"/about/":              `<a href="/">Home</a> <a href="/about/team/">Team</a>`,
+		"/blog/":               `<a href="/">Home</a> <a href="/about/">About</a> <a href="/blog/">Blog</a>`,
+		"/about/team/":         `<a href="/about/team/history/">History</a>`,
+		"/about/team/history/": `<p>Too deep</p>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body>` + body + `</body></html>`))
+	}))
+	defer server.Close()
+
+	start, _ := url.Parse(server.URL + "/")
+	body, err := FetchPage(start.String(), Options{})
+	if err != nil {
+		t.Fatalf("FetchPage returned error: %v", err)
+	}
+	pages := CrawlPages(Page{URL: start, OutPath: "index.html", Body: body}, 2, Options{})
+
+	var outPaths []string
+	for _, page := range pages {
+		outPaths = append(outPaths, page.OutPath)
+	}
+	want := []string{"index.html", "about/index.html", "blog/index.html", "about/team/index.html"}
+	if strings.Join(outPaths, ",") != strings.Join(want, ",") {
+		t.Errorf("crawled pages = %v, want %v", outPaths, want)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hits["/about/team/history/"] != 0 {
+		t.Errorf("pages beyond -depth should not be fetched")
+	}
+	for _, path := range []string{"/", "/about/", "/blog/"} {
+		if hits[path] != 1 {
+			t.Errorf("%s fetched %d times, want 1 despite the link cycle", path, hits[path])
+		}
+	}
+	if hits["/brochure.pdf"] != 0 {
+		t.Errorf("links to non-HTML files should not be crawled")
+	}
+	if atomic.LoadInt64(&externalHits) != 0 {
+		t.Errorf("external links should not be followed")
+	}
+}
+
+func TestRewriteInternalLinks(t *testing.T) {
+	base, _ := url.Parse("https://example.com/about/team/")
+	page := `<html><body>` +
+		`<a href="https://example.com/contact/">Contact</a>` +
+		`<a href="https://EXAMPLE.com/services/#pricing">Pricing</a>` +
+		`<a href="/blog/first-post/">Post</a>` +
+		`<a href="//example.com/">Home</a>` +
+		`<a href="https://other.example.org/contact/">Partner</a>` +
+		`<a href="#section">Section</a>` +
+		`<a href="?page=2">Next</a>` +
+		`<a href="mailto:hello@example.com">Mail</a>` +
+		`<a href="tel:+15555550100">Call</a>` +
+		`<a href="https://example.com/?s=query">Search</a>` +
+		`<a href="https://example.com/wp-content/uploads/menu.pdf">Menu</a>` +
+		`<a href="history/">History</a>` +
+		`</body></html>`
+
+	result, err := RewriteInternalLinks(page, base, "about/team/index.html")
+	if err != nil {
+		t.Fatalf("RewriteInternalLinks returned error: %v", err)
+	}
+
+	for _, want := range []string{
+		`href="../../contact/index.html"`,
+		`href="../../services/index.html#pricing"`,
+		`href="../../blog/first-post/index.html"`,
+		`href="../../index.html"`,
+		// Left as written
+		`href="https://other.example.org/contact/"`,
+		`href="#section"`,
+		`href="?page=2"`,
+		`href="mailto:hello@example.com"`,
+		`href="tel:+15555550100"`,
+		`href="https://example.com/?s=query"`,
+		`href="https://example.com/wp-content/uploads/menu.pdf"`,
+		`href="history/"`,
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %s in %s", want, result)
+		}
+	}
+
+	// From the start page saved at the output root, links need no ../ prefix
+	result, err = RewriteInternalLinks(`<a href="https://example.com/contact/">Contact</a>`, base, "index.html")
+	if err != nil {
+		t.Fatalf("RewriteInternalLinks returned error: %v", err)
+	}
+	if !strings.Contains(result, `href="contact/index.html"`) {
+		t.Errorf("expected a root-relative layout path: %s", result)
+	}
+}
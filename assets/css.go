@@ -0,0 +1,383 @@
+package assets
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"wp-static-scraper/utils"
+)
+
+// cssURLRe matches url(...) references in a stylesheet
+var cssURLRe = regexp.MustCompile(`url\(\s*(['"]?)([^)'"]+)(['"]?)\s*\)`)
+
+// cssRewrite is a downloaded stylesheet whose url() references are rewritten once their assets resolve
+type cssRewrite struct {
+	URL          string
+	OriginalPath string
+	LocalPath    string
+	Content      string
+	Refs         map[string]string // reference as written -> resolved asset URL
+	LastModified string            // Last-Modified header of the stylesheet response
+}
+
+// cssAssetType picks the job type for an asset referenced from CSS
+func cssAssetType(assetURL string) string {
+	u, err := url.Parse(assetURL)
+	if err != nil {
+		return "image"
+	}
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".woff", ".woff2", ".ttf", ".eot", ".otf":
+		return "font"
+	case ".css":
+		return "css"
+	default:
+		return "image"
+	}
+}
+
+// queueCSSAssets enqueues every asset referenced by a stylesheet, including those nested in
+// @media and @supports blocks, into the worker pool and
+// defers the rewrite of the stylesheet until GetResults has collected their results.
+// @import targets are queued as stylesheets whose own references resolve against their URL, so
+// relative paths stay correct however deep the import chain goes.
+func (cd *ConcurrentDownloader) queueCSSAssets(job DownloadJob, localPath, cssContent, lastModified string) {
+	sheetURL, err := url.Parse(job.URL)
+	if err != nil {
+		sheetURL = job.BaseURL
+	}
+
+	// Purged first, so assets only referenced by removed rules are not downloaded
+	cssContent = cd.cssPurge.purge(job.URL, cssContent)
+	refs := make(map[string]string)
+	for ref, isImport := range cssRefs(cssContent) {
+		if ref == "" || strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "#") {
+			continue
+		}
+
+		// References inside a stylesheet are relative to the stylesheet itself
+		var assetURL string
+		if strings.HasPrefix(ref, "//") {
+			assetURL = sheetURL.Scheme + ":" + ref
+		} else {
+			assetURL = utils.ResolveURL(sheetURL, ref)
+		}
+		refs[ref] = assetURL
+
+		// Claimed URLs are never queued twice, which also ends import cycles
+		if !cd.claimURL(assetURL) {
+			continue
+		}
+		jobType := cssAssetType(assetURL)
+		if isImport {
+			jobType = "css"
+		}
+		cd.enqueue(DownloadJob{
+			URL:          assetURL,
+			Type:         jobType,
+			OriginalPath: assetURL,
+			BaseURL:      sheetURL,
+		})
+	}
+
+	cd.cssMu.Lock()
+	cd.cssRewrites = append(cd.cssRewrites, cssRewrite{
+		URL:          job.URL,
+		OriginalPath: job.OriginalPath,
+		LocalPath:    localPath,
+		Content:      cssContent,
+		Refs:         refs,
+		LastModified: lastModified,
+	})
+	cd.cssMu.Unlock()
+}
+
+// absolutizeCSSURLs rewrites relative url() references in a saved stylesheet to absolute paths rooted
+// at urlBase, the location the output directory is served from, so the stylesheet works wherever it is hosted
+func absolutizeCSSURLs(cssContent, cssLocalPath, outDir, urlBase string) string {
+	sheetDir := path.Dir(relativeToOutDir(cssLocalPath, outDir))
+	root := strings.TrimSuffix(urlBase, "/")
+
+	return cssURLRe.ReplaceAllStringFunc(cssContent, func(match string) string {
+		parts := cssURLRe.FindStringSubmatch(match)
+		ref := strings.TrimSpace(parts[2])
+		if strings.Contains(ref, "://") || strings.HasPrefix(ref, "/") || strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "#") {
+			return match
+		}
+		return "url(" + parts[1] + root + "/" + path.Join(sheetDir, ref) + parts[3] + ")"
+	})
+}
+
+// prefixLocalFontURLs rewrites url() references that resolve into output/assets/fonts/ to fontPrefix
+// (e.g. /assets/fonts/), so fonts load no matter where the stylesheet is re-hosted. References are
+// resolved against the stylesheet first, so webfonts/ and other look-alike directories are left alone.
+func prefixLocalFontURLs(cssContent, cssLocalPath, outDir, fontPrefix string) string {
+	sheetDir := path.Dir(relativeToOutDir(cssLocalPath, outDir))
+	prefix := strings.TrimSuffix(fontPrefix, "/") + "/"
+
+	return cssURLRe.ReplaceAllStringFunc(cssContent, func(match string) string {
+		parts := cssURLRe.FindStringSubmatch(match)
+		ref := strings.TrimSpace(parts[2])
+		if strings.Contains(ref, "://") || strings.HasPrefix(ref, "/") || strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "#") {
+			return match
+		}
+		fontFile, ok := strings.CutPrefix(path.Join(sheetDir, ref), "assets/fonts/")
+		if !ok {
+			return match
+		}
+		return "url(" + parts[1] + prefix + fontFile + parts[3] + ")"
+	})
+}
+
+// finalizeCSSRewrites writes deferred stylesheets with their url() references pointing at the
+// downloaded assets. References that failed to download are left as absolute remote URLs.
+func (cd *ConcurrentDownloader) finalizeCSSRewrites(urlMap map[string]string) {
+	localByURL := make(map[string]string)
+	for _, result := range cd.collected {
+		if result.Success {
+			localByURL[assetKey(result.Job.URL, cd.AssetCase)] = result.LocalPath
+		}
+	}
+
+	for _, rewrite := range cd.cssRewrites {
+		content := rewriteCSSURLRefs(rewrite.Content, func(ref string) (string, bool) {
+			assetURL, ok := rewrite.Refs[ref]
+			if !ok {
+				return "", false
+			}
+			target := assetURL
+			if assetPath, ok := localByURL[assetKey(assetURL, cd.AssetCase)]; ok {
+				if dataURI, ok := cd.inlined.Load(assetPath); ok {
+					return dataURI.(string), true
+				}
+				if rel, err := filepath.Rel(filepath.Dir(rewrite.LocalPath), assetPath); err == nil {
+					target = filepath.ToSlash(rel)
+				}
+			}
+			return target, true
+		})
+
+		if cd.FontURLPrefix != "" {
+			content = prefixLocalFontURLs(content, rewrite.LocalPath, cd.outputDir(), cd.FontURLPrefix)
+		}
+		if cd.CSSURLBase != "" {
+			content = absolutizeCSSURLs(content, rewrite.LocalPath, cd.outputDir(), cd.CSSURLBase)
+		}
+		data := utils.NormalizeLineEndings([]byte(content), cd.LineEndings)
+		localPath, err := cd.writeFile(rewrite.LocalPath, data)
+		if err != nil {
+			fmt.Fprintf(cd.logWriter(), "PRIMARY ASSET FAILED: %s (type: css): %v\n", rewrite.OriginalPath, err)
+			delete(urlMap, rewrite.OriginalPath)
+			continue
+		}
+		if cd.PreserveMTime {
+			setModTime(localPath, rewrite.LastModified)
+		}
+		urlMap[rewrite.OriginalPath] = localPath
+		// Content naming or dedupe may have saved the stylesheet under another name
+		for i := range cd.collected {
+			if cd.collected[i].Success && cd.collected[i].Job.URL == rewrite.URL {
+				cd.collected[i].LocalPath = localPath
+			}
+		}
+	}
+}
+
+// localizeStylesheet prepares a stylesheet fetched by downloadSheet for saving at localPath.
+// Images, @import targets, and fonts resolve against the stylesheet's own URL and are pointed at
+// their downloads, and source map references are removed; with a CSS purge, unused rules are
+// dropped before anything is downloaded. chain holds the sheets whose @imports led here.
+func (cd *ConcurrentDownloader) localizeStylesheet(cssContent string, sheetURL *url.URL, localPath string, chain *importChain) (string, error) {
+	sheetDir := path.Dir(localPath)
+	cssContent = cd.cssPurge.purge(sheetURL.String(), cssContent)
+	cssContent = localizeCSSAssetURLs(cssContent, sheetURL, sheetDir, cd.downloadCSSImage)
+	cssContent = cd.localizeCSSImports(cssContent, sheetURL, sheetDir, chain)
+	cssContent = localizeCSSRefsOfType(cssContent, sheetURL, sheetDir, "font", cd.downloadCSSFont)
+	cssContent = utils.RemoveSourceMapReferences(cssContent)
+	if cd.FontURLPrefix != "" {
+		cssContent = prefixLocalFontURLs(cssContent, localPath, cd.outputDir(), cd.FontURLPrefix)
+	}
+	if cd.CSSURLBase != "" {
+		cssContent = absolutizeCSSURLs(cssContent, localPath, cd.outputDir(), cd.CSSURLBase)
+	}
+	return cssContent, nil
+}
+
+// localizeCSSImports downloads the stylesheets a sheet @imports, given as url() or a bare string,
+// and points each @import at its local copy. Imported sheets are localized the same way, so chains
+// of any depth are followed. An @import that closes a cycle points at the sheet's local copy when
+// its name is known before it is written, and at its absolute URL otherwise. Imports that fail to
+// download keep their target as written.
+func (cd *ConcurrentDownloader) localizeCSSImports(cssContent string, sheetURL *url.URL, sheetDir string, chain *importChain) string {
+	refs := cssRefs(cssContent)
+	return rewriteCSSURLRefs(cssContent, func(ref string) (string, bool) {
+		if !refs[ref] {
+			return "", false
+		}
+		importURL, ok := resolveAssetURL(sheetURL, ref)
+		if !ok {
+			return "", false
+		}
+		importPath, err := cd.downloadImport(importURL, chain)
+		if errors.Is(err, errImportCycle) {
+			return importURL, true
+		}
+		if errors.Is(err, ErrExcluded) {
+			return "", false
+		}
+		if err != nil {
+			fmt.Fprintf(cd.logWriter(), "Failed to download imported stylesheet %s: %v\n", importURL, err)
+			return "", false
+		}
+		rel, err := filepath.Rel(sheetDir, importPath)
+		if err != nil {
+			return "", false
+		}
+		return filepath.ToSlash(rel), true
+	})
+}
+
+// downloadImport saves a stylesheet reached through @import from a sheet in chain. The first sheet
+// to claim its URL downloads it now and reports it as its own result, like downloadCSSImage, so it
+// reaches the manifest and the reports; an import the page or another sheet already claimed shares
+// that download.
+func (cd *ConcurrentDownloader) downloadImport(importURL string, chain *importChain) (string, error) {
+	if !cd.claimURL(importURL) {
+		return cd.downloadSheet(importURL, chain)
+	}
+	job := DownloadJob{URL: importURL, Type: "css", OriginalPath: importURL}
+	result := cd.downloadNow(job, func(job DownloadJob) DownloadResult {
+		localPath, err := cd.downloadSheet(job.URL, chain)
+		if err != nil {
+			return DownloadResult{Job: job, Success: false, Error: err}
+		}
+		return DownloadResult{Job: job, LocalPath: localPath, Success: true}
+	})
+	return result.LocalPath, result.Error
+}
+
+// errImportCycle is returned by downloadSheet for an @import back to a sheet still being
+// localized whose final name depends on its content
+var errImportCycle = errors.New("stylesheet import cycle")
+
+// sheetFetch is one stylesheet download shared by every job and @import reaching its URL
+type sheetFetch struct {
+	done      chan struct{}
+	chain     *importChain // Chain localizing the sheet; nil once it is done
+	localPath string
+	err       error
+}
+
+// importChain is the stylesheets one top-level sheet is localizing, from itself down to the
+// @import being followed, each mapped to the path it will be saved at
+type importChain struct {
+	sheets  map[string]string
+	waiting *sheetFetch // Sheet another chain is localizing that this one waits for
+}
+
+func newImportChain() *importChain {
+	return &importChain{sheets: make(map[string]string)}
+}
+
+// downloadSheet fetches, localizes, and saves a stylesheet, returning its local path. Each URL is
+// downloaded once per run, whether it is a job or reached through @import; callers arriving while
+// it downloads wait for that result, and a failed download is forgotten so a retry fetches it
+// again. An @import back to a sheet in chain, or to one whose chain waits on this one, is a cycle:
+// it gets the sheet's planned path, or errImportCycle when the name is only settled on write.
+func (cd *ConcurrentDownloader) downloadSheet(sheetURL string, chain *importChain) (string, error) {
+	key := assetKey(sheetURL, cd.AssetCase)
+	cd.sheetMu.Lock()
+	if planned, ok := chain.sheets[key]; ok {
+		cd.sheetMu.Unlock()
+		return cd.cyclicSheetPath(planned)
+	}
+	if shared, ok := cd.sheetFetches[key]; ok {
+		if shared.chain != nil && shared.chain.waitsOn(chain) {
+			planned, ok := shared.chain.sheets[key]
+			cd.sheetMu.Unlock()
+			if !ok {
+				return "", errImportCycle
+			}
+			return cd.cyclicSheetPath(planned)
+		}
+		chain.waiting = shared
+		cd.sheetMu.Unlock()
+		<-shared.done
+		cd.sheetMu.Lock()
+		chain.waiting = nil
+		cd.sheetMu.Unlock()
+		return shared.localPath, shared.err
+	}
+	fetch := &sheetFetch{done: make(chan struct{}), chain: chain}
+	if cd.sheetFetches == nil {
+		cd.sheetFetches = make(map[string]*sheetFetch)
+	}
+	cd.sheetFetches[key] = fetch
+	cd.sheetMu.Unlock()
+
+	fetch.localPath, fetch.err = cd.fetchSheet(sheetURL, key, chain)
+	cd.sheetMu.Lock()
+	delete(chain.sheets, key)
+	fetch.chain = nil
+	if fetch.err != nil {
+		delete(cd.sheetFetches, key)
+	}
+	cd.sheetMu.Unlock()
+	close(fetch.done)
+	return fetch.localPath, fetch.err
+}
+
+// waitsOn reports whether chain c is, or waits through other chains on, target
+func (c *importChain) waitsOn(target *importChain) bool {
+	for c != nil {
+		if c == target {
+			return true
+		}
+		if c.waiting == nil {
+			return false
+		}
+		c = c.waiting.chain
+	}
+	return false
+}
+
+// cyclicSheetPath is the path an @import closing a cycle points at. Content-hashed and deduplicated
+// sheets are only named once written, which a cycle never waits for.
+func (cd *ConcurrentDownloader) cyclicSheetPath(planned string) (string, error) {
+	if cd.HashNames || cd.Dedupe {
+		return "", errImportCycle
+	}
+	return applyAssetCase(planned, cd.AssetCase), nil
+}
+
+// fetchSheet is downloadSheet's download, run once per URL
+func (cd *ConcurrentDownloader) fetchSheet(sheetURL, key string, chain *importChain) (string, error) {
+	u, err := url.Parse(sheetURL)
+	if err != nil {
+		return "", err
+	}
+	data, header, err := cd.fetch(sheetURL)
+	if err != nil {
+		return "", err
+	}
+	localPath, _, err := cd.localPathFor("css", sheetURL)
+	if err != nil {
+		return "", err
+	}
+
+	// Recorded before recursing, so a sheet importing itself or an ancestor stops here
+	cd.sheetMu.Lock()
+	chain.sheets[key] = localPath
+	cd.sheetMu.Unlock()
+	cssContent, err := cd.localizeStylesheet(string(data), u, localPath, chain)
+	if err != nil {
+		return "", err
+	}
+	data = utils.NormalizeLineEndings([]byte(cssContent), cd.LineEndings)
+	return cd.writeFetched(localPath, data, header)
+}
@@ -0,0 +1,612 @@
+package assets
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"wp-static-scraper/internal/testsite"
+	"wp-static-scraper/utils"
+)
+
+func TestCSSImagesResolveAgainstStylesheet(t *testing.T) {
+	chdirOutput(t)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := strings.TrimPrefix(server.URL, "http:")
+		switch r.URL.Path {
+		case "/wp-content/themes/t/css/style.css":
+			w.Write([]byte(`.a { background: url(../img/x.png) }` +
+				` .b { background: url("` + server.URL + `/uploads/y.png") }` +
+				` .c { background: url(` + host + `/cdn/z.png) }`))
+		case "/wp-content/themes/t/img/x.png":
+			w.Write([]byte("x"))
+		case "/uploads/y.png":
+			w.Write([]byte("y"))
+		case "/cdn/z.png":
+			w.Write([]byte("z"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// The page lives elsewhere, so resolving ../img/x.png against it would miss the image
+	base, _ := url.Parse(server.URL + "/blog/post/")
+	page := `<html><head><link rel="stylesheet" href="/wp-content/themes/t/css/style.css"></head><body></body></html>`
+	if _, err := LocalizeAssets(page, base, Options{Concurrency: 2}); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	css, err := os.ReadFile("output/assets/style.css")
+	if err != nil {
+		t.Fatalf("stylesheet not saved: %v", err)
+	}
+	for _, want := range []string{`url(images/x.png)`, `url("images/y.png")`, `url(images/z.png)`} {
+		if !strings.Contains(string(css), want) {
+			t.Errorf("expected %s in %s", want, css)
+		}
+	}
+	for name, want := range map[string]string{"x.png": "x", "y.png": "y", "z.png": "z"} {
+		if data, err := os.ReadFile("output/assets/images/" + name); err != nil || string(data) != want {
+			t.Errorf("images/%s = %q, %v; want %q", name, data, err, want)
+		}
+	}
+}
+
+func TestCSSImportChain(t *testing.T) {
+	_, base := newTestSite(t, map[string]string{
+		"/css/main.css":       `@import url("theme-base.css"); body { margin: 0 }`,
+		"/css/theme-base.css": `@import "../shared/vars.css"; .base { color: red }`,
+		"/shared/vars.css":    `:root { --bg: url(../img/bg.png) }`,
+		"/img/bg.png":         "bg",
+	})
+	page := `<html><head><link rel="stylesheet" href="/css/main.css"></head><body></body></html>`
+	if _, err := LocalizeAssets(page, base, Options{Concurrency: 2}); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	for file, want := range map[string]string{
+		"output/assets/main.css":       `@import url("theme-base.css");`,
+		"output/assets/theme-base.css": `@import "vars.css";`,
+		"output/assets/vars.css":       `url(images/bg.png)`,
+		"output/assets/images/bg.png":  "bg",
+	} {
+		data, err := os.ReadFile(file)
+		if err != nil || !strings.Contains(string(data), want) {
+			t.Errorf("%s = %q, %v; want it to contain %s", file, data, err, want)
+		}
+	}
+}
+
+func TestImportedSheetsReported(t *testing.T) {
+	server, base := newTestSite(t, map[string]string{
+		"/css/main.css":  `@import url("theme.css"); body { margin: 0 }`,
+		"/css/theme.css": `.theme { color: red }`,
+	})
+	page := `<html><head><link rel="stylesheet" href="/css/main.css"></head><body></body></html>`
+	opts := Options{Concurrency: 2, ManifestPath: "output/manifest.json"}
+	if _, err := LocalizeAssets(page, base, opts); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	data, err := os.ReadFile("output/manifest.json")
+	if err != nil {
+		t.Fatalf("manifest was not written: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("manifest is not valid JSON: %v", err)
+	}
+	var imported *ManifestEntry
+	for i, entry := range manifest.Assets {
+		if entry.URL == server.URL+"/css/theme.css" {
+			imported = &manifest.Assets[i]
+		}
+	}
+	if imported == nil {
+		t.Fatalf("imported stylesheet should be listed in the manifest: %s", data)
+	}
+	if imported.Type != "css" || imported.LocalPath != "assets/theme.css" || imported.Size == 0 || imported.Integrity == "" {
+		t.Errorf("imported stylesheet entry = %+v; want a saved css asset with its size and integrity", *imported)
+	}
+}
+
+func TestStylesheetFontsReported(t *testing.T) {
+	var fontHits int64
+	var server *httptest.Server
+	server, base := newTestSiteHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/css/site.css":
+			w.Write([]byte(`@font-face { font-family: "X"; src: url("../fonts/x.woff2") format("woff2"); }`))
+		case "/fonts/x.woff2":
+			atomic.AddInt64(&fontHits, 1)
+			w.Write([]byte("font"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	page := `<html><head><link rel="stylesheet" href="/css/site.css">` +
+		`<link rel="preload" href="/fonts/x.woff2" as="font"></head><body></body></html>`
+	opts := Options{Concurrency: 2, ManifestPath: "output/manifest.json"}
+	if _, err := LocalizeAssets(page, base, opts); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	if got := atomic.LoadInt64(&fontHits); got != 1 {
+		t.Errorf("font linked from the page and the stylesheet should be downloaded once, got %d requests", got)
+	}
+	css, err := os.ReadFile("output/assets/site.css")
+	if err != nil {
+		t.Fatalf("stylesheet should be saved: %v", err)
+	}
+	if !strings.Contains(string(css), `url("fonts/x.woff2")`) {
+		t.Errorf("stylesheet should point at the local font: %s", css)
+	}
+
+	data, err := os.ReadFile("output/manifest.json")
+	if err != nil {
+		t.Fatalf("manifest was not written: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("manifest is not valid JSON: %v", err)
+	}
+	var fonts []ManifestEntry
+	for _, entry := range manifest.Assets {
+		if entry.URL == server.URL+"/fonts/x.woff2" {
+			fonts = append(fonts, entry)
+		}
+	}
+	if len(fonts) != 1 || fonts[0].Type != "font" || fonts[0].LocalPath != "assets/fonts/x.woff2" || fonts[0].Size == 0 {
+		t.Errorf("font should be listed once as a saved font, got %+v", fonts)
+	}
+}
+
+func TestStylesheetOnlyFontReported(t *testing.T) {
+	server, base := newTestSite(t, map[string]string{
+		"/css/site.css": `@font-face { font-family: "X"; src: url(/fonts/y.woff); }`,
+		"/fonts/y.woff": "font",
+	})
+	page := `<html><head><link rel="stylesheet" href="/css/site.css"></head><body></body></html>`
+	opts := Options{Concurrency: 2, ManifestPath: "output/manifest.json"}
+	if _, err := LocalizeAssets(page, base, opts); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	data, err := os.ReadFile("output/manifest.json")
+	if err != nil {
+		t.Fatalf("manifest was not written: %v", err)
+	}
+	if !strings.Contains(string(data), `"url": "`+server.URL+`/fonts/y.woff"`) {
+		t.Errorf("font referenced only from a stylesheet should be in the manifest: %s", data)
+	}
+}
+
+func TestCSSImportCycleTerminates(t *testing.T) {
+	chdirOutput(t)
+
+	var hits sync.Map
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count, _ := hits.LoadOrStore(r.URL.Path, new(int64))
+		atomic.AddInt64(count.(*int64), 1)
+		switch r.URL.Path {
+		case "/css/self.css":
+			w.Write([]byte(`@import "self.css"; @import url(other.css); .self { color: red }`))
+		case "/css/other.css":
+			w.Write([]byte(`@import url("/css/self.css"); .other { color: blue }`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	base, _ := url.Parse(server.URL + "/")
+	page := `<html><head><link rel="stylesheet" href="/css/self.css"></head><body></body></html>`
+	if _, err := LocalizeAssets(page, base, Options{Concurrency: 2}); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	for _, sheet := range []string{"/css/self.css", "/css/other.css"} {
+		count, ok := hits.Load(sheet)
+		if !ok || atomic.LoadInt64(count.(*int64)) != 1 {
+			t.Errorf("%s should be fetched exactly once", sheet)
+		}
+	}
+	self, _ := os.ReadFile("output/assets/self.css")
+	if !strings.Contains(string(self), `@import "self.css"; @import url(other.css);`) {
+		t.Errorf("self.css imports should point at the local sheets: %s", self)
+	}
+	other, _ := os.ReadFile("output/assets/other.css")
+	if !strings.Contains(string(other), `@import url("self.css");`) {
+		t.Errorf("other.css should import the local self.css: %s", other)
+	}
+}
+
+func TestSharedCSSImportFetchedOnce(t *testing.T) {
+	chdirOutput(t)
+
+	var hits sync.Map
+	routes := map[string]string{
+		"/css/a.css":      `@import url("shared.css"); @import url("b.css"); .a { color: red }`,
+		"/css/b.css":      `@import url("shared.css"); @import url("a.css"); .b { color: blue }`,
+		"/css/shared.css": `.shared { margin: 0 }`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count, _ := hits.LoadOrStore(r.URL.Path, new(int64))
+		atomic.AddInt64(count.(*int64), 1)
+		w.Write([]byte(routes[r.URL.Path]))
+	}))
+	defer server.Close()
+
+	base, _ := url.Parse(server.URL + "/")
+	page := `<html><head><link rel="stylesheet" href="/css/a.css"><link rel="stylesheet" href="/css/b.css">` +
+		`<link rel="stylesheet" href="/css/shared.css"></head><body></body></html>`
+	if _, err := LocalizeAssets(page, base, Options{Concurrency: 4}); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	for sheet := range routes {
+		count, ok := hits.Load(sheet)
+		if !ok || atomic.LoadInt64(count.(*int64)) != 1 {
+			t.Errorf("%s should be fetched exactly once", sheet)
+		}
+	}
+	for file, want := range map[string]string{
+		"output/assets/a.css": `@import url("shared.css"); @import url("b.css");`,
+		"output/assets/b.css": `@import url("shared.css"); @import url("a.css");`,
+	} {
+		data, err := os.ReadFile(file)
+		if err != nil || !strings.Contains(string(data), want) {
+			t.Errorf("%s = %q, %v; want it to contain %s", file, data, err, want)
+		}
+	}
+}
+
+func TestHashedCSSImportCycleReferencesWrittenFiles(t *testing.T) {
+	server, base := newTestSite(t, map[string]string{
+		"/css/self.css":  `@import "self.css"; @import url(other.css); .self { color: red }`,
+		"/css/other.css": `@import url("/css/self.css"); .other { color: blue }`,
+	})
+	page := `<html><head><link rel="stylesheet" href="/css/self.css"></head><body></body></html>`
+	if _, err := LocalizeAssets(page, base, Options{Concurrency: 2, HashNames: true}); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	sheets, _ := filepath.Glob("output/assets/*.css")
+	if len(sheets) != 2 {
+		t.Fatalf("expected both sheets to be written, got %v", sheets)
+	}
+	for _, sheet := range sheets {
+		data, _ := os.ReadFile(sheet)
+		for ref := range cssRefs(string(data)) {
+			if strings.HasPrefix(ref, server.URL) {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(filepath.Dir(sheet), ref)); err != nil {
+				t.Errorf("%s imports %s, which was never written", sheet, ref)
+			}
+		}
+	}
+}
+
+func TestCSSExtensionlessImportIsNotAnImage(t *testing.T) {
+	_, base := newTestSite(t, map[string]string{
+		"/css/site.css":             `@import url("/fonts-api/css?family=Roboto"); body { font-family: Roboto }`,
+		"/fonts-api/css":            `@font-face { font-family: Roboto; src: url(/fonts-api/s/roboto.woff2) format("woff2") }`,
+		"/fonts-api/s/roboto.woff2": "woff2",
+	})
+	page := `<html><head><link rel="stylesheet" href="/css/site.css"></head><body></body></html>`
+	if _, err := LocalizeAssets(page, base, Options{Concurrency: 2}); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	if _, err := os.Stat("output/assets/images/css"); !os.IsNotExist(err) {
+		t.Errorf("an extensionless @import target should not be saved as an image")
+	}
+	for file, want := range map[string]string{
+		"output/assets/site.css":           `@import url("css.css");`,
+		"output/assets/css.css":            `url(fonts/roboto.woff2)`,
+		"output/assets/fonts/roboto.woff2": "woff2",
+	} {
+		data, err := os.ReadFile(file)
+		if err != nil || !strings.Contains(string(data), want) {
+			t.Errorf("%s = %q, %v; want it to contain %s", file, data, err, want)
+		}
+	}
+}
+
+func TestCSSImagesShareDownloaderBookkeeping(t *testing.T) {
+	chdirOutput(t)
+
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/css/site.css":
+			w.Write([]byte(`.a { background: url(../img/shared.png) } .b { background: url(../img/photo) } .c { background: url(../img/dot.png) }`))
+		case "/img/shared.png":
+			time.Sleep(20 * time.Millisecond)
+			w.Write([]byte("shared image"))
+		case "/img/photo":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("photo bytes"))
+		case "/img/dot.png":
+			w.Write([]byte("dot"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	base, _ := url.Parse(server.URL + "/")
+
+	downloader := NewConcurrentDownloader(4)
+	downloader.InlineBelow = 5
+	downloader.Start()
+	downloader.AddJob(DownloadJob{URL: server.URL + "/css/site.css", Type: "css", OriginalPath: "/css/site.css", BaseURL: base})
+	downloader.AddJob(DownloadJob{URL: server.URL + "/img/shared.png", Type: "image", OriginalPath: "/img/shared.png", BaseURL: base})
+	downloader.FinishJobs()
+	urlMap := downloader.GetResults()
+
+	mu.Lock()
+	if hits["/img/shared.png"] != 1 {
+		t.Errorf("an image referenced by the page and a stylesheet should be fetched once, got %d", hits["/img/shared.png"])
+	}
+	mu.Unlock()
+	if urlMap["/img/shared.png"] != "output/assets/images/shared.png" {
+		t.Errorf("the page reference should resolve to the shared download, got %v", urlMap)
+	}
+
+	css, err := os.ReadFile("output/assets/site.css")
+	if err != nil {
+		t.Fatalf("stylesheet not saved: %v", err)
+	}
+	for _, want := range []string{`url(images/shared.png)`, `url(images/photo.png)`, `url(data:image/png;base64,`} {
+		if !strings.Contains(string(css), want) {
+			t.Errorf("expected %s in %s", want, css)
+		}
+	}
+
+	recorded := make(map[string]bool)
+	for _, result := range downloader.Results() {
+		if result.Success {
+			recorded[result.Job.URL] = true
+		}
+	}
+	for _, image := range []string{"/img/photo", "/img/dot.png"} {
+		if !recorded[server.URL+image] {
+			t.Errorf("stylesheet image %s should be recorded as a download result", image)
+		}
+	}
+}
+
+func TestConcurrentCSSRewrite(t *testing.T) {
+	server, base := newTestSite(t, map[string]string{
+		"/css/theme.css":     `body{background:url("../img/hero.png")}@font-face{src:url(../fonts/brand.woff2)}.x{background:url(data:image/gif;base64,R0lG)}`,
+		"/img/hero.png":      "png",
+		"/fonts/brand.woff2": "font",
+	})
+	page := `<html><head><link rel="stylesheet" href="` + server.URL + `/css/theme.css"></head><body></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 2, ConcurrentCSSRewrite: true})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	if !strings.Contains(result, `href="assets/theme.css"`) {
+		t.Errorf("stylesheet should be localized, got %s", result)
+	}
+
+	css, err := os.ReadFile("output/assets/theme.css")
+	if err != nil {
+		t.Fatalf("stylesheet should be written after its assets resolve: %v", err)
+	}
+	for _, want := range []string{`url("images/hero.png")`, `url(fonts/brand.woff2)`, `url(data:image/gif;base64,R0lG)`} {
+		if !strings.Contains(string(css), want) {
+			t.Errorf("rewritten stylesheet should contain %s, got %s", want, css)
+		}
+	}
+	for _, path := range []string{"output/assets/images/hero.png", "output/assets/fonts/brand.woff2"} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("CSS-referenced asset should be downloaded to %s: %v", path, err)
+		}
+	}
+}
+
+// BenchmarkCSSRewrite compares serial per-stylesheet fetching with the shared-pool pipeline
+// for a stylesheet referencing 20 images served with a small delay each.
+func BenchmarkCSSRewrite(b *testing.B) {
+	mux := http.NewServeMux()
+	var css strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&css, ".bg%d{background:url(/img/bg%d.png)}\n", i, i)
+	}
+	mux.HandleFunc("/css/theme.css", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(css.String()))
+	})
+	mux.HandleFunc("/img/", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.Write([]byte("png"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	base, _ := url.Parse(server.URL + "/")
+	page := `<html><head><link rel="stylesheet" href="` + server.URL + `/css/theme.css"></head><body></body></html>`
+
+	for _, bc := range []struct {
+		name       string
+		concurrent bool
+	}{
+		{"serial", false},
+		{"concurrent", true},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			chdirOutput(b)
+			opts := Options{Concurrency: 10, ConcurrentCSSRewrite: bc.concurrent}
+			for i := 0; i < b.N; i++ {
+				if _, err := LocalizeAssets(page, base, opts); err != nil {
+					b.Fatalf("LocalizeAssets returned error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestCSSURLRewriteAbsolute(t *testing.T) {
+	server, base := newTestSite(t, map[string]string{
+		"/css/site.css":      `body{background:url("../img/bg.png")}@font-face{src:url(../fonts/brand.woff2)}`,
+		"/img/bg.png":        "png",
+		"/fonts/brand.woff2": "font",
+	})
+	page := `<html><head><link rel="stylesheet" href="` + server.URL + `/css/site.css"></head><body></body></html>`
+
+	opts := Options{Concurrency: 2, ConcurrentCSSRewrite: true, CSSURLBase: "https://cdn.example.com/static/"}
+	if _, err := LocalizeAssets(page, base, opts); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	css, err := os.ReadFile("output/assets/site.css")
+	if err != nil {
+		t.Fatalf("stylesheet should be saved: %v", err)
+	}
+	for _, want := range []string{
+		`url("https://cdn.example.com/static/assets/images/bg.png")`,
+		`url(https://cdn.example.com/static/assets/fonts/brand.woff2)`,
+	} {
+		if !strings.Contains(string(css), want) {
+			t.Errorf("CSS url() should be absolute, want %s in %s", want, css)
+		}
+	}
+}
+
+func TestCSSAutoprefixLocalFonts(t *testing.T) {
+	for _, concurrentCSS := range []bool{false, true} {
+		t.Run(fmt.Sprintf("concurrent=%v", concurrentCSS), func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			if err := utils.EnsureDirectories(); err != nil {
+				t.Fatalf("Failed to create directories: %v", err)
+			}
+
+			server := testsite.New(t, map[string]string{
+				"/css/site.css":      `@font-face{font-family:Brand;src:url("../fonts/brand.woff2") format("woff2")}`,
+				"/fonts/brand.woff2": "font",
+			})
+			base, _ := url.Parse(server.URL + "/")
+			page := `<html><head><link rel="stylesheet" href="` + server.URL + `/css/site.css"></head><body></body></html>`
+
+			opts := Options{Concurrency: 2, ConcurrentCSSRewrite: concurrentCSS, FontURLPrefix: "/static/fonts"}
+			if _, err := LocalizeAssets(page, base, opts); err != nil {
+				t.Fatalf("LocalizeAssets returned error: %v", err)
+			}
+
+			css, err := os.ReadFile("output/assets/site.css")
+			if err != nil {
+				t.Fatalf("stylesheet should be saved: %v", err)
+			}
+			if !strings.Contains(string(css), `url("/static/fonts/brand.woff2")`) {
+				t.Errorf("font url() should use the configured prefix: %s", css)
+			}
+			if _, err := os.Stat("output/assets/fonts/brand.woff2"); err != nil {
+				t.Errorf("font should still be saved locally: %v", err)
+			}
+		})
+	}
+}
+
+func TestExcludeExtensionsCoversStylesheetsAndSourceMaps(t *testing.T) {
+	for _, concurrentCSS := range []bool{false, true} {
+		t.Run(fmt.Sprintf("concurrent-css=%v", concurrentCSS), func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			if err := utils.EnsureDirectories(); err != nil {
+				t.Fatalf("Failed to create directories: %v", err)
+			}
+
+			var excludedRequests int32
+			routes := map[string]string{
+				"/css/site.css":  `.hero { background: url(../img/hero.webp) } .logo { background: url(../img/logo.png) }`,
+				"/js/app.js":     "console.log(1);\n//# sourceMappingURL=app.js.map",
+				"/js/app.js.map": `{"version":3,"sources":["src/app.js"],"sourcesContent":["import bg from '/img/map.webp';"]}`,
+			}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, ".webp") {
+					atomic.AddInt32(&excludedRequests, 1)
+				}
+				if body, ok := routes[r.URL.Path]; ok {
+					w.Write([]byte(body))
+					return
+				}
+				w.Write([]byte("png"))
+			}))
+			defer server.Close()
+
+			base, _ := url.Parse(server.URL + "/")
+			page := `<html><head><link rel="stylesheet" href="/css/site.css"><script src="/js/app.js"></script></head><body></body></html>`
+			opts := Options{Concurrency: 2, ParseSourceMaps: true, ConcurrentCSSRewrite: concurrentCSS, ExcludeExtensions: []string{"webp"}}
+			if _, err := LocalizeAssets(page, base, opts); err != nil {
+				t.Fatalf("LocalizeAssets returned error: %v", err)
+			}
+
+			if n := atomic.LoadInt32(&excludedRequests); n != 0 {
+				t.Errorf("excluded .webp assets should not be requested, got %d requests", n)
+			}
+			css, _ := os.ReadFile("output/assets/site.css")
+			if strings.Contains(string(css), "images/hero.webp") {
+				t.Errorf("excluded stylesheet image should stay remote: %s", css)
+			}
+			if !strings.Contains(string(css), "images/logo.png") {
+				t.Errorf("other stylesheet images should still be localized: %s", css)
+			}
+		})
+	}
+}
+
+func TestNestedCSSImportResolvesAgainstDeepestSheet(t *testing.T) {
+	chdirOutput(t)
+
+	server := testsite.New(t, map[string]string{
+		"/css/main.css":                    `@import "parts/base.css"; body { margin: 0; }`,
+		"/css/parts/base.css":              `@import url(deep/type.css) screen; h1 { color: red; }`,
+		"/css/parts/deep/type.css":         `@font-face { font-family: Deep; src: url("files/deep.woff2") format("woff2"); } @import "../../main.css";`,
+		"/css/parts/deep/files/deep.woff2": "woff2",
+		"/css/parts/files/deep.woff2":      "wrong",
+		"/css/files/deep.woff2":            "wrong",
+		"/files/deep.woff2":                "wrong",
+	})
+	base, _ := url.Parse(server.URL + "/blog/")
+	page := `<html><head><link rel="stylesheet" href="/css/main.css"></head></html>`
+
+	if _, err := LocalizeAssets(page, base, Options{Concurrency: 4, ConcurrentCSSRewrite: true}); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	font, err := os.ReadFile("output/assets/fonts/deep.woff2")
+	if err != nil || string(font) != "woff2" {
+		t.Fatalf("font should resolve against the deepest sheet, got %q (%v)", font, err)
+	}
+	for file, want := range map[string]string{
+		"output/assets/main.css": `@import "base.css";`,
+		"output/assets/base.css": `@import url(type.css) screen;`,
+		"output/assets/type.css": `src: url("fonts/deep.woff2")`,
+	} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("%s should be saved: %v", file, err)
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in %s, got: %s", want, file, data)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package assets
+
+import "strings"
+
+// scanCSSURLRefs tokenizes a stylesheet and calls visit with the byte range of every url() reference,
+// image-set() string candidate, and bare-string @import target, excluding quotes and surrounding whitespace. Because it walks
+// tokens instead of matching declarations, references nested in @media, @supports, and nested rules
+// are found at any depth, and comments or quoted strings containing ")" or "}" cannot derail it.
+func scanCSSURLRefs(css string, visit func(start, end int)) {
+	scanCSSRefs(css, func(start, end int, _ bool) {
+		visit(start, end)
+	})
+}
+
+// scanCSSRefs is scanCSSURLRefs that also reports @import targets, given either as url() or as a
+// bare string (@import "vars.css"), flagging them so callers can treat them as stylesheets
+func scanCSSRefs(css string, visit func(start, end int, isImport bool)) {
+	var funcs []string // open function names, innermost last
+	importing := false // between @import and its target
+	for i := 0; i < len(css); {
+		c := css[i]
+		switch {
+		case strings.HasPrefix(css[i:], "/*"):
+			end := strings.Index(css[i+2:], "*/")
+			if end < 0 {
+				return
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			end := cssStringEnd(css, i)
+			if n := len(funcs); n > 0 && (funcs[n-1] == "image-set" || funcs[n-1] == "-webkit-image-set") {
+				visit(i+1, end, false)
+			} else if importing && end > i+1 {
+				visit(i+1, end, true)
+			}
+			importing = false
+			i = end + 1
+		case c == '@':
+			start := i + 1
+			i++
+			for i < len(css) && isCSSNameByte(css[i]) {
+				i++
+			}
+			importing = strings.EqualFold(css[start:i], "import")
+		case c == ';' || c == '{':
+			importing = false
+			i++
+		case c == '\\':
+			i += 2
+		case c == '(':
+			funcs = append(funcs, "")
+			i++
+		case c == ')':
+			if len(funcs) > 0 {
+				funcs = funcs[:len(funcs)-1]
+			}
+			i++
+		case isCSSNameByte(c):
+			start := i
+			for i < len(css) && isCSSNameByte(css[i]) {
+				i++
+			}
+			if i >= len(css) || css[i] != '(' {
+				continue
+			}
+			name := strings.ToLower(css[start:i])
+			i++
+			if name != "url" {
+				funcs = append(funcs, name)
+				continue
+			}
+			isImport := importing
+			importing = false
+			i = scanCSSURLArg(css, i, func(start, end int) {
+				visit(start, end, isImport)
+			})
+		default:
+			i++
+		}
+	}
+}
+
+// scanCSSURLArg visits the argument of a url( whose opening parenthesis ends just before i and
+// returns the index after its closing parenthesis
+func scanCSSURLArg(css string, i int, visit func(start, end int)) int {
+	for i < len(css) && isCSSSpace(css[i]) {
+		i++
+	}
+	if i < len(css) && (css[i] == '"' || css[i] == '\'') {
+		end := cssStringEnd(css, i)
+		if end > i+1 {
+			visit(i+1, end)
+		}
+		i = end + 1
+	} else {
+		start := i
+		for i < len(css) && css[i] != ')' && !isCSSSpace(css[i]) {
+			if css[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		i = min(i, len(css))
+		if i > start {
+			visit(start, i)
+		}
+	}
+	if closing := strings.IndexByte(css[i:], ')'); closing >= 0 {
+		return i + closing + 1
+	}
+	return len(css)
+}
+
+// cssStringEnd returns the index of the quote closing the string that opens at start, or the end
+// of the line or input for an unterminated string
+func cssStringEnd(css string, start int) int {
+	quote := css[start]
+	i := start + 1
+	for i < len(css) && css[i] != quote && css[i] != '\n' {
+		if css[i] == '\\' {
+			i++
+		}
+		i++
+	}
+	return min(i, len(css))
+}
+
+func isCSSNameByte(c byte) bool {
+	return c == '-' || c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || c >= 0x80
+}
+
+func isCSSSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// cssURLRefs returns every reference scanCSSURLRefs finds in a stylesheet, as written
+func cssURLRefs(css string) []string {
+	var refs []string
+	scanCSSURLRefs(css, func(start, end int) {
+		refs = append(refs, css[start:end])
+	})
+	return refs
+}
+
+// cssRefs returns every reference found by scanCSSRefs, as written, mapped to whether it is an
+// @import target
+func cssRefs(css string) map[string]bool {
+	refs := make(map[string]bool)
+	scanCSSRefs(css, func(start, end int, isImport bool) {
+		refs[css[start:end]] = refs[css[start:end]] || isImport
+	})
+	return refs
+}
+
+// rewriteCSSURLRefs replaces each reference found by scanCSSRefs with replace's result, leaving
+// the quotes, whitespace, and everything else in the stylesheet untouched
+func rewriteCSSURLRefs(css string, replace func(ref string) (string, bool)) string {
+	var b strings.Builder
+	last := 0
+	scanCSSRefs(css, func(start, end int, _ bool) {
+		if target, ok := replace(css[start:end]); ok {
+			b.WriteString(css[last:start])
+			b.WriteString(target)
+			last = end
+		}
+	})
+	b.WriteString(css[last:])
+	return b.String()
+}
@@ -0,0 +1,41 @@
+package assets
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCSSURLsInNestedAtRules(t *testing.T) {
+	server, base := newTestSite(t, map[string]string{
+		"/css/theme.css":      `/* url(commented.png) } */@media (min-width: 600px){@supports (display:grid){.hero{background:url( "../img/wide.jpg" )}}}`,
+		"/img/wide.jpg":       "wide",
+		"/img/hero-small.jpg": "small",
+	})
+	page := `<html><head><link rel="stylesheet" href="` + server.URL + `/css/theme.css">` +
+		`<style>@media (max-width: 600px){.hero{&:hover{background:url('/img/hero-small.jpg')}}}</style></head><body></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 2, ConcurrentCSSRewrite: true})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	if !strings.Contains(result, `url('assets/images/hero-small.jpg')`) {
+		t.Errorf("url() inside an inline @media block should be localized: %s", result)
+	}
+
+	css, err := os.ReadFile("output/assets/theme.css")
+	if err != nil {
+		t.Fatalf("stylesheet should be written: %v", err)
+	}
+	if !strings.Contains(string(css), `url( "images/wide.jpg" )`) {
+		t.Errorf("url() nested in @media/@supports should be rewritten: %s", css)
+	}
+	if !strings.Contains(string(css), "url(commented.png)") {
+		t.Errorf("references inside comments should be left alone: %s", css)
+	}
+	for _, path := range []string{"output/assets/images/wide.jpg", "output/assets/images/hero-small.jpg"} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("nested CSS image should be downloaded to %s: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package assets
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// isJSONType reports whether a MIME type is application/json or a +json variant such as application/ld+json
+func isJSONType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// dataFilename names a saved JSON response after its whole URL path, since REST routes such as
+// /wp-json/wp/v2/posts and /wp-json/wc/v3/posts share their last segment. A query string adds a short
+// hash so ?page=1 and ?page=2 are kept apart.
+func dataFilename(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	name := strings.Trim(strings.TrimSuffix(u.Path, ".json"), "/")
+	name = strings.ReplaceAll(name, "/", "-")
+	if name == "" {
+		name = "index"
+	}
+	if u.RawQuery != "" {
+		sum := sha1.Sum([]byte(u.RawQuery))
+		name += "-" + hex.EncodeToString(sum[:4])
+	}
+	return name + ".json", nil
+}
+
+// downloadData saves a JSON fetch preload to output/assets/data/, rejecting responses that are not
+// JSON (e.g. a login page served in place of a protected endpoint)
+func (cd *ConcurrentDownloader) downloadData(dataURL string) (string, error) {
+	data, header, err := cd.fetch(dataURL)
+	if err != nil {
+		return "", err
+	}
+	if contentType := header.Get("Content-Type"); !isJSONType(contentType) {
+		return "", fmt.Errorf("fetch preload is not JSON: %s", contentType)
+	}
+
+	localPath, _, err := cd.localPathFor("data", dataURL)
+	if err != nil {
+		return "", err
+	}
+
+	// Ensure output/assets/data directory exists
+	cd.Output.MkdirAll(cd.outputDir() + "/assets/data/")
+
+	return cd.writeFetched(localPath, data, header)
+}
+
+// rewriteInlineDataFetches points inline scripts at the saved copies of JSON fetch preloads. Besides
+// the href as written (already handled by updateHTMLWithLocalPaths), scripts tend to use the absolute,
+// protocol-relative, root-relative, or JSON-escaped URL, or a wp.apiFetch({ path }) REST route.
+func rewriteInlineDataFetches(htmlContent string, base *url.URL, dataPaths map[string]string, opts Options) (string, error) {
+	if len(dataPaths) == 0 {
+		return htmlContent, nil
+	}
+
+	localByRef := make(map[string]string)
+	var apiFetchRes []*regexp.Regexp
+	var apiFetchLocals []string
+	for dataURL, localPath := range dataPaths {
+		u, err := url.Parse(dataURL)
+		if err != nil {
+			continue
+		}
+		local := pageRootPrefix(opts.PagePath) + outRelative(localPath, opts.outputDir())
+
+		refs := []string{dataURL, "//" + u.Host + u.RequestURI()}
+		if strings.EqualFold(u.Host, base.Host) {
+			refs = append(refs, u.RequestURI())
+		}
+		for _, ref := range refs {
+			localByRef[ref] = local
+			localByRef[strings.ReplaceAll(ref, "/", `\/`)] = local
+		}
+
+		if _, route, ok := strings.Cut(u.RequestURI(), "/wp-json"); ok && route != "" {
+			apiFetchRes = append(apiFetchRes, regexp.MustCompile(`(apiFetch\(\s*\{\s*)path(\s*:\s*)(['"])`+regexp.QuoteMeta(route)+`(['"])`))
+			apiFetchLocals = append(apiFetchLocals, local)
+		}
+	}
+
+	// Longest first so an absolute URL is never split by its own root-relative path
+	refs := make([]string, 0, len(localByRef))
+	for ref := range localByRef {
+		refs = append(refs, regexp.QuoteMeta(ref))
+	}
+	sort.Slice(refs, func(i, j int) bool { return len(refs[i]) > len(refs[j]) })
+	// Only whole string literals are replaced, so /posts never rewrites part of /posts/123
+	quotedRe := regexp.MustCompile("(['\"`])(" + strings.Join(refs, "|") + ")(['\"`])")
+
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", err
+	}
+
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "script" && getAttr(n, "src") == "" {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type != html.TextNode {
+					continue
+				}
+				c.Data = quotedRe.ReplaceAllStringFunc(c.Data, func(match string) string {
+					parts := quotedRe.FindStringSubmatch(match)
+					if parts[1] != parts[3] {
+						return match
+					}
+					return parts[1] + localByRef[parts[2]] + parts[3]
+				})
+				for i, re := range apiFetchRes {
+					c.Data = re.ReplaceAllString(c.Data, "${1}url${2}${3}"+apiFetchLocals[i]+"${4}")
+				}
+			}
+			return
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+
+	traverse(doc)
+
+	var buf strings.Builder
+	if err := html.Render(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
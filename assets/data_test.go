@@ -0,0 +1,60 @@
+package assets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJSONFetchPreloadSaved(t *testing.T) {
+	chdirOutput(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/wp-json/wp/v2/posts", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.Write([]byte(`[{"id":1}]`))
+	})
+	mux.HandleFunc("/search.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	base, _ := url.Parse(server.URL + "/blog/")
+	page := `<html><head><link rel="preload" as="fetch" type="application/json" href="../wp-json/wp/v2/posts?per_page=3" crossorigin>` +
+		`<link rel="preload" as="fetch" type="text/html" href="/search.html"></head><body><script>` +
+		`fetch("` + server.URL + `/wp-json/wp/v2/posts?per_page=3").then(r => r.json());` +
+		`wp.apiFetch({ path: '/wp/v2/posts?per_page=3' });` +
+		`fetch('/wp-json/wp/v2/posts?per_page=3&page=2');` +
+		`</script></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	matches, _ := filepath.Glob("output/assets/data/wp-json-wp-v2-posts-*.json")
+	if len(matches) != 1 {
+		t.Fatalf("JSON preload should be saved under output/assets/data/, got %v", matches)
+	}
+	local := strings.TrimPrefix(filepath.ToSlash(matches[0]), "output/")
+	for _, want := range []string{
+		`href="` + local + `"`,
+		`fetch("` + local + `")`,
+		`wp.apiFetch({ url: '` + local + `' })`,
+		`fetch('/wp-json/wp/v2/posts?per_page=3&page=2')`,
+		`href="/search.html"`,
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %s in output: %s", want, result)
+		}
+	}
+	if _, err := os.Stat("output/assets/search.html.css"); !os.IsNotExist(err) {
+		t.Error("non-JSON fetch preloads should not be saved")
+	}
+}
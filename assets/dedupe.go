@@ -0,0 +1,102 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"sync"
+)
+
+// DedupeStats summarizes downloads collapsed because their content matched an earlier file
+type DedupeStats struct {
+	Duplicates int   `json:"duplicates"`
+	BytesSaved int64 `json:"bytes_saved"`
+}
+
+// contentRegistry maps content hashes to the first file saved with that content
+type contentRegistry struct {
+	mu    sync.Mutex
+	paths map[string]*contentEntry
+	stats DedupeStats
+}
+
+// contentEntry is the file holding one content hash; done is closed once its write finishes
+type contentEntry struct {
+	path    string
+	done    chan struct{}
+	written bool
+}
+
+// newContentRegistry creates an empty content-hash registry
+func newContentRegistry() *contentRegistry {
+	return &contentRegistry{paths: make(map[string]*contentEntry)}
+}
+
+// contentKey identifies data saved in localPath's directory
+func contentKey(data []byte, localPath string) string {
+	sum := sha256.Sum256(data)
+	return filepath.Dir(localPath) + "|" + hex.EncodeToString(sum[:])
+}
+
+// claim returns the path of an identical file already written in the same directory, waiting for
+// one that is still being written. Otherwise localPath becomes the canonical copy and the caller
+// must report whether its write succeeded with settle; a failed write is forgotten, so a later
+// duplicate writes its own file instead of pointing at one that does not exist.
+func (r *contentRegistry) claim(data []byte, localPath string) (existing string, duplicate bool, settle func(written bool)) {
+	key := contentKey(data, localPath)
+	r.mu.Lock()
+	for {
+		entry, ok := r.paths[key]
+		if !ok {
+			entry = &contentEntry{path: localPath, done: make(chan struct{})}
+			r.paths[key] = entry
+			r.mu.Unlock()
+			return localPath, false, func(written bool) {
+				r.mu.Lock()
+				entry.written = written
+				if !written {
+					delete(r.paths, key)
+				}
+				r.mu.Unlock()
+				close(entry.done)
+			}
+		}
+		if !entry.written {
+			r.mu.Unlock()
+			<-entry.done
+			r.mu.Lock()
+			continue
+		}
+		r.count(entry.path, localPath, data)
+		r.mu.Unlock()
+		return entry.path, true, nil
+	}
+}
+
+// record notes a file that was written, counting it when an identical file is already saved in
+// the same directory. It is used to report duplicates without collapsing them.
+func (r *contentRegistry) record(data []byte, localPath string) {
+	key := contentKey(data, localPath)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry, ok := r.paths[key]; ok {
+		r.count(entry.path, localPath, data)
+		return
+	}
+	r.paths[key] = &contentEntry{path: localPath, written: true}
+}
+
+// count adds a duplicate of existing saved as localPath to the stats; r.mu must be held
+func (r *contentRegistry) count(existing, localPath string, data []byte) {
+	if existing != localPath {
+		r.stats.Duplicates++
+		r.stats.BytesSaved += int64(len(data))
+	}
+}
+
+// Stats returns a snapshot of the duplicates detected so far
+func (r *contentRegistry) Stats() DedupeStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
@@ -0,0 +1,114 @@
+package assets
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"wp-static-scraper/internal/testsite"
+)
+
+func TestDedupeReportCountsDuplicates(t *testing.T) {
+	logo := strings.Repeat("L", 64)
+	server, base := newTestSite(t, map[string]string{
+		"/a/logo.png":   logo,
+		"/b/logo-2.png": logo,
+		"/c/logo-3.png": logo,
+		"/d/other.png":  "different",
+	})
+	page := `<html><body>` +
+		`<img src="` + server.URL + `/a/logo.png">` +
+		`<img src="` + server.URL + `/b/logo-2.png">` +
+		`<img src="` + server.URL + `/c/logo-3.png">` +
+		`<img src="` + server.URL + `/d/other.png">` +
+		`</body></html>`
+
+	opts := Options{Concurrency: 1, Dedupe: true, DedupeReport: true, ManifestPath: "output/manifest.json"}
+	result, err := LocalizeAssets(page, base, opts)
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	data, err := os.ReadFile("output/manifest.json")
+	if err != nil {
+		t.Fatalf("manifest was not written: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("manifest is not valid JSON: %v", err)
+	}
+	if manifest.Dedupe == nil {
+		t.Fatal("manifest should include the dedupe report")
+	}
+	if manifest.Dedupe.Duplicates != 2 || manifest.Dedupe.BytesSaved != int64(2*len(logo)) {
+		t.Errorf("dedupe report = %+v; want 2 duplicates and %d bytes saved", *manifest.Dedupe, 2*len(logo))
+	}
+
+	// All identical images point at the single saved copy
+	if strings.Count(result, `src="assets/images/logo.png"`) != 3 {
+		t.Errorf("identical images should share one local file, got %s", result)
+	}
+	if _, err := os.Stat("output/assets/images/logo-2.png"); !os.IsNotExist(err) {
+		t.Error("duplicate content should not be written a second time")
+	}
+}
+
+func TestDedupeReportAloneKeepsEveryFile(t *testing.T) {
+	logo := strings.Repeat("L", 64)
+	server, base := newTestSite(t, map[string]string{
+		"/a/logo.png":   logo,
+		"/b/logo-2.png": logo,
+	})
+	page := `<html><body><img src="` + server.URL + `/a/logo.png"><img src="` + server.URL + `/b/logo-2.png"></body></html>`
+
+	opts := Options{Concurrency: 1, DedupeReport: true, ManifestPath: "output/manifest.json"}
+	result, err := LocalizeAssets(page, base, opts)
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	data, _ := os.ReadFile("output/manifest.json")
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("manifest is not valid JSON: %v", err)
+	}
+	if manifest.Dedupe == nil || manifest.Dedupe.Duplicates != 1 || manifest.Dedupe.BytesSaved != int64(len(logo)) {
+		t.Errorf("dedupe report = %+v; want 1 duplicate and %d bytes", manifest.Dedupe, len(logo))
+	}
+	if !strings.Contains(result, `src="assets/images/logo-2.png"`) {
+		t.Errorf("-dedupe-report alone should not collapse references, got %s", result)
+	}
+	if _, err := os.Stat("output/assets/images/logo-2.png"); err != nil {
+		t.Errorf("-dedupe-report alone should still write every file: %v", err)
+	}
+}
+
+func TestDedupeSkipsFailedFirstWrite(t *testing.T) {
+	chdirOutput(t)
+	// A directory in the way makes writing the first copy fail
+	if err := os.MkdirAll("output/assets/images/logo.png", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	logo := strings.Repeat("L", 64)
+	server := testsite.New(t, map[string]string{
+		"/a/logo.png":   logo,
+		"/b/logo-2.png": logo,
+	})
+	base, _ := url.Parse(server.URL + "/")
+	page := `<html><body><img src="` + server.URL + `/a/logo.png"><img src="` + server.URL + `/b/logo-2.png"></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 1, Dedupe: true})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	if !strings.Contains(result, `src="assets/images/logo-2.png"`) {
+		t.Errorf("the duplicate should be saved itself when the first copy failed to write, got %s", result)
+	}
+	if data, err := os.ReadFile("output/assets/images/logo-2.png"); err != nil || string(data) != logo {
+		t.Errorf("logo-2.png = %q, %v; want the duplicate content", data, err)
+	}
+}
@@ -5,42 +5,40 @@ import (
 	"io"
 	"net/http"
 	"net/url"
-	"os"
+	"path"
 	"strings"
 
 	"wp-static-scraper/utils"
 )
 
-// DownloadResource downloads a resource (CSS, JS) and saves it locally
-func DownloadResource(resourceURL, ext string, base *url.URL) (string, error) {
-	resp, err := http.Get(resourceURL)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("bad status: %s", resp.Status)
-	}
-	data, err := io.ReadAll(resp.Body)
+// DownloadResource downloads a resource (CSS, JS) and saves it under outDir
+func DownloadResource(resourceURL, ext string, base *url.URL, outDir string) (string, error) {
+	return downloadResourceFile(resourceURL, ext, base, assetLayout{outDir: outDir}, Options{}.fetcher())
+}
+
+// downloadResourceFile is DownloadResource saving the resource, and the fonts and stylesheets it
+// references, under layout, downloading each with fetch
+func downloadResourceFile(resourceURL, ext string, base *url.URL, layout assetLayout, fetch fetchFunc) (string, error) {
+	data, err := fetch(resourceURL)
 	if err != nil {
 		return "", err
 	}
 
-	u, err := url.Parse(resourceURL)
+	localPath, _, err := layout.pathFor(ext, resourceURL)
 	if err != nil {
 		return "", err
 	}
-	segments := strings.Split(u.Path, "/")
-	filename := segments[len(segments)-1]
-	if !strings.HasSuffix(filename, "."+ext) {
-		filename = filename + "." + ext
-	}
-	localPath := "output/assets/" + filename
 
-	// If CSS, also localize font URLs and remove source maps
+	// If CSS, also localize image and font URLs, relative to the stylesheet, and remove source maps
 	if ext == "css" {
-		cssContent := string(data)
-		cssContent, err = LocalizeFontURLs(cssContent, base)
+		sheetURL, err := url.Parse(resourceURL)
+		if err != nil {
+			sheetURL = base
+		}
+		cssContent := localizeCSSAssetURLs(string(data), sheetURL, path.Dir(localPath), func(imageURL string) (string, error) {
+			return downloadCSSImage(imageURL, layout, fetch)
+		})
+		cssContent, err = localizeFontURLs(cssContent, sheetURL, path.Dir(localPath), layout, fetch)
 		if err != nil {
 			return "", err
 		}
@@ -53,7 +51,7 @@ func DownloadResource(resourceURL, ext string, base *url.URL) (string, error) {
 	if ext == "js" {
 		jsContent := string(data)
 		// Process JavaScript for embedded resource URLs (like template CSS files)
-		jsContent, err = LocalizeJavaScriptURLs(jsContent, base)
+		jsContent, err = localizeJavaScriptURLs(jsContent, base, layout, fetch)
 		if err != nil {
 			return "", err
 		}
@@ -62,15 +60,57 @@ func DownloadResource(resourceURL, ext string, base *url.URL) (string, error) {
 		data = []byte(jsContent)
 	}
 
-	err = os.WriteFile(localPath, data, 0644)
-	if err != nil {
+	return writeLaidOut(localPath, data, layout)
+}
+
+// writeLaidOut saves a serially downloaded file, naming it after its content when the layout
+// hashes names and creating the mirrored directories it needs
+func writeLaidOut(localPath string, data []byte, layout assetLayout) (string, error) {
+	localPath = layout.contentPath(localPath, data)
+	if layout.preservePaths {
+		if err := layout.output.MkdirAll(path.Dir(localPath)); err != nil {
+			return "", err
+		}
+	}
+	if err := layout.output.WriteFile(localPath, data); err != nil {
 		return "", err
 	}
 	return localPath, nil
 }
 
-// DownloadImage downloads an image and saves it locally
-func DownloadImage(imageURL string) (string, error) {
+// downloadScriptFile saves a script referenced from other JavaScript with only its source map
+// reference removed. It is not scanned for further URLs, so scripts that reference each other
+// cannot recurse.
+func downloadScriptFile(scriptURL string, layout assetLayout, fetch fetchFunc) (string, error) {
+	data, err := fetch(scriptURL)
+	if err != nil {
+		return "", err
+	}
+
+	localPath, _, err := layout.pathFor("js", scriptURL)
+	if err != nil {
+		return "", err
+	}
+	data = []byte(utils.RemoveSourceMapReferences(string(data)))
+	return writeLaidOut(localPath, data, layout)
+}
+
+// downloadCSSImage saves an image referenced from a serially downloaded stylesheet under layout
+func downloadCSSImage(imageURL string, layout assetLayout, fetch fetchFunc) (string, error) {
+	data, err := fetch(imageURL)
+	if err != nil {
+		return "", err
+	}
+
+	localPath, _, err := layout.pathFor("image", imageURL)
+	if err != nil {
+		return "", err
+	}
+	return writeLaidOut(localPath, data, layout)
+}
+
+// DownloadImage downloads an image and saves it under outDir
+func DownloadImage(imageURL, outDir string) (string, error) {
 	resp, err := http.Get(imageURL)
 	if err != nil {
 		return "", err
@@ -111,9 +151,9 @@ func DownloadImage(imageURL string) (string, error) {
 		}
 	}
 
-	localPath := "output/assets/images/" + filename
+	localPath := outDir + "/assets/images/" + filename
 
-	err = os.WriteFile(localPath, data, 0644)
+	err = utils.WriteFile(localPath, data)
 	if err != nil {
 		return "", err
 	}
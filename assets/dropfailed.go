@@ -0,0 +1,75 @@
+package assets
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// failedReferences returns the references, as written in the page, of assets that failed to download.
+// Assets left remote on purpose (over the byte budget or size cap, or after an abort) are not broken and are excluded.
+func failedReferences(results []DownloadResult, aliasPaths map[string]string) map[string]bool {
+	failed := make(map[string]bool)
+	for _, result := range results {
+		if result.Success || errors.Is(result.Error, ErrBudgetExceeded) || errors.Is(result.Error, ErrTooLarge) || errors.Is(result.Error, context.Canceled) {
+			continue
+		}
+		failed[result.Job.OriginalPath] = true
+	}
+	for aliasPath, keptPath := range aliasPaths {
+		if failed[keptPath] {
+			failed[aliasPath] = true
+		}
+	}
+	return failed
+}
+
+// dropFailedReferences removes <script> and <link> elements whose asset failed to download, so the
+// snapshot does not log errors for them, and blanks any other src, href, or poster that points at one
+func dropFailedReferences(htmlContent string, failed map[string]bool) (string, error) {
+	if len(failed) == 0 {
+		return htmlContent, nil
+	}
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", err
+	}
+
+	var removed []*html.Node
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch {
+			case n.Data == "script" && failed[getAttr(n, "src")]:
+				removed = append(removed, n)
+				return
+			case n.Data == "link" && failed[getAttr(n, "href")]:
+				removed = append(removed, n)
+				return
+			}
+			for i, attr := range n.Attr {
+				if (attr.Key == "src" || attr.Key == "href" || attr.Key == "poster") && failed[attr.Val] {
+					n.Attr[i].Val = ""
+				}
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+
+	traverse(doc)
+
+	for _, n := range removed {
+		n.Parent.RemoveChild(n)
+	}
+
+	var buf strings.Builder
+	if err := html.Render(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,39 @@
+package assets
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDropFailedReferences(t *testing.T) {
+	_, base := newTestSite(t, map[string]string{
+		"/js/app.js": "console.log('app');",
+	})
+	page := `<html><head><script src="/js/app.js"></script><script src="/js/missing.js"></script>` +
+		`<link rel="stylesheet" href="/css/missing.css"></head>` +
+		`<body><img src="/img/missing.png" alt="Gone"></body></html>`
+
+	kept, err := LocalizeAssets(page, base, Options{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	if !strings.Contains(kept, `src="/js/missing.js"`) {
+		t.Errorf("failed script should stay remote by default: %s", kept)
+	}
+
+	dropped, err := LocalizeAssets(page, base, Options{Concurrency: 2, DropFailedRefs: true})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	for _, gone := range []string{"missing.js", "missing.css", "missing.png"} {
+		if strings.Contains(dropped, gone) {
+			t.Errorf("reference to %s should be dropped: %s", gone, dropped)
+		}
+	}
+	if !strings.Contains(dropped, `src="assets/app.js"`) {
+		t.Errorf("downloaded script should be kept: %s", dropped)
+	}
+	if !strings.Contains(dropped, `<img src="" alt="Gone"/>`) {
+		t.Errorf("failed image src should be blanked: %s", dropped)
+	}
+}
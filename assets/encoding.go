@@ -0,0 +1,53 @@
+package assets
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// brotliAcceptEncoding is sent when Brotli is enabled. Setting Accept-Encoding ourselves turns off
+// the transport's transparent gzip, so decodeBody then handles all three encodings.
+const brotliAcceptEncoding = "br, gzip, deflate"
+
+// setAcceptEncoding asks for Brotli-compressed responses when encoding is "br" (no-op otherwise,
+// leaving Go's built-in gzip negotiation in place)
+func setAcceptEncoding(req *http.Request, encoding string) {
+	if encoding == "br" {
+		req.Header.Set("Accept-Encoding", brotliAcceptEncoding)
+	}
+}
+
+// decodeBody returns a reader over the decompressed response body according to its
+// Content-Encoding. Bodies the transport already decompressed are returned as is.
+func decodeBody(resp *http.Response) (io.Reader, error) {
+	if resp.Uncompressed {
+		return resp.Body, nil
+	}
+	switch encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return resp.Body, nil
+	case "br":
+		return brotli.NewReader(resp.Body), nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(resp.Body)
+	case "deflate":
+		return zlib.NewReader(resp.Body)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+}
+
+// readBody reads a whole response body, decompressing it first
+func readBody(resp *http.Response) ([]byte, error) {
+	body, err := decodeBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(body)
+}
@@ -0,0 +1,47 @@
+package assets
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestBrotliEncodedCSS(t *testing.T) {
+	const css = "body { color: #333; }"
+	var compressed bytes.Buffer
+	bw := brotli.NewWriter(&compressed)
+	bw.Write([]byte(css))
+	bw.Close()
+
+	var acceptEncoding atomic.Value
+	_, base := newTestSiteHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding.Store(r.Header.Get("Accept-Encoding"))
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "br") {
+			w.Write([]byte(css))
+			return
+		}
+		w.Header().Set("Content-Type", "text/css")
+		w.Header().Set("Content-Encoding", "br")
+		w.Write(compressed.Bytes())
+	}))
+	page := `<html><head><link rel="stylesheet" href="/css/style.css"></head></html>`
+	if _, err := LocalizeAssets(page, base, Options{Concurrency: 1, AcceptEncoding: "br"}); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	if got, _ := acceptEncoding.Load().(string); !strings.Contains(got, "br") {
+		t.Errorf("expected br in Accept-Encoding, got %q", got)
+	}
+	data, err := os.ReadFile("output/assets/style.css")
+	if err != nil {
+		t.Fatalf("style.css should be saved: %v", err)
+	}
+	if string(data) != css {
+		t.Errorf("expected decoded CSS %q, got %q", css, data)
+	}
+}
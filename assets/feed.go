@@ -0,0 +1,103 @@
+package assets
+
+import (
+	"errors"
+	"html"
+	"mime"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"wp-static-scraper/utils"
+)
+
+// enclosureURLRe matches the url attribute of RSS <enclosure> and Media RSS <media:content>/<media:thumbnail>
+var enclosureURLRe = regexp.MustCompile(`<(?:enclosure|media:content|media:thumbnail)\b[^>]*?\surl=["']([^"']+)["']`)
+
+// IsFeedContentType reports whether a top-level Content-Type is an XML document such as an RSS or Atom
+// feed, which must be saved raw because the HTML parser would mangle it
+func IsFeedContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "application/xml", "text/xml", "application/rss+xml", "application/atom+xml":
+		return true
+	}
+	return false
+}
+
+// SaveFeed writes an XML document to outPath byte for byte. With localizeEnclosures set, enclosure
+// and media URLs are downloaded to output/assets/media/ and rewritten to point at the local copies;
+// everything else in the document is left untouched. When opts.Deadline cuts the downloads short the
+// partially localized feed is still written and ErrRuntimeExceeded returned.
+func SaveFeed(feed []byte, base *url.URL, outPath string, localizeEnclosures bool, opts Options) error {
+	var runErr error
+	if localizeEnclosures {
+		var localized string
+		localized, runErr = localizeFeedEnclosures(string(feed), base, opts)
+		if runErr != nil && !errors.Is(runErr, ErrRuntimeExceeded) {
+			return runErr
+		}
+		feed = []byte(localized)
+	}
+	if err := opts.Output.WriteFile(outPath, feed); err != nil {
+		return err
+	}
+	return runErr
+}
+
+// localizeFeedEnclosures downloads the media a feed references and rewrites those URLs only
+func localizeFeedEnclosures(feed string, base *url.URL, opts Options) (string, error) {
+	var jobs []DownloadJob
+	seen := make(map[string]bool)
+	for _, match := range enclosureURLRe.FindAllStringSubmatch(feed, -1) {
+		original := match[1]
+		if seen[original] {
+			continue
+		}
+		seen[original] = true
+		jobs = append(jobs, DownloadJob{
+			URL:          utils.ResolveURL(base, html.UnescapeString(original)),
+			Type:         "media",
+			OriginalPath: original,
+			BaseURL:      base,
+		})
+	}
+	if len(jobs) == 0 {
+		return feed, nil
+	}
+
+	downloader, err := newDownloader(opts)
+	if err != nil {
+		return "", err
+	}
+	downloader.Start()
+
+	reporter := NewProgressReporter(downloader, 2*time.Second, opts.Progress)
+	reporter.Start()
+
+	go func() {
+		for _, job := range jobs {
+			downloader.AddJob(job)
+		}
+		downloader.FinishJobs()
+	}()
+
+	urlMap := downloader.GetResults()
+	reporter.Stop()
+	runErr := downloader.Err()
+	if runErr != nil && !errors.Is(runErr, ErrRuntimeExceeded) {
+		return "", runErr
+	}
+
+	// Only the attribute values are replaced, so item links and GUIDs sharing a URL stay remote
+	for original, localPath := range urlMap {
+		relativePath := outRelative(localPath, opts.outputDir())
+		feed = strings.ReplaceAll(feed, `url="`+original+`"`, `url="`+relativePath+`"`)
+		feed = strings.ReplaceAll(feed, `url='`+original+`'`, `url='`+relativePath+`'`)
+	}
+	return feed, runErr
+}
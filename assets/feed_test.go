@@ -0,0 +1,67 @@
+package assets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFeedSavedRaw(t *testing.T) {
+	chdirOutput(t)
+
+	feed := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/">
+<channel><title>Blog &amp; News</title><link>https://example.com/</link>
+<item><title>Episode 1</title><link>https://example.com/ep1/</link><description><![CDATA[<p>Show notes</p>]]></description>
+<enclosure url="/media/ep1.mp3?v=1&amp;x=2" length="3" type="audio/mpeg"/></item>
+</channel></rss>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/feed/":
+			w.Header().Set("Content-Type", "application/rss+xml; charset=UTF-8")
+			w.Write([]byte(feed))
+		case "/media/ep1.mp3":
+			w.Write([]byte("mp3"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	body, contentType, err := FetchDocument(server.URL+"/feed/", Options{})
+	if err != nil {
+		t.Fatalf("FetchDocument returned error: %v", err)
+	}
+	if !IsFeedContentType(contentType) {
+		t.Fatalf("%q should be detected as a feed", contentType)
+	}
+	if IsFeedContentType("text/html; charset=UTF-8") {
+		t.Error("text/html must not be treated as a feed")
+	}
+
+	base, _ := url.Parse(server.URL + "/feed/")
+	if err := SaveFeed(body, base, "output/feed.xml", false, Options{Concurrency: 1}); err != nil {
+		t.Fatalf("SaveFeed returned error: %v", err)
+	}
+	saved, err := os.ReadFile("output/feed.xml")
+	if err != nil {
+		t.Fatalf("feed should be saved: %v", err)
+	}
+	if string(saved) != feed {
+		t.Errorf("feed should be saved intact, got:\n%s", saved)
+	}
+
+	if err := SaveFeed(body, base, "output/feed.xml", true, Options{Concurrency: 1}); err != nil {
+		t.Fatalf("SaveFeed returned error: %v", err)
+	}
+	saved, _ = os.ReadFile("output/feed.xml")
+	if !strings.Contains(string(saved), `<enclosure url="assets/media/ep1.mp3"`) {
+		t.Errorf("enclosure should point at the local copy:\n%s", saved)
+	}
+	if !strings.Contains(string(saved), "<![CDATA[<p>Show notes</p>]]>") || !strings.Contains(string(saved), "<link>https://example.com/ep1/</link>") {
+		t.Errorf("the rest of the feed should be untouched:\n%s", saved)
+	}
+}
@@ -0,0 +1,66 @@
+package assets
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// FragmentPath names the fragment file saved next to a page, e.g. page/2/index.fragment.html, so
+// its localized asset references resolve exactly like the page's own
+func FragmentPath(pagePath string) string {
+	return strings.TrimSuffix(pagePath, ".html") + ".fragment.html"
+}
+
+// ExtractFragment returns the inner HTML of <body>, without the doctype, <html>, or <head>, for
+// embedding scraped content into another CMS. With a selector (tag, .class, #id), the first
+// matching element is returned instead, including its own tag.
+func ExtractFragment(htmlContent, selector string) (string, error) {
+	match := func(n *html.Node) bool { return n.Data == "body" }
+	if selector != "" {
+		sel, err := parseCompoundSelector(selector)
+		if err != nil {
+			return "", fmt.Errorf("fragment selector: %w", err)
+		}
+		match = sel.matches
+	}
+
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", err
+	}
+
+	var found *html.Node
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if found != nil {
+			return
+		}
+		if n.Type == html.ElementNode && match(n) {
+			found = n
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+	traverse(doc)
+
+	if found == nil {
+		return "", fmt.Errorf("no element matches %q", selector)
+	}
+
+	var buf strings.Builder
+	if selector != "" {
+		err = html.Render(&buf, found)
+	} else {
+		for c := found.FirstChild; c != nil && err == nil; c = c.NextSibling {
+			err = html.Render(&buf, c)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
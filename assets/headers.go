@@ -0,0 +1,53 @@
+package assets
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"wp-static-scraper/utils"
+)
+
+// HeaderRecord is the response status and headers captured for one fetched URL
+type HeaderRecord struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers"`
+}
+
+// headerLog collects response headers from concurrent fetches
+type headerLog struct {
+	mu      sync.Mutex
+	records map[string]HeaderRecord
+}
+
+// newHeaderLog creates an empty header log
+func newHeaderLog() *headerLog {
+	return &headerLog{records: make(map[string]HeaderRecord)}
+}
+
+// record stores the status and headers of a response, replacing any earlier attempt for the URL
+func (l *headerLog) record(rawURL string, resp *http.Response) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records[rawURL] = HeaderRecord{Status: resp.StatusCode, Headers: resp.Header.Clone()}
+}
+
+// snapshot returns a copy of the captured records
+func (l *headerLog) snapshot() map[string]HeaderRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	records := make(map[string]HeaderRecord, len(l.records))
+	for rawURL, record := range l.records {
+		records[rawURL] = record
+	}
+	return records
+}
+
+// WriteHeaderLog saves captured response headers as indented JSON keyed by URL through out
+func WriteHeaderLog(out *utils.Output, path string, records map[string]HeaderRecord) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return out.WriteFile(path, data)
+}
@@ -0,0 +1,38 @@
+package assets
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestScrapeHeadersToFile(t *testing.T) {
+	server, base := newTestSiteHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("png"))
+	}))
+	page := `<html><body><img src="` + server.URL + `/img/logo.png"></body></html>`
+
+	if _, err := LocalizeAssets(page, base, Options{Concurrency: 1, HeadersPath: "output/_headers.json"}); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	data, err := os.ReadFile("output/_headers.json")
+	if err != nil {
+		t.Fatalf("header dump should be written: %v", err)
+	}
+	var records map[string]HeaderRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		t.Fatalf("header dump should be valid JSON: %v", err)
+	}
+
+	record, ok := records[server.URL+"/img/logo.png"]
+	if !ok {
+		t.Fatalf("headers should be captured for the fetched asset, got %v", records)
+	}
+	if record.Status != http.StatusOK || record.Headers.Get("Cache-Control") != "max-age=60" {
+		t.Errorf("captured record = %+v", record)
+	}
+}
@@ -0,0 +1,66 @@
+package assets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"wp-static-scraper/internal/testsite"
+	"wp-static-scraper/utils"
+)
+
+// chdirOutput runs the rest of the test in a fresh working directory with the output/ tree created
+func chdirOutput(tb testing.TB) {
+	tb.Helper()
+	tb.Chdir(tb.TempDir())
+	if err := utils.EnsureDirectories(); err != nil {
+		tb.Fatalf("Failed to create directories: %v", err)
+	}
+}
+
+// newTestSite moves the test into a fresh output tree and serves routes from a test server,
+// returning the server and its root URL for use as the page base
+func newTestSite(t *testing.T, routes map[string]string) (*httptest.Server, *url.URL) {
+	t.Helper()
+	chdirOutput(t)
+	server := testsite.New(t, routes)
+	base, _ := url.Parse(server.URL + "/")
+	return server, base
+}
+
+// newTestSiteHandler is newTestSite for tests that need their own handler
+func newTestSiteHandler(t *testing.T, handler http.Handler) (*httptest.Server, *url.URL) {
+	t.Helper()
+	chdirOutput(t)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	base, _ := url.Parse(server.URL + "/")
+	return server, base
+}
+
+// recordingProxy is a fake forward proxy that records the URLs it was asked for
+type recordingProxy struct {
+	mu   sync.Mutex
+	urls []string
+}
+
+func (p *recordingProxy) seen() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.urls...)
+}
+
+func newRecordingProxy(t *testing.T, respond func(w http.ResponseWriter, r *http.Request)) (*httptest.Server, *recordingProxy) {
+	t.Helper()
+	rec := &recordingProxy{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec.mu.Lock()
+		rec.urls = append(rec.urls, r.URL.String())
+		rec.mu.Unlock()
+		respond(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, rec
+}
@@ -0,0 +1,152 @@
+package assets
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+	"wp-static-scraper/utils"
+)
+
+// localizeLazyIframes handles iframes deferred by performance plugins via data-src.
+// Same-origin frames are fetched, localized, and saved next to the page; cross-origin
+// frames can have data-src promoted into src so the embed loads without the plugin script.
+func localizeLazyIframes(htmlContent string, base *url.URL, opts Options) (string, error) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", err
+	}
+
+	savedFrames := make(map[string]string) // resolved URL -> local file name
+	usedNames := make(map[string]bool)
+
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "iframe" {
+			if dataSrc := getAttr(n, "data-src"); dataSrc != "" {
+				frameURL := utils.ResolveURL(base, dataSrc)
+				u, err := url.Parse(frameURL)
+				sameOrigin := err == nil && u.Host == base.Host
+
+				if sameOrigin && opts.LazyIframes {
+					localName, ok := savedFrames[frameURL]
+					if !ok {
+						localName = frameFileName(u, usedNames)
+						if err := saveFrameDocument(frameURL, localName, opts); err != nil {
+							fmt.Fprintf(opts.logWriter(), "Failed to localize iframe %s: %v\n", frameURL, err)
+							localName = ""
+						} else {
+							savedFrames[frameURL] = localName
+							usedNames[localName] = true
+						}
+					}
+					if localName != "" {
+						setAttr(n, "src", pageRootPrefix(opts.PagePath)+localName)
+						removeAttr(n, "data-src")
+					}
+				} else if !sameOrigin && opts.PromoteIframeDataSrc {
+					setAttr(n, "src", frameURL)
+					removeAttr(n, "data-src")
+				}
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+
+	traverse(doc)
+
+	var buf strings.Builder
+	if err := html.Render(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// saveFrameDocument fetches an iframe document, localizes its assets, and writes it to output/
+func saveFrameDocument(frameURL, localName string, opts Options) error {
+	body, err := opts.fetcher()(frameURL)
+	if err != nil {
+		return err
+	}
+
+	frameBase, err := url.Parse(frameURL)
+	if err != nil {
+		return err
+	}
+
+	// Frames are localized like the page itself, but never recurse into their own frames
+	// or overwrite the page's manifest, report, or header dump
+	frameOpts := opts
+	frameOpts.LazyIframes = false
+	frameOpts.ManifestPath = ""
+	frameOpts.ReportPath = ""
+	frameOpts.HeadersPath = ""
+	frameOpts.PagePath = ""
+	localized, err := LocalizeAssets(string(body), frameBase, frameOpts)
+	if err != nil {
+		return err
+	}
+
+	return opts.Output.WriteFile(opts.outputDir()+"/"+localName, utils.NormalizeLineEndings([]byte(localized), opts.LineEndings))
+}
+
+// frameFileName derives a flat, unique file name for a saved iframe document
+func frameFileName(frameURL *url.URL, usedNames map[string]bool) string {
+	slug := strings.Trim(frameURL.Path, "/")
+	slug = strings.TrimSuffix(slug, path.Ext(slug))
+	slug = strings.ReplaceAll(slug, "/", "-")
+	if slug == "" {
+		slug = "index"
+	}
+	name := fmt.Sprintf("frame-%s.html", slug)
+	for i := 2; usedNames[name]; i++ {
+		name = fmt.Sprintf("frame-%s-%d.html", slug, i)
+	}
+	return name
+}
+
+// getAttr returns the value of an attribute, or "" when absent
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// hasAttr reports whether an attribute is present, even when its value is empty
+func hasAttr(n *html.Node, key string) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// setAttr sets an attribute value, adding the attribute when missing
+func setAttr(n *html.Node, key, val string) {
+	for i, attr := range n.Attr {
+		if attr.Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+// removeAttr deletes an attribute from a node
+func removeAttr(n *html.Node, key string) {
+	for i, attr := range n.Attr {
+		if attr.Key == key {
+			n.Attr = append(n.Attr[:i], n.Attr[i+1:]...)
+			return
+		}
+	}
+}
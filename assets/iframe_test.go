@@ -0,0 +1,51 @@
+package assets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLazyIframesDataSrc(t *testing.T) {
+	var server *httptest.Server
+	server, base := newTestSiteHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/embed/video.html":
+			w.Write([]byte(`<html><body><img src="` + server.URL + `/img/thumb.png"></body></html>`))
+		case "/img/thumb.png":
+			w.Write([]byte("png"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	page := `<html><body>` +
+		`<iframe class="lazyload" src="about:blank" data-src="/embed/video.html"></iframe>` +
+		`<iframe data-src="https://player.example.com/embed/1"></iframe>` +
+		`</body></html>`
+
+	opts := Options{Concurrency: 2, LazyIframes: true, PromoteIframeDataSrc: true}
+	result, err := LocalizeAssets(page, base, opts)
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	if !strings.Contains(result, `src="frame-embed-video.html"`) {
+		t.Errorf("same-origin iframe should point at the saved frame, got %s", result)
+	}
+	if !strings.Contains(result, `src="https://player.example.com/embed/1"`) {
+		t.Errorf("cross-origin data-src should be promoted into src, got %s", result)
+	}
+	if strings.Contains(result, "data-src") {
+		t.Errorf("handled iframes should no longer defer via data-src, got %s", result)
+	}
+
+	frame, err := os.ReadFile("output/frame-embed-video.html")
+	if err != nil {
+		t.Fatalf("frame document should be saved: %v", err)
+	}
+	if !strings.Contains(string(frame), `src="assets/images/thumb.png"`) {
+		t.Errorf("frame document assets should be localized, got %s", frame)
+	}
+}
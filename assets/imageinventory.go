@@ -0,0 +1,80 @@
+package assets
+
+import (
+	"encoding/json"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	_ "golang.org/x/image/webp"
+	"wp-static-scraper/utils"
+)
+
+// ImageInfo describes one downloaded image in the image inventory
+type ImageInfo struct {
+	URL       string `json:"url"`
+	LocalPath string `json:"local_path"` // Relative to the output directory
+	Format    string `json:"format"`
+	Width     int    `json:"width,omitempty"` // Zero for vector (SVG) or undecodable images
+	Height    int    `json:"height,omitempty"`
+	Size      int64  `json:"size"`
+}
+
+// BuildImageInventory lists every downloaded image with its pixel dimensions, byte size, and format.
+// Dimensions come from the image header alone; SVGs and formats without a registered decoder are
+// listed by extension without dimensions, as are images that were only uploaded.
+func BuildImageInventory(out *utils.Output, results []DownloadResult, outDir string) []ImageInfo {
+	images := make([]ImageInfo, 0)
+	for _, result := range results {
+		if !result.Success || result.Job.Type != "image" {
+			continue
+		}
+		size, err := out.Size(result.LocalPath)
+		if err != nil {
+			continue
+		}
+		entry := ImageInfo{
+			URL:       result.Job.URL,
+			LocalPath: relativeToOutDir(result.LocalPath, outDir),
+			Format:    strings.TrimPrefix(strings.ToLower(filepath.Ext(result.LocalPath)), "."),
+			Size:      size,
+		}
+		if entry.Format != "svg" {
+			if config, format, err := decodeImageConfig(result.LocalPath); err == nil {
+				entry.Width, entry.Height, entry.Format = config.Width, config.Height, format
+			}
+		}
+		images = append(images, entry)
+	}
+
+	// Results arrive in completion order, so sort for stable output
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].URL < images[j].URL
+	})
+
+	return images
+}
+
+// decodeImageConfig reads only as much of an image file as needed for its dimensions and format
+func decodeImageConfig(path string) (image.Config, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return image.Config{}, "", err
+	}
+	defer f.Close()
+	return image.DecodeConfig(f)
+}
+
+// WriteImageInventory saves the image inventory as indented JSON through out
+func WriteImageInventory(out *utils.Output, path string, images []ImageInfo) error {
+	data, err := json.MarshalIndent(images, "", "  ")
+	if err != nil {
+		return err
+	}
+	return out.WriteFile(path, data)
+}
@@ -0,0 +1,49 @@
+package assets
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"os"
+	"testing"
+)
+
+func TestImageInventoryDimensions(t *testing.T) {
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, image.NewRGBA(image.Rect(0, 0, 37, 21))); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	_, base := newTestSite(t, map[string]string{
+		"/img/photo.png": pngData.String(),
+		"/img/logo.svg":  `<svg xmlns="http://www.w3.org/2000/svg"></svg>`,
+	})
+	page := `<html><body><img src="/img/photo.png"><img src="/img/logo.svg"></body></html>`
+
+	opts := Options{Concurrency: 2, ImageInventoryPath: "output/images.json"}
+	if _, err := LocalizeAssets(page, base, opts); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	data, err := os.ReadFile("output/images.json")
+	if err != nil {
+		t.Fatalf("image inventory should be written: %v", err)
+	}
+	var images []ImageInfo
+	if err := json.Unmarshal(data, &images); err != nil {
+		t.Fatalf("invalid image inventory: %v", err)
+	}
+	if len(images) != 2 {
+		t.Fatalf("expected 2 images, got %+v", images)
+	}
+	logo, photo := images[0], images[1]
+	if photo.Width != 37 || photo.Height != 21 || photo.Format != "png" || photo.Size != int64(pngData.Len()) {
+		t.Errorf("unexpected PNG entry: %+v", photo)
+	}
+	if photo.LocalPath != "assets/images/photo.png" {
+		t.Errorf("expected local path relative to output/, got %q", photo.LocalPath)
+	}
+	if logo.Format != "svg" || logo.Width != 0 || logo.Height != 0 {
+		t.Errorf("SVG should be listed without dimensions: %+v", logo)
+	}
+}
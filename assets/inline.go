@@ -0,0 +1,32 @@
+package assets
+
+import (
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// imageDataURI encodes image bytes as a base64 data: URI, typed by the response Content-Type
+// when it is an image type, else by the file extension or the bytes themselves
+func imageDataURI(data []byte, contentType, localPath string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if !strings.HasPrefix(mediaType, "image/") {
+		mediaType = mime.TypeByExtension(path.Ext(localPath))
+		if mediaType == "" {
+			mediaType = http.DetectContentType(data)
+		}
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// inlineSmallImages points urlMap entries for images under the inline threshold at their data: URIs,
+// so the rewrite pass embeds them instead of linking the saved files
+func inlineSmallImages(urlMap map[string]string, inlined map[string]string) {
+	for originalPath, localPath := range urlMap {
+		if dataURI, ok := inlined[localPath]; ok {
+			urlMap[originalPath] = dataURI
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package assets
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestInlineImagesBelow(t *testing.T) {
+	large := strings.Repeat("L", 4096)
+	server, base := newTestSite(t, map[string]string{
+		"/img/dot.png":   "tiny",
+		"/img/photo.jpg": large,
+	})
+	page := `<html><body><img src="` + server.URL + `/img/dot.png"><img src="` + server.URL + `/img/photo.jpg"></body></html>`
+
+	updated, err := LocalizeAssets(page, base, Options{Concurrency: 2, InlineImagesBelow: 1024})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	dataURI := "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("tiny"))
+	if !strings.Contains(updated, `src="`+dataURI+`"`) {
+		t.Errorf("small image should be inlined as %s: %s", dataURI, updated)
+	}
+	if !strings.Contains(updated, `src="assets/images/photo.jpg"`) {
+		t.Errorf("large image should stay a file: %s", updated)
+	}
+}
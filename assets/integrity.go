@@ -0,0 +1,114 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// integrityHash returns the hash for a Subresource Integrity algorithm name, or nil if unsupported
+func integrityHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case "sha256":
+		return sha256.New()
+	case "sha384":
+		return sha512.New384()
+	case "sha512":
+		return sha512.New()
+	}
+	return nil
+}
+
+// subresourceIntegrity computes an integrity value such as sha384-<base64> for data
+func subresourceIntegrity(algorithm string, data []byte) (string, bool) {
+	h := integrityHash(algorithm)
+	if h == nil {
+		return "", false
+	}
+	h.Write(data)
+	return algorithm + "-" + base64.StdEncoding.EncodeToString(h.Sum(nil)), true
+}
+
+// recomputeIntegrity rewrites the integrity attribute of every <link> and <script> that now
+// points at a local file, hashing the file as saved (after CSS rewriting, dedupe, or any renaming)
+// with the strongest algorithm the original value used. The origin's hash no longer matches a
+// rewritten file, and browsers refuse to apply a stylesheet or script that fails its check.
+func recomputeIntegrity(htmlContent string, opts Options) (string, error) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", err
+	}
+
+	pageDir := path.Dir(opts.PagePath)
+	changed := false
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "link" || n.Data == "script") && getAttr(n, "integrity") != "" {
+			ref := getAttr(n, "href")
+			if n.Data == "script" {
+				ref = getAttr(n, "src")
+			}
+			if localFile, ok := localFileForRef(opts.outputDir(), pageDir, ref); ok {
+				if data, err := opts.Output.ReadFile(localFile); err == nil {
+					if value, ok := subresourceIntegrity(strongestIntegrityAlgorithm(getAttr(n, "integrity")), data); ok && value != getAttr(n, "integrity") {
+						setAttr(n, "integrity", value)
+						changed = true
+					}
+				}
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+
+	traverse(doc)
+
+	if !changed {
+		return htmlContent, nil
+	}
+	var buf strings.Builder
+	if err := html.Render(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// strongestIntegrityAlgorithm picks the strongest supported algorithm named in an integrity
+// attribute (which may list several hashes), defaulting to sha384
+func strongestIntegrityAlgorithm(integrity string) string {
+	best := ""
+	rank := map[string]int{"sha256": 1, "sha384": 2, "sha512": 3}
+	for _, token := range strings.Fields(integrity) {
+		algorithm, _, _ := strings.Cut(token, "-")
+		if rank[algorithm] > rank[best] {
+			best = algorithm
+		}
+	}
+	if best == "" {
+		return "sha384"
+	}
+	return best
+}
+
+// localFileForRef maps a page-relative reference to a saved file under outDir, rejecting
+// remote, root-relative, and data: references
+func localFileForRef(outDir, pageDir, ref string) (string, bool) {
+	if ref == "" || strings.Contains(ref, ":") || strings.HasPrefix(ref, "/") {
+		return "", false
+	}
+	ref, _, _ = strings.Cut(ref, "?")
+	ref, _, _ = strings.Cut(ref, "#")
+	rel := path.Clean(path.Join(pageDir, ref))
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+	return filepath.Join(outDir, filepath.FromSlash(rel)), true
+}
@@ -0,0 +1,119 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestIntegrityRecomputedForSavedFiles(t *testing.T) {
+	originalCSS := `@font-face { font-family: "X"; src: url("/fonts/x.woff2"); }`
+	originalJS := "console.log('app');"
+	sri := func(sum []byte, algorithm string) string {
+		return algorithm + "-" + base64.StdEncoding.EncodeToString(sum)
+	}
+	cssSum := sha512.Sum384([]byte(originalCSS))
+	jsSum := sha256.Sum256([]byte(originalJS))
+	_, base := newTestSite(t, map[string]string{
+		"/css/site.css":  originalCSS,
+		"/js/app.js":     originalJS,
+		"/fonts/x.woff2": "font",
+	})
+	page := `<html><head>` +
+		`<link rel="stylesheet" href="/css/site.css" integrity="` + sri(cssSum[:], "sha384") + `" crossorigin="anonymous">` +
+		`<script src="/js/app.js" integrity="` + sri(jsSum[:], "sha256") + `"></script>` +
+		`</head><body></body></html>`
+
+	html, err := LocalizeAssets(page, base, Options{Concurrency: 2, ManifestPath: "output/manifest.json"})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	savedCSS, err := os.ReadFile("output/assets/site.css")
+	if err != nil {
+		t.Fatalf("stylesheet should be saved: %v", err)
+	}
+	if string(savedCSS) == originalCSS {
+		t.Fatalf("stylesheet should have been rewritten for this test to be meaningful")
+	}
+	savedSum := sha512.Sum384(savedCSS)
+	wantCSS := sri(savedSum[:], "sha384")
+	if !strings.Contains(html, `integrity="`+wantCSS+`"`) {
+		t.Errorf("stylesheet integrity should match the saved file (%s): %s", wantCSS, html)
+	}
+	if !strings.Contains(html, `integrity="`+sri(jsSum[:], "sha256")+`"`) {
+		t.Errorf("unchanged script should keep its sha256 integrity: %s", html)
+	}
+
+	data, err := os.ReadFile("output/manifest.json")
+	if err != nil {
+		t.Fatalf("manifest should be written: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("invalid manifest: %v", err)
+	}
+	for _, entry := range manifest.Assets {
+		if entry.Type == "css" && entry.Integrity != wantCSS {
+			t.Errorf("manifest integrity %q should agree with the HTML %q", entry.Integrity, wantCSS)
+		}
+	}
+}
+
+func TestHashNamesWithIntegrity(t *testing.T) {
+	originalCSS := `body { background: url("/img/bg.png"); }`
+	cssSum := sha512.Sum384([]byte(originalCSS))
+	_, base := newTestSite(t, map[string]string{
+		"/css/style.css": originalCSS,
+		"/img/bg.png":    "png",
+	})
+	page := `<html><head>` +
+		`<link rel="stylesheet" href="/css/style.css" integrity="sha384-` + base64.StdEncoding.EncodeToString(cssSum[:]) + `">` +
+		`</head><body></body></html>`
+
+	html, err := LocalizeAssets(page, base, Options{Concurrency: 2, HashNames: true, ManifestPath: "output/manifest.json"})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	data, err := os.ReadFile("output/manifest.json")
+	if err != nil {
+		t.Fatalf("manifest should be written: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("invalid manifest: %v", err)
+	}
+	var entry ManifestEntry
+	for _, asset := range manifest.Assets {
+		if asset.Type == "css" {
+			entry = asset
+		}
+	}
+
+	saved, err := os.ReadFile("output/" + entry.LocalPath)
+	if err != nil {
+		t.Fatalf("stylesheet should be saved under its manifest path %q: %v", entry.LocalPath, err)
+	}
+	if want := hashedPath("assets/style.css", saved); entry.LocalPath != want {
+		t.Errorf("stylesheet should be named after its saved content: got %q, want %q", entry.LocalPath, want)
+	}
+	savedSum := sha512.Sum384(saved)
+	wantIntegrity := "sha384-" + base64.StdEncoding.EncodeToString(savedSum[:])
+	if entry.Integrity != wantIntegrity {
+		t.Errorf("manifest integrity %q should hash the saved file (%s)", entry.Integrity, wantIntegrity)
+	}
+	if !strings.Contains(html, `href="`+entry.LocalPath+`" integrity="`+wantIntegrity+`"`) {
+		t.Errorf("page should reference the hashed name with the saved file's integrity: %s", html)
+	}
+	if manifest.Aliases["assets/style.css"] != entry.LocalPath {
+		t.Errorf("aliases should map assets/style.css to %q: %v", entry.LocalPath, manifest.Aliases)
+	}
+	if alias := manifest.Aliases["assets/images/bg.png"]; !strings.HasPrefix(alias, "assets/images/") || alias == "assets/images/bg.png" {
+		t.Errorf("aliases should map the background image to its hashed name: %v", manifest.Aliases)
+	}
+}
@@ -0,0 +1,39 @@
+package assets
+
+import (
+	"net/url"
+	"sort"
+)
+
+// AssetInfo describes one asset a page references
+type AssetInfo struct {
+	Type       string `json:"type"`
+	URL        string `json:"url"`
+	SameOrigin bool   `json:"same_origin"`
+}
+
+// ListAssets returns every asset the page references, as collected for a scrape, without downloading anything
+func ListAssets(htmlContent string, base *url.URL) ([]AssetInfo, error) {
+	jobs, err := collectAllAssetJobs(htmlContent, base, Options{InlineStyleFonts: true, Prefetch: true})
+	if err != nil {
+		return nil, err
+	}
+
+	inventory := make([]AssetInfo, 0, len(jobs))
+	for _, job := range jobs {
+		info := AssetInfo{Type: job.Type, URL: job.URL}
+		if u, err := url.Parse(job.URL); err == nil {
+			info.SameOrigin = u.Scheme == base.Scheme && u.Host == base.Host
+		}
+		inventory = append(inventory, info)
+	}
+
+	sort.SliceStable(inventory, func(i, j int) bool {
+		if inventory[i].Type != inventory[j].Type {
+			return inventory[i].Type < inventory[j].Type
+		}
+		return inventory[i].URL < inventory[j].URL
+	})
+
+	return inventory, nil
+}
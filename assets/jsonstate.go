@@ -0,0 +1,204 @@
+package assets
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// DefaultStateVars are the global state variables SPA-style themes commonly embed as JSON
+var DefaultStateVars = []string{"__INITIAL_STATE__", "__PRELOADED_STATE__", "__DATA__"}
+
+// stateAssignmentRe matches an assignment to one of the state variables, such as
+// window.__INITIAL_STATE__ = or var __DATA__ =, up to where the JSON value starts.
+// A * in a variable name matches any run of identifier characters.
+func stateAssignmentRe(vars []string) *regexp.Regexp {
+	names := make([]string, 0, len(vars))
+	for _, name := range vars {
+		names = append(names, strings.ReplaceAll(regexp.QuoteMeta(name), `\*`, `[\w$]*`))
+	}
+	return regexp.MustCompile(`(?:^|[^\w$.])(?:(?:window|self|globalThis)\.|(?:var|let|const)\s+)?(?:` + strings.Join(names, "|") + `)\s*=\s*`)
+}
+
+// stateBlobs returns the byte ranges of the JSON objects and arrays assigned to state variables
+// in a script. Values that are not valid JSON (object literals with unquoted keys, function calls)
+// are skipped.
+func stateBlobs(script string, re *regexp.Regexp) [][2]int {
+	var blobs [][2]int
+	for _, match := range re.FindAllStringIndex(script, -1) {
+		start := match[1]
+		if start >= len(script) || (script[start] != '{' && script[start] != '[') {
+			continue
+		}
+		dec := json.NewDecoder(strings.NewReader(script[start:]))
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			continue
+		}
+		blobs = append(blobs, [2]int{start, start + int(dec.InputOffset())})
+	}
+	return blobs
+}
+
+// walkJSONStrings calls visit with the byte range (quotes included) and decoded value of every
+// string value in a JSON document. Object keys are not visited.
+func walkJSONStrings(doc string, visit func(start, end int, value string)) {
+	dec := json.NewDecoder(strings.NewReader(doc))
+	type frame struct {
+		object    bool
+		expectKey bool
+	}
+	var stack []frame
+	for {
+		before := int(dec.InputOffset())
+		tok, err := dec.Token()
+		if err != nil {
+			return
+		}
+		switch tok := tok.(type) {
+		case json.Delim:
+			switch tok {
+			case '{':
+				stack = append(stack, frame{object: true, expectKey: true})
+				continue
+			case '[':
+				stack = append(stack, frame{})
+				continue
+			default:
+				stack = stack[:len(stack)-1]
+			}
+		case string:
+			if n := len(stack); n > 0 && stack[n-1].object && stack[n-1].expectKey {
+				stack[n-1].expectKey = false
+				continue
+			}
+			// Only whitespace, ':' or ',' separate the previous token from the opening quote
+			if start := strings.IndexByte(doc[before:], '"'); start >= 0 {
+				visit(before+start, int(dec.InputOffset()), tok)
+			}
+		}
+		// A value completes an object member, so a key comes next
+		if n := len(stack); n > 0 && stack[n-1].object {
+			stack[n-1].expectKey = true
+		}
+	}
+}
+
+// stateAssetType routes an asset URL found in a state blob by its extension. Only images, media,
+// and fonts are localized; scripts, stylesheets, and API routes in state are left alone.
+func stateAssetType(assetURL string) (string, bool) {
+	jobType, ok := prefetchJobType("", assetURL)
+	if !ok || (jobType != "image" && jobType != "media" && jobType != "font") {
+		return "", false
+	}
+	return jobType, true
+}
+
+// inlineStateScripts calls visit for every inline script's text node
+func inlineStateScripts(doc *html.Node, visit func(text *html.Node)) {
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "script" && getAttr(n, "src") == "" {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.TextNode {
+					visit(c)
+				}
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+	traverse(doc)
+}
+
+// collectStateBlobJobs collects the absolute image, media, and font URLs found in JSON state blobs
+// assigned to the given variables in inline scripts (window.__INITIAL_STATE__ = {...})
+func collectStateBlobJobs(htmlContent string, base *url.URL, vars []string) []DownloadJob {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil
+	}
+
+	re := stateAssignmentRe(vars)
+	var jobs []DownloadJob
+	urlSeen := make(map[string]bool)
+	inlineStateScripts(doc, func(text *html.Node) {
+		for _, blob := range stateBlobs(text.Data, re) {
+			walkJSONStrings(text.Data[blob[0]:blob[1]], func(_, _ int, value string) {
+				if !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") && !strings.HasPrefix(value, "//") {
+					return
+				}
+				assetURL, ok := resolveAssetURL(base, value)
+				if !ok || urlSeen[value] {
+					return
+				}
+				jobType, ok := stateAssetType(assetURL)
+				if !ok {
+					return
+				}
+				urlSeen[value] = true
+				jobs = append(jobs, DownloadJob{
+					URL:          assetURL,
+					Type:         jobType,
+					OriginalPath: value,
+					BaseURL:      base,
+				})
+			})
+		}
+	})
+	return jobs
+}
+
+// rewriteStateBlobs points URLs inside JSON state blobs at their downloaded copies. Only the
+// string tokens are replaced, re-encoded as JSON, so the blob stays valid and keeps its formatting
+// and key order; escaped forms such as https:\/\/ are matched by their decoded value.
+func rewriteStateBlobs(htmlContent string, vars []string, urlMap map[string]string, opts Options) (string, error) {
+	if len(urlMap) == 0 {
+		return htmlContent, nil
+	}
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", err
+	}
+
+	re := stateAssignmentRe(vars)
+	inlineStateScripts(doc, func(text *html.Node) {
+		script := text.Data
+		var b strings.Builder
+		last := 0
+		for _, blob := range stateBlobs(script, re) {
+			walkJSONStrings(script[blob[0]:blob[1]], func(start, end int, value string) {
+				localPath, ok := urlMap[value]
+				if !ok {
+					return
+				}
+				ref := localPath
+				if !strings.HasPrefix(localPath, "data:") {
+					ref = pageRootPrefix(opts.PagePath) + outRelative(localPath, opts.outputDir())
+				}
+				encoded, err := json.Marshal(ref)
+				if err != nil {
+					return
+				}
+				b.WriteString(script[last : blob[0]+start])
+				b.Write(encoded)
+				last = blob[0] + end
+			})
+		}
+		if last > 0 {
+			b.WriteString(script[last:])
+			text.Data = b.String()
+		}
+	})
+
+	var buf strings.Builder
+	if err := html.Render(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,62 @@
+package assets
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"wp-static-scraper/internal/testsite"
+)
+
+func TestLocalizeJSONStateBlob(t *testing.T) {
+	chdirOutput(t)
+
+	server := testsite.New(t, map[string]string{
+		"/uploads/hero.jpg":  "jpg",
+		"/uploads/thumb.png": "png",
+	})
+	escaped := strings.ReplaceAll(server.URL, "/", `\/`)
+	page := `<html><head><script>window.__INITIAL_STATE__ = {"post":{"title":"Hello <b>","hero":"` + server.URL + `/uploads/hero.jpg",` +
+		`"thumbs":["` + escaped + `\/uploads\/thumb.png"],"api":"` + server.URL + `/wp-json/wp/v2/posts"},"` + server.URL + `/uploads/hero.jpg":1};` +
+		`window.other = {"img":"` + server.URL + `/uploads/other.png"};</script></head><body></body></html>`
+	base, _ := url.Parse(server.URL + "/")
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 2, StateVars: []string{"__INITIAL_*"}})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	start := strings.Index(result, "__INITIAL_STATE__ = ") + len("__INITIAL_STATE__ = ")
+	end := strings.Index(result, ";window.other")
+	if start < len("__INITIAL_STATE__ = ") || end < start {
+		t.Fatalf("state blob missing from output: %s", result)
+	}
+	var state struct {
+		Post struct {
+			Title  string   `json:"title"`
+			Hero   string   `json:"hero"`
+			Thumbs []string `json:"thumbs"`
+			API    string   `json:"api"`
+		} `json:"post"`
+	}
+	if err := json.Unmarshal([]byte(result[start:end]), &state); err != nil {
+		t.Fatalf("state blob is no longer valid JSON: %v\n%s", err, result[start:end])
+	}
+	if state.Post.Hero != "assets/images/hero.jpg" {
+		t.Errorf("expected localized hero, got %q", state.Post.Hero)
+	}
+	if len(state.Post.Thumbs) != 1 || state.Post.Thumbs[0] != "assets/images/thumb.png" {
+		t.Errorf("expected escaped thumb URL to be localized, got %v", state.Post.Thumbs)
+	}
+	if state.Post.API != server.URL+"/wp-json/wp/v2/posts" || state.Post.Title != "Hello <b>" {
+		t.Errorf("non-asset values should be untouched: %+v", state.Post)
+	}
+	if !strings.Contains(result, `"`+server.URL+`/uploads/other.png"`) {
+		t.Errorf("unlisted variables should be left alone: %s", result)
+	}
+	if _, err := os.Stat("output/assets/images/thumb.png"); err != nil {
+		t.Errorf("thumb.png should be downloaded: %v", err)
+	}
+}
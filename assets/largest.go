@@ -0,0 +1,53 @@
+package assets
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// LargestAssets returns up to n successfully downloaded assets ordered by size on disk, largest
+// first, so oversized images and fonts stand out. Ties are broken by URL for stable output.
+func LargestAssets(results []DownloadResult, n int) []DownloadResult {
+	var downloaded []DownloadResult
+	for _, result := range results {
+		if result.Success && result.Size > 0 {
+			downloaded = append(downloaded, result)
+		}
+	}
+	sort.Slice(downloaded, func(i, j int) bool {
+		if downloaded[i].Size != downloaded[j].Size {
+			return downloaded[i].Size > downloaded[j].Size
+		}
+		return downloaded[i].Job.URL < downloaded[j].Job.URL
+	})
+	if len(downloaded) > n {
+		downloaded = downloaded[:n]
+	}
+	return downloaded
+}
+
+// largestEntries converts the largest assets into manifest entries with paths relative to outDir
+func largestEntries(largest []DownloadResult, outDir string) []ManifestEntry {
+	entries := make([]ManifestEntry, 0, len(largest))
+	for _, result := range largest {
+		entries = append(entries, ManifestEntry{
+			URL:       result.Job.URL,
+			Type:      result.Job.Type,
+			LocalPath: relativeToOutDir(result.LocalPath, outDir),
+			Size:      result.Size,
+		})
+	}
+	return entries
+}
+
+// printLargestAssets prints the largest assets as part of the download summary
+func printLargestAssets(w io.Writer, largest []DownloadResult) {
+	if len(largest) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Largest %d assets:\n", len(largest))
+	for i, result := range largest {
+		fmt.Fprintf(w, "  %d. %d bytes [%s] %s\n", i+1, result.Size, result.Job.Type, result.Job.URL)
+	}
+}
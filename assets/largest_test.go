@@ -0,0 +1,50 @@
+package assets
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLargestAssetsOrdered(t *testing.T) {
+	_, base := newTestSite(t, map[string]string{
+		"/img/hero.png":   strings.Repeat("h", 5000),
+		"/img/icon.png":   strings.Repeat("i", 10),
+		"/fonts/big.woff": strings.Repeat("f", 3000),
+		"/js/app.js":      strings.Repeat("j", 800),
+	})
+	page := `<html><head><script src="/js/app.js"></script>` +
+		`<link rel="preload" href="/fonts/big.woff" as="font"></head>` +
+		`<body><img src="/img/icon.png"><img src="/img/hero.png"></body></html>`
+
+	var log bytes.Buffer
+	opts := Options{Concurrency: 2, LargestAssets: 2, ManifestPath: "output/manifest.json", Log: &log}
+	_, err := LocalizeAssets(page, base, opts)
+	output := log.String()
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	data, err := os.ReadFile("output/manifest.json")
+	if err != nil {
+		t.Fatalf("manifest should be written: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("invalid manifest: %v", err)
+	}
+	if len(manifest.Largest) != 2 {
+		t.Fatalf("expected the 2 largest assets, got %+v", manifest.Largest)
+	}
+	if !strings.HasSuffix(manifest.Largest[0].URL, "/img/hero.png") || manifest.Largest[0].Size != 5000 {
+		t.Errorf("hero.png should be largest, got %+v", manifest.Largest[0])
+	}
+	if !strings.HasSuffix(manifest.Largest[1].URL, "/fonts/big.woff") || manifest.Largest[1].Size != 3000 {
+		t.Errorf("big.woff should be second, got %+v", manifest.Largest[1])
+	}
+	if !strings.Contains(output, "1. 5000 bytes [image]") || !strings.Contains(output, "2. 3000 bytes [font]") {
+		t.Errorf("summary should list the largest assets in order: %s", output)
+	}
+}
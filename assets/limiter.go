@@ -0,0 +1,36 @@
+package assets
+
+import "context"
+
+// RequestLimiter caps how many requests are in flight at once across every page fetch and asset
+// download that shares it, however many downloaders or pages are active. A nil limiter allows
+// any number of requests.
+type RequestLimiter struct {
+	slots chan struct{}
+}
+
+// NewRequestLimiter returns a limiter allowing at most n simultaneous requests
+func NewRequestLimiter(n int) *RequestLimiter {
+	return &RequestLimiter{slots: make(chan struct{}, n)}
+}
+
+// acquire blocks until a request slot is free or ctx is done
+func (l *RequestLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a request slot
+func (l *RequestLimiter) release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
@@ -0,0 +1,115 @@
+package assets
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaxConcurrencyTotalAcrossPages(t *testing.T) {
+	chdirOutput(t)
+
+	var inFlight, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		if strings.HasPrefix(r.URL.Path, "/page/") {
+			var page strings.Builder
+			page.WriteString("<html><body>")
+			for i := range 8 {
+				fmt.Fprintf(&page, `<img src="/img%s-%d.png">`, strings.Trim(r.URL.Path[len("/page"):], "/"), i)
+			}
+			page.WriteString("</body></html>")
+			w.Write([]byte(page.String()))
+			return
+		}
+		w.Write([]byte("png"))
+	}))
+	defer server.Close()
+
+	const limit = 3
+	opts := Options{Concurrency: 5, RequestLimiter: NewRequestLimiter(limit)}
+	var wg sync.WaitGroup
+	for i := 1; i <= 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pageURL := fmt.Sprintf("%s/page/%d/", server.URL, i)
+			body, err := FetchPage(pageURL, opts)
+			if err != nil {
+				t.Errorf("FetchPage returned error: %v", err)
+				return
+			}
+			base, _ := url.Parse(pageURL)
+			if _, err := LocalizeAssets(string(body), base, opts); err != nil {
+				t.Errorf("LocalizeAssets returned error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&peak); got > limit {
+		t.Errorf("expected at most %d simultaneous requests, saw %d", limit, got)
+	} else if got < 2 {
+		t.Errorf("expected requests to overlap up to the cap, saw %d", got)
+	}
+}
+
+func TestMaxConcurrencyTotalCoversSerialFetches(t *testing.T) {
+	var inFlight, peak int32
+	server, base := newTestSiteHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("data"))
+	}))
+
+	const limit = 2
+	opts := Options{Concurrency: 4, RequestLimiter: NewRequestLimiter(limit)}
+	var page strings.Builder
+	page.WriteString("<html><body>")
+	for i := range 6 {
+		fmt.Fprintf(&page, `<img src="/img/%d.png">`, i)
+	}
+	page.WriteString("</body></html>")
+
+	// Inline-script and iframe localization fetch through Options.fetcher while workers download
+	fetch := opts.fetcher()
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := fetch(fmt.Sprintf("%s/js/%d.js", server.URL, i)); err != nil {
+				t.Errorf("serial fetch returned error: %v", err)
+			}
+		}(i)
+	}
+	if _, err := LocalizeAssets(page.String(), base, opts); err != nil {
+		t.Errorf("LocalizeAssets returned error: %v", err)
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&peak); got > limit {
+		t.Errorf("expected at most %d simultaneous requests across serial and pooled fetches, saw %d", limit, got)
+	}
+}
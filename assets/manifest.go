@@ -0,0 +1,85 @@
+package assets
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"sort"
+
+	"wp-static-scraper/utils"
+)
+
+// ManifestEntry describes a single asset processed during a scrape
+type ManifestEntry struct {
+	URL       string `json:"url"`
+	Type      string `json:"type"`
+	LocalPath string `json:"local_path,omitempty"` // Relative to the output directory
+	Size      int64  `json:"size,omitempty"`       // Bytes saved for downloaded assets
+	Integrity string `json:"integrity,omitempty"`  // sha384 Subresource Integrity of the saved stylesheet or script
+	Error     string `json:"error,omitempty"`
+}
+
+// Manifest records what a scrape downloaded and where it was saved
+type Manifest struct {
+	SourceURL   string            `json:"source_url"`
+	Assets      []ManifestEntry   `json:"assets"`
+	Dedupe      *DedupeStats      `json:"dedupe,omitempty"`
+	Concurrency *ConcurrencyStats `json:"concurrency,omitempty"` // Effective concurrency over the run with -concurrency-backoff-on-errors
+	Skipped     []SkippedAsset    `json:"skipped,omitempty"`     // Assets left remote, with reason codes
+	Largest     []ManifestEntry   `json:"largest,omitempty"`     // The biggest downloads, largest first, with -report-largest-assets
+	Partial     bool              `json:"partial,omitempty"`     // The run hit its deadline before every asset was fetched
+	Aliases     map[string]string `json:"aliases,omitempty"`     // Original asset name -> content-hashed name, with -content-hash-names
+}
+
+// BuildManifest converts download results into a manifest with paths relative to outDir, hashing
+// saved stylesheets and scripts as read back through out
+func BuildManifest(out *utils.Output, base *url.URL, results []DownloadResult, outDir string) Manifest {
+	manifest := Manifest{
+		SourceURL: base.String(),
+		Assets:    make([]ManifestEntry, 0, len(results)),
+	}
+
+	for _, result := range results {
+		entry := ManifestEntry{
+			URL:  result.Job.URL,
+			Type: result.Job.Type,
+		}
+		if result.Success {
+			entry.LocalPath = relativeToOutDir(result.LocalPath, outDir)
+			entry.Size = result.Size
+			if result.Job.Type == "css" || result.Job.Type == "js" {
+				if data, err := out.ReadFile(result.LocalPath); err == nil {
+					entry.Integrity, _ = subresourceIntegrity("sha384", data)
+				}
+			}
+		} else if result.Error != nil {
+			entry.Error = result.Error.Error()
+		}
+		manifest.Assets = append(manifest.Assets, entry)
+	}
+
+	// Results arrive in completion order, so sort for stable output
+	sort.Slice(manifest.Assets, func(i, j int) bool {
+		return manifest.Assets[i].URL < manifest.Assets[j].URL
+	})
+
+	return manifest
+}
+
+// WriteManifest saves the manifest as indented JSON through out
+func WriteManifest(out *utils.Output, path string, manifest Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return out.WriteFile(path, data)
+}
+
+// relativeToOutDir makes a saved file path portable by expressing it relative to the output directory
+func relativeToOutDir(localPath, outDir string) string {
+	rel, err := filepath.Rel(outDir, localPath)
+	if err != nil {
+		return filepath.ToSlash(localPath)
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,68 @@
+package assets
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestManifestPathsAreOutDirRelative(t *testing.T) {
+	server, base := newTestSite(t, map[string]string{
+		"/img/x.png": "png-bytes",
+		"/css/a.css": "body{}",
+	})
+	page := `<html><head><link rel="stylesheet" href="` + server.URL + `/css/a.css"></head>` +
+		`<body><img src="` + server.URL + `/img/x.png"></body></html>`
+
+	opts := Options{Concurrency: 2, ManifestPath: "output/manifest.json"}
+	if _, err := LocalizeAssets(page, base, opts); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	data, err := os.ReadFile("output/manifest.json")
+	if err != nil {
+		t.Fatalf("manifest was not written: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("manifest is not valid JSON: %v", err)
+	}
+
+	paths := make(map[string]string)
+	for _, entry := range manifest.Assets {
+		paths[entry.URL] = entry.LocalPath
+	}
+	if got := paths[server.URL+"/img/x.png"]; got != "assets/images/x.png" {
+		t.Errorf("image local path = %q; want %q", got, "assets/images/x.png")
+	}
+	if got := paths[server.URL+"/css/a.css"]; got != "assets/a.css" {
+		t.Errorf("stylesheet local path = %q; want %q", got, "assets/a.css")
+	}
+}
+
+func TestRunArtifactsWrittenWithoutAssets(t *testing.T) {
+	_, base := newTestSite(t, map[string]string{})
+	page := `<html><head><title>Plain</title></head><body><p>No assets here</p></body></html>`
+
+	opts := Options{
+		Concurrency:        1,
+		ManifestPath:       "output/manifest.json",
+		ReportPath:         "output/_report.html",
+		ImageInventoryPath: "output/images.json",
+		HeadersPath:        "output/_headers.json",
+	}
+	if _, err := LocalizeAssets(page, base, opts); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	for _, path := range []string{opts.ManifestPath, opts.ReportPath, opts.ImageInventoryPath, opts.HeadersPath} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s should be written for a page without assets: %v", path, err)
+		}
+	}
+	data, _ := os.ReadFile(opts.ManifestPath)
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil || len(manifest.Assets) != 0 {
+		t.Errorf("manifest should be valid and list no assets, got %s (%v)", data, err)
+	}
+}
@@ -0,0 +1,222 @@
+package assets
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"wp-static-scraper/utils"
+)
+
+// Options configures how assets are collected and downloaded
+type Options struct {
+	Concurrency   int   // Number of concurrent download workers
+	MaxTotalBytes int64 // Cumulative download budget in bytes (0 means unlimited)
+	MaxFileSize   int64 // Largest <a download> file to save in bytes; bigger ones stay remote (0 means unlimited)
+	FailFast      bool  // Abort the run on the first primary (non-font) download failure
+	SkipExisting  bool  // Reuse assets already present in output/ instead of downloading them again
+	Dedupe        bool  // Collapse identical downloads onto one file
+	DedupeReport  bool  // Report identical downloads and the bytes collapsing them saves, without collapsing them unless Dedupe is set
+	WriteLimit    int   // Maximum concurrent disk writes, independent of Concurrency (0 means unlimited)
+	PreserveMTime bool  // Set saved files' modification times from the origin's Last-Modified header
+	RetryEmpty    bool  // Retry image, media, and file 200 responses with an empty body instead of saving zero-byte files
+
+	// Deadline aborts downloading once passed (zero means none); LocalizeAssets then returns the
+	// partially localized page with ErrRuntimeExceeded
+	Deadline time.Time
+
+	// InlineImagesBelow embeds downloaded images smaller than this many bytes as data: URIs in the HTML
+	// and in stylesheets rewritten by ConcurrentCSSRewrite (0 disables)
+	InlineImagesBelow int64
+
+	// ImageQuality re-encodes downloaded JPEGs at this quality (1-100), keeping their dimensions, and
+	// keeps the original whenever re-encoding would not make it smaller (0 disables)
+	ImageQuality int
+
+	// MaxRedirects caps the redirects followed per asset before it is left remote (0 uses DefaultMaxRedirects)
+	MaxRedirects int
+
+	// ProgressJSON receives newline-delimited JSON events as each asset starts, completes, or fails
+	ProgressJSON io.Writer
+
+	// Progress receives a "Downloaded X/Y assets (Z%)" line, rewritten in place with \r while assets
+	// download and ended with a newline once they finish (nil disables it)
+	Progress io.Writer
+
+	// Log receives the messages printed for people: page retries, failed assets, skip reasons, and
+	// the download summary (nil prints them to standard output)
+	Log io.Writer
+
+	// PurgeCSS, when set, removes unused rules from stylesheets before they are saved (-purge-css)
+	PurgeCSS *CSSPurge
+
+	// RequestLimiter, when set, is a global request cap shared by every page fetch and asset download
+	// that uses these options, on top of Concurrency
+	RequestLimiter *RequestLimiter
+
+	// ExtensionLimits caps concurrent downloads per file extension ("mp4" -> 2); others use Concurrency
+	ExtensionLimits map[string]int
+	// SingleSrc, when set, collapses each <img srcset> to one src chosen by this policy after
+	// localization and removes srcset, sizes, and <picture> sources (for email and strict AMP)
+	SingleSrc *SrcsetPolicy
+
+	// StateVars localizes image, media, and font URLs inside JSON blobs that inline scripts assign to
+	// these variables (window.__INITIAL_STATE__ = {...}); a * matches any identifier characters
+	StateVars []string
+
+	// AssetRules download the URLs in custom attributes of matching elements, such as data-bg on
+	// div.hero, for themes and plugins the built-in discovery does not know about
+	AssetRules []AssetRule
+
+	// ExcludeExtensions leaves assets with these file extensions (".mp4" or "mp4") remote, whatever their type,
+	// including ones found in stylesheets and source maps
+	ExcludeExtensions []string
+
+	// LargestAssets prints this many largest downloads by size and lists them in the manifest (0 disables)
+	LargestAssets int
+
+	// VerboseSkips prints every asset left remote with a reason code (admin-bar, over-budget, dead-host, ...)
+	VerboseSkips bool
+
+	// AssetCase normalizes saved filenames to one case (AssetCaseLower or AssetCaseUpper) and treats
+	// URLs that differ only in case as one asset; AssetCasePreserve keeps origin names
+	AssetCase string
+
+	// DropFailedRefs removes <script>/<link> elements whose asset failed to download and blanks
+	// other src/href attributes pointing at failed assets, instead of leaving them remote
+	DropFailedRefs bool
+
+	// Prefetch also downloads the targets of <link rel="prefetch"> hints, which can be large or many
+	Prefetch bool
+
+	// StripScripts removes every script, script preload, inline event handler, and javascript: URL
+	// before assets are collected, so no JavaScript is downloaded or left in the page
+	StripScripts bool
+
+	// StripAdminBar removes the WordPress admin bar (#wpadminbar) and skips its assets
+	StripAdminBar bool
+
+	// InlineStyleFonts localizes font url() references inside style attributes, like those in <style> blocks
+	InlineStyleFonts bool
+
+	// LazyIframes fetches and localizes same-origin iframes deferred via data-src
+	LazyIframes bool
+	// PromoteIframeDataSrc copies data-src into src for cross-origin deferred iframes
+	PromoteIframeDataSrc bool
+
+	// ConcurrentCSSRewrite queues assets referenced by downloaded CSS into the worker pool and
+	// rewrites each stylesheet once they resolve, instead of fetching them serially per stylesheet
+	ConcurrentCSSRewrite bool
+
+	// CSSURLBase rewrites local url() references in saved CSS to absolute paths rooted at this base,
+	// the URL the output directory is served from (empty keeps them relative to the stylesheet)
+	CSSURLBase string
+
+	// FontURLPrefix rewrites url() references to localized fonts in saved CSS to this absolute prefix
+	// (e.g. /assets/fonts/), taking precedence over CSSURLBase for fonts (empty keeps them relative)
+	FontURLPrefix string
+
+	// ParseSourceMaps fetches JS source maps to discover images and fonts hidden by minification
+	ParseSourceMaps bool
+
+	// BackoffOnErrors lowers effective concurrency while 429/5xx responses spike and ramps back up on success
+	BackoffOnErrors bool
+	// Backoff sets the error rate and window the throttle reacts to (zero values use DefaultBackoffConfig)
+	Backoff BackoffConfig
+
+	// RenderEndpoint is a headless-render service that receives the page URL and returns rendered HTML
+	RenderEndpoint string
+
+	// RequestIDHeader sends a unique ID under this header name (e.g. X-Request-ID) with every request
+	RequestIDHeader string
+
+	// UserAgent replaces Go's default User-Agent on every request and is the agent robots.txt rules
+	// are matched against (-user-agent; empty keeps the defaults)
+	UserAgent string
+	// Headers are sent with the page fetch and every asset download from the site's own hosts
+	// (-header; see SiteHosts)
+	Headers http.Header
+
+	// BasicAuth sends HTTP basic auth credentials, given as user:pass, with the page fetch and every
+	// asset download from the site's own hosts (see SiteHosts)
+	BasicAuth string
+	// SiteHosts are the scraped site's hosts (-url, -asset-base). When set, BasicAuth and Headers only
+	// go to them, CrawlHosts, and the OriginAliases hosts, never to CDNs or other third parties (empty
+	// sends them everywhere)
+	SiteHosts []string
+
+	// AcceptEncoding set to "br" requests Brotli as well as gzip/deflate and decodes the responses
+	AcceptEncoding string
+
+	// PageRetries retries the top-level page fetch this many times after a network error or 429/5xx
+	// response, waiting a little longer before each attempt (0 fetches once)
+	PageRetries int
+
+	// PageProxy routes the top-level page fetch through this proxy URL when set
+	PageProxy string
+	// AssetProxy routes asset downloads through this proxy URL when set
+	AssetProxy string
+
+	// LineEndings normalizes saved CSS, JS, JSON, and HTML to "lf" or "crlf" (empty leaves them alone)
+	LineEndings string
+
+	// CrawlHosts are extra hosts (e.g. blog.example.com on a multisite) whose page links are followed
+	// like the start host's; links to any other host are left external
+	CrawlHosts []string
+
+	// OutDir is the directory pages and assets are saved under (empty means utils.DefaultOutDir)
+	OutDir string
+	// Output writes saved files and directories with the configured permissions (nil uses the defaults)
+	Output *utils.Output
+	// PreservePaths mirrors each asset's URL path under assets/ (assets/wp-content/plugins/foo/style.css)
+	// so files sharing a basename no longer overwrite each other
+	PreservePaths bool
+	// HashNames names each saved asset by a short hash of its content plus its extension, so files
+	// sharing a basename no longer overwrite each other and identical files are stored once
+	HashNames bool
+
+	// PagePath is where the page is saved relative to output/ (e.g. "page/2/index.html"), so asset
+	// references from nested pages climb back to output/assets/ (empty means the output root)
+	PagePath string
+
+	// ManifestPath writes a JSON manifest of downloaded assets to this path when set
+	ManifestPath string
+	// HeadersPath writes the status and response headers of every asset fetch as JSON when set
+	HeadersPath string
+	// ReportPath writes an HTML dashboard built from the same manifest data when set
+	ReportPath string
+	// ImageInventoryPath writes the dimensions, size, and format of every downloaded image as JSON when set
+	ImageInventoryPath string
+
+	// OriginAliases maps alias hosts or origins to a canonical one (cdn2.example.com -> cdn1.example.com)
+	// so the same asset served from several hostnames is downloaded once
+	OriginAliases map[string]string
+
+	// TrackingParams are query parameters (exact or prefix*) stripped from URLs left in the output
+	TrackingParams []string
+
+	// ProtocolRelativeScheme rewrites remaining //host/path references to this scheme (empty leaves them alone)
+	ProtocolRelativeScheme string
+}
+
+// layout returns where the serial download helpers save assets for these options
+func (o Options) layout() assetLayout {
+	return assetLayout{outDir: o.outputDir(), preservePaths: o.PreservePaths, hashNames: o.HashNames, output: o.Output}
+}
+
+// logWriter returns where messages for people are printed
+func (o Options) logWriter() io.Writer {
+	if o.Log == nil {
+		return os.Stdout
+	}
+	return o.Log
+}
+
+// outputDir returns the directory pages and assets are saved under
+func (o Options) outputDir() string {
+	if o.OutDir == "" {
+		return utils.DefaultOutDir
+	}
+	return o.OutDir
+}
@@ -0,0 +1,121 @@
+package assets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// renderRequest is the body POSTed to a headless-render service
+type renderRequest struct {
+	URL string `json:"url"`
+}
+
+// FetchPage downloads the top-level HTML page, routed through PageProxy when set.
+// When RenderEndpoint is set the page is rendered by that service instead of fetched directly.
+func FetchPage(pageURL string, opts Options) ([]byte, error) {
+	body, _, err := FetchDocument(pageURL, opts)
+	return body, err
+}
+
+// FetchDocument is FetchPage that also returns the response Content-Type, so callers can tell
+// feeds and other non-HTML documents apart (rendered pages report text/html).
+// Network errors and 429/5xx responses are retried up to opts.PageRetries times with the same
+// growing delay as asset retries; once retries run out the last error is returned, and a final
+// status outside 2xx is an error so an error page is never scraped as the site.
+func FetchDocument(pageURL string, opts Options) ([]byte, string, error) {
+	client := http.DefaultClient
+	if opts.PageProxy != "" {
+		proxyURL, err := url.Parse(opts.PageProxy)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid page proxy: %w", err)
+		}
+		client = &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	}
+
+	for attempt := 0; ; attempt++ {
+		body, contentType, status, err := fetchDocumentOnce(client, pageURL, opts)
+		if attempt >= opts.PageRetries || !isTransientPageFailure(status, err) {
+			if err == nil && (status < 200 || status > 299) {
+				return nil, "", fmt.Errorf("bad status: %d %s", status, http.StatusText(status))
+			}
+			return body, contentType, err
+		}
+		delay := time.Duration(attempt+1) * 200 * time.Millisecond
+		if err != nil {
+			fmt.Fprintf(opts.logWriter(), "Page fetch failed (%v), retrying in %v\n", err, delay)
+		} else {
+			fmt.Fprintf(opts.logWriter(), "Page fetch returned status %d, retrying in %v\n", status, delay)
+		}
+		time.Sleep(delay)
+	}
+}
+
+// isTransientPageFailure reports whether a page fetch is worth another attempt
+func isTransientPageFailure(status int, err error) bool {
+	if err != nil {
+		return true
+	}
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// fetchDocumentOnce makes a single page request, also returning the HTTP status (0 on error)
+func fetchDocumentOnce(client *http.Client, pageURL string, opts Options) ([]byte, string, int, error) {
+	if err := opts.RequestLimiter.acquire(context.Background()); err != nil {
+		return nil, "", 0, err
+	}
+	defer opts.RequestLimiter.release()
+
+	if opts.RenderEndpoint != "" {
+		body, err := renderPage(client, opts.RenderEndpoint, pageURL)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		return body, "text/html", http.StatusOK, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	setUserAgent(req, opts.UserAgent)
+	setHeaders(req, opts.Headers, opts.credentialHosts())
+	setRequestID(req, opts.RequestIDHeader)
+	setAcceptEncoding(req, opts.AcceptEncoding)
+	setBasicAuth(req, opts.BasicAuth, opts.credentialHosts())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := readBody(resp)
+	return body, resp.Header.Get("Content-Type"), resp.StatusCode, err
+}
+
+// renderPage POSTs the page URL as JSON ({"url": "..."}) to a headless-render service
+// and returns the rendered HTML from the response body
+func renderPage(client *http.Client, endpoint, pageURL string) ([]byte, error) {
+	payload, err := json.Marshal(renderRequest{URL: pageURL})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("render endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("render endpoint: bad status: %s", resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
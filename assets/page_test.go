@@ -0,0 +1,130 @@
+package assets
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSplitPageAndAssetProxies(t *testing.T) {
+	chdirOutput(t)
+
+	// site.invalid never resolves, so requests only succeed through the proxies
+	pageProxy, pageRec := newRecordingProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body><img src="http://site.invalid/img/a.png"></body></html>`))
+	})
+	assetProxy, assetRec := newRecordingProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("png"))
+	})
+
+	opts := Options{Concurrency: 1, PageProxy: pageProxy.URL, AssetProxy: assetProxy.URL}
+	body, err := FetchPage("http://site.invalid/", opts)
+	if err != nil {
+		t.Fatalf("FetchPage returned error: %v", err)
+	}
+	base, _ := url.Parse("http://site.invalid/")
+	result, err := LocalizeAssets(string(body), base, opts)
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	if got := pageRec.seen(); len(got) != 1 || got[0] != "http://site.invalid/" {
+		t.Errorf("page proxy should only see the page fetch, got %v", got)
+	}
+	if got := assetRec.seen(); len(got) != 1 || got[0] != "http://site.invalid/img/a.png" {
+		t.Errorf("asset proxy should only see asset downloads, got %v", got)
+	}
+	if !strings.Contains(result, `src="assets/images/a.png"`) {
+		t.Errorf("asset fetched through the proxy should be localized, got %s", result)
+	}
+}
+
+func TestFetchPageUsesRenderEndpoint(t *testing.T) {
+	var requested string
+	renderer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			URL string `json:"url"`
+		}
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&body) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		requested = body.URL
+		w.Write([]byte("<html><body><h1>Rendered</h1></body></html>"))
+	}))
+	defer renderer.Close()
+
+	page, err := FetchPage("https://example.com/app/", Options{RenderEndpoint: renderer.URL})
+	if err != nil {
+		t.Fatalf("FetchPage returned error: %v", err)
+	}
+	if requested != "https://example.com/app/" {
+		t.Errorf("render endpoint should receive the page URL, got %q", requested)
+	}
+	if !strings.Contains(string(page), "<h1>Rendered</h1>") {
+		t.Errorf("rendered HTML should be used as the scrape input, got %s", page)
+	}
+}
+
+func TestPageFetchRetriesTransientFailure(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body>ready</body></html>"))
+	}))
+	defer server.Close()
+
+	body, _, err := FetchDocument(server.URL+"/", Options{PageRetries: 3, Log: io.Discard})
+	if err != nil {
+		t.Fatalf("FetchDocument returned error: %v", err)
+	}
+	if !strings.Contains(string(body), "ready") {
+		t.Errorf("expected the page served after the transient failures, got %q", body)
+	}
+	if got := atomic.LoadInt32(&requests); got != 3 {
+		t.Errorf("expected 3 requests, got %d", got)
+	}
+
+	// Without retries the first failure is returned as an error
+	atomic.StoreInt32(&requests, 0)
+	body, _, err = FetchDocument(server.URL+"/", Options{})
+	if got := atomic.LoadInt32(&requests); err == nil || body != nil || got != 1 {
+		t.Errorf("expected a single failed attempt without retries, got %q (err %v, %d requests)", body, err, got)
+	}
+}
+
+func TestPageFetchFailsOnceRetriesRunOut(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Error(w, "maintenance", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	body, _, err := FetchDocument(server.URL+"/", Options{PageRetries: 2, Log: io.Discard})
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("expected a 503 error once retries run out, got %v", err)
+	}
+	if body != nil {
+		t.Errorf("the error page should not be returned as the document, got %q", body)
+	}
+	if got := atomic.LoadInt32(&requests); got != 3 {
+		t.Errorf("expected the first attempt plus 2 retries, got %d requests", got)
+	}
+
+	// A non-transient status is not retried but still fails
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	if _, err := FetchPage(missing.URL+"/", Options{PageRetries: 2, Log: io.Discard}); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected a 404 error, got %v", err)
+	}
+}
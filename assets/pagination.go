@@ -0,0 +1,171 @@
+package assets
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"wp-static-scraper/utils"
+)
+
+// Page is a fetched HTML page and the output-relative path it is saved to
+type Page struct {
+	URL     *url.URL
+	OutPath string // e.g. "index.html" or "page/2/index.html"
+	Body    []byte
+}
+
+// FetchPaginatedPages follows the rel="next" pagination of an already fetched listing page up to
+// limit pages in total, staying on the start host and opts.CrawlHosts. The first page keeps its
+// OutPath; page N is saved to page/N/index.html.
+func FetchPaginatedPages(first Page, limit int, opts Options) []Page {
+	start := first.URL
+	pages := []Page{first}
+	seen := map[string]bool{pageKey(start): true}
+
+	for len(pages) < limit {
+		if !opts.Deadline.IsZero() && time.Now().After(opts.Deadline) {
+			break
+		}
+		last := pages[len(pages)-1]
+		next, ok := FindNextPageURL(string(last.Body), last.URL)
+		if !ok || seen[pageKey(next)] || !inCrawlScope(next, start, opts.CrawlHosts) {
+			break
+		}
+		seen[pageKey(next)] = true
+
+		body, err := FetchPage(next.String(), opts)
+		if err != nil {
+			fmt.Fprintf(opts.logWriter(), "Failed to fetch page %s: %v\n", next, err)
+			break
+		}
+		pages = append(pages, Page{
+			URL:     next,
+			OutPath: fmt.Sprintf("page/%d/index.html", len(pages)+1),
+			Body:    body,
+		})
+	}
+
+	return pages
+}
+
+// FindNextPageURL returns the next page of a paginated listing, from <link rel="next">, <a rel="next">,
+// or the WordPress paginate_links() "next page-numbers" anchor
+func FindNextPageURL(htmlContent string, base *url.URL) (*url.URL, bool) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil, false
+	}
+
+	var href string
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if href != "" {
+			return
+		}
+		if n.Type == html.ElementNode && (n.Data == "link" || n.Data == "a") {
+			rels := strings.Fields(strings.ToLower(getAttr(n, "rel")))
+			classes := strings.Fields(getAttr(n, "class"))
+			if containsToken(rels, "next") || (n.Data == "a" && containsToken(classes, "next") && containsToken(classes, "page-numbers")) {
+				href = getAttr(n, "href")
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+
+	traverse(doc)
+
+	if href == "" {
+		return nil, false
+	}
+	next, err := url.Parse(utils.ResolveURL(base, href))
+	if err != nil {
+		return nil, false
+	}
+	return next, true
+}
+
+// RewritePageLinks points <a> and <link> hrefs that target another scraped page at its local copy,
+// relative to fromPath. pages maps page URLs (without fragment) to output-relative paths.
+func RewritePageLinks(htmlContent string, base *url.URL, fromPath string, pages map[string]string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", err
+	}
+
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "a" || n.Data == "link") {
+			if href := getAttr(n, "href"); href != "" && !strings.HasPrefix(href, "#") {
+				if target, err := url.Parse(utils.ResolveURL(base, href)); err == nil {
+					if localPath, ok := pages[pageKey(target)]; ok {
+						rel := relativePagePath(fromPath, localPath)
+						if target.Fragment != "" {
+							rel += "#" + target.Fragment
+						}
+						setAttr(n, "href", rel)
+					}
+				}
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+
+	traverse(doc)
+
+	var buf strings.Builder
+	if err := html.Render(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// PageLinkMap maps each page's URL to its output-relative path for RewritePageLinks
+func PageLinkMap(pages []Page) map[string]string {
+	links := make(map[string]string, len(pages))
+	for _, page := range pages {
+		links[pageKey(page.URL)] = page.OutPath
+	}
+	return links
+}
+
+// pageKey normalizes a page URL for lookups by dropping its fragment
+func pageKey(u *url.URL) string {
+	withoutFragment := *u
+	withoutFragment.Fragment = ""
+	return withoutFragment.String()
+}
+
+// pageRootPrefix is the relative path from a saved page back to the output root ("../../" for page/2/index.html)
+func pageRootPrefix(pagePath string) string {
+	return strings.Repeat("../", strings.Count(pagePath, "/"))
+}
+
+// relativePagePath links one output-relative page path to another
+func relativePagePath(fromPath, toPath string) string {
+	rel := pageRootPrefix(fromPath) + toPath
+	// Linking to a sibling index.html in the same directory stays short
+	if path.Dir(fromPath) == path.Dir(toPath) {
+		rel = path.Base(toPath)
+	}
+	return rel
+}
+
+// containsToken reports whether a space-separated attribute value contains token
+func containsToken(tokens []string, token string) bool {
+	for _, t := range tokens {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package assets
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"wp-static-scraper/internal/testsite"
+)
+
+func TestPaginatedArchive(t *testing.T) {
+	chdirOutput(t)
+
+	server := testsite.New(t, map[string]string{
+		"/blog/":        `<html><head><link rel="next" href="/blog/page/2/"></head><body><a class="next page-numbers" href="/blog/page/2/">Next</a></body></html>`,
+		"/blog/page/2/": `<html><head><link rel="prev" href="/blog/"></head><body><img src="/img/post.png"></body></html>`,
+		"/img/post.png": "png",
+	})
+
+	start, _ := url.Parse(server.URL + "/blog/")
+	body, err := FetchPage(start.String(), Options{})
+	if err != nil {
+		t.Fatalf("FetchPage returned error: %v", err)
+	}
+	pages := FetchPaginatedPages(Page{URL: start, OutPath: "index.html", Body: body}, 5, Options{})
+	if len(pages) != 2 {
+		t.Fatalf("expected 2 pages, got %d", len(pages))
+	}
+	if pages[0].OutPath != "index.html" || pages[1].OutPath != "page/2/index.html" {
+		t.Errorf("unexpected output paths: %q, %q", pages[0].OutPath, pages[1].OutPath)
+	}
+
+	second, err := LocalizeAssets(string(pages[1].Body), pages[1].URL, Options{Concurrency: 1, PagePath: pages[1].OutPath})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	if !strings.Contains(second, `src="../../assets/images/post.png"`) {
+		t.Errorf("nested page should climb back to output/assets/: %s", second)
+	}
+
+	links := PageLinkMap(pages)
+	first, err := RewritePageLinks(string(pages[0].Body), pages[0].URL, pages[0].OutPath, links)
+	if err != nil {
+		t.Fatalf("RewritePageLinks returned error: %v", err)
+	}
+	if strings.Contains(first, "/blog/page/2/") || !strings.Contains(first, `href="page/2/index.html"`) {
+		t.Errorf("next links should point at the local copy: %s", first)
+	}
+	second, err = RewritePageLinks(second, pages[1].URL, pages[1].OutPath, links)
+	if err != nil {
+		t.Fatalf("RewritePageLinks returned error: %v", err)
+	}
+	if !strings.Contains(second, `href="../../index.html"`) {
+		t.Errorf("prev link should point back at the first page: %s", second)
+	}
+}
@@ -0,0 +1,175 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"path"
+	"strings"
+
+	"wp-static-scraper/utils"
+)
+
+// urlFilename returns the last path segment of a URL
+func urlFilename(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	segments := strings.Split(u.Path, "/")
+	return segments[len(segments)-1], nil
+}
+
+// mirroredPath maps a URL's path onto a relative path under the assets directory, e.g.
+// wp-content/plugins/foo/style.css. Leading slashes and .. segments are resolved as if the path
+// were rooted, so the result never climbs out of the assets directory; the query is dropped.
+func mirroredPath(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(path.Clean("/"+u.Path), "/"), nil
+}
+
+// localPathFor computes where under outDir an asset of the given job type is saved. With
+// preservePaths, assets other than JSON data mirror their URL path under outDir/assets/ instead of
+// being flattened by basename into per-type directories.
+// The boolean is false when the name can only be known from the response (images without an extension).
+func localPathFor(outDir, jobType, rawURL string, preservePaths bool) (string, bool, error) {
+	filename, err := urlFilename(rawURL)
+	if err != nil {
+		return "", false, err
+	}
+
+	if preservePaths && jobType != "data" {
+		mirrored, err := mirroredPath(rawURL)
+		if err != nil {
+			return "", false, err
+		}
+		switch jobType {
+		case "image":
+			return outDir + "/assets/" + mirrored, strings.Contains(filename, "."), nil
+		case "font", "media", "file":
+			return outDir + "/assets/" + mirrored, true, nil
+		default:
+			if !strings.HasSuffix(mirrored, "."+jobType) {
+				mirrored = mirrored + "." + jobType
+			}
+			return outDir + "/assets/" + mirrored, true, nil
+		}
+	}
+
+	switch jobType {
+	case "image":
+		if !strings.Contains(filename, ".") {
+			return outDir + "/assets/images/" + filename, false, nil
+		}
+		return outDir + "/assets/images/" + filename, true, nil
+	case "font":
+		return outDir + "/assets/fonts/" + filename, true, nil
+	case "media":
+		return outDir + "/assets/media/" + filename, true, nil
+	case "file":
+		return outDir + "/assets/files/" + filename, true, nil
+	case "data":
+		name, err := dataFilename(rawURL)
+		return outDir + "/assets/data/" + name, true, err
+	default:
+		if !strings.HasSuffix(filename, "."+jobType) {
+			filename = filename + "." + jobType
+		}
+		return outDir + "/assets/" + filename, true, nil
+	}
+}
+
+// assetLayout decides where downloaded assets are saved: the output directory, whether URL paths
+// are mirrored under assets/, and whether files are named after their content
+type assetLayout struct {
+	outDir        string
+	preservePaths bool
+	hashNames     bool
+	output        *utils.Output                 // Writes saved files with the configured permissions
+	recordAlias   func(original, hashed string) // Notified of each content-hashed name (nil ignores them)
+}
+
+// pathFor computes where an asset is saved under this layout, before any content naming
+func (l assetLayout) pathFor(jobType, rawURL string) (string, bool, error) {
+	return localPathFor(l.outDir, jobType, rawURL, l.preservePaths)
+}
+
+// contentPath renames a computed local path after its content when names are hashed
+func (l assetLayout) contentPath(localPath string, data []byte) string {
+	if !l.hashNames {
+		return localPath
+	}
+	hashed := hashedPath(localPath, data)
+	if l.recordAlias != nil {
+		l.recordAlias(localPath, hashed)
+	}
+	return hashed
+}
+
+// hashedPath replaces a file's name with the first 10 hex characters of its content's SHA-256,
+// keeping the directory and extension (output/assets/images/3f9a2b1c0d.png), so distinct files
+// sharing a name are kept apart and identical files share one name
+func hashedPath(localPath string, data []byte) string {
+	sum := sha256.Sum256(data)
+	return path.Dir(localPath) + "/" + hex.EncodeToString(sum[:])[:10] + path.Ext(localPath)
+}
+
+// recordAlias notes that a file which would have been saved as original was saved as hashed. Two
+// different files flattened to the same original name leave it ambiguous, so it is dropped from
+// HashAliases rather than pointing at either.
+func (cd *ConcurrentDownloader) recordAlias(original, hashed string) {
+	cd.aliasMu.Lock()
+	defer cd.aliasMu.Unlock()
+	if cd.aliases == nil {
+		cd.aliases = make(map[string]string)
+	}
+	if existing, seen := cd.aliases[original]; seen && existing != hashed {
+		hashed = ""
+	}
+	cd.aliases[original] = hashed
+}
+
+// HashAliases maps each original asset name, relative to the output directory, to the
+// content-hashed name it was saved under with -content-hash-names (nil otherwise)
+func (cd *ConcurrentDownloader) HashAliases() map[string]string {
+	cd.aliasMu.Lock()
+	defer cd.aliasMu.Unlock()
+	var aliases map[string]string
+	for original, hashed := range cd.aliases {
+		if hashed == "" {
+			continue
+		}
+		if aliases == nil {
+			aliases = make(map[string]string, len(cd.aliases))
+		}
+		aliases[outRelative(original, cd.outputDir())] = outRelative(hashed, cd.outputDir())
+	}
+	return aliases
+}
+
+// outRelative converts a saved file's path (outDir/assets/file.ext) into the assets/file.ext form
+// pages reference it by, leaving data: URIs and other references alone
+func outRelative(localPath, outDir string) string {
+	return strings.TrimPrefix(localPath, outDir+"/")
+}
+
+// imageExtensionFor maps an image Content-Type to a file extension
+func imageExtensionFor(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "image/svg+xml":
+		return ".svg"
+	default:
+		return ".jpg" // default fallback
+	}
+}
@@ -0,0 +1,132 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wp-static-scraper/internal/testsite"
+)
+
+func TestPreservePathsKeepsSameNamedAssets(t *testing.T) {
+	_, base := newTestSite(t, map[string]string{
+		"/wp-content/plugins/foo/style.css":       `.foo { color: red; }`,
+		"/wp-content/plugins/bar/style.css":       `@font-face { font-family: "B"; src: url("/wp-content/plugins/bar/fonts/b.woff2"); }`,
+		"/wp-content/plugins/bar/fonts/b.woff2":   "font",
+		"/wp-content/uploads/2024/logo.png":       "png",
+		"/wp-content/themes/site/images/logo.png": "theme png",
+		"/wp-content/themes/site/js/main.js":      "console.log('theme');",
+	})
+	page := `<html><head>` +
+		`<link rel="stylesheet" href="/wp-content/plugins/foo/style.css?ver=1">` +
+		`<link rel="stylesheet" href="/wp-content/plugins/bar/style.css">` +
+		`<script src="/../wp-content/themes/site/js/main.js"></script>` +
+		`</head><body><img src="/wp-content/uploads/2024/logo.png"><img src="/wp-content/themes/site/images/logo.png"></body></html>`
+
+	html, err := LocalizeAssets(page, base, Options{Concurrency: 2, PreservePaths: true})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	files := map[string]string{
+		"output/assets/wp-content/plugins/foo/style.css":       ".foo",
+		"output/assets/wp-content/plugins/bar/style.css":       "@font-face",
+		"output/assets/wp-content/uploads/2024/logo.png":       "png",
+		"output/assets/wp-content/themes/site/images/logo.png": "theme png",
+		"output/assets/wp-content/themes/site/js/main.js":      "theme",
+	}
+	for file, want := range files {
+		data, err := os.ReadFile(file)
+		if err != nil || !strings.Contains(string(data), want) {
+			t.Errorf("%s should survive with its own content, got %q (%v)", file, data, err)
+		}
+	}
+	for _, ref := range []string{
+		`href="assets/wp-content/plugins/foo/style.css"`,
+		`href="assets/wp-content/plugins/bar/style.css"`,
+		`src="assets/wp-content/uploads/2024/logo.png"`,
+		`src="assets/wp-content/themes/site/images/logo.png"`,
+		`src="assets/wp-content/themes/site/js/main.js"`,
+	} {
+		if !strings.Contains(html, ref) {
+			t.Errorf("HTML should reference the mirrored path %s: %s", ref, html)
+		}
+	}
+
+	css, _ := os.ReadFile("output/assets/wp-content/plugins/bar/style.css")
+	_, fontRef, ok := strings.Cut(string(css), `url("`)
+	fontRef, _, _ = strings.Cut(fontRef, `"`)
+	if !ok || fontRef == "" {
+		t.Fatalf("stylesheet should keep a font reference: %s", css)
+	}
+	font := filepath.Join("output/assets/wp-content/plugins/bar", fontRef)
+	if data, err := os.ReadFile(font); err != nil || string(data) != "font" {
+		t.Errorf("font reference %q should resolve from the stylesheet's own directory (%v)", fontRef, err)
+	}
+}
+
+func TestContentHashNames(t *testing.T) {
+	server := testsite.New(t, map[string]string{
+		"/plugins/a/style.css": `.a { color: red; }`,
+		"/plugins/b/style.css": `.b { color: blue; }`,
+		"/img/logo.png":        "same image",
+		"/cdn/logo-copy.png":   "same image",
+	})
+	base, _ := url.Parse(server.URL + "/")
+	page := `<html><head>` +
+		`<link rel="stylesheet" href="/plugins/a/style.css">` +
+		`<link rel="stylesheet" href="/plugins/b/style.css">` +
+		`</head><body><img src="/img/logo.png"><img src="/cdn/logo-copy.png"></body></html>`
+	hashed := func(content, ext string) string {
+		sum := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(sum[:])[:10] + ext
+	}
+
+	for _, concurrentCSS := range []bool{false, true} {
+		t.Run(fmt.Sprintf("concurrent-css=%v", concurrentCSS), func(t *testing.T) {
+			chdirOutput(t)
+
+			html, err := LocalizeAssets(page, base, Options{
+				Concurrency:          2,
+				HashNames:            true,
+				ConcurrentCSSRewrite: concurrentCSS,
+				ManifestPath:         "output/manifest.json",
+			})
+			if err != nil {
+				t.Fatalf("LocalizeAssets returned error: %v", err)
+			}
+
+			styleA, styleB := "assets/"+hashed(`.a { color: red; }`, ".css"), "assets/"+hashed(`.b { color: blue; }`, ".css")
+			for file, want := range map[string]string{styleA: ".a", styleB: ".b"} {
+				if data, err := os.ReadFile("output/" + file); err != nil || !strings.Contains(string(data), want) {
+					t.Errorf("%s should hold its own stylesheet, got %q (%v)", file, data, err)
+				}
+				if !strings.Contains(html, `href="`+file+`"`) {
+					t.Errorf("HTML should reference %s: %s", file, html)
+				}
+			}
+			if _, err := os.Stat("output/assets/style.css"); !os.IsNotExist(err) {
+				t.Errorf("no file should be saved under the shared basename")
+			}
+
+			image := "assets/images/" + hashed("same image", ".png")
+			if strings.Count(html, `src="`+image+`"`) != 2 {
+				t.Errorf("both image URLs should point at the single hashed copy %s: %s", image, html)
+			}
+			entries, _ := os.ReadDir("output/assets/images")
+			if len(entries) != 1 {
+				t.Errorf("identical content should be stored once, found %d files", len(entries))
+			}
+
+			manifestData, _ := os.ReadFile("output/manifest.json")
+			if !strings.Contains(string(manifestData), `"`+styleA+`"`) || !strings.Contains(string(manifestData), `"`+styleB+`"`) {
+				t.Errorf("manifest should list the hashed stylesheet names: %s", manifestData)
+			}
+		})
+	}
+}
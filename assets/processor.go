@@ -1,10 +1,11 @@
 package assets
 
 import (
-	"io"
-	"net/http"
+	"errors"
+	"fmt"
 	"net/url"
-	"os"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
@@ -13,68 +14,326 @@ import (
 	"wp-static-scraper/utils"
 )
 
-// LocalizeAssets processes HTML content and localizes all assets using concurrent downloads
-func LocalizeAssets(htmlContent string, base *url.URL, concurrency int) (string, error) {
+// LocalizeAssets processes HTML content and localizes all assets using concurrent downloads.
+// When opts.Deadline passes mid-run, the partially localized page is returned together with an
+// error wrapping ErrRuntimeExceeded.
+func LocalizeAssets(htmlContent string, base *url.URL, opts Options) (string, error) {
+	// Resolve lazy-loaded iframes first so their documents are saved alongside the page
+	if opts.LazyIframes || opts.PromoteIframeDataSrc {
+		var err error
+		htmlContent, err = localizeLazyIframes(htmlContent, base, opts)
+		if err != nil {
+			return "", err
+		}
+	}
+	
+	// Drop the logged-in admin bar before its assets are collected
+	var skipped []SkippedAsset
+	if opts.StripAdminBar {
+		var removedRefs []string
+		var err error
+		htmlContent, removedRefs, err = stripAdminBar(htmlContent)
+		if err != nil {
+			return "", err
+		}
+		for _, ref := range removedRefs {
+			skipped = append(skipped, SkippedAsset{URL: utils.ResolveURL(base, ref), Reason: SkipAdminBar})
+		}
+	}
+	
+	// Remove all JavaScript for a fully static snapshot before any of it is collected
+	if opts.StripScripts {
+		var err error
+		htmlContent, err = stripScripts(htmlContent)
+		if err != nil {
+			return "", err
+		}
+	}
+	
 	// Phase 1: Collect ALL asset URLs including fonts from inline CSS upfront
-	allJobs, err := collectAllAssetJobs(htmlContent, base)
+	allJobs, err := collectAllAssetJobs(htmlContent, base, opts)
 	if err != nil {
 		return "", err
 	}
 	
-	if len(allJobs) == 0 {
-		return htmlContent, nil
-	}
+	// Consolidate assets served from aliased origins or referenced by several element types
+	// before anything is downloaded, so each source URL is fetched once
+	allJobs = applyOriginAliases(allJobs, opts.OriginAliases)
+	allJobs, aliasPaths := dedupeJobs(allJobs, opts.AssetCase)
 	
 	// Phase 2: Download ALL assets (CSS, JS, Images, Fonts) in parallel
-	downloader := NewConcurrentDownloader(concurrency)
+	downloader, err := newDownloader(opts)
+	if err != nil {
+		return "", err
+	}
 	downloader.Start()
 	
 	// Start progress reporting (reduced frequency for better performance)
-	reporter := NewProgressReporter(downloader, 2*time.Second)
+	reporter := NewProgressReporter(downloader, 2*time.Second, opts.Progress)
 	reporter.Start()
 	
-	// Queue all asset jobs at once - no waiting for CSS to finish
-	for _, job := range allJobs {
-		downloader.AddJob(job)
-	}
-	downloader.FinishJobs()
+	// Queue all asset jobs at once - no waiting for CSS to finish.
+	// Queue from a goroutine so results are drained while jobs are still being added.
+	go func() {
+		for _, job := range allJobs {
+			downloader.AddJob(job)
+		}
+		downloader.FinishJobs()
+	}()
 	
 	// Get results from all downloads
 	urlMap := downloader.GetResults()
 	reporter.Stop()
 	
+	skipped = append(skipped, skippedFromResults(downloader.Results())...)
+	if opts.VerboseSkips {
+		printSkipReasons(opts.logWriter(), skipped)
+	}
+	var largest []DownloadResult
+	if opts.LargestAssets > 0 {
+		largest = LargestAssets(downloader.Results(), opts.LargestAssets)
+		printLargestAssets(opts.logWriter(), largest)
+	}
+	
+	// References to duplicate or aliased copies point at the single canonical download
+	for aliasPath, keptPath := range aliasPaths {
+		if localPath, ok := urlMap[keptPath]; ok {
+			urlMap[aliasPath] = localPath
+		}
+	}
+	
+	if opts.ManifestPath != "" || opts.ReportPath != "" {
+		manifest := BuildManifest(opts.Output, base, downloader.Results(), opts.outputDir())
+		manifest.Skipped = skipped
+		if largest != nil {
+			manifest.Largest = largestEntries(largest, opts.outputDir())
+		}
+		manifest.Partial = errors.Is(downloader.Err(), ErrRuntimeExceeded)
+		manifest.Concurrency = downloader.ConcurrencyStats()
+		manifest.Aliases = downloader.HashAliases()
+		if opts.DedupeReport {
+			stats := downloader.DedupeStats()
+			manifest.Dedupe = &stats
+		}
+		if opts.ManifestPath != "" {
+			if err := WriteManifest(opts.Output, opts.ManifestPath, manifest); err != nil {
+				return "", err
+			}
+		}
+		if opts.ReportPath != "" {
+			if err := WriteReportHTML(opts.Output, opts.ReportPath, manifest); err != nil {
+				return "", err
+			}
+		}
+	}
+	if opts.ImageInventoryPath != "" {
+		if err := WriteImageInventory(opts.Output, opts.ImageInventoryPath, BuildImageInventory(opts.Output, downloader.Results(), opts.outputDir())); err != nil {
+			return "", err
+		}
+	}
+	if opts.HeadersPath != "" {
+		if err := WriteHeaderLog(opts.Output, opts.HeadersPath, downloader.Headers()); err != nil {
+			return "", err
+		}
+	}
+	// A run cut short by the deadline still rewrites whatever was downloaded
+	runErr := downloader.Err()
+	if runErr != nil && !errors.Is(runErr, ErrRuntimeExceeded) {
+		return "", runErr
+	}
+	
+	// Embed small images as data: URIs instead of linking the saved files
+	if opts.InlineImagesBelow > 0 {
+		inlineSmallImages(urlMap, downloader.InlinedImages())
+	}
+	
+	// Remove or blank references that would only error in the snapshot
+	if opts.DropFailedRefs {
+		htmlContent, err = dropFailedReferences(htmlContent, failedReferences(downloader.Results(), aliasPaths))
+		if err != nil {
+			return "", err
+		}
+	}
+	
 	// Phase 3: Process inline JavaScript for template URLs (like Complianz)
-	htmlContent, err = processInlineJavaScript(htmlContent, base)
+	htmlContent, err = processInlineJavaScript(htmlContent, base, opts.layout(), opts.fetcher())
 	if err != nil {
 		return "", err
 	}
 	
+	// Point URLs inside JSON state blobs at their downloads, keeping the JSON valid
+	if len(opts.StateVars) > 0 {
+		htmlContent, err = rewriteStateBlobs(htmlContent, opts.StateVars, urlMap, opts)
+		if err != nil {
+			return "", err
+		}
+	}
+	
 	// Phase 4: Update HTML with all localized asset references
-	updatedHTML, err := updateHTMLWithLocalPaths(htmlContent, base, urlMap)
+	updatedHTML, err := updateHTMLWithLocalPaths(htmlContent, base, urlMap, opts)
 	if err != nil {
 		return "", err
 	}
 	
-	return updatedHTML, nil
+	// Keep src and srcset consistent when they name the same image in different forms
+	imagePaths := make(map[string]string)
+	for _, result := range downloader.Results() {
+		if localPath, ok := urlMap[result.Job.OriginalPath]; ok && result.Success && result.Job.Type == "image" {
+			imagePaths[assetKey(result.Job.URL, opts.AssetCase)] = localPath
+		}
+	}
+	updatedHTML, err = alignImageSources(updatedHTML, base, imagePaths, opts)
+	if err != nil {
+		return "", err
+	}
+	
+	// Subresource Integrity must describe the files as saved, not as the origin served them
+	updatedHTML, err = recomputeIntegrity(updatedHTML, opts)
+	if err != nil {
+		return "", err
+	}
+	
+	// Point inline fetch()/apiFetch() calls at the saved JSON preloads
+	dataPaths := make(map[string]string)
+	for _, result := range downloader.Results() {
+		if result.Success && result.Job.Type == "data" {
+			dataPaths[result.Job.URL] = result.LocalPath
+		}
+	}
+	updatedHTML, err = rewriteInlineDataFetches(updatedHTML, base, dataPaths, opts)
+	if err != nil {
+		return "", err
+	}
+	
+	// Reduce responsive images to a single src for targets without srcset support
+	if opts.SingleSrc != nil {
+		updatedHTML, err = collapseSrcsets(updatedHTML, *opts.SingleSrc)
+		if err != nil {
+			return "", err
+		}
+	}
+	
+	return updatedHTML, runErr
+}
+
+// newDownloader builds a worker pool configured from opts; the caller starts it
+func newDownloader(opts Options) (*ConcurrentDownloader, error) {
+	downloader := NewConcurrentDownloader(opts.Concurrency)
+	downloader.MaxTotalBytes = opts.MaxTotalBytes
+	downloader.FailFast = opts.FailFast
+	downloader.SkipExisting = opts.SkipExisting
+	downloader.ParseSourceMaps = opts.ParseSourceMaps
+	downloader.Dedupe = opts.Dedupe
+	downloader.DedupeReport = opts.DedupeReport
+	downloader.ConcurrentCSS = opts.ConcurrentCSSRewrite
+	downloader.LineEndings = opts.LineEndings
+	downloader.WriteLimit = opts.WriteLimit
+	downloader.CSSURLBase = opts.CSSURLBase
+	downloader.FontURLPrefix = opts.FontURLPrefix
+	downloader.InlineBelow = opts.InlineImagesBelow
+	downloader.ImageQuality = opts.ImageQuality
+	downloader.RequestIDHeader = opts.RequestIDHeader
+	downloader.BasicAuth = opts.BasicAuth
+	downloader.UserAgent = opts.UserAgent
+	downloader.CredentialHosts = opts.credentialHosts()
+	downloader.RequestHeaders = opts.Headers
+	downloader.MaxFileSize = opts.MaxFileSize
+	downloader.AcceptEncoding = opts.AcceptEncoding
+	downloader.RetryEmpty = opts.RetryEmpty
+	downloader.PreserveMTime = opts.PreserveMTime
+	downloader.AssetCase = opts.AssetCase
+	downloader.OutDir = opts.OutDir
+	downloader.Output = opts.Output
+	downloader.PreservePaths = opts.PreservePaths
+	downloader.HashNames = opts.HashNames
+	downloader.Log = opts.Log
+	if !opts.Deadline.IsZero() {
+		downloader.SetDeadline(opts.Deadline)
+	}
+	downloader.CaptureHeaders = opts.HeadersPath != ""
+	if opts.BackoffOnErrors {
+		downloader.EnableBackoff(opts.Backoff)
+	}
+	if opts.MaxRedirects > 0 {
+		downloader.SetMaxRedirects(opts.MaxRedirects)
+	}
+	if len(opts.ExtensionLimits) > 0 {
+		downloader.SetExtensionLimits(opts.ExtensionLimits)
+	}
+	if len(opts.ExcludeExtensions) > 0 {
+		downloader.SetExcludedExtensions(opts.ExcludeExtensions)
+	}
+	if opts.RequestLimiter != nil {
+		downloader.SetRequestLimiter(opts.RequestLimiter)
+	}
+	if opts.ProgressJSON != nil {
+		downloader.SetProgressJSON(opts.ProgressJSON)
+	}
+	if opts.PurgeCSS != nil {
+		downloader.SetCSSPurge(opts.PurgeCSS)
+	}
+	if opts.AssetProxy != "" {
+		proxyURL, err := url.Parse(opts.AssetProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asset proxy: %w", err)
+		}
+		downloader.SetProxy(proxyURL)
+	}
+	return downloader, nil
 }
 
-// collectAllAssetJobs parses HTML and collects ALL asset download jobs including fonts from inline CSS
-func collectAllAssetJobs(htmlContent string, base *url.URL) ([]DownloadJob, error) {
+// collectAllAssetJobs parses HTML and collects ALL asset download jobs including fonts from inline CSS.
+// opts.InlineStyleFonts also collects font url() references from style attributes, opts.Prefetch
+// the targets of <link rel="prefetch">, and opts.ExcludeExtensions drops jobs by file extension.
+func collectAllAssetJobs(htmlContent string, base *url.URL, opts Options) ([]DownloadJob, error) {
 	// First collect primary assets
-	jobs, err := collectAssetJobs(htmlContent, base)
+	jobs, err := collectAssetJobs(htmlContent, base, opts.Prefetch)
 	if err != nil {
 		return nil, err
 	}
 	
-	// Then collect fonts from inline CSS in <style> tags
-	fontJobs := collectInlineFontJobs(htmlContent, base)
+	// Then collect fonts from inline CSS in <style> tags (and style attributes when requested)
+	fontJobs := collectInlineFontJobs(htmlContent, base, opts.InlineStyleFonts)
 	jobs = append(jobs, fontJobs...)
 	
+	// Asset URLs inside JSON state blobs assigned by inline scripts
+	if len(opts.StateVars) > 0 {
+		jobs = append(jobs, collectStateBlobJobs(htmlContent, base, opts.StateVars)...)
+	}
+	
+	// Theme- or plugin-specific attributes declared with -asset-rule
+	if len(opts.AssetRules) > 0 {
+		jobs = append(jobs, collectAssetRuleJobs(htmlContent, base, opts.AssetRules)...)
+	}
+	
+	if len(opts.ExcludeExtensions) > 0 {
+		jobs = excludeExtensions(jobs, opts.ExcludeExtensions)
+	}
+	
 	return jobs, nil
 }
 
-// collectAssetJobs parses HTML and collects primary asset download jobs
-func collectAssetJobs(htmlContent string, base *url.URL) ([]DownloadJob, error) {
+// excludeExtensions drops jobs whose URL path ends in one of the given extensions, leaving those
+// references remote
+func excludeExtensions(jobs []DownloadJob, extensions []string) []DownloadJob {
+	excluded := make(map[string]bool, len(extensions))
+	for _, ext := range extensions {
+		excluded[normalizeExt(ext)] = true
+	}
+	
+	kept := jobs[:0]
+	for _, job := range jobs {
+		if u, err := url.Parse(job.URL); err == nil && excluded[normalizeExt(path.Ext(u.Path))] {
+			continue
+		}
+		kept = append(kept, job)
+	}
+	return kept
+}
+
+// collectAssetJobs parses HTML and collects primary asset download jobs, including prefetch hints
+// when prefetch is set
+func collectAssetJobs(htmlContent string, base *url.URL, prefetch bool) ([]DownloadJob, error) {
 	doc, err := html.Parse(strings.NewReader(htmlContent))
 	if err != nil {
 		return nil, err
@@ -87,30 +346,58 @@ func collectAssetJobs(htmlContent string, base *url.URL) ([]DownloadJob, error)
 	traverse = func(n *html.Node) {
 		// Collect CSS and JS from <link> and <script> tags
 		if n.Type == html.ElementNode && n.Data == "link" {
-			var href, rel string
+			var href, rel, as, linkType string
 			for _, attr := range n.Attr {
 				if attr.Key == "href" {
 					href = attr.Val
 				}
+				if attr.Key == "type" {
+					linkType = attr.Val
+				}
 				if attr.Key == "rel" {
 					rel = attr.Val
 				}
+				if attr.Key == "as" {
+					as = strings.ToLower(attr.Val)
+				}
 			}
-			if (rel == "stylesheet" || rel == "preload") && href != "" {
-				resolvedURL := utils.ResolveURL(base, href)
-				if !urlSeen[resolvedURL] {
+			// Fetch preloads are only worth saving when they declare a JSON type
+			preload := rel == "preload" && (as != "fetch" || isJSONType(linkType))
+			if (rel == "stylesheet" || preload) && href != "" {
+				jobType := "css"
+				if rel == "preload" {
+					jobType = preloadJobType(as)
+				}
+				resolvedURL, ok := resolveAssetURL(base, href)
+				if ok && !urlSeen[resolvedURL] {
 					urlSeen[resolvedURL] = true
 					jobs = append(jobs, DownloadJob{
 						URL:          resolvedURL,
-						Type:         "css",
+						Type:         jobType,
 						OriginalPath: href,
 						BaseURL:      base,
 					})
 				}
 			}
+			if prefetch && rel == "prefetch" && href != "" {
+				jobType, ok := prefetchJobType(as, href)
+				resolvedURL, resolved := resolveAssetURL(base, href)
+				if ok && resolved && !urlSeen[resolvedURL] {
+					urlSeen[resolvedURL] = true
+					jobs = append(jobs, DownloadJob{
+						URL:          resolvedURL,
+						Type:         jobType,
+						OriginalPath: href,
+						BaseURL:      base,
+					})
+				}
+			}
+			if isMicrodataImage(getAttr(n, "itemprop")) {
+				jobs = append(jobs, collectMicrodataImageJob(href, base, urlSeen)...)
+			}
 			if rel == "manifest" && href != "" {
-				resolvedURL := utils.ResolveURL(base, href)
-				if !urlSeen[resolvedURL] {
+				resolvedURL, ok := resolveAssetURL(base, href)
+				if ok && !urlSeen[resolvedURL] {
 					urlSeen[resolvedURL] = true
 					jobs = append(jobs, DownloadJob{
 						URL:          resolvedURL,
@@ -121,8 +408,8 @@ func collectAssetJobs(htmlContent string, base *url.URL) ([]DownloadJob, error)
 				}
 			}
 			if (rel == "icon" || rel == "shortcut icon" || rel == "apple-touch-icon") && href != "" {
-				resolvedURL := utils.ResolveURL(base, href)
-				if !urlSeen[resolvedURL] {
+				resolvedURL, ok := resolveAssetURL(base, href)
+				if ok && !urlSeen[resolvedURL] {
 					urlSeen[resolvedURL] = true
 					jobs = append(jobs, DownloadJob{
 						URL:          resolvedURL,
@@ -142,8 +429,8 @@ func collectAssetJobs(htmlContent string, base *url.URL) ([]DownloadJob, error)
 				}
 			}
 			if src != "" {
-				resolvedURL := utils.ResolveURL(base, src)
-				if !urlSeen[resolvedURL] {
+				resolvedURL, ok := resolveAssetURL(base, src)
+				if ok && !urlSeen[resolvedURL] {
 					urlSeen[resolvedURL] = true
 					jobs = append(jobs, DownloadJob{
 						URL:          resolvedURL,
@@ -162,8 +449,7 @@ func collectAssetJobs(htmlContent string, base *url.URL) ([]DownloadJob, error)
 				if attr.Key == "src" || attr.Key == "data-src" {
 					src = attr.Val
 				}
-				if src != "" && (strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://")) {
-					resolvedURL := utils.ResolveURL(base, src)
+				if resolvedURL, ok := resolveAssetURL(base, src); ok {
 					if !urlSeen[resolvedURL] {
 						urlSeen[resolvedURL] = true
 						jobs = append(jobs, DownloadJob{
@@ -182,6 +468,65 @@ func collectAssetJobs(htmlContent string, base *url.URL) ([]DownloadJob, error)
 			}
 		}
 		
+		// Collect responsive candidates from <picture><source srcset>; the fallback <img> is handled above
+		if n.Type == html.ElementNode && n.Data == "source" && n.Parent != nil && n.Parent.Data == "picture" {
+			for _, attr := range n.Attr {
+				if attr.Key == "srcset" || attr.Key == "data-srcset" {
+					jobs = append(jobs, collectSrcsetJobsWithDupeCheck(attr.Val, base, urlSeen)...)
+				}
+			}
+		}
+		
+		// Collect images from <input type="image"> form buttons
+		if n.Type == html.ElementNode && n.Data == "input" {
+			var inputType, src string
+			for _, attr := range n.Attr {
+				if attr.Key == "type" {
+					inputType = strings.ToLower(attr.Val)
+				}
+				if attr.Key == "src" {
+					src = attr.Val
+				}
+			}
+			if inputType == "image" {
+				if resolvedURL, ok := resolveAssetURL(base, src); ok && !urlSeen[resolvedURL] {
+					urlSeen[resolvedURL] = true
+					jobs = append(jobs, DownloadJob{
+						URL:          resolvedURL,
+						Type:         "image",
+						OriginalPath: src,
+						BaseURL:      base,
+					})
+				}
+			}
+		}
+		
+		// Collect files offered for download with <a download>, e.g. PDFs and ZIPs
+		if n.Type == html.ElementNode && n.Data == "a" {
+			var href string
+			var download bool
+			for _, attr := range n.Attr {
+				if attr.Key == "href" {
+					href = attr.Val
+				}
+				if attr.Key == "download" {
+					download = true
+				}
+			}
+			if download && href != "" {
+				resolvedURL, ok := resolveAssetURL(base, href)
+				if ok && !urlSeen[resolvedURL] {
+					urlSeen[resolvedURL] = true
+					jobs = append(jobs, DownloadJob{
+						URL:          resolvedURL,
+						Type:         "file",
+						OriginalPath: href,
+						BaseURL:      base,
+					})
+				}
+			}
+		}
+		
 		// Collect images from <meta> tags
 		if n.Type == html.ElementNode && n.Data == "meta" {
 			var content, property, name string
@@ -212,6 +557,24 @@ func collectAssetJobs(htmlContent string, base *url.URL) ([]DownloadJob, error)
 					})
 				}
 			}
+			
+			// Schema.org microdata images, which may be relative
+			if isMicrodataImage(getAttr(n, "itemprop")) {
+				jobs = append(jobs, collectMicrodataImageJob(content, base, urlSeen)...)
+			}
+			
+			// Open Graph video and audio are saved alongside other media
+			if openGraphMediaProperties[property] {
+				if resolvedURL, ok := resolveAssetURL(base, content); ok && !urlSeen[resolvedURL] {
+					urlSeen[resolvedURL] = true
+					jobs = append(jobs, DownloadJob{
+						URL:          resolvedURL,
+						Type:         "media",
+						OriginalPath: content,
+						BaseURL:      base,
+					})
+				}
+			}
 		}
 		
 		// Collect background images from style attributes
@@ -221,6 +584,17 @@ func collectAssetJobs(htmlContent string, base *url.URL) ([]DownloadJob, error)
 					styleJobs := collectStyleBackgroundJobsWithDupeCheck(attr.Val, base, urlSeen)
 					jobs = append(jobs, styleJobs...)
 				}
+				if attr.Key == "style" && strings.Contains(attr.Val, "image-set(") {
+					imageSetJobs := collectImageSetJobsWithDupeCheck(attr.Val, base, urlSeen)
+					jobs = append(jobs, imageSetJobs...)
+				}
+			}
+		}
+		
+		// Collect url() and image-set() images from <style> blocks, at any @media/@supports depth
+		if n.Type == html.ElementNode && n.Data == "style" {
+			if n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+				jobs = append(jobs, collectStyleBlockImageJobs(n.FirstChild.Data, base)...)
 			}
 		}
 		
@@ -233,6 +607,119 @@ func collectAssetJobs(htmlContent string, base *url.URL) ([]DownloadJob, error)
 	return jobs, nil
 }
 
+// preloadJobType routes a <link rel="preload"> by its as attribute; untyped preloads are treated as CSS
+func preloadJobType(as string) string {
+	switch as {
+	case "script":
+		return "js"
+	case "font":
+		return "font"
+	case "image":
+		return "image"
+	case "audio", "video":
+		return "media"
+	case "fetch":
+		return "data"
+	default:
+		return "css"
+	}
+}
+
+// prefetchJobType routes a <link rel="prefetch"> by its as attribute, or by the file extension when
+// it has none. Prefetched documents and targets without a recognizable extension (usually the next
+// page) are not assets and are skipped.
+func prefetchJobType(as, href string) (string, bool) {
+	if as == "document" {
+		return "", false
+	}
+	if as != "" {
+		return preloadJobType(as), true
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".css":
+		return "css", true
+	case ".js", ".mjs":
+		return "js", true
+	case ".json":
+		return "data", true
+	case ".woff", ".woff2", ".ttf", ".otf", ".eot":
+		return "font", true
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp", ".avif", ".svg", ".ico":
+		return "image", true
+	case ".mp4", ".webm", ".mov", ".mp3", ".ogg", ".wav", ".m4a":
+		return "media", true
+	}
+	return "", false
+}
+
+// microdataImageProps are schema.org itemprop names whose <meta content> or <link href> is an image
+var microdataImageProps = map[string]bool{
+	"image":        true,
+	"logo":         true,
+	"thumbnailUrl": true,
+}
+
+// isMicrodataImage reports whether a space-separated itemprop value names an image property
+func isMicrodataImage(itemprop string) bool {
+	for _, prop := range strings.Fields(itemprop) {
+		if microdataImageProps[prop] {
+			return true
+		}
+	}
+	return false
+}
+
+// collectMicrodataImageJob resolves an itemprop image reference into at most one image job
+func collectMicrodataImageJob(ref string, base *url.URL, urlSeen map[string]bool) []DownloadJob {
+	resolvedURL, ok := resolveAssetURL(base, ref)
+	if !ok || urlSeen[resolvedURL] {
+		return nil
+	}
+	urlSeen[resolvedURL] = true
+	return []DownloadJob{{
+		URL:          resolvedURL,
+		Type:         "image",
+		OriginalPath: ref,
+		BaseURL:      base,
+	}}
+}
+
+// openGraphMediaProperties are <meta property> values that reference video or audio files
+var openGraphMediaProperties = map[string]bool{
+	"og:video":            true,
+	"og:video:url":        true,
+	"og:video:secure_url": true,
+	"og:audio":            true,
+	"og:audio:url":        true,
+	"og:audio:secure_url": true,
+}
+
+// resolveAssetURL resolves an absolute, root-relative, relative, or protocol-relative asset reference
+// against the page base. Inline data:/blob: references are not downloadable. Protocol-relative refs
+// take the page scheme, falling back to https when the page was not fetched over http(s).
+func resolveAssetURL(base *url.URL, ref string) (string, bool) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "blob:") || strings.HasPrefix(ref, "#") {
+		return "", false
+	}
+	if strings.HasPrefix(ref, "//") {
+		scheme := strings.ToLower(base.Scheme)
+		if scheme != "http" && scheme != "https" {
+			scheme = "https"
+		}
+		return scheme + ":" + ref, true
+	}
+	resolvedURL := utils.ResolveURL(base, ref)
+	if !strings.HasPrefix(resolvedURL, "http://") && !strings.HasPrefix(resolvedURL, "https://") {
+		return "", false
+	}
+	return resolvedURL, true
+}
+
 // collectSrcsetJobs extracts image URLs from srcset attributes (legacy function)
 func collectSrcsetJobs(srcsetContent string, base *url.URL) []DownloadJob {
 	urlSeen := make(map[string]bool)
@@ -243,21 +730,10 @@ func collectSrcsetJobs(srcsetContent string, base *url.URL) []DownloadJob {
 func collectSrcsetJobsWithDupeCheck(srcsetContent string, base *url.URL, urlSeen map[string]bool) []DownloadJob {
 	var jobs []DownloadJob
 	
-	entries := strings.Split(srcsetContent, ",")
-	for _, entry := range entries {
-		entry = strings.TrimSpace(entry)
-		if entry == "" {
-			continue
-		}
-		
-		parts := strings.Fields(entry)
-		if len(parts) == 0 {
-			continue
-		}
-		
-		imageURL := parts[0]
-		if strings.HasPrefix(imageURL, "http://") || strings.HasPrefix(imageURL, "https://") {
-			resolvedURL := utils.ResolveURL(base, imageURL)
+	// data: candidates are kept inline and never queued
+	for _, candidate := range parseSrcset(srcsetContent) {
+		imageURL := candidate.URL
+		if resolvedURL, ok := resolveAssetURL(base, imageURL); ok {
 			if !urlSeen[resolvedURL] {
 				urlSeen[resolvedURL] = true
 				jobs = append(jobs, DownloadJob{
@@ -309,9 +785,72 @@ func collectStyleBackgroundJobsWithDupeCheck(styleContent string, base *url.URL,
 	return jobs
 }
 
+// imageSetRe matches image-set() and -webkit-image-set() including nested url() candidates
+var imageSetRe = regexp.MustCompile(`(?:-webkit-)?image-set\(((?:[^()]|\([^()]*\))*)\)`)
+
+// imageSetCandidateRe matches a candidate inside image-set(), either url(...) or a quoted string
+var imageSetCandidateRe = regexp.MustCompile(`url\(\s*['"]?([^'")\s]+)['"]?\s*\)|['"]([^'"]+)['"]`)
+
+// collectImageSetJobsWithDupeCheck extracts every candidate image from CSS image-set() functions with duplicate checking
+func collectImageSetJobsWithDupeCheck(cssContent string, base *url.URL, urlSeen map[string]bool) []DownloadJob {
+	var jobs []DownloadJob
+	
+	for _, set := range imageSetRe.FindAllStringSubmatch(cssContent, -1) {
+		for _, candidate := range imageSetCandidateRe.FindAllStringSubmatch(set[1], -1) {
+			imagePath := candidate[1]
+			if imagePath == "" {
+				imagePath = candidate[2]
+			}
+			if imagePath == "" || strings.HasPrefix(imagePath, "data:") {
+				continue
+			}
+			
+			var imageURL string
+			if strings.HasPrefix(imagePath, "//") {
+				imageURL = base.Scheme + ":" + imagePath
+			} else {
+				imageURL = utils.ResolveURL(base, imagePath)
+			}
+			if !urlSeen[imageURL] {
+				urlSeen[imageURL] = true
+				jobs = append(jobs, DownloadJob{
+					URL:          imageURL,
+					Type:         "image",
+					OriginalPath: imagePath,
+					BaseURL:      base,
+				})
+			}
+		}
+	}
+	
+	return jobs
+}
+
+// collectStyleBlockImageJobs extracts the images an inline stylesheet references; fonts are left to
+// collectInlineFontJobs. Each reference is kept as written, even when another element shares its URL,
+// so dedupeJobs can rewrite both.
+func collectStyleBlockImageJobs(cssContent string, base *url.URL) []DownloadJob {
+	var jobs []DownloadJob
+	refSeen := make(map[string]bool)
+	for _, ref := range cssURLRefs(cssContent) {
+		imageURL, ok := resolveAssetURL(base, ref)
+		if !ok || refSeen[ref] || cssAssetType(imageURL) != "image" {
+			continue
+		}
+		refSeen[ref] = true
+		jobs = append(jobs, DownloadJob{
+			URL:          imageURL,
+			Type:         "image",
+			OriginalPath: ref,
+			BaseURL:      base,
+		})
+	}
+	return jobs
+}
 
-// collectInlineFontJobs extracts font URLs from inline CSS within <style> tags
-func collectInlineFontJobs(htmlContent string, base *url.URL) []DownloadJob {
+// collectInlineFontJobs extracts font URLs from inline CSS within <style> tags, and from style
+// attributes when styleAttrs is set (email-style HTML puts font url() references there)
+func collectInlineFontJobs(htmlContent string, base *url.URL, styleAttrs bool) []DownloadJob {
 	doc, err := html.Parse(strings.NewReader(htmlContent))
 	if err != nil {
 		return nil
@@ -337,6 +876,20 @@ func collectInlineFontJobs(htmlContent string, base *url.URL) []DownloadJob {
 			}
 		}
 		
+		if styleAttrs && n.Type == html.ElementNode {
+			for _, attr := range n.Attr {
+				if attr.Key != "style" || !strings.Contains(attr.Val, "url(") {
+					continue
+				}
+				for _, job := range collectFontJobsFromCSS(attr.Val, base) {
+					if !urlSeen[job.URL] {
+						urlSeen[job.URL] = true
+						jobs = append(jobs, job)
+					}
+				}
+			}
+		}
+		
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
 			traverse(c)
 		}
@@ -392,55 +945,227 @@ func collectFontJobsFromCSS(cssContent string, base *url.URL) []DownloadJob {
 }
 
 // updateHTMLWithLocalPaths updates HTML content with localized asset paths
-func updateHTMLWithLocalPaths(htmlContent string, base *url.URL, urlMap map[string]string) (string, error) {
-	// For now, use a simple string replacement approach
-	// This could be optimized to use HTML parsing if needed
-	updatedHTML := htmlContent
+func updateHTMLWithLocalPaths(htmlContent string, base *url.URL, urlMap map[string]string, opts Options) (string, error) {
+	updatedHTML, err := rewriteLocalReferences(htmlContent, urlMap, opts)
+	if err != nil {
+		return "", err
+	}
+	
+	// Drop tracking noise such as utm_* from links and references that stayed remote
+	if len(opts.TrackingParams) > 0 {
+		updatedHTML, err = trimTrackingParams(updatedHTML, opts.TrackingParams)
+		if err != nil {
+			return "", err
+		}
+	}
 	
-	for originalPath, localPath := range urlMap {
-		// Convert output/assets/file.ext to assets/file.ext for HTML references
-		relativePath := strings.TrimPrefix(localPath, "output/")
-		updatedHTML = strings.ReplaceAll(updatedHTML, originalPath, relativePath)
+	// Normalize references that stayed remote so they work under any serving scheme
+	if opts.ProtocolRelativeScheme != "" {
+		updatedHTML, err = rewriteProtocolRelative(updatedHTML, opts.ProtocolRelativeScheme, opts.AssetRules)
+		if err != nil {
+			return "", err
+		}
 	}
 	
 	return updatedHTML, nil
 }
 
-// LocalizeSrcset processes srcset attributes for responsive images
-func LocalizeSrcset(srcsetContent string, base *url.URL) (string, error) {
-	if srcsetContent == "" {
-		return srcsetContent, nil
+// srcsetAttrs hold comma-separated candidate lists rather than a single URL
+var srcsetAttrs = map[string]bool{"srcset": true, "data-srcset": true, "imagesrcset": true}
+
+// rewriteLocalReferences points the references collected from the page at their downloads. Only
+// whole attribute values, individual srcset candidates (descriptors kept), and url() references in
+// style attributes and <style> blocks are matched against urlMap, so an original path that also
+// appears inside a longer URL (logo.png within logo.png?v=2 or /old/logo.png) is never touched.
+func rewriteLocalReferences(htmlContent string, urlMap map[string]string, opts Options) (string, error) {
+	if len(urlMap) == 0 {
+		return htmlContent, nil
+	}
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", err
+	}
+	
+	localRef := func(ref string) (string, bool) {
+		localPath, ok := urlMap[strings.TrimSpace(ref)]
+		if !ok {
+			return "", false
+		}
+		if strings.HasPrefix(localPath, "data:") {
+			return localPath, true
+		}
+		return pageRootPrefix(opts.PagePath) + outRelative(localPath, opts.outputDir()), true
+	}
+	
+	// Attributes holding a single URL, including those named by -asset-rule
+	urlAttrs := map[string]bool{"src": true, "href": true, "content": true, "data-src": true, "poster": true}
+	for _, rule := range opts.AssetRules {
+		urlAttrs[rule.Attr] = true
+	}
+	
+	changed := false
+	rewriteCSS := func(css string) string {
+		rewritten := rewriteCSSURLRefs(css, localRef)
+		changed = changed || rewritten != css
+		return rewritten
+	}
+	
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for i, attr := range n.Attr {
+				switch {
+				case srcsetAttrs[attr.Key]:
+					if rewritten, ok := rewriteSrcset(attr.Val, localRef); ok {
+						n.Attr[i].Val = rewritten
+						changed = true
+					}
+				case attr.Key == "style":
+					n.Attr[i].Val = rewriteCSS(attr.Val)
+				case urlAttrs[attr.Key]:
+					if local, ok := localRef(attr.Val); ok {
+						n.Attr[i].Val = local
+						changed = true
+					}
+				}
+			}
+			if n.Data == "style" {
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					if c.Type == html.TextNode {
+						c.Data = rewriteCSS(c.Data)
+					}
+				}
+			}
+		}
+		
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
 	}
+	
+	traverse(doc)
+	if !changed {
+		return htmlContent, nil
+	}
+	
+	var buf strings.Builder
+	if err := html.Render(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
 
-	// Split srcset by comma to handle multiple entries
-	entries := strings.Split(srcsetContent, ",")
-	var localizedEntries []string
+// protocolRelativeAttrs are the attributes holding a single URL that -rewrite-protocol-relative
+// gives an explicit scheme; srcset attributes are rewritten candidate by candidate
+var protocolRelativeAttrs = map[string]bool{
+	"src": true, "href": true, "action": true, "formaction": true, "poster": true, "data-src": true,
+	"content": true, "cite": true, "data": true, "background": true,
+}
 
-	for _, entry := range entries {
-		entry = strings.TrimSpace(entry)
-		if entry == "" {
-			continue
+// rewriteProtocolRelative gives protocol-relative references (//cdn/x.js) the scheme: whole URL
+// attribute values, including those named by asset rules, each srcset candidate, and url()
+// references in style attributes and <style> blocks. Text content is never touched.
+func rewriteProtocolRelative(htmlContent, scheme string, rules []AssetRule) (string, error) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", err
+	}
+	
+	withScheme := func(ref string) (string, bool) {
+		ref = strings.TrimSpace(ref)
+		if !strings.HasPrefix(ref, "//") || strings.HasPrefix(ref, "///") {
+			return "", false
 		}
-
-		// Split entry into URL and descriptor (e.g., "image.jpg 2x" or "image.jpg 300w")
-		parts := strings.Fields(entry)
-		if len(parts) == 0 {
-			continue
+		return scheme + ":" + ref, true
+	}
+	isURLAttr := func(key string) bool {
+		if protocolRelativeAttrs[key] {
+			return true
+		}
+		for _, rule := range rules {
+			if rule.Attr == key {
+				return true
+			}
+		}
+		return false
+	}
+	
+	changed := false
+	rewriteCSS := func(css string) string {
+		rewritten := rewriteCSSURLRefs(css, withScheme)
+		changed = changed || rewritten != css
+		return rewritten
+	}
+	
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for i, attr := range n.Attr {
+				switch {
+				case srcsetAttrs[attr.Key]:
+					if rewritten, ok := rewriteSrcset(attr.Val, withScheme); ok {
+						n.Attr[i].Val = rewritten
+						changed = true
+					}
+				case attr.Key == "style":
+					n.Attr[i].Val = rewriteCSS(attr.Val)
+				case isURLAttr(attr.Key):
+					if rewritten, ok := withScheme(attr.Val); ok {
+						n.Attr[i].Val = rewritten
+						changed = true
+					}
+				}
+			}
+			if n.Data == "style" {
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					if c.Type == html.TextNode {
+						c.Data = rewriteCSS(c.Data)
+					}
+				}
+			}
 		}
+		
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+	
+	traverse(doc)
+	if !changed {
+		return htmlContent, nil
+	}
+	
+	var buf strings.Builder
+	if err := html.Render(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// LocalizeSrcset processes srcset attributes for responsive images, saving them under outDir
+func LocalizeSrcset(srcsetContent string, base *url.URL, outDir string) (string, error) {
+	if srcsetContent == "" {
+		return srcsetContent, nil
+	}
 
-		imageURL := parts[0]
+	// Parse candidates so commas inside data: URIs don't split entries
+	var localizedEntries []string
+
+	for _, candidate := range parseSrcset(srcsetContent) {
+		entry := candidate.String()
+		imageURL := candidate.URL
 		descriptor := ""
-		if len(parts) > 1 {
-			descriptor = " " + strings.Join(parts[1:], " ")
+		if candidate.Descriptor != "" {
+			descriptor = " " + candidate.Descriptor
 		}
 
 		// Only process HTTP/HTTPS URLs
 		if strings.HasPrefix(imageURL, "http://") || strings.HasPrefix(imageURL, "https://") {
 			resolvedURL := utils.ResolveURL(base, imageURL)
-			localPath, err := DownloadImage(resolvedURL)
+			localPath, err := DownloadImage(resolvedURL, outDir)
 			if err == nil {
 				// Convert output/assets/images/file.jpg to assets/images/file.jpg for HTML references
-				relativePath := strings.TrimPrefix(localPath, "output/")
+				relativePath := outRelative(localPath, outDir)
 				localizedEntries = append(localizedEntries, relativePath+descriptor)
 			} else {
 				// If download failed, keep original URL
@@ -456,12 +1181,33 @@ func LocalizeSrcset(srcsetContent string, base *url.URL) (string, error) {
 }
 
 // processInlineJavaScript processes inline script tags for template URLs
-func processInlineJavaScript(htmlContent string, base *url.URL) (string, error) {
+func processInlineJavaScript(htmlContent string, base *url.URL, layout assetLayout, fetch fetchFunc) (string, error) {
 	doc, err := html.Parse(strings.NewReader(htmlContent))
 	if err != nil {
 		return "", err
 	}
 	
+	localizeInlineScripts(doc, base, layout, fetch)
+	
+	// Convert back to HTML
+	var buf strings.Builder
+	err = html.Render(&buf, doc)
+	if err != nil {
+		return "", err
+	}
+	
+	return buf.String(), nil
+}
+
+// LocalizeInlineScripts runs LocalizeJavaScriptURLs over every inline script in a parsed document.
+// A script's text may be split across several text nodes (by the parser or by earlier DOM edits),
+// so the nodes are joined before processing and the result is written back as a single node.
+func LocalizeInlineScripts(doc *html.Node, base *url.URL, outDir string) {
+	localizeInlineScripts(doc, base, assetLayout{outDir: outDir}, Options{}.fetcher())
+}
+
+// localizeInlineScripts is LocalizeInlineScripts saving referenced assets under layout
+func localizeInlineScripts(doc *html.Node, base *url.URL, layout assetLayout, fetch fetchFunc) {
 	var processScript func(*html.Node)
 	processScript = func(n *html.Node) {
 		if n.Type == html.ElementNode && n.Data == "script" {
@@ -474,12 +1220,23 @@ func processInlineJavaScript(htmlContent string, base *url.URL) (string, error)
 				}
 			}
 			
-			if !hasSrc && n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+			var textNodes []*html.Node
+			var scriptContent strings.Builder
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.TextNode {
+					textNodes = append(textNodes, c)
+					scriptContent.WriteString(c.Data)
+				}
+			}
+			
+			if !hasSrc && len(textNodes) > 0 {
 				// Process inline JavaScript content
-				scriptContent := n.FirstChild.Data
-				processedContent, err := LocalizeJavaScriptURLs(scriptContent, base)
-				if err == nil && processedContent != scriptContent {
-					n.FirstChild.Data = processedContent
+				processedContent, err := localizeJavaScriptURLs(scriptContent.String(), base, layout, fetch)
+				if err == nil && (processedContent != scriptContent.String() || len(textNodes) > 1) {
+					textNodes[0].Data = processedContent
+					for _, extra := range textNodes[1:] {
+						n.RemoveChild(extra)
+					}
 				}
 			}
 		}
@@ -490,19 +1247,10 @@ func processInlineJavaScript(htmlContent string, base *url.URL) (string, error)
 	}
 	
 	processScript(doc)
-	
-	// Convert back to HTML
-	var buf strings.Builder
-	err = html.Render(&buf, doc)
-	if err != nil {
-		return "", err
-	}
-	
-	return buf.String(), nil
 }
 
-// LocalizeStyleBackgroundImages processes background images in style attributes
-func LocalizeStyleBackgroundImages(styleContent string, base *url.URL) (string, error) {
+// LocalizeStyleBackgroundImages processes background images in style attributes, saving them under outDir
+func LocalizeStyleBackgroundImages(styleContent string, base *url.URL, outDir string) (string, error) {
 	// Regex to find background-image: url(...) in style attributes
 	re := regexp.MustCompile(`background-image:\s*url\(['"]?([^'"]+)['"]?\)`)
 	matches := re.FindAllStringSubmatch(styleContent, -1)
@@ -515,10 +1263,10 @@ func LocalizeStyleBackgroundImages(styleContent string, base *url.URL) (string,
 		// Only process if it's an HTTP/HTTPS URL
 		if strings.HasPrefix(imagePath, "http://") || strings.HasPrefix(imagePath, "https://") {
 			imageURL := utils.ResolveURL(base, imagePath)
-			localPath, err := DownloadImage(imageURL)
+			localPath, err := DownloadImage(imageURL, outDir)
 			if err == nil {
 				// Convert output/assets/images/file.jpg to assets/images/file.jpg for HTML references
-				relativePath := strings.TrimPrefix(localPath, "output/")
+				relativePath := outRelative(localPath, outDir)
 				// Replace the original URL with local path
 				styleContent = strings.ReplaceAll(styleContent, imagePath, relativePath)
 			}
@@ -527,8 +1275,14 @@ func LocalizeStyleBackgroundImages(styleContent string, base *url.URL) (string,
 	return styleContent, nil
 }
 
-// LocalizeJavaScriptURLs processes JavaScript content for embedded resource URLs
-func LocalizeJavaScriptURLs(jsContent string, base *url.URL) (string, error) {
+// LocalizeJavaScriptURLs processes JavaScript content for embedded resource URLs, saving them under outDir
+func LocalizeJavaScriptURLs(jsContent string, base *url.URL, outDir string) (string, error) {
+	return localizeJavaScriptURLs(jsContent, base, assetLayout{outDir: outDir}, Options{}.fetcher())
+}
+
+// localizeJavaScriptURLs is LocalizeJavaScriptURLs saving referenced assets under layout, downloading
+// each with fetch
+func localizeJavaScriptURLs(jsContent string, base *url.URL, layout assetLayout, fetch fetchFunc) (string, error) {
 	// Handle template URLs with placeholders like {banner_id}, {type}
 	// Account for escaped slashes in JavaScript - handle both \/ and / patterns
 	templateRe := regexp.MustCompile(`"([^"]*\\?\/[^"]*\{[^}]+\}[^"]*\.(?:css|js)(?:\?[^"]*)?)"`)
@@ -563,9 +1317,9 @@ func LocalizeJavaScriptURLs(jsContent string, base *url.URL) (string, error) {
 			resolvedURL = strings.ReplaceAll(resolvedURL, `\/`, "/") // Unescape JSON slashes
 			
 			// Download the resolved CSS file
-			localPath, err := DownloadResource(resolvedURL, "css", base)
+			localPath, err := downloadResourceFile(resolvedURL, "css", base, layout, fetch)
 			if err == nil {
-				relativePath := strings.TrimPrefix(localPath, "output/")
+				relativePath := outRelative(localPath, layout.outDir)
 				// Replace both the template URL and resolved URL with local path
 				jsContent = strings.ReplaceAll(jsContent, templateURL, relativePath)
 				jsContent = strings.ReplaceAll(jsContent, resolvedURL, relativePath)
@@ -637,10 +1391,10 @@ func LocalizeJavaScriptURLs(jsContent string, base *url.URL) (string, error) {
 			// Download the resolved CSS file
 			if strings.Contains(resolvedURL, ".css") {
 				cssURL := utils.ResolveURL(base, resolvedURL)
-				localPath, err := DownloadResource(cssURL, "css", base)
+				localPath, err := downloadResourceFile(cssURL, "css", base, layout, fetch)
 				if err == nil {
 					// Convert output/assets/file.css to assets/file.css for HTML references
-					relativePath := strings.TrimPrefix(localPath, "output/")
+					relativePath := outRelative(localPath, layout.outDir)
 					// Replace the template URL with local path in JavaScript
 					jsContent = strings.ReplaceAll(jsContent, `"`+templateURL+`"`, `"`+relativePath+`"`)
 				}
@@ -648,8 +1402,9 @@ func LocalizeJavaScriptURLs(jsContent string, base *url.URL) (string, error) {
 		}
 	}
 	
-	// General regex to find direct URLs in JavaScript strings (with escaped slashes)
-	re := regexp.MustCompile(`"(https?:\\?\/\\?\/[^"]*\.(?:css|js|png|jpg|jpeg|gif|webp|svg)(?:\?[^"]*)?)"`)
+	// General regex to find direct URLs in JavaScript strings (with escaped slashes): absolute
+	// (https:\/\/...), protocol-relative (\/\/cdn...), and root-relative (\/wp-content\/...)
+	re := regexp.MustCompile(`"((?:https?:)?\\?\/[^"]*\.(?:css|js|png|jpg|jpeg|gif|webp|svg)(?:\?[^"]*)?)"`)
 	matches := re.FindAllStringSubmatch(jsContent, -1)
 	
 	for _, match := range matches {
@@ -659,27 +1414,44 @@ func LocalizeJavaScriptURLs(jsContent string, base *url.URL) (string, error) {
 		
 		url := match[1]
 		unescapedURL := strings.ReplaceAll(url, "\\/", "/")
+		assetURL, ok := resolveAssetURL(base, unescapedURL)
+		if !ok {
+			continue
+		}
 		
-		// Check if it's a CSS file we should download
-		if strings.Contains(unescapedURL, ".css") {
-			cssURL := utils.ResolveURL(base, unescapedURL)
-			localPath, err := DownloadResource(cssURL, "css", base)
-			if err == nil {
-				// Convert output/assets/file.css to assets/file.css for HTML references
-				relativePath := strings.TrimPrefix(localPath, "output/")
-				// Replace the URL with local path in the JavaScript
-				jsContent = strings.ReplaceAll(jsContent, `"`+url+`"`, `"`+relativePath+`"`)
-			}
+		// Download stylesheets and scripts; images are left for the page-level collectors
+		var localPath string
+		var err error
+		switch {
+		case strings.Contains(unescapedURL, ".css"):
+			localPath, err = downloadResourceFile(assetURL, "css", base, layout, fetch)
+		case strings.HasSuffix(strings.SplitN(unescapedURL, "?", 2)[0], ".js"):
+			localPath, err = downloadScriptFile(assetURL, layout, fetch)
+		default:
+			continue
+		}
+		if err == nil {
+			// Convert output/assets/file.css to assets/file.css for HTML references
+			relativePath := outRelative(localPath, layout.outDir)
+			// Replace the URL with local path in the JavaScript
+			jsContent = strings.ReplaceAll(jsContent, `"`+url+`"`, `"`+relativePath+`"`)
 		}
 	}
 	
 	return jsContent, nil
 }
 
-// LocalizeFontURLs processes CSS content for font URLs and downloads fonts
-func LocalizeFontURLs(cssContent string, base *url.URL) (string, error) {
-	fontDir := "output/assets/fonts/"
-	os.MkdirAll(fontDir, 0755)
+// LocalizeFontURLs processes CSS content for font URLs and downloads fonts into outDir
+func LocalizeFontURLs(cssContent string, base *url.URL, outDir string) (string, error) {
+	return localizeFontURLs(cssContent, base, outDir+"/assets", assetLayout{outDir: outDir}, Options{}.fetcher())
+}
+
+// localizeFontURLs is LocalizeFontURLs for a stylesheet saved in sheetDir, downloading each font
+// with fetch. Fonts are referenced relative to the stylesheet, so they can be saved under any layout
+// like other assets.
+func localizeFontURLs(cssContent string, base *url.URL, sheetDir string, layout assetLayout, fetch fetchFunc) (string, error) {
+	fontDir := layout.outDir + "/assets/fonts/"
+	layout.output.MkdirAll(fontDir)
 	// Regex to find url(...) - matches both HTTP URLs and relative paths
 	re := regexp.MustCompile(`url\((['"]?)([^)'"]+)['"]?\)`)
 	matches := re.FindAllStringSubmatch(cssContent, -1)
@@ -701,25 +1473,29 @@ func LocalizeFontURLs(cssContent string, base *url.URL) (string, error) {
 			// Relative path - resolve against base URL
 			fontURL = utils.ResolveURL(base, fontPath)
 		}
-		fontResp, err := http.Get(fontURL)
-		if err != nil {
+		// Images and nested stylesheets are left to localizeCSSAssetURLs and the @import handling
+		if cssAssetType(fontURL) != "font" {
 			continue
 		}
-		fontData, err := io.ReadAll(fontResp.Body)
-		fontResp.Body.Close()
+		fontData, err := fetch(fontURL)
 		if err != nil {
 			continue
 		}
-		fontU, err := url.Parse(fontURL)
+		localFontPath, _, err := layout.pathFor("font", fontURL)
 		if err != nil {
 			continue
 		}
-		fontSegments := strings.Split(fontU.Path, "/")
-		fontFilename := fontSegments[len(fontSegments)-1]
-		localFontPath := fontDir + fontFilename
-		os.WriteFile(localFontPath, fontData, 0644)
+		localFontPath = layout.contentPath(localFontPath, fontData)
+		if layout.preservePaths {
+			layout.output.MkdirAll(path.Dir(localFontPath))
+		}
+		layout.output.WriteFile(localFontPath, fontData)
 		// Replace both original path and resolved URL with local path in CSS
-		relativeFontPath := "fonts/" + fontFilename
+		rel, err := filepath.Rel(sheetDir, localFontPath)
+		if err != nil {
+			continue
+		}
+		relativeFontPath := filepath.ToSlash(rel)
 		cssContent = strings.ReplaceAll(cssContent, fontPath, relativeFontPath)
 		if fontPath != fontURL {
 			// Also replace the resolved URL in case it appears elsewhere
@@ -727,4 +1503,50 @@ func LocalizeFontURLs(cssContent string, base *url.URL) (string, error) {
 		}
 	}
 	return cssContent, nil
-}
\ No newline at end of file
+}
+
+// LocalizeCSSAssetURLs downloads the images a stylesheet fetched from cssURL references into outDir
+// and points its url() and image-set() references at them
+func LocalizeCSSAssetURLs(cssContent string, cssURL *url.URL, outDir string) (string, error) {
+	layout := assetLayout{outDir: outDir}
+	return localizeCSSAssetURLs(cssContent, cssURL, outDir+"/assets", func(imageURL string) (string, error) {
+		return downloadCSSImage(imageURL, layout, Options{}.fetcher())
+	}), nil
+}
+
+// localizeCSSAssetURLs is LocalizeCSSAssetURLs for a stylesheet saved in sheetDir, saving each image
+// with download. References resolve against the stylesheet's own URL, not the page that linked it:
+// ../img/x.png in /wp-content/themes/t/css/style.css is /wp-content/themes/t/img/x.png. Images that
+// fail to download keep their reference as written.
+func localizeCSSAssetURLs(cssContent string, sheetURL *url.URL, sheetDir string, download func(imageURL string) (string, error)) string {
+	return localizeCSSRefsOfType(cssContent, sheetURL, sheetDir, "image", download)
+}
+
+// localizeCSSRefsOfType is localizeCSSAssetURLs for the references cssAssetType classifies as
+// assetType ("image" or "font")
+func localizeCSSRefsOfType(cssContent string, sheetURL *url.URL, sheetDir, assetType string, download func(assetURL string) (string, error)) string {
+	localByURL := make(map[string]string)
+	imports := cssRefs(cssContent)
+	return rewriteCSSURLRefs(cssContent, func(ref string) (string, bool) {
+		// @import targets are stylesheets even without a .css extension (fonts.googleapis.com/css?family=...)
+		if imports[ref] {
+			return "", false
+		}
+		assetURL, ok := resolveAssetURL(sheetURL, ref)
+		if !ok || cssAssetType(assetURL) != assetType {
+			return "", false
+		}
+		local, seen := localByURL[assetURL]
+		if !seen {
+			if localPath, err := download(assetURL); err == nil && strings.HasPrefix(localPath, "data:") {
+				local = localPath
+			} else if err == nil {
+				if rel, err := filepath.Rel(sheetDir, localPath); err == nil {
+					local = filepath.ToSlash(rel)
+				}
+			}
+			localByURL[assetURL] = local
+		}
+		return local, local != ""
+	})
+}
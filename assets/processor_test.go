@@ -0,0 +1,653 @@
+package assets
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/html"
+	"wp-static-scraper/internal/testsite"
+	"wp-static-scraper/utils"
+)
+
+func TestUpdateHTMLWithLocalPathsWholeValues(t *testing.T) {
+	_, base := newTestSite(t, map[string]string{
+		"/img/logo.png": "logo",
+	})
+	page := `<html><head><style>.hero { background: url('/img/logo.png') } .old { background: url('/missing/img/logo.png') }</style></head><body>` +
+		`<img src="/img/logo.png" srcset="/img/logo.png 1x, /missing/img/logo.png 2x">` +
+		`<img src="/missing/img/logo.png">` +
+		`<a href="/img/logo.png?v=2">old logo</a>` +
+		`<p data-caption="/img/logo.png">/img/logo.png</p>` +
+		`<div style="background-image: url(/img/logo.png)"></div>` +
+		`</body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	for _, want := range []string{
+		`<img src="assets/images/logo.png" srcset="assets/images/logo.png 1x, /missing/img/logo.png 2x"/>`,
+		`.hero { background: url('assets/images/logo.png') }`,
+		`style="background-image: url(assets/images/logo.png)"`,
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %s in %s", want, result)
+		}
+	}
+	// References that merely contain the downloaded path stay exactly as written
+	for _, want := range []string{
+		`.old { background: url('/missing/img/logo.png') }`,
+		`<img src="/missing/img/logo.png"/>`,
+		`href="/img/logo.png?v=2"`,
+		`<p data-caption="/img/logo.png">/img/logo.png</p>`,
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %s to be left untouched in %s", want, result)
+		}
+	}
+}
+
+func TestUpdateHTMLWithLocalPathsPrefixOverlap(t *testing.T) {
+	server, base := newTestSite(t, map[string]string{
+		"/js/app":          "console.log('app');",
+		"/js/app-extra.js": "console.log('extra');",
+	})
+	page := `<html><head><script src="` + server.URL + `/js/app"></script>` +
+		`<script src="` + server.URL + `/js/app-extra.js"></script></head><body></body></html>`
+
+	// Map iteration order is random, so repeat to catch order-dependent rewrites
+	for i := 0; i < 10; i++ {
+		result, err := LocalizeAssets(page, base, Options{Concurrency: 4})
+		if err != nil {
+			t.Fatalf("LocalizeAssets returned error: %v", err)
+		}
+		if !strings.Contains(result, `src="assets/app.js"`) || !strings.Contains(result, `src="assets/app-extra.js"`) {
+			t.Fatalf("run %d: prefix-overlapping URLs rewritten inconsistently: %s", i, result)
+		}
+	}
+}
+
+func TestLocalizeInputImage(t *testing.T) {
+	server, base := newTestSite(t, map[string]string{
+		"/img/submit.png": "png-bytes",
+	})
+	page := `<html><body><form><input type="image" src="` + server.URL + `/img/submit.png" alt="Go"></form></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	if !strings.Contains(result, `src="assets/images/submit.png"`) {
+		t.Errorf("input image src should be rewritten, got %s", result)
+	}
+	if _, err := os.Stat("output/assets/images/submit.png"); err != nil {
+		t.Errorf("input image should be downloaded: %v", err)
+	}
+}
+
+func TestLocalizeRelativeInputImage(t *testing.T) {
+	_, base := newTestSite(t, map[string]string{
+		"/img/submit.png":    "submit",
+		"/blog/img/next.png": "next",
+	})
+	base = base.ResolveReference(&url.URL{Path: "/blog/"})
+	page := `<html><body><form><input type="image" src="/img/submit.png"><input type="image" src="img/next.png"></form></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	for _, name := range []string{"submit.png", "next.png"} {
+		if !strings.Contains(result, `src="assets/images/`+name+`"`) {
+			t.Errorf("relative input image %s should be rewritten, got %s", name, result)
+		}
+		if _, err := os.Stat("output/assets/images/" + name); err != nil {
+			t.Errorf("relative input image %s should be downloaded: %v", name, err)
+		}
+	}
+}
+
+func TestLocalizeAssetsRewritesProtocolRelative(t *testing.T) {
+	chdirOutput(t)
+
+	base, _ := url.Parse("http://127.0.0.1:1/")
+	page := `<html><head></head><body><a href="//cdn.example/x.js">x</a></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 1, ProtocolRelativeScheme: "https"})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	if !strings.Contains(result, `href="https://cdn.example/x.js"`) {
+		t.Errorf("protocol-relative reference should use https, got %s", result)
+	}
+}
+
+func TestRewriteProtocolRelative(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "script src",
+			input:    `<script src="//cdn/x.js"></script>`,
+			expected: `<script src="https://cdn/x.js"></script>`,
+		},
+		{
+			name:     "css url",
+			input:    `<div style="background: url(//cdn/bg.png)"></div>`,
+			expected: `<div style="background: url(https://cdn/bg.png)"></div>`,
+		},
+		{
+			name:     "every srcset candidate",
+			input:    `<img srcset="//cdn/a.jpg 1x, //cdn/b.jpg 2x">`,
+			expected: `<img srcset="https://cdn/a.jpg 1x, https://cdn/b.jpg 2x"/>`,
+		},
+		{
+			name:     "lazy and preload srcsets",
+			input:    `<img data-srcset="//cdn/a.jpg 300w, //cdn/b.jpg 600w"><link rel="preload" as="image" imagesrcset="//cdn/c.jpg 1x, //cdn/d.jpg 2x">`,
+			expected: `<img data-srcset="https://cdn/a.jpg 300w, https://cdn/b.jpg 600w"/><link rel="preload" as="image" imagesrcset="https://cdn/c.jpg 1x, https://cdn/d.jpg 2x"/>`,
+		},
+		{
+			name:     "absolute and relative untouched",
+			input:    `<a href="https://example.com/">x</a><img src="images/a.png">`,
+			expected: `<a href="https://example.com/">x</a><img src="images/a.png">`,
+		},
+		{
+			name:     "text content untouched",
+			input:    `<p>see //cdn/x.js</p>`,
+			expected: `<p>see //cdn/x.js</p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := rewriteProtocolRelative(tt.input, "https", nil)
+			if err != nil {
+				t.Fatalf("rewriteProtocolRelative returned error: %v", err)
+			}
+			if !strings.Contains(result, tt.expected) {
+				t.Errorf("rewriteProtocolRelative(%q) = %q; want it to contain %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLocalizeInlineImageSet(t *testing.T) {
+	server, base := newTestSite(t, map[string]string{
+		"/img/hero-1x.png": "1x",
+		"/img/hero-2x.png": "2x",
+		"/img/card.webp":   "card",
+	})
+	page := `<html><head><style>.card{background:-webkit-image-set(url(` + server.URL + `/img/card.webp) 1x)}</style></head>` +
+		`<body><div style="background:image-set('` + server.URL + `/img/hero-1x.png' 1x, '` + server.URL + `/img/hero-2x.png' 2x)"></div></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	for _, local := range []string{"assets/images/hero-1x.png", "assets/images/hero-2x.png", "assets/images/card.webp"} {
+		if !strings.Contains(result, local) {
+			t.Errorf("image-set candidate should be rewritten to %s, got %s", local, result)
+		}
+		if _, err := os.Stat("output/" + local); err != nil {
+			t.Errorf("image-set candidate %s should be downloaded: %v", local, err)
+		}
+	}
+	if strings.Contains(result, server.URL) {
+		t.Errorf("no image-set candidate should stay remote, got %s", result)
+	}
+}
+
+func TestPreloadMediaRouting(t *testing.T) {
+	server, base := newTestSite(t, map[string]string{
+		"/media/intro.mp3": "audio",
+		"/media/hero.mp4":  "video",
+	})
+
+	for _, tt := range []struct {
+		as   string
+		file string
+	}{
+		{"audio", "intro.mp3"},
+		{"video", "hero.mp4"},
+	} {
+		t.Run(tt.as, func(t *testing.T) {
+			page := `<html><head><link rel="preload" as="` + tt.as + `" href="` + server.URL + `/media/` + tt.file + `" crossorigin="anonymous"></head><body></body></html>`
+
+			result, err := LocalizeAssets(page, base, Options{Concurrency: 1})
+			if err != nil {
+				t.Fatalf("LocalizeAssets returned error: %v", err)
+			}
+			if _, err := os.Stat("output/assets/media/" + tt.file); err != nil {
+				t.Errorf("as=%s preload should be saved under output/assets/media/: %v", tt.as, err)
+			}
+			if !strings.Contains(result, `href="assets/media/`+tt.file+`"`) {
+				t.Errorf("as=%s preload should be rewritten to the media directory, got %s", tt.as, result)
+			}
+			if !strings.Contains(result, `crossorigin="anonymous"`) {
+				t.Errorf("crossorigin should be preserved, got %s", result)
+			}
+		})
+	}
+}
+
+func TestLocalizeAssetsNormalizesLineEndings(t *testing.T) {
+	server, base := newTestSite(t, map[string]string{
+		"/css/mixed.css": "body{color:red}\r\n.a{color:blue}\n.b{color:green}\r\n",
+	})
+	page := `<html><head><link rel="stylesheet" href="` + server.URL + `/css/mixed.css"></head><body></body></html>`
+
+	if _, err := LocalizeAssets(page, base, Options{Concurrency: 1, LineEndings: "lf"}); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	css, err := os.ReadFile("output/assets/mixed.css")
+	if err != nil {
+		t.Fatalf("stylesheet should be saved: %v", err)
+	}
+	if strings.Contains(string(css), "\r") {
+		t.Errorf("saved stylesheet should only contain LF line endings, got %q", css)
+	}
+}
+
+func TestLocalizeRelativeImageSources(t *testing.T) {
+	chdirOutput(t)
+
+	server := testsite.New(t, map[string]string{
+		"/blog/images/x.jpg":           "relative",
+		"/wp-content/uploads/root.png": "root-relative",
+		"/blog/images/x-2x.jpg":        "srcset",
+	})
+	base, _ := url.Parse(server.URL + "/blog/")
+	page := `<html><body><img src="images/x.jpg" srcset="images/x-2x.jpg 2x">` +
+		`<img data-src="/wp-content/uploads/root.png"><img src="data:image/gif;base64,R0lG"></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	for file, want := range map[string]string{"x.jpg": "relative", "root.png": "root-relative", "x-2x.jpg": "srcset"} {
+		data, err := os.ReadFile("output/assets/images/" + file)
+		if err != nil || string(data) != want {
+			t.Errorf("image %s should be downloaded, got %q (%v)", file, data, err)
+		}
+	}
+	for _, want := range []string{`src="assets/images/x.jpg"`, `srcset="assets/images/x-2x.jpg 2x"`, `data-src="assets/images/root.png"`, `src="data:image/gif;base64,R0lG"`} {
+		if !strings.Contains(result, want) {
+			t.Errorf("output should contain %s, got %s", want, result)
+		}
+	}
+}
+
+func TestLocalizeOpenGraphMedia(t *testing.T) {
+	server, base := newTestSite(t, map[string]string{
+		"/media/promo.mp4":   "video",
+		"/media/podcast.mp3": "audio",
+	})
+	protocolRelative := strings.TrimPrefix(server.URL, "http:")
+	page := `<html><head>` +
+		`<meta property="og:video" content="` + server.URL + `/media/promo.mp4">` +
+		`<meta property="og:audio" content="` + protocolRelative + `/media/podcast.mp3">` +
+		`</head><body></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	for _, file := range []string{"promo.mp4", "podcast.mp3"} {
+		if _, err := os.Stat("output/assets/media/" + file); err != nil {
+			t.Errorf("Open Graph media %s should be downloaded: %v", file, err)
+		}
+		if !strings.Contains(result, `content="assets/media/`+file+`"`) {
+			t.Errorf("Open Graph content should point at the local %s, got %s", file, result)
+		}
+	}
+}
+
+func TestInlineStyleFontsLocalized(t *testing.T) {
+	server, base := newTestSite(t, map[string]string{"/fonts/brand.woff2": "woff2"})
+	page := `<html><body><p style="font-family: Brand; src: url(` + server.URL + `/fonts/brand.woff2)">Hi</p></body></html>`
+
+	updated, err := LocalizeAssets(page, base, Options{Concurrency: 1, InlineStyleFonts: true})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	if !strings.Contains(updated, "url(assets/fonts/brand.woff2)") {
+		t.Errorf("inline style font should be rewritten to the local copy: %s", updated)
+	}
+	if _, err := os.Stat("output/assets/fonts/brand.woff2"); err != nil {
+		t.Errorf("font should be saved to the fonts directory: %v", err)
+	}
+}
+
+func TestScriptAndLinkURLResolution(t *testing.T) {
+	tests := []struct {
+		name string
+		base string
+		ref  string
+		want string
+	}{
+		{name: "protocol-relative", base: "http://example.com/blog/post/", ref: "//cdn.example.net/x.js", want: "http://cdn.example.net/x.js"},
+		{name: "protocol-relative on https", base: "https://example.com/", ref: "//cdn.example.net/x.js", want: "https://cdn.example.net/x.js"},
+		{name: "protocol-relative on unusual scheme", base: "file:///tmp/page.html", ref: "//cdn.example.net/x.js", want: "https://cdn.example.net/x.js"},
+		{name: "root-relative", base: "https://example.com/blog/post/", ref: "/wp-includes/x.js", want: "https://example.com/wp-includes/x.js"},
+		{name: "parent-relative", base: "https://example.com/blog/post/", ref: "../lib/x.js", want: "https://example.com/blog/lib/x.js"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, _ := url.Parse(tt.base)
+			cssRef := strings.TrimSuffix(tt.ref, ".js") + ".css"
+			page := `<html><head><link rel="stylesheet" href="` + cssRef + `"><script src="` + tt.ref + `"></script></head></html>`
+			inventory, err := ListAssets(page, base)
+			if err != nil {
+				t.Fatalf("ListAssets returned error: %v", err)
+			}
+			want := []AssetInfo{
+				{Type: "css", URL: strings.TrimSuffix(tt.want, ".js") + ".css"},
+				{Type: "js", URL: tt.want},
+			}
+			if len(inventory) != len(want) {
+				t.Fatalf("got %d assets, want %d: %+v", len(inventory), len(want), inventory)
+			}
+			for i := range want {
+				if inventory[i].Type != want[i].Type || inventory[i].URL != want[i].URL {
+					t.Errorf("asset %d = %s %s; want %s %s", i, inventory[i].Type, inventory[i].URL, want[i].Type, want[i].URL)
+				}
+			}
+		})
+	}
+
+	// Every form downloads and is rewritten to its local copy
+	chdirOutput(t)
+	server := testsite.New(t, map[string]string{
+		"/cdn/a.js":      "a()",
+		"/css/b.css":     "body{}",
+		"/blog/lib/c.js": "c()",
+	})
+	host := strings.TrimPrefix(server.URL, "http:")
+	base, _ := url.Parse(server.URL + "/blog/post/")
+	page := `<html><head>` +
+		`<script src="` + host + `/cdn/a.js"></script>` +
+		`<link rel="stylesheet" href="/css/b.css">` +
+		`<script src="../lib/c.js"></script>` +
+		`</head></html>`
+	updated, err := LocalizeAssets(page, base, Options{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	for _, local := range []string{`src="assets/a.js"`, `href="assets/b.css"`, `src="assets/c.js"`} {
+		if !strings.Contains(updated, local) {
+			t.Errorf("expected %s in output: %s", local, updated)
+		}
+	}
+}
+
+// fakeObjectStore is an in-memory utils.Uploader
+type fakeObjectStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeObjectStore) Upload(key string, data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[key] = data
+	return nil
+}
+
+func TestOutputUploader(t *testing.T) {
+	chdirOutput(t)
+
+	store := &fakeObjectStore{objects: make(map[string][]byte)}
+	output := utils.NewOutput(utils.DefaultFileMode, utils.DefaultDirMode)
+	output.SetUploader(store, false, utils.DefaultOutDir)
+
+	server := testsite.New(t, map[string]string{
+		"/style.css":    "body{color:red}",
+		"/img/logo.png": "png",
+	})
+	base, _ := url.Parse(server.URL + "/")
+	page := `<html><head><link rel="stylesheet" href="` + server.URL + `/style.css" integrity="sha384-fromtheorigin"></head>` +
+		`<body><img src="` + server.URL + `/img/logo.png"></body></html>`
+	opts := Options{Concurrency: 2, Output: output, ManifestPath: "output/manifest.json"}
+	updated, err := LocalizeAssets(page, base, opts)
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	if err := output.WriteFile("output/index.html", []byte(updated)); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+
+	for key, want := range map[string]string{
+		"assets/style.css":       "body{color:red}",
+		"assets/images/logo.png": "png",
+		"index.html":             updated,
+	} {
+		if got, ok := store.objects[key]; !ok || string(got) != want {
+			t.Errorf("object %s = %q (present: %v); want %q", key, got, ok, want)
+		}
+	}
+	if _, err := os.Stat("output/index.html"); !os.IsNotExist(err) {
+		t.Error("upload-only output should not be written to disk")
+	}
+
+	// Sizes and integrity come from what was uploaded, since nothing can be read back from disk
+	sum := sha512.Sum384([]byte("body{color:red}"))
+	integrity := "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+	if !strings.Contains(updated, `integrity="`+integrity+`"`) {
+		t.Errorf("page integrity should be recomputed from the uploaded stylesheet %s: %s", integrity, updated)
+	}
+	manifest := string(store.objects["manifest.json"])
+	if !strings.Contains(manifest, `"integrity": "`+integrity+`"`) {
+		t.Errorf("manifest should hash the uploaded stylesheet: %s", manifest)
+	}
+	if !strings.Contains(manifest, `"size": 3`) || !strings.Contains(manifest, `"size": 15`) {
+		t.Errorf("manifest should record uploaded sizes: %s", manifest)
+	}
+}
+
+func TestPictureRelativeFallback(t *testing.T) {
+	chdirOutput(t)
+
+	server := testsite.New(t, map[string]string{
+		"/blog/img/hero.jpg":      "jpg",
+		"/blog/img/hero.webp":     "webp",
+		"/blog/img/hero-2x.webp":  "webp2x",
+		"/uploads/hero-wide.avif": "avif",
+	})
+	base, _ := url.Parse(server.URL + "/blog/post/")
+	page := `<html><body><picture>` +
+		`<source media="(min-width: 800px)" srcset="/uploads/hero-wide.avif" type="image/avif">` +
+		`<source srcset="../img/hero.webp 1x, ../img/hero-2x.webp 2x" type="image/webp">` +
+		`<img src="../img/hero.jpg" alt="Hero"></picture></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	for _, want := range []string{
+		`srcset="assets/images/hero-wide.avif"`,
+		`srcset="assets/images/hero.webp 1x, assets/images/hero-2x.webp 2x"`,
+		`src="assets/images/hero.jpg"`,
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %s in output: %s", want, result)
+		}
+	}
+	for _, name := range []string{"hero.jpg", "hero.webp", "hero-2x.webp", "hero-wide.avif"} {
+		if _, err := os.Stat("output/assets/images/" + name); err != nil {
+			t.Errorf("%s should be downloaded: %v", name, err)
+		}
+	}
+}
+
+func TestDownloadPrefetch(t *testing.T) {
+	_, base := newTestSite(t, map[string]string{
+		"/js/next-chunk.js":  "chunk()",
+		"/uploads/hero.webp": "webp",
+		"/page/2/":           "<html></html>",
+	})
+	page := `<html><head><link rel="prefetch" href="/js/next-chunk.js">` +
+		`<link rel="prefetch" as="image" href="/uploads/hero.webp"><link rel="prefetch" href="/page/2/"></head><body></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	if !strings.Contains(result, `href="/js/next-chunk.js"`) {
+		t.Errorf("prefetch hints should stay remote unless enabled: %s", result)
+	}
+
+	result, err = LocalizeAssets(page, base, Options{Concurrency: 2, Prefetch: true})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	for _, want := range []string{`href="assets/next-chunk.js"`, `href="assets/images/hero.webp"`, `href="/page/2/"`} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %s in output: %s", want, result)
+		}
+	}
+	for _, path := range []string{"output/assets/next-chunk.js", "output/assets/images/hero.webp"} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("prefetch target should be downloaded to %s: %v", path, err)
+		}
+	}
+}
+
+func TestLocalizeMicrodataImages(t *testing.T) {
+	chdirOutput(t)
+
+	server := testsite.New(t, map[string]string{
+		"/uploads/article.jpg": "jpg",
+		"/uploads/logo.png":    "png",
+		"/uploads/thumb.webp":  "webp",
+	})
+	base, _ := url.Parse(server.URL + "/blog/post/")
+	page := `<html><head><meta itemprop="image" content="../../uploads/article.jpg"></head><body>` +
+		`<div itemscope itemtype="https://schema.org/Organization"><link itemprop="logo" href="/uploads/logo.png">` +
+		`<meta itemprop="thumbnailUrl" content="` + server.URL + `/uploads/thumb.webp"><meta itemprop="name" content="Acme"></div></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	for _, want := range []string{
+		`content="assets/images/article.jpg"`,
+		`href="assets/images/logo.png"`,
+		`content="assets/images/thumb.webp"`,
+		`content="Acme"`,
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %s in output: %s", want, result)
+		}
+	}
+}
+
+func TestDownloadAttributeLinks(t *testing.T) {
+	_, base := newTestSite(t, map[string]string{
+		"/files/brochure.pdf": "%PDF-1.4 brochure",
+		"/files/archive.zip":  strings.Repeat("z", 4096),
+		"/about/":             "<html></html>",
+	})
+	page := `<html><body><a href="/files/brochure.pdf" download>Brochure</a>` +
+		`<a href="/files/archive.zip" download="archive.zip">Archive</a>` +
+		`<a href="/about/">About</a></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 2, MaxFileSize: 1024})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	if !strings.Contains(result, `href="assets/files/brochure.pdf"`) {
+		t.Errorf("download link should be rewritten: %s", result)
+	}
+	data, err := os.ReadFile("output/assets/files/brochure.pdf")
+	if err != nil || string(data) != "%PDF-1.4 brochure" {
+		t.Errorf("brochure.pdf should be downloaded, got %q (%v)", data, err)
+	}
+	if !strings.Contains(result, `href="/files/archive.zip"`) {
+		t.Errorf("file over -max-file-size should stay remote: %s", result)
+	}
+	if _, err := os.Stat("output/assets/files/archive.zip"); err == nil {
+		t.Error("archive.zip exceeds the max file size and should not be saved")
+	}
+	if !strings.Contains(result, `href="/about/"`) {
+		t.Errorf("ordinary links should not be downloaded: %s", result)
+	}
+}
+
+func TestLocalizeJavaScriptURLsRelativeForms(t *testing.T) {
+	chdirOutput(t)
+
+	server := testsite.New(t, map[string]string{
+		"/cdn/lazy-chunk.js":             "console.log('chunk');\n//# sourceMappingURL=lazy-chunk.js.map",
+		"/wp-content/themes/t/extra.css": "body { color: red; }",
+	})
+	base, _ := url.Parse(server.URL + "/blog/")
+	host := strings.TrimPrefix(server.URL, "http://")
+	js := `var cfg = {"chunk":"\/\/` + host + `\/cdn\/lazy-chunk.js","style":"\/wp-content\/themes\/t\/extra.css?ver=2","logo":"\/img\/logo.png"};`
+
+	result, err := LocalizeJavaScriptURLs(js, base, utils.DefaultOutDir)
+	if err != nil {
+		t.Fatalf("LocalizeJavaScriptURLs returned error: %v", err)
+	}
+	for _, want := range []string{`"chunk":"assets/lazy-chunk.js"`, `"style":"assets/extra.css"`, `"logo":"\/img\/logo.png"`} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %s in result: %s", want, result)
+		}
+	}
+	data, err := os.ReadFile("output/assets/lazy-chunk.js")
+	if err != nil {
+		t.Fatalf("protocol-relative script should be downloaded: %v", err)
+	}
+	if strings.Contains(string(data), "sourceMappingURL") {
+		t.Errorf("source map reference should be removed: %q", data)
+	}
+}
+
+func TestLocalizeInlineScriptsAcrossTextNodes(t *testing.T) {
+	_, base := newTestSite(t, map[string]string{
+		"/wp-content/themes/t/extra.css": "body { color: red; }",
+	})
+
+	doc, err := html.Parse(strings.NewReader(`<html><body><script>var s = "\/wp-content\/themes\/t\/ex</script></body></html>`))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+	// Split the script so the URL straddles two text nodes
+	var script *html.Node
+	var find func(*html.Node)
+	find = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "script" {
+			script = n
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(doc)
+	script.AppendChild(&html.Node{Type: html.TextNode, Data: `tra.css?ver=2";`})
+
+	LocalizeInlineScripts(doc, base, utils.DefaultOutDir)
+
+	if script.FirstChild == nil || script.FirstChild.NextSibling != nil {
+		t.Fatalf("script text should be merged into one node")
+	}
+	if want := `var s = "assets/extra.css";`; script.FirstChild.Data != want {
+		t.Errorf("script = %q; want %q", script.FirstChild.Data, want)
+	}
+	if _, err := os.Stat("output/assets/extra.css"); err != nil {
+		t.Errorf("stylesheet split across text nodes should be downloaded: %v", err)
+	}
+}
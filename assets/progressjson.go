@@ -0,0 +1,72 @@
+package assets
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// Progress event names written by -progress-json
+const (
+	ProgressStarted   = "started"
+	ProgressCompleted = "completed"
+	ProgressFailed    = "failed"
+)
+
+// ProgressEvent is one line of the newline-delimited JSON progress stream
+type ProgressEvent struct {
+	Event     string `json:"event"`
+	URL       string `json:"url"`
+	Type      string `json:"type"`
+	LocalPath string `json:"local_path,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Completed int64  `json:"completed"` // Jobs finished so far, including this one for completed/failed
+	Total     int64  `json:"total"`     // Jobs queued so far; grows as stylesheets reveal more assets
+}
+
+// progressStream serializes events from concurrent workers onto one writer
+type progressStream struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newProgressStream(w io.Writer) *progressStream {
+	return &progressStream{enc: json.NewEncoder(w)}
+}
+
+// emit writes one event as a JSON line; write errors are ignored so a closed pipe never stalls downloads
+func (p *progressStream) emit(event ProgressEvent) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.enc.Encode(event)
+}
+
+// SetProgressJSON streams a started event as each job begins and a completed or failed event as
+// it finishes to w, one JSON object per line, for wrapping tools to show live progress. Call before Start.
+func (cd *ConcurrentDownloader) SetProgressJSON(w io.Writer) {
+	cd.progress = newProgressStream(w)
+}
+
+// emitProgress reports a job starting, or finishing when result is non-nil
+func (cd *ConcurrentDownloader) emitProgress(job DownloadJob, result *DownloadResult) {
+	if cd.progress == nil {
+		return
+	}
+	completed, total := cd.GetProgress()
+	event := ProgressEvent{Event: ProgressStarted, URL: job.URL, Type: job.Type, Completed: completed, Total: total}
+	if result != nil {
+		event.Event = ProgressCompleted
+		event.LocalPath = result.LocalPath
+		// A redirect duplicate shares another job's download, which is reported on its own
+		if !result.Success && !isRedirectDuplicate(result.Error) {
+			event.Event = ProgressFailed
+			if result.Error != nil {
+				event.Error = result.Error.Error()
+			}
+		}
+	}
+	cd.progress.emit(event)
+}
@@ -0,0 +1,44 @@
+package assets
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestProgressJSONEvents(t *testing.T) {
+	_, base := newTestSite(t, map[string]string{
+		"/js/app.js":    "console.log('app');",
+		"/img/logo.png": "png",
+	})
+	page := `<html><head><script src="/js/app.js"></script></head>` +
+		`<body><img src="/img/logo.png"><img src="/img/missing.png"></body></html>`
+
+	var events bytes.Buffer
+	if _, err := LocalizeAssets(page, base, Options{Concurrency: 2, ProgressJSON: &events}); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	counts := make(map[string]int)
+	var last ProgressEvent
+	for _, line := range strings.Split(strings.TrimSpace(events.String()), "\n") {
+		var event ProgressEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("progress line is not valid JSON: %q: %v", line, err)
+		}
+		counts[event.Event]++
+		if event.Event != ProgressStarted && event.Completed >= last.Completed {
+			last = event
+		}
+		if event.Event == ProgressFailed && (!strings.HasSuffix(event.URL, "/img/missing.png") || event.Error == "") {
+			t.Errorf("unexpected failed event: %+v", event)
+		}
+	}
+	if counts[ProgressStarted] != 3 || counts[ProgressCompleted] != 2 || counts[ProgressFailed] != 1 {
+		t.Errorf("expected 3 started, 2 completed, 1 failed event, got %v", counts)
+	}
+	if last.Completed != 3 || last.Total != 3 {
+		t.Errorf("expected the final finish event to report 3/3, got %d/%d", last.Completed, last.Total)
+	}
+}
@@ -0,0 +1,283 @@
+package assets
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+	"wp-static-scraper/utils"
+)
+
+// usedSelectors holds the classes and ids present in the scraped pages
+type usedSelectors struct {
+	classes map[string]bool
+	ids     map[string]bool
+}
+
+// collectUsedSelectors gathers every class and id used by elements in the given pages
+func collectUsedSelectors(pages []string) (usedSelectors, error) {
+	used := usedSelectors{classes: make(map[string]bool), ids: make(map[string]bool)}
+	for _, page := range pages {
+		doc, err := html.Parse(strings.NewReader(page))
+		if err != nil {
+			return used, err
+		}
+		var traverse func(*html.Node)
+		traverse = func(n *html.Node) {
+			if n.Type == html.ElementNode {
+				for _, class := range strings.Fields(getAttr(n, "class")) {
+					used.classes[class] = true
+				}
+				if id := getAttr(n, "id"); id != "" {
+					used.ids[id] = true
+				}
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				traverse(c)
+			}
+		}
+		traverse(doc)
+	}
+	return used, nil
+}
+
+// PurgeUnusedCSS removes rules from the stylesheets saved under cssDir whose every selector names a
+// class or id that appears in none of the pages, returning how many rules were dropped. Only
+// plain class/id/element selectors are judged; anything with attribute selectors, functional
+// pseudo-classes, or escapes is kept, as are at-rules other than @media, @supports, @layer, and
+// @container (whose nested rules are purged the same way). Classes added by scripts at runtime
+// are not seen, which is why the purge is opt-in.
+func PurgeUnusedCSS(cssDir string, pages []string) (int, error) {
+	used, err := collectUsedSelectors(pages)
+	if err != nil {
+		return 0, err
+	}
+
+	// Walk the whole tree, since -preserve-paths nests stylesheets under their URL paths
+	total := 0
+	err = filepath.WalkDir(cssDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".css") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		purged, removed := purgeCSSRules(string(data), used)
+		if removed == 0 {
+			return nil
+		}
+		if err := utils.WriteFile(path, []byte(purged)); err != nil {
+			return err
+		}
+		total += removed
+		return nil
+	})
+	if err != nil {
+		return total, err
+	}
+	return total, nil
+}
+
+// CSSPurge removes unused rules from stylesheets as they are downloaded, judged against the
+// classes and ids of every page in the run. Purging before a stylesheet is saved keeps its
+// integrity hash, content-hashed name, and manifest entry in step with the file on disk.
+type CSSPurge struct {
+	used    usedSelectors
+	mu      sync.Mutex
+	removed map[string]int // Stylesheet URL -> rules removed, so sheets shared by pages count once
+}
+
+// NewCSSPurge collects the classes and ids of the pages in a run, before any of their assets are
+// downloaded
+func NewCSSPurge(pages []string) (*CSSPurge, error) {
+	used, err := collectUsedSelectors(pages)
+	if err != nil {
+		return nil, err
+	}
+	return &CSSPurge{used: used, removed: make(map[string]int)}, nil
+}
+
+// Removed returns how many rules have been removed across all stylesheets so far
+func (p *CSSPurge) Removed() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	total := 0
+	for _, n := range p.removed {
+		total += n
+	}
+	return total
+}
+
+// purge drops the unused rules from the stylesheet downloaded from sheetURL (no-op on a nil purge)
+func (p *CSSPurge) purge(sheetURL, css string) string {
+	if p == nil {
+		return css
+	}
+	purged, removed := purgeCSSRules(css, p.used)
+	p.mu.Lock()
+	p.removed[sheetURL] = removed
+	p.mu.Unlock()
+	return purged
+}
+
+// purgeCSSRules drops the unused style rules from a list of rules, recursing into grouping
+// at-rules, and returns the remaining CSS with the number of rules removed
+func purgeCSSRules(css string, used usedSelectors) (string, int) {
+	var b strings.Builder
+	removed := 0
+	for i := 0; i < len(css); {
+		// Copy whitespace, comments, and stray braces between rules as-is
+		switch {
+		case isCSSSpace(css[i]) || css[i] == '}':
+			b.WriteByte(css[i])
+			i++
+			continue
+		case strings.HasPrefix(css[i:], "/*"):
+			end := strings.Index(css[i+2:], "*/")
+			if end < 0 {
+				b.WriteString(css[i:])
+				return b.String(), removed
+			}
+			b.WriteString(css[i : i+end+4])
+			i += end + 4
+			continue
+		}
+
+		start := i
+		open := cssPreludeEnd(css, i)
+		if open >= len(css) || css[open] == ';' {
+			// A statement at-rule such as @import or @charset, or trailing garbage
+			end := min(open+1, len(css))
+			b.WriteString(css[start:end])
+			i = end
+			continue
+		}
+		closing := cssBlockEnd(css, open)
+		prelude := css[start:open]
+		end := min(closing+1, len(css))
+
+		if strings.HasPrefix(prelude, "@") {
+			name := strings.ToLower(strings.TrimLeft(prelude, "@"))
+			if fields := strings.Fields(name); len(fields) > 0 {
+				name = fields[0]
+			}
+			switch name {
+			case "media", "supports", "layer", "container":
+				inner, n := purgeCSSRules(css[open+1:min(closing, len(css))], used)
+				b.WriteString(css[start : open+1])
+				b.WriteString(inner)
+				b.WriteString(css[min(closing, len(css)):end])
+				removed += n
+			default:
+				b.WriteString(css[start:end])
+			}
+		} else if selectorsUnused(prelude, used) {
+			removed++
+		} else {
+			b.WriteString(css[start:end])
+		}
+		i = end
+	}
+	return b.String(), removed
+}
+
+// cssPreludeEnd returns the index of the { or ; ending the rule prelude that starts at i,
+// skipping strings, comments, and parenthesized or bracketed groups
+func cssPreludeEnd(css string, i int) int {
+	depth := 0
+	for i < len(css) {
+		switch c := css[i]; {
+		case strings.HasPrefix(css[i:], "/*"):
+			end := strings.Index(css[i+2:], "*/")
+			if end < 0 {
+				return len(css)
+			}
+			i += end + 4
+			continue
+		case c == '"' || c == '\'':
+			i = cssStringEnd(css, i)
+		case c == '\\':
+			i++
+		case c == '(' || c == '[':
+			depth++
+		case (c == ')' || c == ']') && depth > 0:
+			depth--
+		case (c == '{' || c == ';') && depth == 0:
+			return i
+		}
+		i++
+	}
+	return len(css)
+}
+
+// cssBlockEnd returns the index of the } matching the { at open, or len(css) if it is unclosed
+func cssBlockEnd(css string, open int) int {
+	depth := 0
+	for i := open; i < len(css); i++ {
+		switch c := css[i]; {
+		case strings.HasPrefix(css[i:], "/*"):
+			end := strings.Index(css[i+2:], "*/")
+			if end < 0 {
+				return len(css)
+			}
+			i += end + 3
+		case c == '"' || c == '\'':
+			i = cssStringEnd(css, i)
+		case c == '\\':
+			i++
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(css)
+}
+
+// selectorsUnused reports whether every selector in a comma-separated list is sure not to match.
+// The prelude is split naively on commas, so lists containing parentheses or brackets are kept.
+func selectorsUnused(prelude string, used usedSelectors) bool {
+	if strings.ContainsAny(prelude, `()[]\"'`) {
+		return false
+	}
+	for _, selector := range strings.Split(prelude, ",") {
+		if !selectorUnused(selector, used) {
+			return false
+		}
+	}
+	return true
+}
+
+// selectorUnused reports whether a simple selector names a class or id missing from the pages
+func selectorUnused(selector string, used usedSelectors) bool {
+	for i := 0; i < len(selector); i++ {
+		c := selector[i]
+		if c != '.' && c != '#' {
+			continue
+		}
+		start := i + 1
+		end := start
+		for end < len(selector) && isCSSNameByte(selector[end]) {
+			end++
+		}
+		name := selector[start:end]
+		if name == "" {
+			continue
+		}
+		if (c == '.' && !used.classes[name]) || (c == '#' && !used.ids[name]) {
+			return true
+		}
+		i = end - 1
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package assets
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPurgeUnusedCSS(t *testing.T) {
+	chdirOutput(t)
+	css := `.unused-class{color:red}
+.used-class{color:blue}
+#missing p{margin:0}
+.used-class, .other-unused{padding:0}
+@media (max-width: 600px){.unused-class{color:green}.used-class{color:navy}}
+@font-face{font-family:"X";src:url(fonts/x.woff2)}
+a[href$=".pdf"].unused-class{color:gray}
+body{margin:0}
+`
+	os.WriteFile("output/assets/style.css", []byte(css), 0644)
+	page := `<html><body><div class="wrap used-class"><p>Hi</p></div></body></html>`
+
+	removed, err := PurgeUnusedCSS("output/assets", []string{page})
+	if err != nil {
+		t.Fatalf("PurgeUnusedCSS returned error: %v", err)
+	}
+	if removed != 3 {
+		t.Errorf("expected 3 rules removed, got %d", removed)
+	}
+
+	data, _ := os.ReadFile("output/assets/style.css")
+	purged := string(data)
+	for _, gone := range []string{".unused-class{color:red}", "#missing p", ".unused-class{color:green}"} {
+		if strings.Contains(purged, gone) {
+			t.Errorf("unused rule %q should be removed: %s", gone, purged)
+		}
+	}
+	for _, kept := range []string{
+		".used-class{color:blue}",
+		".used-class, .other-unused{padding:0}",
+		"@media (max-width: 600px){.used-class{color:navy}}",
+		`@font-face{font-family:"X";src:url(fonts/x.woff2)}`,
+		`a[href$=".pdf"].unused-class{color:gray}`,
+		"body{margin:0}",
+	} {
+		if !strings.Contains(purged, kept) {
+			t.Errorf("rule %q should be kept: %s", kept, purged)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package assets
+
+import (
+	"bytes"
+	"image/jpeg"
+)
+
+// isJPEG reports whether data starts with the JPEG SOI marker, whatever the URL or Content-Type claims
+func isJPEG(data []byte) bool {
+	return len(data) >= 3 && data[0] == 0xFF && data[1] == 0xD8 && data[2] == 0xFF
+}
+
+// recompressJPEG re-encodes a JPEG at the given quality (1-100), keeping its dimensions. The
+// original bytes are returned when data is not a JPEG, cannot be decoded, or would not shrink.
+// PNG, GIF, SVG, and WebP images are never touched.
+func recompressJPEG(data []byte, quality int) []byte {
+	if quality <= 0 || !isJPEG(data) {
+		return data
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return data
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: min(quality, 100)}); err != nil {
+		return data
+	}
+	if buf.Len() >= len(data) {
+		return data
+	}
+	return buf.Bytes()
+}
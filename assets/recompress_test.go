@@ -0,0 +1,60 @@
+package assets
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestImageQualityRecompressesJPEG(t *testing.T) {
+	// Noise compresses poorly, so quality 100 is far larger than quality 30
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i * 7919 % 251)
+	}
+	var original bytes.Buffer
+	if err := jpeg.Encode(&original, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, img); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	_, base := newTestSite(t, map[string]string{
+		"/img/hero.jpg": original.String(),
+		"/img/icon.png": pngData.String(),
+	})
+	page := `<html><body><img src="/img/hero.jpg"><img src="/img/icon.png"></body></html>`
+
+	html, err := LocalizeAssets(page, base, Options{Concurrency: 2, ImageQuality: 30})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	if !strings.Contains(html, `src="assets/images/hero.jpg"`) {
+		t.Errorf("recompressed JPEG should still be referenced locally: %s", html)
+	}
+
+	saved, err := os.ReadFile("output/assets/images/hero.jpg")
+	if err != nil {
+		t.Fatalf("JPEG should be saved: %v", err)
+	}
+	if len(saved) >= original.Len() {
+		t.Errorf("expected recompressed JPEG smaller than %d bytes, got %d", original.Len(), len(saved))
+	}
+	config, err := jpeg.DecodeConfig(bytes.NewReader(saved))
+	if err != nil {
+		t.Fatalf("recompressed JPEG should decode: %v", err)
+	}
+	if config.Width != 64 || config.Height != 48 {
+		t.Errorf("dimensions should be kept, got %dx%d", config.Width, config.Height)
+	}
+
+	icon, err := os.ReadFile("output/assets/images/icon.png")
+	if err != nil || !bytes.Equal(icon, pngData.Bytes()) {
+		t.Errorf("PNG should be saved untouched (err %v)", err)
+	}
+}
@@ -0,0 +1,178 @@
+package assets
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// redirectDuplicateError reports that a URL redirected to a final URL whose body another job
+// is already downloading
+type redirectDuplicateError struct {
+	final string
+	owner string // Job URL that downloads the final URL
+}
+
+func (e *redirectDuplicateError) Error() string {
+	return fmt.Sprintf("redirects to %s, already downloaded via %s", e.final, e.owner)
+}
+
+// isRedirectDuplicate reports whether a download was skipped because another job fetches the
+// same final URL
+func isRedirectDuplicate(err error) bool {
+	var dup *redirectDuplicateError
+	return errors.As(err, &dup)
+}
+
+// claimFinalURL records that rawURL downloads the body served at finalURL (a redirect target or
+// the URL finally answered), failing with a redirectDuplicateError when another source URL got there first
+func (cd *ConcurrentDownloader) claimFinalURL(rawURL, finalURL string) error {
+	owner, _ := cd.finalURLs.LoadOrStore(finalURL, rawURL)
+	if owner.(string) != rawURL {
+		return &redirectDuplicateError{final: finalURL, owner: owner.(string)}
+	}
+	return nil
+}
+
+// redirectOwner tracks how the job that claimed a final URL ended, and the redirect duplicates
+// held back until it does
+type redirectOwner struct {
+	done    bool
+	failed  bool
+	waiting []heldDuplicate
+}
+
+// heldDuplicate is a redirect duplicate's job and result, reported once its owner settles
+type heldDuplicate struct {
+	job    DownloadJob
+	result DownloadResult
+}
+
+// owner returns the tracked state of a source URL. The caller holds redirectMu.
+func (cd *ConcurrentDownloader) owner(sourceURL string) *redirectOwner {
+	if cd.redirectOwners == nil {
+		cd.redirectOwners = make(map[string]*redirectOwner)
+	}
+	owner, ok := cd.redirectOwners[sourceURL]
+	if !ok {
+		owner = &redirectOwner{}
+		cd.redirectOwners[sourceURL] = owner
+	}
+	return owner
+}
+
+// holdRedirectDuplicate keeps a redirect duplicate's result back until the job downloading its
+// final URL reports. A duplicate whose owner already failed is queued again to fetch the URL
+// itself. It returns false when the owner succeeded, so the result can be reported right away.
+func (cd *ConcurrentDownloader) holdRedirectDuplicate(job DownloadJob, result DownloadResult) bool {
+	var dup *redirectDuplicateError
+	if !errors.As(result.Error, &dup) {
+		return false
+	}
+	cd.redirectMu.Lock()
+	defer cd.redirectMu.Unlock()
+	owner := cd.owner(dup.owner)
+	switch {
+	case !owner.done:
+		owner.waiting = append(owner.waiting, heldDuplicate{job: job, result: result})
+	case owner.failed:
+		cd.refetch(job)
+	default:
+		return false
+	}
+	return true
+}
+
+// settleRedirectDuplicates runs once a job reported its final result. When it failed, the final
+// URLs it claimed are released and every duplicate waiting on it is queued again to fetch them
+// itself; otherwise the duplicates are reported and later resolved to its file.
+func (cd *ConcurrentDownloader) settleRedirectDuplicates(job DownloadJob, result DownloadResult) {
+	sourceURL := requestKey(job.URL)
+	failed := !result.Success && !isRedirectDuplicate(result.Error)
+	if failed {
+		cd.finalURLs.Range(func(finalURL, owner any) bool {
+			if owner.(string) == sourceURL {
+				cd.finalURLs.CompareAndDelete(finalURL, owner)
+			}
+			return true
+		})
+	}
+
+	cd.redirectMu.Lock()
+	owner := cd.owner(sourceURL)
+	owner.done, owner.failed = true, failed
+	waiting := owner.waiting
+	owner.waiting = nil
+	cd.redirectMu.Unlock()
+
+	for _, held := range waiting {
+		if failed {
+			cd.refetch(held.job)
+		} else {
+			cd.report(held.job, held.result)
+		}
+	}
+}
+
+// refetch queues a held duplicate again. It still holds its pendingJobs slot, so the queue stays
+// open until it reports.
+func (cd *ConcurrentDownloader) refetch(job DownloadJob) {
+	go func() {
+		cd.jobs <- job
+	}()
+}
+
+// checkRedirect applies the redirect limit, drops the custom headers once a redirect leaves the
+// site's hosts, and claims each redirect target for the source URL, stopping the chain before the
+// body is fetched when another source URL already claimed it
+func (cd *ConcurrentDownloader) checkRedirect(limit int) func(*http.Request, []*http.Request) error {
+	check := redirectLimit(limit)
+	return func(req *http.Request, via []*http.Request) error {
+		if err := check(req, via); err != nil {
+			return err
+		}
+		stripOffsiteHeaders(req, cd.RequestHeaders, cd.CredentialHosts)
+		return cd.claimFinalURL(via[0].URL.String(), req.URL.String())
+	}
+}
+
+// requestKey normalizes a job URL the way http.NewRequest does, to match claimed source URLs
+func requestKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.String()
+}
+
+// resolveRedirectDuplicates points every job skipped as a redirect duplicate at the file saved by
+// the job that downloaded the shared final URL, so all original references are still rewritten
+func (cd *ConcurrentDownloader) resolveRedirectDuplicates(urlMap map[string]string) {
+	saved := make(map[string]string)
+	for _, result := range cd.collected {
+		if result.Success {
+			saved[requestKey(result.Job.URL)] = result.LocalPath
+		}
+	}
+	// An owner may itself be a duplicate further down its redirect chain, so repeat until settled
+	for resolved := true; resolved; {
+		resolved = false
+		for i, result := range cd.collected {
+			var dup *redirectDuplicateError
+			if !errors.As(result.Error, &dup) {
+				continue
+			}
+			localPath, ok := saved[dup.owner]
+			if !ok {
+				continue
+			}
+			cd.collected[i].Success = true
+			cd.collected[i].LocalPath = localPath
+			cd.collected[i].Error = nil
+			urlMap[result.Job.OriginalPath] = localPath
+			saved[requestKey(result.Job.URL)] = localPath
+			resolved = true
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package assets
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRedirectingURLsDownloadCanonicalOnce(t *testing.T) {
+	var canonicalHits int32
+	mux := http.NewServeMux()
+	mux.Handle("/img/a.png", http.RedirectHandler("/img/canonical.png", http.StatusFound))
+	mux.Handle("/img/b.png", http.RedirectHandler("/img/canonical.png", http.StatusMovedPermanently))
+	mux.HandleFunc("/img/canonical.png", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&canonicalHits, 1)
+		w.Write([]byte("canonical"))
+	})
+	_, base := newTestSiteHandler(t, mux)
+	page := `<html><body><img src="/img/a.png"><img src="/img/b.png"></body></html>`
+
+	html, err := LocalizeAssets(page, base, Options{Concurrency: 1, ManifestPath: "output/manifest.json"})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	if got := atomic.LoadInt32(&canonicalHits); got != 1 {
+		t.Errorf("canonical asset should be downloaded once, got %d requests", got)
+	}
+	if strings.Count(html, `src="assets/images/a.png"`) != 2 {
+		t.Errorf("both references should point at the single download: %s", html)
+	}
+	if _, err := os.Stat("output/assets/images/b.png"); !os.IsNotExist(err) {
+		t.Errorf("the duplicate should not be saved separately")
+	}
+
+	data, err := os.ReadFile("output/manifest.json")
+	if err != nil {
+		t.Fatalf("manifest should be written: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("invalid manifest: %v", err)
+	}
+	for _, entry := range manifest.Assets {
+		if entry.Error != "" || entry.LocalPath != "assets/images/a.png" {
+			t.Errorf("every redirecting URL should map to the canonical copy, got %+v", entry)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package assets
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+
+	"wp-static-scraper/utils"
+)
+
+// reportTemplate renders the human-readable scrape summary written by -output-report-html
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"size":    formatSize,
+	"percent": func(rate float64) string { return fmt.Sprintf("%.0f%%", rate*100) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Scrape report: {{.SourceURL}}</title>
+<style>
+body{font-family:system-ui,sans-serif;margin:2rem;color:#222}
+table{border-collapse:collapse;margin-bottom:2rem}
+th,td{border:1px solid #ddd;padding:.4rem .8rem;text-align:left}
+.failed td{background:#fdecea}
+.grid{display:flex;flex-wrap:wrap;gap:.5rem}
+.grid figure{margin:0;width:120px;font-size:.75rem;word-break:break-all}
+.grid img{width:120px;height:90px;object-fit:cover;border:1px solid #ddd}
+</style>
+</head>
+<body>
+<h1>Scrape report</h1>
+<p>Source: <a href="{{.SourceURL}}">{{.SourceURL}}</a></p>
+<p>{{.Downloaded}} of {{.Total}} assets downloaded, {{size .TotalSize}} on disk.</p>
+
+<h2>By type</h2>
+<table>
+<tr><th>Type</th><th>Downloaded</th><th>Failed</th><th>Size</th></tr>
+{{range .Types}}<tr><td>{{.Type}}</td><td>{{.Downloaded}}</td><td>{{.Failed}}</td><td>{{size .Size}}</td></tr>
+{{end}}</table>
+
+{{with .Concurrency}}<h2>Concurrency</h2>
+<p>Effective workers: min {{.Min}}, max {{.Max}}, average {{printf "%.1f" .Avg}}; backed off {{.Backoffs}} time(s).</p>
+<table>
+<tr><th>Elapsed</th><th>Workers</th><th>Error rate</th></tr>
+{{range .Samples}}<tr{{if .Throttled}} class="failed"{{end}}><td>{{printf "%.1fs" .ElapsedSeconds}}</td><td>{{.Workers}}</td><td>{{percent .ErrorRate}}</td></tr>
+{{end}}</table>
+{{end}}
+{{if .Failures}}<h2>Failures</h2>
+<table>
+<tr><th>URL</th><th>Type</th><th>Error</th></tr>
+{{range .Failures}}<tr class="failed"><td><a href="{{.URL}}">{{.URL}}</a></td><td>{{.Type}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Downloaded assets</h2>
+<table>
+<tr><th>Local path</th><th>Type</th><th>Size</th><th>Original URL</th></tr>
+{{range .Downloads}}<tr><td><a href="{{.LocalPath}}">{{.LocalPath}}</a></td><td>{{.Type}}</td><td>{{size .Size}}</td><td><a href="{{.URL}}">{{.URL}}</a></td></tr>
+{{end}}</table>
+
+{{if .Images}}<h2>Images</h2>
+<div class="grid">
+{{range .Images}}<figure><a href="{{.LocalPath}}"><img src="{{.LocalPath}}" alt="" loading="lazy"></a><figcaption>{{.LocalPath}}</figcaption></figure>
+{{end}}</div>
+{{end}}</body>
+</html>
+`))
+
+// reportTypeSummary aggregates manifest entries of one asset type
+type reportTypeSummary struct {
+	Type       string
+	Downloaded int
+	Failed     int
+	Size       int64
+}
+
+// reportData is the view model for reportTemplate
+type reportData struct {
+	SourceURL  string
+	Total      int
+	Downloaded int
+	TotalSize  int64
+	Types      []reportTypeSummary
+	Failures   []ManifestEntry
+	Downloads  []ManifestEntry
+	Images     []ManifestEntry
+
+	Concurrency *ConcurrencyStats
+}
+
+// WriteReportHTML renders the manifest as an HTML dashboard. The report is meant to live in the
+// output directory, so local paths in the manifest link straight to the saved files.
+func WriteReportHTML(out *utils.Output, path string, manifest Manifest) error {
+	data := reportData{SourceURL: manifest.SourceURL, Total: len(manifest.Assets), Concurrency: manifest.Concurrency}
+	byType := make(map[string]*reportTypeSummary)
+
+	for _, entry := range manifest.Assets {
+		summary, ok := byType[entry.Type]
+		if !ok {
+			summary = &reportTypeSummary{Type: entry.Type}
+			byType[entry.Type] = summary
+		}
+
+		if entry.LocalPath == "" {
+			summary.Failed++
+			data.Failures = append(data.Failures, entry)
+			continue
+		}
+
+		summary.Downloaded++
+		summary.Size += entry.Size
+		data.Downloaded++
+		data.TotalSize += entry.Size
+		data.Downloads = append(data.Downloads, entry)
+		if entry.Type == "image" {
+			data.Images = append(data.Images, entry)
+		}
+	}
+
+	for _, summary := range byType {
+		data.Types = append(data.Types, *summary)
+	}
+	sort.Slice(data.Types, func(i, j int) bool {
+		return data.Types[i].Type < data.Types[j].Type
+	})
+
+	var buf bytes.Buffer
+	if err := reportTemplate.Execute(&buf, data); err != nil {
+		return err
+	}
+	return out.WriteFile(path, buf.Bytes())
+}
+
+// formatSize renders a byte count for humans
+func formatSize(bytes int64) string {
+	switch {
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
+}
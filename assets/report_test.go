@@ -0,0 +1,36 @@
+package assets
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestOutputReportHTML(t *testing.T) {
+	server, base := newTestSite(t, map[string]string{
+		"/img/logo.png":  "png-bytes",
+		"/css/style.css": "body{color:red}",
+	})
+	page := `<html><head><link rel="stylesheet" href="` + server.URL + `/css/style.css"></head>` +
+		`<body><img src="` + server.URL + `/img/logo.png"><img src="` + server.URL + `/img/missing.png"></body></html>`
+
+	opts := Options{Concurrency: 2, ReportPath: "output/_report.html"}
+	if _, err := LocalizeAssets(page, base, opts); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	data, err := os.ReadFile("output/_report.html")
+	if err != nil {
+		t.Fatalf("report should be written: %v", err)
+	}
+	report := string(data)
+	for _, want := range []string{
+		`<a href="assets/style.css">assets/style.css</a>`,
+		`<img src="assets/images/logo.png"`,
+		`<a href="` + server.URL + `/img/missing.png">`,
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report should contain %s, got %s", want, report)
+		}
+	}
+}
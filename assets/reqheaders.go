@@ -0,0 +1,111 @@
+package assets
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// fetchFunc downloads a URL's body for the serial helpers, failing on any status but 200
+type fetchFunc func(rawURL string) ([]byte, error)
+
+// setHeaders adds the user-supplied request headers (-header), replacing Go's defaults, when the
+// request goes to one of hosts (see Options.credentialHosts; empty allows every host). A name
+// given more than once sends every value.
+func setHeaders(req *http.Request, headers http.Header, hosts []string) {
+	if len(hosts) > 0 && !hostListed(req.URL, hosts) {
+		return
+	}
+	for name, values := range headers {
+		req.Header.Del(name)
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+}
+
+// stripOffsiteHeaders removes the user-supplied headers from a redirect that left hosts. Go's
+// client already drops Authorization and cookies on its own, but not custom headers.
+func stripOffsiteHeaders(req *http.Request, headers http.Header, hosts []string) {
+	if len(hosts) == 0 || hostListed(req.URL, hosts) {
+		return
+	}
+	for name := range headers {
+		req.Header.Del(name)
+	}
+}
+
+// setUserAgent replaces Go's default User-Agent (no-op when empty). Unlike -header values it goes
+// to every host.
+func setUserAgent(req *http.Request, userAgent string) {
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+}
+
+// assetClient builds the client serial downloads use, routed through AssetProxy and stopping after
+// MaxRedirects like the concurrent downloader's
+func (o Options) assetClient() (*http.Client, error) {
+	limit := DefaultMaxRedirects
+	if o.MaxRedirects > 0 {
+		limit = o.MaxRedirects
+	}
+	check := redirectLimit(limit)
+	hosts := o.credentialHosts()
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if err := check(req, via); err != nil {
+				return err
+			}
+			stripOffsiteHeaders(req, o.Headers, hosts)
+			return nil
+		},
+	}
+	if o.AssetProxy != "" {
+		proxyURL, err := url.Parse(o.AssetProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asset proxy: %w", err)
+		}
+		client.Transport = &http.Transport{Proxy: http.ProxyURL(proxyURL)}
+	}
+	return client, nil
+}
+
+// fetcher returns the fetchFunc serial downloads use: the asset client, sending the User-Agent,
+// custom headers, request ID, and basic auth credentials the page fetch sends, and holding a
+// RequestLimiter slot for each request like the worker pool
+func (o Options) fetcher() fetchFunc {
+	client, clientErr := o.assetClient()
+	hosts := o.credentialHosts()
+	return func(rawURL string) ([]byte, error) {
+		if clientErr != nil {
+			return nil, clientErr
+		}
+		req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		setUserAgent(req, o.UserAgent)
+		setHeaders(req, o.Headers, hosts)
+		setRequestID(req, o.RequestIDHeader)
+		setBasicAuth(req, o.BasicAuth, hosts)
+
+		if err := o.RequestLimiter.acquire(context.Background()); err != nil {
+			return nil, err
+		}
+		defer o.RequestLimiter.release()
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 {
+			return nil, fmt.Errorf("bad status: %s", resp.Status)
+		}
+		return io.ReadAll(resp.Body)
+	}
+}
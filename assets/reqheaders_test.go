@@ -0,0 +1,105 @@
+package assets
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"wp-static-scraper/internal/testsite"
+)
+
+func TestCustomHeadersStayOnSiteHosts(t *testing.T) {
+	var foreignToken, foreignAgent atomic.Value
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		foreignToken.Store(r.Header.Get("X-Staging-Token"))
+		foreignAgent.Store(r.UserAgent())
+		w.Write([]byte("cdn"))
+	}))
+	defer foreign.Close()
+
+	var missing int64
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Staging-Token") != "abc123" {
+			atomic.AddInt64(&missing, 1)
+		}
+		switch r.URL.Path {
+		case "/img/moved.png":
+			// A redirect off the site must not carry the headers along
+			http.Redirect(w, r, foreign.URL+"/img/moved.png", http.StatusFound)
+		default:
+			w.Write([]byte("site"))
+		}
+	}))
+	defer site.Close()
+
+	chdirOutput(t)
+	opts := Options{
+		Concurrency: 1,
+		UserAgent:   "StagingBot/1.0",
+		Headers:     http.Header{"X-Staging-Token": {"abc123"}},
+		SiteHosts:   []string{strings.TrimPrefix(site.URL, "http://")},
+	}
+	base, _ := url.Parse(site.URL + "/")
+	for _, src := range []string{site.URL + "/img/logo.png", site.URL + "/img/moved.png", foreign.URL + "/img/cdn.png"} {
+		foreignToken.Store("")
+		page := `<html><body><img src="` + src + `"></body></html>`
+		if _, err := LocalizeAssets(page, base, opts); err != nil {
+			t.Fatalf("LocalizeAssets returned error: %v", err)
+		}
+		if token := foreignToken.Load(); token != nil && token != "" {
+			t.Errorf("%s: a third-party host received X-Staging-Token %q", src, token)
+		}
+	}
+	if n := atomic.LoadInt64(&missing); n != 0 {
+		t.Errorf("%d requests to the site went without the custom headers", n)
+	}
+	if agent := foreignAgent.Load(); agent != "StagingBot/1.0" {
+		t.Errorf("the User-Agent should still go to every host, got %q", agent)
+	}
+}
+
+func TestFetcherUsesAssetClient(t *testing.T) {
+	var proxied int64
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&proxied, 1)
+		w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	body, err := Options{AssetProxy: proxy.URL}.fetcher()("http://assets.invalid/app.js")
+	if err != nil || string(body) != "via proxy" {
+		t.Fatalf("serial fetches should go through -asset-proxy, got %q (%v)", body, err)
+	}
+	if atomic.LoadInt64(&proxied) != 1 {
+		t.Errorf("proxy should see the request")
+	}
+
+	var hops int64
+	loop := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hops, 1)
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	}))
+	defer loop.Close()
+	if _, err := (Options{MaxRedirects: 2}).fetcher()(loop.URL + "/loop"); !errors.Is(err, ErrTooManyRedirects) {
+		t.Errorf("serial fetches should stop after -max-redirects, got %v", err)
+	}
+	if got := atomic.LoadInt64(&hops); got != 3 {
+		t.Errorf("server saw %d requests; want 3", got)
+	}
+}
+
+func TestRobotsMatchesSentUserAgent(t *testing.T) {
+	server := testsite.New(t, map[string]string{
+		"/robots.txt": "User-agent: Googlebot\nDisallow: /\n\nUser-agent: *\nAllow: /\n",
+	})
+	if allowed, err := RobotsAllowed(server.URL+"/page/", Options{}); err != nil || !allowed {
+		t.Errorf("the default agent falls under *, got %v (%v)", allowed, err)
+	}
+	if allowed, err := RobotsAllowed(server.URL+"/page/", Options{UserAgent: "Googlebot/2.1 (+http://www.google.com/bot.html)"}); err != nil || allowed {
+		t.Errorf("rules for the -user-agent actually sent should apply, got %v (%v)", allowed, err)
+	}
+}
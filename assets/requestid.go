@@ -0,0 +1,25 @@
+package assets
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+// newRequestID returns a random (version 4) UUID
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// setRequestID tags a request with a fresh unique ID under the given header name (no-op when empty)
+func setRequestID(req *http.Request, header string) {
+	if header != "" {
+		req.Header.Set(header, newRequestID())
+	}
+}
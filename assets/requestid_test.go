@@ -0,0 +1,45 @@
+package assets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func TestRequestIDHeader(t *testing.T) {
+	chdirOutput(t)
+
+	var mu sync.Mutex
+	ids := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ids[r.URL.Path] = r.Header.Get("X-Request-ID")
+		mu.Unlock()
+		w.Write([]byte("asset"))
+	}))
+	defer server.Close()
+
+	opts := Options{Concurrency: 2, RequestIDHeader: "X-Request-ID"}
+	if _, err := FetchPage(server.URL+"/", opts); err != nil {
+		t.Fatalf("FetchPage returned error: %v", err)
+	}
+	base, _ := url.Parse(server.URL + "/")
+	page := `<html><body><img src="` + server.URL + `/a.png"><img src="` + server.URL + `/b.png"></body></html>`
+	if _, err := LocalizeAssets(page, base, opts); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, path := range []string{"/", "/a.png", "/b.png"} {
+		id := ids[path]
+		if len(id) != 36 {
+			t.Errorf("request for %s should carry a UUID request ID, got %q", path, id)
+		}
+		if seen[id] {
+			t.Errorf("request IDs should be distinct, %q was reused", id)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,172 @@
+package assets
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RobotsUserAgent is the product token matched against robots.txt User-agent lines
+const RobotsUserAgent = "wp-static-scraper"
+
+// robotsRule is one Allow or Disallow line
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// RobotsAllowed fetches robots.txt from the page's origin and reports whether it lets the agent
+// actually sent (opts.UserAgent, else RobotsUserAgent) fetch the page. A missing robots.txt (any
+// 4xx) allows everything; a server error or unreachable host is returned as an error so strict
+// callers can refuse to continue.
+func RobotsAllowed(pageURL string, opts Options) (bool, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return false, err
+	}
+	robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+
+	client := http.DefaultClient
+	if opts.PageProxy != "" {
+		proxyURL, err := url.Parse(opts.PageProxy)
+		if err != nil {
+			return false, fmt.Errorf("invalid page proxy: %w", err)
+		}
+		client = &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, robotsURL.String(), nil)
+	if err != nil {
+		return false, err
+	}
+	setUserAgent(req, opts.UserAgent)
+	setHeaders(req, opts.Headers, opts.credentialHosts())
+	setBasicAuth(req, opts.BasicAuth, opts.credentialHosts())
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("fetching %s: %w", robotsURL, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return true, nil
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("fetching %s: bad status: %s", robotsURL, resp.Status)
+	}
+
+	target := u.EscapedPath()
+	if target == "" {
+		target = "/"
+	}
+	if u.RawQuery != "" {
+		target += "?" + u.RawQuery
+	}
+	return robotsAllows(resp.Body, opts.robotsAgent(), target), nil
+}
+
+// robotsAgent is the product token robots.txt rules are matched against: the first token of the
+// User-Agent sent (e.g. "Googlebot" for "Googlebot/2.1 (+http://www.google.com/bot.html)"), else
+// RobotsUserAgent
+func (o Options) robotsAgent() string {
+	if fields := strings.Fields(o.UserAgent); len(fields) > 0 {
+		product, _, _ := strings.Cut(fields[0], "/")
+		return product
+	}
+	return RobotsUserAgent
+}
+
+// robotsAllows applies the rules of the group for userAgent (or the * group when none names it)
+// to path: the longest matching pattern decides, and Allow wins a tie. A group names the agent
+// only when its product token matches in full, ignoring case (RFC 9309), so "wp" or "bot" does not.
+func robotsAllows(robots io.Reader, userAgent, path string) bool {
+	var specific, wildcard []robotsRule
+	var agents []string
+	inRules := false     // a rule line ends the User-agent lines of a group
+	hasSpecific := false // a group names userAgent, so the * group is ignored
+
+	scanner := bufio.NewScanner(robots)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field = strings.ToLower(strings.TrimSpace(field))
+		value = strings.TrimSpace(value)
+
+		switch field {
+		case "user-agent":
+			if inRules {
+				agents = nil
+				inRules = false
+			}
+			agent := strings.ToLower(value)
+			agents = append(agents, agent)
+			if agent != "*" && strings.EqualFold(agent, userAgent) {
+				hasSpecific = true
+			}
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				// An empty Disallow allows everything and adds no rule
+				continue
+			}
+			rule := robotsRule{allow: field == "allow", pattern: value}
+			for _, agent := range agents {
+				switch {
+				case agent != "*" && strings.EqualFold(agent, userAgent):
+					specific = append(specific, rule)
+				case agent == "*":
+					wildcard = append(wildcard, rule)
+				}
+			}
+		}
+	}
+
+	rules := wildcard
+	if hasSpecific {
+		rules = specific
+	}
+	allowed, best := true, -1
+	for _, rule := range rules {
+		if !robotsPatternMatches(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > best || (len(rule.pattern) == best && rule.allow) {
+			allowed, best = rule.allow, len(rule.pattern)
+		}
+	}
+	return allowed
+}
+
+// robotsPatternMatches matches a path against a robots.txt pattern, where * matches any run of
+// characters and a trailing $ anchors the end of the path
+func robotsPatternMatches(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for _, part := range parts[1:] {
+		idx := strings.Index(path[pos:], part)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(part)
+	}
+	if !anchored {
+		return true
+	}
+	// With a wildcard before the anchor, the last part only needs to end the path
+	if len(parts) > 1 {
+		return strings.HasSuffix(path, parts[len(parts)-1])
+	}
+	return pos == len(path)
+}
@@ -0,0 +1,26 @@
+package assets
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRobotsGroupMatchesWholeProductToken(t *testing.T) {
+	robots := "User-agent: wp\nDisallow: /\n\n" +
+		"User-agent: bot\nDisallow: /\n\n" +
+		"User-agent: *\nDisallow: /private/\n"
+	if !robotsAllows(strings.NewReader(robots), RobotsUserAgent, "/page/") {
+		t.Errorf("groups whose names are only part of %q should not apply to it", RobotsUserAgent)
+	}
+	if robotsAllows(strings.NewReader(robots), RobotsUserAgent, "/private/x") {
+		t.Errorf("the * group should apply when no group names %q", RobotsUserAgent)
+	}
+
+	named := "User-agent: WP-Static-Scraper\nDisallow: /drafts/\n\nUser-agent: *\nDisallow: /\n"
+	if !robotsAllows(strings.NewReader(named), RobotsUserAgent, "/page/") {
+		t.Errorf("a group naming the agent in another case should replace the * group")
+	}
+	if robotsAllows(strings.NewReader(named), RobotsUserAgent, "/drafts/x") {
+		t.Errorf("the named group's rules should apply")
+	}
+}
@@ -0,0 +1,23 @@
+package assets
+
+import (
+	"net/url"
+	"strings"
+)
+
+// inCrawlScope reports whether a page link may be followed: it must be on the start host or on
+// one of the allowlisted hosts (bare hostnames match any port, host:port entries match exactly)
+func inCrawlScope(u, start *url.URL, allowedHosts []string) bool {
+	return strings.EqualFold(u.Host, start.Host) || hostListed(u, allowedHosts)
+}
+
+// hostListed reports whether u's host is in hosts (bare hostnames match any port, host:port
+// entries match exactly)
+func hostListed(u *url.URL, hosts []string) bool {
+	for _, host := range hosts {
+		if strings.EqualFold(host, u.Host) || strings.EqualFold(host, u.Hostname()) {
+			return true
+		}
+	}
+	return false
+}
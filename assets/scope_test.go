@@ -0,0 +1,38 @@
+package assets
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"wp-static-scraper/internal/testsite"
+)
+
+func TestCrawlHostsAllowlist(t *testing.T) {
+	external := testsite.New(t, map[string]string{
+		"/page/3/": `<html><body>elsewhere</body></html>`,
+	})
+	subdomain := testsite.New(t, map[string]string{
+		"/page/2/": `<html><head><link rel="next" href="` + external.URL + `/page/3/"></head><body>shop</body></html>`,
+	})
+	start, _ := url.Parse("http://blog.example.test/")
+	first := Page{
+		URL:     start,
+		OutPath: "index.html",
+		Body:    []byte(`<html><head><link rel="next" href="` + subdomain.URL + `/page/2/"></head><body>blog</body></html>`),
+	}
+
+	pages := FetchPaginatedPages(first, 5, Options{})
+	if len(pages) != 1 {
+		t.Fatalf("other hosts should not be followed without an allowlist, got %d pages", len(pages))
+	}
+
+	subdomainURL, _ := url.Parse(subdomain.URL)
+	pages = FetchPaginatedPages(first, 5, Options{CrawlHosts: []string{subdomainURL.Host}})
+	if len(pages) != 2 {
+		t.Fatalf("expected the allowlisted host to be followed and the external one skipped, got %d pages", len(pages))
+	}
+	if pages[1].URL.Host != subdomainURL.Host || !strings.Contains(string(pages[1].Body), "shop") {
+		t.Errorf("second page should come from the allowlisted host: %s %s", pages[1].URL, pages[1].Body)
+	}
+}
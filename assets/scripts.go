@@ -0,0 +1,71 @@
+package assets
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// scriptLinkRels are <link> relations that only exist to fetch JavaScript
+var scriptLinkRels = map[string]bool{
+	"modulepreload": true,
+}
+
+// isScriptNode reports whether an element is a script or only preloads one
+func isScriptNode(n *html.Node) bool {
+	switch n.Data {
+	case "script":
+		return true
+	case "link":
+		rel := strings.ToLower(getAttr(n, "rel"))
+		return scriptLinkRels[rel] || (rel == "preload" && strings.EqualFold(getAttr(n, "as"), "script"))
+	}
+	return false
+}
+
+// stripScripts removes every <script> element, script preloads, inline on* event handlers, and
+// javascript: URLs, leaving a pure HTML+CSS document that cannot run code once archived
+func stripScripts(htmlContent string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", err
+	}
+
+	var removed []*html.Node
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if isScriptNode(n) {
+				removed = append(removed, n)
+				return
+			}
+			attrs := n.Attr[:0]
+			for _, attr := range n.Attr {
+				if strings.HasPrefix(strings.ToLower(attr.Key), "on") {
+					continue
+				}
+				if strings.HasPrefix(strings.ToLower(strings.TrimSpace(attr.Val)), "javascript:") {
+					continue
+				}
+				attrs = append(attrs, attr)
+			}
+			n.Attr = attrs
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+
+	traverse(doc)
+
+	for _, n := range removed {
+		n.Parent.RemoveChild(n)
+	}
+
+	var buf strings.Builder
+	if err := html.Render(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,39 @@
+package assets
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestStripScriptsAll(t *testing.T) {
+	var jsHits int64
+	_, base := newTestSiteHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".js") {
+			atomic.AddInt64(&jsHits, 1)
+		}
+		w.Write([]byte("asset"))
+	}))
+	page := `<html><head><script src="/js/app.js"></script><link rel="modulepreload" href="/js/mod.js">` +
+		`<link rel="stylesheet" href="/css/site.css"><script>track()</script></head>` +
+		`<body onload="init()"><a href="javascript:void(0)" onclick="open()">Menu</a><img src="/img/a.png"></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 2, StripScripts: true})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	for _, unwanted := range []string{"<script", "onload", "onclick", "javascript:", "mod.js"} {
+		if strings.Contains(result, unwanted) {
+			t.Errorf("stripped snapshot should not contain %q: %s", unwanted, result)
+		}
+	}
+	if got := atomic.LoadInt64(&jsHits); got != 0 {
+		t.Errorf("no JavaScript should be downloaded, got %d requests", got)
+	}
+	for _, want := range []string{`href="assets/site.css"`, `src="assets/images/a.png"`, ">Menu</a>"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %s in stripped snapshot: %s", want, result)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package assets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+)
+
+// Reason codes for assets that were left out of the localized output
+const (
+	SkipAdminBar      = "admin-bar"      // Removed with the WordPress admin bar (-strip-admin-bar)
+	SkipOverBudget    = "over-budget"    // The -max-total-bytes budget was used up
+	SkipAborted       = "aborted"        // The run was cancelled by -fail-fast or -max-runtime
+	SkipRedirectLimit = "redirect-limit" // More redirects than -max-redirect-per-asset
+	SkipTooLarge      = "too-large"      // A downloadable file bigger than -max-file-size
+	SkipEmptyBody     = "empty-body"     // Empty 200 responses on every attempt
+	SkipDeadHost      = "dead-host"      // DNS lookup or connection failed
+	SkipHTTPStatus    = "http-status"    // Non-200 response
+	SkipFailed        = "failed"         // Any other download or write error
+)
+
+// SkippedAsset is an asset left remote, with the reason code explaining why
+type SkippedAsset struct {
+	URL    string `json:"url"`
+	Type   string `json:"type,omitempty"`
+	Reason string `json:"reason"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// skippedFromResults lists every unsuccessful download with its reason code
+func skippedFromResults(results []DownloadResult) []SkippedAsset {
+	var skipped []SkippedAsset
+	for _, result := range results {
+		if result.Success {
+			continue
+		}
+		reason := skipReasonFor(result.Error)
+		entry := SkippedAsset{URL: result.Job.URL, Type: result.Job.Type, Reason: reason}
+		if result.Error != nil && reason != SkipAborted && reason != SkipOverBudget {
+			entry.Detail = result.Error.Error()
+		}
+		skipped = append(skipped, entry)
+	}
+	return skipped
+}
+
+// skipReasonFor maps a download error to its reason code
+func skipReasonFor(err error) string {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	switch {
+	case errors.Is(err, ErrBudgetExceeded):
+		return SkipOverBudget
+	case errors.Is(err, context.Canceled):
+		return SkipAborted
+	case errors.Is(err, ErrTooManyRedirects):
+		return SkipRedirectLimit
+	case errors.Is(err, ErrTooLarge):
+		return SkipTooLarge
+	case errors.Is(err, ErrEmptyBody):
+		return SkipEmptyBody
+	case errors.As(err, &dnsErr), errors.As(err, &opErr) && opErr.Op == "dial":
+		return SkipDeadHost
+	case errors.Is(err, ErrBadStatus):
+		return SkipHTTPStatus
+	default:
+		return SkipFailed
+	}
+}
+
+// printSkipReasons logs one line per skipped asset, ordered by reason and URL
+func printSkipReasons(w io.Writer, skipped []SkippedAsset) {
+	sort.SliceStable(skipped, func(i, j int) bool {
+		if skipped[i].Reason != skipped[j].Reason {
+			return skipped[i].Reason < skipped[j].Reason
+		}
+		return skipped[i].URL < skipped[j].URL
+	})
+	for _, entry := range skipped {
+		line := fmt.Sprintf("SKIPPED [%s] %s", entry.Reason, entry.URL)
+		if entry.Type != "" {
+			line += " (type: " + entry.Type + ")"
+		}
+		if entry.Detail != "" {
+			line += ": " + entry.Detail
+		}
+		fmt.Fprintln(w, line)
+	}
+}
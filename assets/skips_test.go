@@ -0,0 +1,55 @@
+package assets
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestVerboseSkipReasons(t *testing.T) {
+	server, base := newTestSite(t, map[string]string{"/style.css": "body{}"})
+	page := `<html><head>` +
+		`<link rel="stylesheet" id="admin-bar-css" href="` + server.URL + `/wp-includes/css/admin-bar.min.css">` +
+		`<link rel="stylesheet" href="` + server.URL + `/style.css">` +
+		`</head><body><img src="` + server.URL + `/missing.png"></body></html>`
+	var log bytes.Buffer
+	opts := Options{Concurrency: 2, StripAdminBar: true, VerboseSkips: true, ManifestPath: "output/manifest.json", Log: &log}
+
+	_, err := LocalizeAssets(page, base, opts)
+	output := log.String()
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	for _, want := range []string{
+		"SKIPPED [admin-bar] " + server.URL + "/wp-includes/css/admin-bar.min.css",
+		"SKIPPED [http-status] " + server.URL + "/missing.png (type: image): bad status: 404 Not Found",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in output:\n%s", want, output)
+		}
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "SKIPPED") && strings.Contains(line, "/style.css") {
+			t.Errorf("downloaded assets should not be reported as skipped: %s", line)
+		}
+	}
+
+	data, err := os.ReadFile("output/manifest.json")
+	if err != nil {
+		t.Fatalf("manifest should be written: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("invalid manifest JSON: %v", err)
+	}
+	reasons := make(map[string]string)
+	for _, entry := range manifest.Skipped {
+		reasons[entry.URL] = entry.Reason
+	}
+	if reasons[server.URL+"/wp-includes/css/admin-bar.min.css"] != SkipAdminBar || reasons[server.URL+"/missing.png"] != SkipHTTPStatus {
+		t.Errorf("manifest should list skip reasons, got %+v", manifest.Skipped)
+	}
+}
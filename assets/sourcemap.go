@@ -0,0 +1,101 @@
+package assets
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"wp-static-scraper/utils"
+)
+
+// sourceMapURLRe finds the sourceMappingURL comment in a JS or CSS file
+var sourceMapURLRe = regexp.MustCompile(`(?:/\*|//)[#@]\s*sourceMappingURL=([^\s*]+)`)
+
+// sourceMapAssetRe finds quoted image and font references inside original sources
+var sourceMapAssetRe = regexp.MustCompile("[\"'`]([^\"'`\\s]+\\.(png|jpe?g|gif|webp|avif|svg|ico|woff2?|ttf|eot|otf))(?:\\?[^\"'`\\s]*)?[\"'`]")
+
+// sourceMap holds the parts of a source map used for asset discovery
+type sourceMap struct {
+	Sources        []string `json:"sources"`
+	SourcesContent []string `json:"sourcesContent"`
+}
+
+// discoverSourceMapAssets downloads images and fonts that are only referenced in a file's source map
+func (cd *ConcurrentDownloader) discoverSourceMapAssets(fileURL, content string) {
+	matches := sourceMapURLRe.FindAllStringSubmatch(content, -1)
+	if len(matches) == 0 {
+		return
+	}
+	mapRef := matches[len(matches)-1][1]
+
+	mapData, err := cd.loadSourceMap(fileURL, mapRef)
+	if err != nil {
+		return
+	}
+
+	var sm sourceMap
+	if err := json.Unmarshal(mapData, &sm); err != nil {
+		return
+	}
+
+	base, err := url.Parse(fileURL)
+	if err != nil {
+		return
+	}
+
+	for _, source := range sm.SourcesContent {
+		for _, match := range sourceMapAssetRe.FindAllStringSubmatch(source, -1) {
+			ref := match[1]
+			// Skip inline data and bundler-internal schemes such as webpack://
+			if strings.HasPrefix(ref, "data:") || (strings.Contains(ref, "://") && !strings.HasPrefix(ref, "http")) {
+				continue
+			}
+
+			assetURL := utils.ResolveURL(base, ref)
+			if strings.HasPrefix(ref, "//") {
+				assetURL = base.Scheme + ":" + ref
+			}
+			if !cd.claimURL(assetURL) {
+				continue
+			}
+
+			jobType := "image"
+			switch match[2] {
+			case "woff", "woff2", "ttf", "eot", "otf":
+				jobType = "font"
+			}
+
+			// Report discovered assets alongside the page's own results
+			job := DownloadJob{URL: assetURL, Type: jobType, OriginalPath: assetURL, BaseURL: base}
+			atomic.AddInt64(&cd.totalJobs, 1)
+			result := cd.processJob(job)
+			atomic.AddInt64(&cd.completedJobs, 1)
+			cd.results <- result
+		}
+	}
+}
+
+// loadSourceMap fetches a source map, decoding inline data: URI maps directly
+func (cd *ConcurrentDownloader) loadSourceMap(fileURL, mapRef string) ([]byte, error) {
+	if strings.HasPrefix(mapRef, "data:") {
+		comma := strings.Index(mapRef, ",")
+		if comma == -1 {
+			return nil, errors.New("invalid data URI source map")
+		}
+		if strings.HasSuffix(mapRef[:comma], ";base64") {
+			return base64.StdEncoding.DecodeString(mapRef[comma+1:])
+		}
+		return []byte(mapRef[comma+1:]), nil
+	}
+
+	base, err := url.Parse(fileURL)
+	if err != nil {
+		return nil, err
+	}
+	data, _, err := cd.fetch(utils.ResolveURL(base, mapRef))
+	return data, err
+}
@@ -0,0 +1,31 @@
+package assets
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSourceMapAssetDiscovery(t *testing.T) {
+	server, base := newTestSite(t, map[string]string{
+		"/js/app.js":           "console.log(1);\n//# sourceMappingURL=app.js.map",
+		"/js/app.js.map":       `{"version":3,"sources":["src/app.js"],"sourcesContent":["import hero from '/img/only-in-map.png';\nconst icon = '../fonts/icons.woff2';"]}`,
+		"/img/only-in-map.png": "png",
+		"/fonts/icons.woff2":   "font",
+	})
+	page := `<html><head><script src="` + server.URL + `/js/app.js"></script></head><body></body></html>`
+
+	if _, err := LocalizeAssets(page, base, Options{Concurrency: 2, ParseSourceMaps: true}); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	for _, path := range []string{"output/assets/images/only-in-map.png", "output/assets/fonts/icons.woff2"} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("asset referenced only via the source map should be downloaded to %s: %v", path, err)
+		}
+	}
+	js, _ := os.ReadFile("output/assets/app.js")
+	if strings.Contains(string(js), "sourceMappingURL") {
+		t.Error("source map reference should still be stripped from the saved script")
+	}
+}
@@ -0,0 +1,311 @@
+package assets
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// srcsetCandidate is one image candidate from a srcset attribute
+type srcsetCandidate struct {
+	URL        string
+	Descriptor string // e.g. "2x" or "300w"; empty when omitted
+}
+
+// String renders the candidate back into srcset syntax
+func (c srcsetCandidate) String() string {
+	if c.Descriptor == "" {
+		return c.URL
+	}
+	return c.URL + " " + c.Descriptor
+}
+
+// rewriteSrcset applies replace to each candidate URL of a srcset, keeping the descriptors, and
+// reports whether any candidate changed
+func rewriteSrcset(srcset string, replace func(ref string) (string, bool)) (string, bool) {
+	candidates := parseSrcset(srcset)
+	rewritten := false
+	for i, candidate := range candidates {
+		if target, ok := replace(candidate.URL); ok {
+			candidates[i].URL = target
+			rewritten = true
+		}
+	}
+	if !rewritten {
+		return srcset, false
+	}
+	parts := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		parts[i] = candidate.String()
+	}
+	return strings.Join(parts, ", "), true
+}
+
+// parseSrcset splits a srcset attribute into candidates following the HTML parsing rules:
+// a URL runs until whitespace, so commas inside data: URIs are kept as part of the URL
+func parseSrcset(srcset string) []srcsetCandidate {
+	var candidates []srcsetCandidate
+	isSpace := func(c byte) bool {
+		return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+	}
+
+	i := 0
+	for i < len(srcset) {
+		// Skip separators between candidates
+		for i < len(srcset) && (isSpace(srcset[i]) || srcset[i] == ',') {
+			i++
+		}
+		if i >= len(srcset) {
+			break
+		}
+
+		start := i
+		for i < len(srcset) && !isSpace(srcset[i]) {
+			i++
+		}
+		rawURL := srcset[start:i]
+
+		// A URL ending in commas has no descriptors
+		if strings.HasSuffix(rawURL, ",") {
+			candidates = append(candidates, srcsetCandidate{URL: strings.TrimRight(rawURL, ",")})
+			continue
+		}
+
+		// Descriptors run until the next comma outside parentheses
+		start = i
+		depth := 0
+		for i < len(srcset) {
+			if srcset[i] == '(' {
+				depth++
+			} else if srcset[i] == ')' && depth > 0 {
+				depth--
+			} else if srcset[i] == ',' && depth == 0 {
+				break
+			}
+			i++
+		}
+		descriptor := strings.Join(strings.Fields(srcset[start:i]), " ")
+		candidates = append(candidates, srcsetCandidate{URL: rawURL, Descriptor: descriptor})
+	}
+
+	return candidates
+}
+
+// SrcsetPolicy chooses a single candidate when a srcset is reduced to one image
+type SrcsetPolicy struct {
+	TargetWidth int     // Layout width of the image in CSS pixels
+	DPR         float64 // Device pixel ratio to serve (0 means 1)
+	MaxWidth    int     // Never pick a candidate wider than this many pixels when possible (0 means no cap)
+}
+
+// effectiveWidth converts a candidate's descriptor into the pixel width it provides at the target
+// layout width: "480w" is 480 pixels, "2x" is twice the target width, and no descriptor means 1x
+func (c srcsetCandidate) effectiveWidth(targetWidth int) (float64, bool) {
+	descriptor := c.Descriptor
+	if descriptor == "" {
+		descriptor = "1x"
+	}
+	// Only the width or density descriptor matters; a height descriptor (e.g. "300h") is ignored
+	for _, field := range strings.Fields(descriptor) {
+		if len(field) < 2 {
+			continue
+		}
+		value, err := strconv.ParseFloat(field[:len(field)-1], 64)
+		if err != nil || value <= 0 {
+			continue
+		}
+		switch field[len(field)-1] {
+		case 'w':
+			return value, true
+		case 'x':
+			return value * float64(targetWidth), true
+		}
+	}
+	return 0, false
+}
+
+// SelectSrcsetCandidate picks the URL of the best candidate in a srcset for the policy: the smallest
+// candidate that covers TargetWidth*DPR pixels, or the largest one when none does. Candidates wider than
+// MaxWidth are skipped unless every candidate is. Width (w) and density (x) descriptors may be mixed.
+func SelectSrcsetCandidate(srcset string, policy SrcsetPolicy) (string, bool) {
+	dpr := policy.DPR
+	if dpr <= 0 {
+		dpr = 1
+	}
+	needed := float64(policy.TargetWidth) * dpr
+
+	type sized struct {
+		url   string
+		width float64
+	}
+	var all, capped []sized
+	for _, candidate := range parseSrcset(srcset) {
+		width, ok := candidate.effectiveWidth(policy.TargetWidth)
+		if !ok {
+			continue
+		}
+		all = append(all, sized{candidate.URL, width})
+		if policy.MaxWidth <= 0 || width <= float64(policy.MaxWidth) {
+			capped = append(capped, sized{candidate.URL, width})
+		}
+	}
+	if len(all) == 0 {
+		return "", false
+	}
+
+	pool := capped
+	if len(pool) == 0 {
+		// Everything exceeds the cap: fall back to the smallest available
+		smallest := all[0]
+		for _, c := range all[1:] {
+			if c.width < smallest.width {
+				smallest = c
+			}
+		}
+		return smallest.url, true
+	}
+
+	var best, largest *sized
+	for i := range pool {
+		c := &pool[i]
+		if c.width >= needed && (best == nil || c.width < best.width) {
+			best = c
+		}
+		if largest == nil || c.width > largest.width {
+			largest = c
+		}
+	}
+	if best == nil {
+		best = largest
+	}
+	return best.url, true
+}
+
+// collapseSrcsets rewrites every <img srcset> to a single src picked by the policy and drops its
+// srcset and sizes, for targets without responsive image support (email clients, strict AMP).
+// An img's own width attribute takes precedence over the policy's TargetWidth. <picture> sources
+// are removed so only the collapsed fallback <img> remains.
+func collapseSrcsets(htmlContent string, policy SrcsetPolicy) (string, error) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", err
+	}
+
+	var removed []*html.Node
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch {
+			case n.Data == "source" && n.Parent != nil && n.Parent.Data == "picture":
+				removed = append(removed, n)
+				return
+			case n.Data == "img" && getAttr(n, "srcset") != "":
+				imgPolicy := policy
+				if width, err := strconv.Atoi(strings.TrimSpace(getAttr(n, "width"))); err == nil && width > 0 {
+					imgPolicy.TargetWidth = width
+				}
+				if src, ok := SelectSrcsetCandidate(getAttr(n, "srcset"), imgPolicy); ok {
+					setAttr(n, "src", src)
+				}
+				removeAttr(n, "srcset")
+				removeAttr(n, "sizes")
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+
+	traverse(doc)
+
+	for _, n := range removed {
+		n.Parent.RemoveChild(n)
+	}
+
+	var buf strings.Builder
+	if err := html.Render(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// alignImageSources points every <img> src and srcset candidate (and <picture> source candidate)
+// that still resolves to a downloaded image at its local copy. Jobs are deduplicated by resolved
+// URL, so when src and a srcset candidate name the same image in different forms (absolute and
+// root-relative) only the first form is replaced by updateHTMLWithLocalPaths; this keeps the
+// others consistent with it. localByURL maps assetKey(URL) to the saved path or data: URI.
+func alignImageSources(htmlContent string, base *url.URL, localByURL map[string]string, opts Options) (string, error) {
+	if len(localByURL) == 0 {
+		return htmlContent, nil
+	}
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", err
+	}
+
+	localRef := func(ref string) (string, bool) {
+		resolvedURL, ok := resolveAssetURL(base, ref)
+		if !ok {
+			return "", false
+		}
+		localPath, ok := localByURL[assetKey(canonicalizeURL(resolvedURL, opts.OriginAliases), opts.AssetCase)]
+		if !ok {
+			return "", false
+		}
+		if strings.HasPrefix(localPath, "data:") {
+			return localPath, true
+		}
+		return pageRootPrefix(opts.PagePath) + outRelative(localPath, opts.outputDir()), true
+	}
+
+	changed := false
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "img" || (n.Data == "source" && n.Parent != nil && n.Parent.Data == "picture")) {
+			for i, attr := range n.Attr {
+				switch attr.Key {
+				case "src", "data-src":
+					if local, ok := localRef(attr.Val); ok && local != attr.Val {
+						n.Attr[i].Val = local
+						changed = true
+					}
+				case "srcset", "data-srcset":
+					candidates := parseSrcset(attr.Val)
+					rewritten := false
+					for j, candidate := range candidates {
+						if local, ok := localRef(candidate.URL); ok && local != candidate.URL {
+							candidates[j].URL = local
+							rewritten = true
+						}
+					}
+					if rewritten {
+						parts := make([]string, len(candidates))
+						for j, candidate := range candidates {
+							parts[j] = candidate.String()
+						}
+						n.Attr[i].Val = strings.Join(parts, ", ")
+						changed = true
+					}
+				}
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+
+	traverse(doc)
+
+	if !changed {
+		return htmlContent, nil
+	}
+	var buf strings.Builder
+	if err := html.Render(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,124 @@
+package assets
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"wp-static-scraper/utils"
+)
+
+func TestSrcsetPreservesDataCandidates(t *testing.T) {
+	chdirOutput(t)
+
+	dataCandidate := "data:image/svg+xml,%3Csvg xmlns=%22http://www.w3.org/2000/svg%22/%3E"
+	base64Candidate := "data:image/png;base64,iVBORw0KGgo="
+	origin, rec := newRecordingProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("png"))
+	})
+	base, _ := url.Parse(origin.URL + "/")
+
+	inlineOnly := dataCandidate + " 1x, " + base64Candidate + " 2x"
+	localized, err := LocalizeSrcset(inlineOnly, base, utils.DefaultOutDir)
+	if err != nil || localized != inlineOnly {
+		t.Errorf("LocalizeSrcset(%q) = %q, %v; data: candidates should be kept verbatim", inlineOnly, localized, err)
+	}
+
+	page := `<html><body><img srcset="` + base64Candidate + ` 1x, ` + origin.URL + `/img/photo@2x.png 2x"></body></html>`
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 1})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	if !strings.Contains(result, `srcset="`+base64Candidate+` 1x, assets/images/photo@2x.png 2x"`) {
+		t.Errorf("data: candidate should be preserved and http candidate localized, got %s", result)
+	}
+	if got := rec.seen(); len(got) != 1 || got[0] != "/img/photo@2x.png" {
+		t.Errorf("only the http candidate should be fetched, got %v", got)
+	}
+}
+
+func TestSelectSrcsetCandidate(t *testing.T) {
+	srcset := "a.jpg 480w, b.jpg 1x, c.jpg 2x, d.jpg 1200w"
+
+	tests := []struct {
+		name     string
+		policy   SrcsetPolicy
+		expected string
+	}{
+		{"1x display picks exact fit", SrcsetPolicy{TargetWidth: 400, DPR: 1}, "b.jpg"},
+		{"2x display picks density match", SrcsetPolicy{TargetWidth: 400, DPR: 2}, "c.jpg"},
+		{"3x display picks widest", SrcsetPolicy{TargetWidth: 400, DPR: 3}, "d.jpg"},
+		{"cap keeps largest under limit", SrcsetPolicy{TargetWidth: 400, DPR: 2, MaxWidth: 700}, "a.jpg"},
+		{"cap below every candidate picks smallest", SrcsetPolicy{TargetWidth: 400, MaxWidth: 100}, "b.jpg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := SelectSrcsetCandidate(srcset, tt.policy)
+			if !ok || result != tt.expected {
+				t.Errorf("SelectSrcsetCandidate(%q, %+v) = %q, %v; want %q", srcset, tt.policy, result, ok, tt.expected)
+			}
+		})
+	}
+
+	if _, ok := SelectSrcsetCandidate("", SrcsetPolicy{TargetWidth: 400}); ok {
+		t.Error("empty srcset should have no candidate")
+	}
+}
+
+func TestRewriteSrcsetToSingleSrc(t *testing.T) {
+	_, base := newTestSite(t, map[string]string{
+		"/img/small.jpg":  "small",
+		"/img/medium.jpg": "medium",
+		"/img/large.jpg":  "large",
+		"/img/hero.webp":  "webp",
+	})
+	page := `<html><body>` +
+		`<img src="/img/small.jpg" srcset="/img/small.jpg 300w, /img/medium.jpg 600w, /img/large.jpg 1200w" sizes="100vw" alt="A">` +
+		`<img width="250" src="/img/large.jpg" srcset="/img/small.jpg 300w, /img/large.jpg 1200w" alt="B">` +
+		`<picture><source srcset="/img/hero.webp" type="image/webp"><img src="/img/large.jpg" alt="C"></picture>` +
+		`</body></html>`
+
+	opts := Options{Concurrency: 2, SingleSrc: &SrcsetPolicy{TargetWidth: 600, DPR: 1}}
+	result, err := LocalizeAssets(page, base, opts)
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	for _, want := range []string{
+		`<img src="assets/images/medium.jpg" alt="A"/>`,
+		`<img width="250" src="assets/images/small.jpg" alt="B"/>`,
+		`<picture><img src="assets/images/large.jpg" alt="C"/></picture>`,
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %s in output: %s", want, result)
+		}
+	}
+	if strings.Contains(result, "srcset") || strings.Contains(result, "sizes") {
+		t.Errorf("srcset and sizes should be removed: %s", result)
+	}
+}
+
+func TestSrcAndSrcsetShareLocalPath(t *testing.T) {
+	server, base := newTestSite(t, map[string]string{
+		"/img/x-300.jpg": "small",
+		"/img/x-600.jpg": "large",
+	})
+	page := `<html><body>` +
+		`<img src="` + server.URL + `/img/x-600.jpg" srcset="/img/x-300.jpg 300w, /img/x-600.jpg 600w">` +
+		`<img src="/img/x-600.jpg" srcset="/img/x-300.jpg 300w, /img/x-600.jpg 600w">` +
+		`</body></html>`
+
+	html, err := LocalizeAssets(page, base, Options{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	want := `<img src="assets/images/x-600.jpg" srcset="assets/images/x-300.jpg 300w, assets/images/x-600.jpg 600w"/>`
+	if strings.Count(html, want) != 2 {
+		t.Errorf("src and matching srcset candidates should share one local path, got: %s", html)
+	}
+	if strings.Contains(html, "/img/x-600.jpg") {
+		t.Errorf("no reference to the shared image should stay remote: %s", html)
+	}
+}
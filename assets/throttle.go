@@ -0,0 +1,155 @@
+package assets
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// BackoffConfig tunes the adaptive throttle that lowers concurrency while a server is struggling
+type BackoffConfig struct {
+	ErrorRate float64 // Rolling 429/5xx rate (0-1) at which effective concurrency is halved
+	Window    int     // Number of recent responses the error rate is computed over
+}
+
+// DefaultBackoffConfig backs off once half of the last 20 responses were errors
+var DefaultBackoffConfig = BackoffConfig{ErrorRate: 0.5, Window: 20}
+
+// ConcurrencySample is the effective concurrency after one window of responses
+type ConcurrencySample struct {
+	ElapsedSeconds float64 `json:"elapsed_seconds"` // Time since downloads started
+	Workers        int     `json:"workers"`
+	ErrorRate      float64 `json:"error_rate"` // 429/5xx share of the window that produced this sample
+	Throttled      bool    `json:"throttled"`  // The limit was lowered at this sample
+}
+
+// ConcurrencyStats summarizes how the adaptive throttle varied effective concurrency over a run
+type ConcurrencyStats struct {
+	Min      int                 `json:"min"`
+	Max      int                 `json:"max"`
+	Avg      float64             `json:"avg"`      // Mean over the samples, including the starting limit
+	Backoffs int                 `json:"backoffs"` // Number of times the limit was lowered
+	Samples  []ConcurrencySample `json:"samples"`
+}
+
+// adaptiveThrottle gates request issuance with a resizable semaphore. A full window of responses
+// at or above the error rate halves the limit; a window under half that rate grows it by half
+// again, up to the worker count (multiplicative decrease, fast recovery).
+type adaptiveThrottle struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	cfg      BackoffConfig
+	ceiling  int
+	limit    int
+	inFlight int
+	samples  int
+	failures int
+	started  time.Time
+	history  []ConcurrencySample
+	log      io.Writer // Receives a line each time the limit changes
+}
+
+func newAdaptiveThrottle(workers int, cfg BackoffConfig, log io.Writer) *adaptiveThrottle {
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultBackoffConfig.Window
+	}
+	if cfg.ErrorRate <= 0 || cfg.ErrorRate > 1 {
+		cfg.ErrorRate = DefaultBackoffConfig.ErrorRate
+	}
+	t := &adaptiveThrottle{cfg: cfg, ceiling: workers, limit: workers, started: time.Now(), log: log}
+	t.history = []ConcurrencySample{{Workers: workers}}
+	t.cond = sync.NewCond(&t.mu)
+	return t
+}
+
+// acquire blocks until fewer than the current limit of requests are in flight
+func (t *adaptiveThrottle) acquire() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	for t.inFlight >= t.limit {
+		t.cond.Wait()
+	}
+	t.inFlight++
+	t.mu.Unlock()
+}
+
+// release frees a request slot
+func (t *adaptiveThrottle) release() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.inFlight--
+	t.mu.Unlock()
+	t.cond.Broadcast()
+}
+
+// observe records one response and adjusts the limit once a full window has been seen
+func (t *adaptiveThrottle) observe(failed bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.samples++
+	if failed {
+		t.failures++
+	}
+	if t.samples < t.cfg.Window {
+		return
+	}
+
+	rate := float64(t.failures) / float64(t.samples)
+	previous := t.limit
+	switch {
+	case rate >= t.cfg.ErrorRate && t.limit > 1:
+		t.limit = max(1, t.limit/2)
+	case rate < t.cfg.ErrorRate/2 && t.limit < t.ceiling:
+		t.limit = min(t.ceiling, t.limit+max(1, t.limit/2))
+	}
+	t.samples, t.failures = 0, 0
+	t.history = append(t.history, ConcurrencySample{
+		ElapsedSeconds: time.Since(t.started).Seconds(),
+		Workers:        t.limit,
+		ErrorRate:      rate,
+		Throttled:      t.limit < previous,
+	})
+
+	if t.limit != previous {
+		fmt.Fprintf(t.log, "Backoff: %.0f%% of recent requests failed, concurrency %d -> %d\n", rate*100, previous, t.limit)
+		t.cond.Broadcast()
+	}
+}
+
+// current returns the effective concurrency
+func (t *adaptiveThrottle) current() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.limit
+}
+
+// stats summarizes the recorded samples, or returns nil when backoff is disabled
+func (t *adaptiveThrottle) stats() *ConcurrencyStats {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := &ConcurrencyStats{Min: t.ceiling, Samples: append([]ConcurrencySample(nil), t.history...)}
+	total := 0
+	for _, sample := range t.history {
+		stats.Min = min(stats.Min, sample.Workers)
+		stats.Max = max(stats.Max, sample.Workers)
+		total += sample.Workers
+		if sample.Throttled {
+			stats.Backoffs++
+		}
+	}
+	stats.Avg = float64(total) / float64(len(t.history))
+	return stats
+}
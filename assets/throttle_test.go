@@ -0,0 +1,85 @@
+package assets
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestConcurrencyBackoffOn429(t *testing.T) {
+	chdirOutput(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	downloader := NewConcurrentDownloader(8)
+	downloader.EnableBackoff(BackoffConfig{ErrorRate: 0.5, Window: 4})
+	if got := downloader.EffectiveConcurrency(); got != 8 {
+		t.Fatalf("initial concurrency = %d; want 8", got)
+	}
+	downloader.Start()
+	go func() {
+		for i := 0; i < 8; i++ {
+			downloader.AddJob(DownloadJob{URL: fmt.Sprintf("%s/img/%d.png", server.URL, i), Type: "image"})
+		}
+		downloader.FinishJobs()
+	}()
+	downloader.GetResults()
+
+	if got := downloader.EffectiveConcurrency(); got >= 8 {
+		t.Errorf("a burst of 429s should reduce concurrency, still %d", got)
+	}
+}
+
+func TestConcurrencyBackoffReport(t *testing.T) {
+	_, base := newTestSiteHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	var page strings.Builder
+	page.WriteString("<html><body>")
+	for i := 0; i < 8; i++ {
+		fmt.Fprintf(&page, `<img src="/img/%d.png">`, i)
+	}
+	page.WriteString("</body></html>")
+
+	opts := Options{
+		Concurrency:     8,
+		BackoffOnErrors: true,
+		Backoff:         BackoffConfig{ErrorRate: 0.5, Window: 4},
+		ManifestPath:    "output/manifest.json",
+		ReportPath:      "output/_report.html",
+	}
+	if _, err := LocalizeAssets(page.String(), base, opts); err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+
+	data, err := os.ReadFile("output/manifest.json")
+	if err != nil {
+		t.Fatalf("manifest should be written: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("manifest should be valid JSON: %v", err)
+	}
+	stats := manifest.Concurrency
+	if stats == nil || len(stats.Samples) < 2 {
+		t.Fatalf("manifest should include concurrency samples: %s", data)
+	}
+	if stats.Max != 8 || stats.Min >= 8 || stats.Backoffs == 0 {
+		t.Errorf("throttled run should show a drop from 8 workers, got %+v", stats)
+	}
+
+	report, err := os.ReadFile("output/_report.html")
+	if err != nil {
+		t.Fatalf("report should be written: %v", err)
+	}
+	if !strings.Contains(string(report), "<h2>Concurrency</h2>") || !strings.Contains(string(report), "max 8") {
+		t.Errorf("report should include the concurrency section: %s", report)
+	}
+}
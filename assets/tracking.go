@@ -0,0 +1,66 @@
+package assets
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"wp-static-scraper/utils"
+)
+
+// DefaultTrackingParams are the query parameters stripped by -trim-tracking-params
+var DefaultTrackingParams = []string{"utm_*", "fbclid", "gclid", "dclid", "msclkid", "mc_cid", "mc_eid", "_ga"}
+
+// trackingAttrs are URL-bearing attributes that may still point at remote URLs after localization;
+// srcset attributes are trimmed candidate by candidate
+var trackingAttrs = map[string]bool{
+	"href":     true,
+	"src":      true,
+	"action":   true,
+	"poster":   true,
+	"data-src": true,
+}
+
+// trimTrackingParams strips tracking query parameters from URLs left in the HTML, including each
+// candidate of srcset, data-srcset, and imagesrcset
+func trimTrackingParams(htmlContent string, params []string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", err
+	}
+
+	trim := func(ref string) (string, bool) {
+		trimmed := utils.StripQueryParams(ref, params)
+		return trimmed, trimmed != ref
+	}
+
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for i, attr := range n.Attr {
+				if srcsetAttrs[attr.Key] {
+					if trimmed, ok := rewriteSrcset(attr.Val, trim); ok {
+						n.Attr[i].Val = trimmed
+					}
+					continue
+				}
+				isURLMeta := n.Data == "meta" && attr.Key == "content" &&
+					(strings.HasPrefix(attr.Val, "http://") || strings.HasPrefix(attr.Val, "https://"))
+				if (trackingAttrs[attr.Key] || isURLMeta) && strings.Contains(attr.Val, "?") {
+					n.Attr[i].Val = utils.StripQueryParams(attr.Val, params)
+				}
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+
+	traverse(doc)
+
+	var buf strings.Builder
+	if err := html.Render(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
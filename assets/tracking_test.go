@@ -0,0 +1,45 @@
+package assets
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestTrimTrackingParamsInOutput(t *testing.T) {
+	chdirOutput(t)
+
+	base, _ := url.Parse("http://127.0.0.1:1/")
+	page := `<html><body><a href="https://example.com/shop/?utm_source=x&amp;id=7">Shop</a></body></html>`
+
+	result, err := LocalizeAssets(page, base, Options{Concurrency: 1, TrackingParams: DefaultTrackingParams})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	if strings.Contains(result, "utm_source") {
+		t.Errorf("tracking parameter should be removed, got %s", result)
+	}
+	if !strings.Contains(result, `href="https://example.com/shop/?id=7"`) {
+		t.Errorf("non-tracking parameters should be kept, got %s", result)
+	}
+}
+
+func TestTrimTrackingParamsInSrcset(t *testing.T) {
+	page := `<html><body>` +
+		`<img srcset="https://cdn.example/a.jpg?utm_source=x 1x, https://cdn.example/b.jpg?w=800&amp;fbclid=abc 2x">` +
+		`<img data-srcset="https://cdn.example/c.jpg?gclid=1 480w">` +
+		`</body></html>`
+
+	result, err := trimTrackingParams(page, DefaultTrackingParams)
+	if err != nil {
+		t.Fatalf("trimTrackingParams returned error: %v", err)
+	}
+	for _, want := range []string{
+		`srcset="https://cdn.example/a.jpg 1x, https://cdn.example/b.jpg?w=800 2x"`,
+		`data-srcset="https://cdn.example/c.jpg 480w"`,
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %s in %s", want, result)
+		}
+	}
+}
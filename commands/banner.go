@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"bytes"
+	"html"
+	"net/http"
+	"strings"
+)
+
+// bannerHTML renders the fixed notice shown above served pages
+func bannerHTML(text string) string {
+	return `<div id="wp-static-scraper-banner" style="position:fixed;top:0;left:0;right:0;z-index:2147483647;` +
+		`padding:6px 12px;background:#1d2327;color:#fff;font:13px/1.4 sans-serif;text-align:center">` +
+		html.EscapeString(text) + `</div>`
+}
+
+// bannerMiddleware injects a banner right after the opening <body> tag of every HTML response.
+// The body is rewritten as it streams, so files on disk are never modified.
+func bannerMiddleware(next http.Handler, text, dir string) http.Handler {
+	banner := []byte(bannerHTML(text))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A byte range of the original file would not line up with the rewritten body
+		if file, ok := localFileForPath(dir, r.URL.Path); ok && (strings.HasSuffix(file, ".html") || strings.HasSuffix(file, ".htm")) {
+			r.Header.Del("Range")
+		}
+		bw := &bannerWriter{ResponseWriter: w, banner: banner}
+		next.ServeHTTP(bw, r)
+		bw.finish()
+	})
+}
+
+// bannerWriter passes a response through, inserting the banner after the first <body ...> tag
+// of HTML responses. Bytes that may be the start of a split "<body" are held back until the
+// next write.
+type bannerWriter struct {
+	http.ResponseWriter
+	banner      []byte
+	wroteHeader bool
+	rewrite     bool // HTML response where the banner is still to be inserted
+	inBodyTag   bool // "<body" seen, waiting for its closing ">"
+	pending     []byte
+}
+
+func (bw *bannerWriter) WriteHeader(status int) {
+	if bw.wroteHeader {
+		return
+	}
+	bw.wroteHeader = true
+	if status == http.StatusOK && strings.HasPrefix(bw.Header().Get("Content-Type"), "text/html") {
+		bw.rewrite = true
+		bw.Header().Del("Content-Length")
+	}
+	bw.ResponseWriter.WriteHeader(status)
+}
+
+func (bw *bannerWriter) Write(p []byte) (int, error) {
+	if !bw.wroteHeader {
+		if bw.Header().Get("Content-Type") == "" {
+			bw.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		bw.WriteHeader(http.StatusOK)
+	}
+	if !bw.rewrite {
+		return bw.ResponseWriter.Write(p)
+	}
+
+	data := append(bw.pending, p...)
+	bw.pending = nil
+	var out bytes.Buffer
+	for bw.rewrite && len(data) > 0 {
+		if bw.inBodyTag {
+			end := bytes.IndexByte(data, '>')
+			if end < 0 {
+				out.Write(data)
+				data = nil
+				break
+			}
+			out.Write(data[:end+1])
+			out.Write(bw.banner)
+			data = data[end+1:]
+			bw.inBodyTag = false
+			bw.rewrite = false
+			break
+		}
+
+		idx := indexBodyTag(data)
+		if idx < 0 || idx+len("<body") == len(data) {
+			// Hold back anything that could be the start of a tag split across writes
+			keep := min(len(data), len("<body")-1)
+			if idx >= 0 {
+				keep = len(data) - idx
+			}
+			out.Write(data[:len(data)-keep])
+			bw.pending = append(bw.pending, data[len(data)-keep:]...)
+			data = nil
+			break
+		}
+		next := data[idx+len("<body")]
+		out.Write(data[:idx+len("<body")])
+		data = data[idx+len("<body"):]
+		// <bodyguard> is not a body tag
+		if next == '>' || next == '/' || next == ' ' || next == '\t' || next == '\n' || next == '\r' || next == '\f' {
+			bw.inBodyTag = true
+		}
+	}
+	out.Write(data)
+
+	if _, err := bw.ResponseWriter.Write(out.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// indexBodyTag returns the index of the first "<body" in data, matched case-insensitively, or -1
+func indexBodyTag(data []byte) int {
+	for i := 0; i+len("<body") <= len(data); i++ {
+		if data[i] == '<' && strings.EqualFold(string(data[i+1:i+len("<body")]), "body") {
+			return i
+		}
+	}
+	return -1
+}
+
+// finish writes any bytes still held back once the handler is done
+func (bw *bannerWriter) finish() {
+	if len(bw.pending) > 0 {
+		bw.ResponseWriter.Write(bw.pending)
+		bw.pending = nil
+	}
+}
+
+// Flush lets streaming handlers such as the live-reload event stream pass through
+func (bw *bannerWriter) Flush() {
+	if flusher, ok := bw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestServeBannerInjectedWithoutTouchingDisk(t *testing.T) {
+	chdirOutput(t)
+	page := `<html><head><title>Home</title></head><BODY class="home"><p>Hello</p></BODY></html>`
+	os.WriteFile("output/index.html", []byte(page), 0644)
+	os.WriteFile("output/assets/style.css", []byte("body{color:red}"), 0644)
+
+	server := httptest.NewServer(NewServeHandler(ServeOptions{Banner: "This is a static snapshot from May 4, 2026"}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `<BODY class="home"><div id="wp-static-scraper-banner"`) {
+		t.Errorf("banner should follow the opening body tag: %s", body)
+	}
+	if !strings.Contains(string(body), "This is a static snapshot from May 4, 2026</div><p>Hello</p>") {
+		t.Errorf("banner text missing: %s", body)
+	}
+
+	onDisk, _ := os.ReadFile("output/index.html")
+	if string(onDisk) != page {
+		t.Errorf("file on disk should be unchanged, got %s", onDisk)
+	}
+
+	resp, err = http.Get(server.URL + "/assets/style.css")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	css, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(css) != "body{color:red}" {
+		t.Errorf("non-HTML responses should pass through, got %q", css)
+	}
+}
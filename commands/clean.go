@@ -0,0 +1,28 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"wp-static-scraper/utils"
+)
+
+// CleanCommand removes the output directory without scraping, so state can be reset deliberately
+// (for example before a series of scrape -no-clean runs)
+func CleanCommand() {
+	cleanFlags := flag.NewFlagSet("clean", flag.ExitOnError)
+	dir := cleanFlags.String("dir", utils.DefaultOutDir, "Output directory to remove (the -outdir used when scraping)")
+	cleanFlags.Parse(os.Args[2:])
+
+	if _, err := os.Stat(*dir); os.IsNotExist(err) {
+		fmt.Printf("Nothing to clean: %s/ does not exist.\n", *dir)
+		return
+	}
+
+	if err := utils.RemoveOutput(*dir); err != nil {
+		fmt.Printf("Failed to remove %s/: %v\n", *dir, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Removed %s/\n", *dir)
+}
@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// stringList is a repeatable string flag
+type stringList []string
+
+// String joins the collected values for flag usage output
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+// Set appends one occurrence of the flag
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// flagWasSet reports whether a flag was passed explicitly rather than left at its default
+func flagWasSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// applyConfigFile sets flags from a JSON object keyed by flag name, e.g. {"concurrency": 10,
+// "crawl-hosts": ["blog.example.com"]}. Flags given on the command line win over the file, and
+// unknown keys are rejected so a typo never silently falls back to a default. Arrays are passed
+// to repeatable flags one value at a time and joined with commas for list flags.
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var config map[string]any
+	if err := decoder.Decode(&config); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := fs.Lookup(name)
+		if f == nil || name == "config" {
+			return fmt.Errorf("%s: unknown option %q", path, name)
+		}
+		if flagWasSet(fs, name) {
+			continue
+		}
+		values, err := configValues(config[name])
+		if err != nil {
+			return fmt.Errorf("%s: option %q: %w", path, name, err)
+		}
+		if _, repeatable := f.Value.(*stringList); !repeatable {
+			values = []string{strings.Join(values, ",")}
+		}
+		for _, value := range values {
+			if err := fs.Set(name, value); err != nil {
+				return fmt.Errorf("%s: option %q: %w", path, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// configValues converts a JSON config value into flag strings
+func configValues(value any) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case bool:
+		return []string{fmt.Sprint(v)}, nil
+	case json.Number:
+		return []string{v.String()}, nil
+	case []any:
+		var values []string
+		for _, item := range v {
+			itemValues, err := configValues(item)
+			if err != nil || len(itemValues) != 1 {
+				return nil, fmt.Errorf("list items must be strings, numbers, or booleans")
+			}
+			values = append(values, itemValues...)
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("unsupported value %v", value)
+	}
+}
+
+// envOr returns the environment variable key, or fallback when it is unset or empty
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
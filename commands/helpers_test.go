@@ -0,0 +1,16 @@
+package commands
+
+import (
+	"testing"
+
+	"wp-static-scraper/utils"
+)
+
+// chdirOutput runs the rest of the test in a fresh working directory with the output/ tree created
+func chdirOutput(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	if err := utils.EnsureDirectories(); err != nil {
+		t.Fatalf("Failed to create directories: %v", err)
+	}
+}
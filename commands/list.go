@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"wp-static-scraper/assets"
+)
+
+// ListCommand prints the assets a page references without downloading them
+func ListCommand() {
+	listFlags := flag.NewFlagSet("list", flag.ExitOnError)
+	inputURL := listFlags.String("url", "", "URL of the page to inspect")
+	format := listFlags.String("format", "table", "Output format: table (tab-separated) or json")
+	listFlags.Parse(os.Args[2:])
+
+	if *inputURL == "" {
+		fmt.Println("Please provide a URL with -url flag.")
+		listFlags.Usage()
+		os.Exit(1)
+	}
+
+	if *format != "table" && *format != "json" {
+		fmt.Println("Format must be one of: table, json.")
+		os.Exit(1)
+	}
+
+	base, err := url.Parse(*inputURL)
+	if err != nil {
+		fmt.Printf("Invalid base URL: %v\n", err)
+		os.Exit(1)
+	}
+
+	body, err := assets.FetchPage(*inputURL, assets.Options{})
+	if err != nil {
+		fmt.Printf("Failed to fetch URL: %v\n", err)
+		os.Exit(1)
+	}
+
+	inventory, err := assets.ListAssets(string(body), base)
+	if err != nil {
+		fmt.Printf("Failed to collect assets: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := WriteAssetList(os.Stdout, inventory, *format); err != nil {
+		fmt.Printf("Failed to write asset list: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// WriteAssetList renders an asset inventory as tab-separated lines (type, origin, URL) or as JSON
+func WriteAssetList(w io.Writer, inventory []assets.AssetInfo, format string) error {
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(inventory)
+	}
+
+	for _, asset := range inventory {
+		origin := "cross-origin"
+		if asset.SameOrigin {
+			origin = "same-origin"
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", asset.Type, origin, asset.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"wp-static-scraper/assets"
+)
+
+func TestListAssets(t *testing.T) {
+	base, _ := url.Parse("https://example.com/")
+	page := `<html><head>` +
+		`<link rel="stylesheet" href="https://example.com/style.css">` +
+		`<script src="https://cdn.example.net/app.js"></script>` +
+		`</head><body><img src="/uploads/logo.png"></body></html>`
+
+	inventory, err := assets.ListAssets(page, base)
+	if err != nil {
+		t.Fatalf("ListAssets returned error: %v", err)
+	}
+
+	var out strings.Builder
+	if err := WriteAssetList(&out, inventory, "table"); err != nil {
+		t.Fatalf("WriteAssetList returned error: %v", err)
+	}
+	expected := "css\tsame-origin\thttps://example.com/style.css\n" +
+		"image\tsame-origin\thttps://example.com/uploads/logo.png\n" +
+		"js\tcross-origin\thttps://cdn.example.net/app.js\n"
+	if out.String() != expected {
+		t.Errorf("table output = %q; want %q", out.String(), expected)
+	}
+
+	out.Reset()
+	if err := WriteAssetList(&out, inventory, "json"); err != nil {
+		t.Fatalf("WriteAssetList returned error: %v", err)
+	}
+	var decoded []assets.AssetInfo
+	if err := json.Unmarshal([]byte(out.String()), &decoded); err != nil || len(decoded) != 3 {
+		t.Errorf("JSON output should list 3 assets, got %s (%v)", out.String(), err)
+	}
+}
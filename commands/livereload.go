@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// liveReloadPath is the Server-Sent Events endpoint browsers listen on for reloads
+const liveReloadPath = "/__livereload"
+
+// liveReloadScript reloads the page whenever the server reports a change
+const liveReloadScript = `<script>
+// Live reload injected by wp-static-scraper serve -watch
+new EventSource('` + liveReloadPath + `').onmessage = function() { location.reload(); };
+</script>`
+
+// LiveReloader watches a directory tree and notifies connected browsers of changes
+type LiveReloader struct {
+	watcher *fsnotify.Watcher
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+	done    chan struct{}
+}
+
+// NewLiveReloader starts watching dir and all of its subdirectories
+func NewLiveReloader(dir string) (*LiveReloader, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	// fsnotify is not recursive, so register every directory in the tree
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	lr := &LiveReloader{
+		watcher: watcher,
+		clients: make(map[chan struct{}]struct{}),
+		done:    make(chan struct{}),
+	}
+	go lr.watch()
+	return lr, nil
+}
+
+// watch forwards filesystem events to all connected clients
+func (lr *LiveReloader) watch() {
+	for {
+		select {
+		case event, ok := <-lr.watcher.Events:
+			if !ok {
+				return
+			}
+			// Pick up directories created after startup
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					lr.watcher.Add(event.Name)
+				}
+			}
+			lr.broadcast()
+		case _, ok := <-lr.watcher.Errors:
+			if !ok {
+				return
+			}
+		case <-lr.done:
+			return
+		}
+	}
+}
+
+// broadcast signals every client without blocking on slow ones
+func (lr *LiveReloader) broadcast() {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for client := range lr.clients {
+		select {
+		case client <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// ServeHTTP streams reload events to a browser using Server-Sent Events
+func (lr *LiveReloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	client := make(chan struct{}, 1)
+	lr.mu.Lock()
+	lr.clients[client] = struct{}{}
+	lr.mu.Unlock()
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.clients, client)
+		lr.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-client:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-lr.done:
+			return
+		}
+	}
+}
+
+// ServeHTML serves an HTML file with the live-reload script injected before </body>
+func (lr *LiveReloader) ServeHTML(w http.ResponseWriter, r *http.Request, path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	content := string(data)
+	if idx := strings.LastIndex(strings.ToLower(content), "</body>"); idx != -1 {
+		content = content[:idx] + liveReloadScript + content[idx:]
+	} else {
+		content += liveReloadScript
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, content)
+}
+
+// Close stops watching and disconnects all clients
+func (lr *LiveReloader) Close() error {
+	close(lr.done)
+	return lr.watcher.Close()
+}
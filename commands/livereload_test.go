@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeWatchTriggersReload(t *testing.T) {
+	chdirOutput(t)
+	os.WriteFile("output/index.html", []byte("<html><body><p>hi</p></body></html>"), 0644)
+
+	reloader, err := NewLiveReloader("output")
+	if err != nil {
+		t.Fatalf("NewLiveReloader returned error: %v", err)
+	}
+	defer reloader.Close()
+
+	server := httptest.NewServer(NewServeHandler(ServeOptions{Reloader: reloader}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/")
+	if err != nil {
+		t.Fatalf("Failed to fetch index: %v", err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(page), "EventSource('/__livereload')") {
+		t.Errorf("served HTML should include the live-reload script, got %s", page)
+	}
+
+	events, err := http.Get(server.URL + "/__livereload")
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	defer events.Body.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(events.Body)
+		for scanner.Scan() {
+			if strings.HasPrefix(scanner.Text(), "data:") {
+				received <- scanner.Text()
+				return
+			}
+		}
+	}()
+
+	os.WriteFile("output/assets/style.css", []byte("body{}"), 0644)
+
+	select {
+	case event := <-received:
+		if event != "data: reload" {
+			t.Errorf("unexpected event %q", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("file change did not trigger a reload event")
+	}
+}
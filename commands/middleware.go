@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// fingerprintRe matches filenames carrying a content hash such as app.3f9a2b1c.js or style-5d41402abc.css,
+// or named by one alone like the 3f9a2b1c0d.png files saved by scrape -content-hash-names
+var fingerprintRe = regexp.MustCompile(`(^|[.-])[0-9a-fA-F]{8,}\.[a-zA-Z0-9]+$`)
+
+// cacheHeadersMiddleware sets caching headers so the preview behaves like a real static host.
+// Fingerprinted assets are cached long-term, other assets briefly, and HTML is always revalidated.
+func cacheHeadersMiddleware(next http.Handler, dir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if file, ok := localFileForPath(dir, r.URL.Path); ok {
+			if info, err := os.Stat(file); err == nil && !info.IsDir() {
+				// http.ServeContent honors If-None-Match when the ETag is set up front
+				w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+				w.Header().Set("Cache-Control", cacheControlFor(file))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// basicAuthMiddleware requires HTTP basic auth credentials matching "user:pass" on every request
+// and answers anything else with a 401 challenge
+func basicAuthMiddleware(next http.Handler, credentials string) http.Handler {
+	wantUser, wantPass, _ := strings.Cut(credentials, ":")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		// Compare both halves in constant time so neither leaks through timing
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(wantUser)) == 1
+		passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(wantPass)) == 1
+		if !ok || !userOK || !passOK {
+			w.Header().Set("WWW-Authenticate", `Basic realm="wp-static-scraper preview", charset="UTF-8"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cacheControlFor picks a Cache-Control policy based on the served file
+func cacheControlFor(file string) string {
+	name := filepath.Base(file)
+	switch {
+	case strings.HasSuffix(name, ".html") || strings.HasSuffix(name, ".htm"):
+		return "no-cache"
+	case fingerprintRe.MatchString(name):
+		return "public, max-age=31536000, immutable"
+	default:
+		return "public, max-age=3600"
+	}
+}
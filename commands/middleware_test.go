@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"wp-static-scraper/assets"
+)
+
+func TestServeCacheHeaders(t *testing.T) {
+	chdirOutput(t)
+	os.WriteFile("output/index.html", []byte("<html></html>"), 0644)
+	os.WriteFile("output/assets/style.css", []byte("body{}"), 0644)
+	os.WriteFile("output/assets/app.3f9a2b1c.js", []byte("1"), 0644)
+
+	server := httptest.NewServer(NewServeHandler(ServeOptions{CacheHeaders: true}))
+	defer server.Close()
+
+	tests := []struct {
+		path         string
+		cacheControl string
+	}{
+		{path: "/", cacheControl: "no-cache"},
+		{path: "/assets/style.css", cacheControl: "public, max-age=3600"},
+		{path: "/assets/app.3f9a2b1c.js", cacheControl: "public, max-age=31536000, immutable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := http.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if got := resp.Header.Get("Cache-Control"); got != tt.cacheControl {
+				t.Errorf("Cache-Control = %q; want %q", got, tt.cacheControl)
+			}
+			if resp.Header.Get("ETag") == "" || resp.Header.Get("Last-Modified") == "" {
+				t.Error("response should carry ETag and Last-Modified headers")
+			}
+
+			// A matching ETag should revalidate without resending the body
+			req, _ := http.NewRequest(http.MethodGet, server.URL+tt.path, nil)
+			req.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+			cached, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("conditional request failed: %v", err)
+			}
+			cached.Body.Close()
+			if cached.StatusCode != http.StatusNotModified {
+				t.Errorf("conditional request status = %d; want 304", cached.StatusCode)
+			}
+		})
+	}
+}
+
+func TestServeCacheHeadersForContentHashNames(t *testing.T) {
+	chdirOutput(t)
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("logo"))
+	}))
+	defer origin.Close()
+	base, _ := url.Parse(origin.URL + "/")
+
+	page := `<html><body><img src="/img/logo.png"></body></html>`
+	localized, err := assets.LocalizeAssets(page, base, assets.Options{Concurrency: 1, HashNames: true})
+	if err != nil {
+		t.Fatalf("LocalizeAssets returned error: %v", err)
+	}
+	match := regexp.MustCompile(`src="(assets/images/[0-9a-f]{10}\.png)"`).FindStringSubmatch(localized)
+	if match == nil {
+		t.Fatalf("image should be saved under a content-hashed name: %s", localized)
+	}
+
+	server := httptest.NewServer(NewServeHandler(ServeOptions{CacheHeaders: true}))
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/" + match[1])
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("Cache-Control"); got != "public, max-age=31536000, immutable" {
+		t.Errorf("content-hashed %s should be cached as immutable, got %q", match[1], got)
+	}
+}
+
+func TestServeBasicAuth(t *testing.T) {
+	chdirOutput(t)
+	os.WriteFile("output/index.html", []byte("<html></html>"), 0644)
+
+	server := httptest.NewServer(NewServeHandler(ServeOptions{BasicAuth: "alice:s3cret"}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		user, pass string
+		withAuth   bool
+		status     int
+	}{
+		{name: "no credentials", status: http.StatusUnauthorized},
+		{name: "wrong password", user: "alice", pass: "nope", withAuth: true, status: http.StatusUnauthorized},
+		{name: "correct credentials", user: "alice", pass: "s3cret", withAuth: true, status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, server.URL+"/", nil)
+			if tt.withAuth {
+				req.SetBasicAuth(tt.user, tt.pass)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d; want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status == http.StatusUnauthorized && !strings.HasPrefix(resp.Header.Get("WWW-Authenticate"), "Basic ") {
+				t.Errorf("401 should carry a Basic challenge, got %q", resp.Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
@@ -1,12 +1,16 @@
 package commands
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"wp-static-scraper/assets"
@@ -14,72 +18,708 @@ import (
 	"wp-static-scraper/utils"
 )
 
+// exitCodeTimeout matches timeout(1), so CI can tell a run cut short by -max-runtime from a failure
+const exitCodeTimeout = 124
+
 // ScrapeCommand handles the scraping workflow
 func ScrapeCommand() {
 	startTime := time.Now()
 	
 	scrapeFlags := flag.NewFlagSet("scrape", flag.ExitOnError)
+	configFile := scrapeFlags.String("config", "", "Load scrape options from a JSON file keyed by flag name; command-line flags override it")
 	inputURL := scrapeFlags.String("url", "", "URL of the website to scrape")
 	outputFile := scrapeFlags.String("out", "index.html", "Output HTML file")
+	outDirFlag := scrapeFlags.String("outdir", utils.DefaultOutDir, "Directory the page and its assets are written to")
+	hashNames := scrapeFlags.Bool("content-hash-names", false, "Name each saved asset by a 10-character SHA-256 hash of its content plus its extension, storing identical files once")
+	preservePaths := scrapeFlags.Bool("preserve-paths", false, "Mirror each asset's URL path under assets/ (e.g. assets/wp-content/plugins/foo/style.css) so files sharing a name don't overwrite each other")
 	concurrency := scrapeFlags.Int("concurrency", 100, "Number of concurrent downloads (1-100)")
+	outputToS3 := scrapeFlags.String("output-to-s3", "", "Upload the output to this S3-compatible bucket, given as bucket or bucket/prefix")
+	s3Endpoint := scrapeFlags.String("s3-endpoint", os.Getenv("AWS_ENDPOINT_URL"), "S3-compatible endpoint URL (default: AWS_ENDPOINT_URL, else the AWS endpoint for -s3-region)")
+	s3Region := scrapeFlags.String("s3-region", envOr("AWS_REGION", "us-east-1"), "Region used to sign uploads (default: AWS_REGION, else us-east-1)")
+	s3AccessKey := scrapeFlags.String("s3-access-key", os.Getenv("AWS_ACCESS_KEY_ID"), "Access key for -output-to-s3 (default: AWS_ACCESS_KEY_ID)")
+	s3SecretKey := scrapeFlags.String("s3-secret-key", "", "Secret key for -output-to-s3 (default: AWS_SECRET_ACCESS_KEY)")
+	s3KeepLocal := scrapeFlags.Bool("s3-keep-local", true, "Also write the -outdir directory to disk when uploading (use -s3-keep-local=false to upload only)")
+	fileMode := scrapeFlags.String("file-mode", "0644", "Octal permissions for written files")
+	dirMode := scrapeFlags.String("dir-mode", "0755", "Octal permissions for created directories")
+	backoffOnErrors := scrapeFlags.Bool("concurrency-backoff-on-errors", false, "Lower effective concurrency while 429/5xx responses spike and ramp back up as requests succeed")
+	backoffErrorRate := scrapeFlags.Float64("backoff-error-rate", assets.DefaultBackoffConfig.ErrorRate, "Rolling 429/5xx rate (0-1) that halves concurrency under -concurrency-backoff-on-errors")
+	backoffWindow := scrapeFlags.Int("backoff-window", assets.DefaultBackoffConfig.Window, "Number of recent responses the -backoff-error-rate is measured over")
+	maxRedirects := scrapeFlags.Int("max-redirect-per-asset", assets.DefaultMaxRedirects, "Redirects followed per asset before it fails and stays remote")
+	excludeExt := scrapeFlags.String("exclude-ext", "", "Comma-separated file extensions to leave remote, e.g. .mp4,.zip")
+	extensionLimits := scrapeFlags.String("download-concurrency-per-extension", "", "Comma-separated per-extension download limits, e.g. mp4=2,jpg=10 (others use -concurrency)")
+	parallelWrites := scrapeFlags.Int("parallel-writes-limit", 0, "Maximum concurrent disk writes, independent of -concurrency (0 = unlimited)")
+	rewriteProtocolRelative := scrapeFlags.Bool("rewrite-protocol-relative", false, "Rewrite remaining protocol-relative (//host) references to an explicit scheme")
+	protocolRelativeScheme := scrapeFlags.String("protocol-relative-scheme", "https", "Scheme used by -rewrite-protocol-relative")
+	manifest := scrapeFlags.Bool("manifest", false, "Write manifest.json in the -outdir directory listing every downloaded asset")
+	imageInventory := scrapeFlags.Bool("image-inventory", false, "Write images.json in the -outdir directory listing every downloaded image with its dimensions, size, and format")
+	reportHTML := scrapeFlags.Bool("output-report-html", false, "Write _report.html in the -outdir directory summarizing downloads, failures, and sizes")
+	scrapeHeaders := scrapeFlags.Bool("scrape-headers-to-file", false, "Write _headers.json in the -outdir directory with the status and response headers of every fetched asset")
+	noClean := scrapeFlags.Bool("no-clean", false, "Keep the existing -outdir directory instead of removing it before scraping (see the clean command)")
+	skipExisting := scrapeFlags.Bool("skip-existing", false, "Keep the -outdir directory and reuse assets that were already downloaded")
+	parseSourceMaps := scrapeFlags.Bool("parse-js-sourcemaps-for-assets", false, "Fetch JS source maps to discover images and fonts hidden by minification")
+	concurrentCSS := scrapeFlags.Bool("concurrent-css-rewrite", false, "Download assets referenced by CSS through the shared worker pool and rewrite stylesheets afterwards")
+	cssURLAbsolute := scrapeFlags.Bool("css-url-rewrite-absolute", false, "Rewrite url() references in saved CSS to absolute paths rooted at -css-url-base")
+	cssURLBase := scrapeFlags.String("css-url-base", "/", "Base URL or path the output directory is served from, used by -css-url-rewrite-absolute")
+	fontAutoprefix := scrapeFlags.Bool("css-autoprefix-local-fonts", false, "Rewrite url() references to localized fonts in saved CSS to the absolute -font-url-prefix")
+	fontURLPrefix := scrapeFlags.String("font-url-prefix", "/assets/fonts/", "Absolute path or URL the fonts directory is served from, used by -css-autoprefix-local-fonts")
+	dedupe := scrapeFlags.Bool("dedupe", false, "Collapse downloads with identical content onto one file")
+	dedupeReport := scrapeFlags.Bool("dedupe-report", false, "Report downloads with identical content and the bytes -dedupe saves, without collapsing them unless -dedupe is set")
+	largestAssets := scrapeFlags.Int("report-largest-assets", 0, "Print the N largest downloaded assets by size and list them in the manifest (0 = off)")
+	verboseSkips := scrapeFlags.Bool("verbose-skip-reasons", false, "Print every asset left remote with a reason code (admin-bar, over-budget, dead-host, http-status, ...)")
+	dropFailedRefs := scrapeFlags.Bool("drop-failed-references", false, "Remove <script>/<link> elements whose asset failed to download and blank other references to failed assets")
+	downloadPrefetch := scrapeFlags.Bool("download-prefetch", false, "Also download the targets of <link rel=\"prefetch\"> hints (they may be large or many)")
+	stripScriptsAll := scrapeFlags.Bool("strip-scripts-all", false, "Remove every <script>, inline event handler, and javascript: URL for a pure HTML+CSS snapshot (interactivity is lost)")
+	stripAdminBar := scrapeFlags.Bool("strip-admin-bar", true, "Remove the WordPress admin bar and its assets from logged-in scrapes (use -strip-admin-bar=false to keep it)")
+	inlineStyleFonts := scrapeFlags.Bool("rewrite-inline-style-fonts", true, "Download and rewrite font url() references in style attributes (use -rewrite-inline-style-fonts=false to leave them remote)")
+	lazyIframes := scrapeFlags.Bool("lazy-iframes", false, "Fetch and localize same-origin iframes deferred via data-src")
+	promoteIframes := scrapeFlags.Bool("promote-iframe-data-src", false, "Copy data-src into src for cross-origin deferred iframes")
+	purgeCSS := scrapeFlags.Bool("purge-css", false, "Remove CSS rules whose class or id selectors match nothing in the scraped pages (may drop rules for classes added by JavaScript)")
+	htmlFragment := scrapeFlags.Bool("html-fragment", false, "Also save each page's <body> inner HTML, without <html>/<head>, as a .fragment.html file for embedding in another CMS")
+	fragmentSelector := scrapeFlags.String("selector", "", "With -html-fragment, save only the first element matching this selector (tag, .class, #id), e.g. article.post")
+	collapseWhitespace := scrapeFlags.Bool("collapse-whitespace-text-nodes", false, "Collapse whitespace runs in text nodes (outside pre, textarea, script, style) to save space")
+	assetCase := scrapeFlags.String("normalize-asset-case", "", "Save asset filenames in one case, lower or upper, and treat URLs differing only in case as one asset (default: keep origin names)")
+	lineEndings := scrapeFlags.String("normalize-line-endings", "", "Normalize line endings of saved HTML, CSS, JS, and JSON: lf or crlf (default: leave as fetched)")
+	validateHTML := scrapeFlags.String("validate-html", "off", "Check the output HTML for malformed markup: off, warn, or strict (exit non-zero)")
+	title := scrapeFlags.String("title", "", "Replace the page <title> (and existing og:title/twitter:title) in the output")
+	metaDescription := scrapeFlags.String("meta-description", "", "Replace or insert the meta description (and existing og:description/twitter:description)")
+	respectRobots := scrapeFlags.Bool("respect-robots-strict", false, "Abort without scraping when robots.txt disallows the -url path")
+	assetBase := scrapeFlags.String("asset-base", "", "Resolve and download assets against this base URL instead of -url (e.g. when fetching by IP or through a proxy)")
+	renderEndpoint := scrapeFlags.String("render-endpoint", "", "Headless-render service URL; the page URL is POSTed as JSON and the returned HTML is scraped")
+	acceptEncoding := scrapeFlags.String("accept-encoding", "", "Set to br to also request and decode Brotli-compressed responses (default: gzip only)")
+	requestIDHeader := scrapeFlags.String("request-id-header", "", "Send a unique request ID under this header (e.g. X-Request-ID) with every request")
+	basicAuth := scrapeFlags.String("basic-auth", "", "HTTP basic auth credentials for sites behind a password, given as user:pass")
+	userAgent := scrapeFlags.String("user-agent", "", "User-Agent header sent with the page fetch and every asset download")
+	pageProxy := scrapeFlags.String("page-proxy", "", "Proxy URL used only for the top-level page fetch")
+	assetProxy := scrapeFlags.String("asset-proxy", "", "Proxy URL used only for asset downloads")
+	singleSrc := scrapeFlags.Bool("rewrite-srcset-to-single-src", false, "Collapse each img srcset to a single src for email/AMP targets (see -srcset-target-width)")
+	srcsetTargetWidth := scrapeFlags.Int("srcset-target-width", 600, "Layout width in CSS pixels used to pick the single src when an img has no width attribute")
+	srcsetDPR := scrapeFlags.Float64("srcset-dpr", 1, "Device pixel ratio used to pick the single src")
+	srcsetMaxWidth := scrapeFlags.Int("srcset-max-width", 0, "Never pick a single src wider than this many pixels when possible (0 = no cap)")
+	localizeState := scrapeFlags.Bool("localize-json-state", false, "Localize image, media, and font URLs inside JSON state blobs assigned by inline scripts")
+	stateVars := scrapeFlags.String("json-state-vars", strings.Join(assets.DefaultStateVars, ","), "Comma-separated state variables searched by -localize-json-state (* wildcard allowed)")
+	trimTracking := scrapeFlags.Bool("trim-tracking-params", false, "Strip tracking query parameters (utm_*, fbclid, gclid, ...) from remaining URLs")
+	trackingParams := scrapeFlags.String("tracking-params", strings.Join(assets.DefaultTrackingParams, ","), "Comma-separated parameters removed by -trim-tracking-params (prefix* allowed)")
+	retryEmpty := scrapeFlags.Bool("retry-on-empty-body", true, "Retry images, media, and files whose 200 response has an empty body instead of saving zero-byte files")
+	failFast := scrapeFlags.Bool("fail-fast", false, "Abort once a CSS, JS, or image download fails after its retries")
+	localizeEnclosures := scrapeFlags.Bool("localize-feed-enclosures", false, "When -url is an RSS/Atom/XML feed, download enclosure and media:content files and point the feed at them")
+	crawlHosts := scrapeFlags.String("crawl-hosts", "", "Comma-separated extra hosts whose page links are followed like the start host's (e.g. blog.example.com,shop.example.com)")
+	paginate := scrapeFlags.Int("paginate", 0, "Follow rel=next pagination and scrape up to this many archive pages into page/N/ under -outdir (0 = single page)")
+	relativeLinks := scrapeFlags.Bool("relative-internal-links", false, "Rewrite links to other pages on the scraped host to relative paths in the output layout (e.g. https://example.com/contact/ to contact/index.html)")
+	depth := scrapeFlags.Int("depth", 0, "Follow same-host <a href> links up to this many clicks from -url, saving each page at its URL path under -outdir (e.g. about/index.html) (0 = single page)")
+	inlineImagesBelow := scrapeFlags.Int64("inline-images-below", 0, "Embed downloaded images smaller than this many bytes as data: URIs (0 = off)")
+	preserveMTime := scrapeFlags.Bool("preserve-mtime", false, "Set each downloaded file's modification time to the origin's Last-Modified header")
+	maxRuntime := scrapeFlags.Duration("max-runtime", 0, "Stop the scrape after this wall-clock time (e.g. 10m), keep the partial output, and exit with code 124 (0 = no limit)")
+	pageRetries := scrapeFlags.Int("fetch-retries-separate-page", 3, "Retry the top-level page fetch this many times after a network error or 429/5xx response")
+	imageQuality := scrapeFlags.Int("image-quality", 0, "Re-encode downloaded JPEGs at this quality (1-100) when it makes them smaller (0 = off)")
+	maxFileSize := scrapeFlags.Int64("max-file-size", 0, "Leave <a download> files larger than this many bytes remote (0 = unlimited)")
+	progressJSON := scrapeFlags.String("progress-json", "", "Stream newline-delimited JSON progress events to this file, or - for stdout (other output then goes to stderr)")
+	quiet := scrapeFlags.Bool("quiet", false, "Don't print the \"Downloaded X/Y assets\" progress line to stderr")
+	maxConcurrencyTotal := scrapeFlags.Int("max-concurrency-total", 0, "Cap simultaneous requests across all pages and their assets (0 = no global cap)")
+	maxTotalBytes := scrapeFlags.Int64("max-total-bytes", 0, "Stop downloading assets after this many bytes (0 = unlimited)")
+	var originAliases stringList
+	var assetRuleSpecs stringList
+	var headerSpecs stringList
+	scrapeFlags.Var(&headerSpecs, "header", "Send an extra request header with the page fetch and every asset download from the site's hosts, \"Name: Value\" (repeatable)")
+	scrapeFlags.Var(&assetRuleSpecs, "asset-rule", "Download the URL in an attribute of matching elements, selector@attr[:type] (repeatable), e.g. div.hero@data-bg")
+	scrapeFlags.Var(&originAliases, "origin-alias", "Treat an origin as an alias of another, old=new (repeatable), e.g. cdn2.example.com=cdn1.example.com")
 	scrapeFlags.Parse(os.Args[2:])
 
+	if *configFile != "" {
+		if err := applyConfigFile(scrapeFlags, *configFile); err != nil {
+			fmt.Printf("Failed to load config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// With -progress-json -, stdout carries only the events; everything printed for people goes
+	// to stderr so the stream stays parseable
+	var human io.Writer = os.Stdout
+	if *progressJSON == "-" {
+		human = os.Stderr
+	}
+
 	if *inputURL == "" {
-		fmt.Println("Please provide a URL with -url flag.")
+		fmt.Fprintln(human, "Please provide a URL with -url flag.")
 		scrapeFlags.Usage()
 		os.Exit(1)
 	}
 
 	// Validate concurrency parameter
 	if *concurrency < 1 || *concurrency > 100 {
-		fmt.Println("Concurrency must be between 1 and 100.")
+		fmt.Fprintln(human, "Concurrency must be between 1 and 100.")
 		os.Exit(1)
 	}
 
-	// Clean up old files before starting new scrape
-	utils.CleanupOldFiles(*outputFile)
+	if *validateHTML != "off" && *validateHTML != "warn" && *validateHTML != "strict" {
+		fmt.Fprintln(human, "Validate HTML must be one of: off, warn, strict.")
+		os.Exit(1)
+	}
 
-	// Ensure output directories exist
-	if err := utils.EnsureDirectories(); err != nil {
-		fmt.Printf("Failed to create directories: %v\n", err)
+	if *lineEndings != "" && *lineEndings != "lf" && *lineEndings != "crlf" {
+		fmt.Fprintln(human, "Normalize line endings must be one of: lf, crlf.")
 		os.Exit(1)
 	}
 
-	resp, err := http.Get(*inputURL)
-	if err != nil {
-		fmt.Printf("Failed to fetch URL: %v\n", err)
+	if *acceptEncoding != "" && *acceptEncoding != "br" {
+		fmt.Fprintln(human, "Accept encoding must be br.")
 		os.Exit(1)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Printf("Failed to read response body: %v\n", err)
+	if *assetCase != assets.AssetCasePreserve && *assetCase != assets.AssetCaseLower && *assetCase != assets.AssetCaseUpper {
+		fmt.Fprintln(human, "Normalize asset case must be one of: lower, upper.")
 		os.Exit(1)
 	}
 
-	base, err := url.Parse(*inputURL)
+	// Cleaned once so every path built from it, and every prefix stripped from those paths, agrees
+	outDir := filepath.ToSlash(filepath.Clean(*outDirFlag))
+	if outDir == "." || outDir == ".." || outDir == filepath.Dir(outDir) {
+		fmt.Fprintln(human, "Output directory must not be the current directory, its parent, or the filesystem root.")
+		os.Exit(1)
+	}
+
+	if *parallelWrites < 0 {
+		fmt.Fprintln(human, "Parallel writes limit cannot be negative.")
+		os.Exit(1)
+	}
+
+	aliases := make(map[string]string)
+	for _, alias := range originAliases {
+		from, to, ok := strings.Cut(alias, "=")
+		if !ok || strings.TrimSpace(from) == "" || strings.TrimSpace(to) == "" {
+			fmt.Fprintf(human, "Invalid origin alias %q, expected old=new.\n", alias)
+			os.Exit(1)
+		}
+		aliases[strings.TrimSpace(from)] = strings.TrimSpace(to)
+	}
+
+	headers := make(http.Header)
+	for _, spec := range headerSpecs {
+		name, value, ok := strings.Cut(spec, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			fmt.Fprintf(human, "Invalid header %q, expected \"Name: Value\".\n", spec)
+			os.Exit(1)
+		}
+		headers.Add(name, strings.TrimSpace(value))
+	}
+	// -user-agent wins over a User-Agent given with -header
+	if *userAgent != "" {
+		headers.Del("User-Agent")
+	}
+
+	var assetRules []assets.AssetRule
+	for _, spec := range assetRuleSpecs {
+		rule, err := assets.ParseAssetRule(spec)
+		if err != nil {
+			fmt.Fprintf(human, "Invalid asset rule: %v\n", err)
+			os.Exit(1)
+		}
+		assetRules = append(assetRules, rule)
+	}
+
+	extLimits := make(map[string]int)
+	for _, entry := range strings.Split(*extensionLimits, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		ext, value, ok := strings.Cut(entry, "=")
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || strings.TrimSpace(ext) == "" || err != nil || limit < 1 {
+			fmt.Fprintf(human, "Invalid per-extension limit %q, expected ext=N with N >= 1.\n", entry)
+			os.Exit(1)
+		}
+		extLimits[strings.TrimSpace(ext)] = limit
+	}
+
+	if *fontAutoprefix && !strings.HasPrefix(*fontURLPrefix, "/") && !strings.Contains(*fontURLPrefix, "://") {
+		fmt.Fprintln(human, "Font URL prefix must be an absolute path or URL, e.g. /assets/fonts/.")
+		os.Exit(1)
+	}
+
+	if *preservePaths && *fontAutoprefix {
+		fmt.Fprintln(human, "-css-autoprefix-local-fonts needs fonts in assets/fonts/ and cannot be combined with -preserve-paths.")
+		os.Exit(1)
+	}
+
+	if *hashNames && *skipExisting {
+		fmt.Fprintln(human, "-skip-existing cannot find content-hashed files before downloading them; drop -content-hash-names or -skip-existing.")
+		os.Exit(1)
+	}
+
+	if *maxRedirects < 1 {
+		fmt.Fprintln(human, "Max redirects per asset must be at least 1.")
+		os.Exit(1)
+	}
+
+	if *backoffErrorRate <= 0 || *backoffErrorRate > 1 || *backoffWindow < 1 {
+		fmt.Fprintln(human, "Backoff error rate must be in (0, 1] and the window at least 1.")
+		os.Exit(1)
+	}
+
+	if *basicAuth != "" && !strings.Contains(*basicAuth, ":") {
+		fmt.Fprintln(human, "Basic auth must be given as user:pass.")
+		os.Exit(1)
+	}
+
+	if *paginate < 0 {
+		fmt.Fprintln(human, "Paginate cannot be negative.")
+		os.Exit(1)
+	}
+
+	if *depth < 0 {
+		fmt.Fprintln(human, "Depth cannot be negative.")
+		os.Exit(1)
+	}
+
+	if *depth > 0 && *paginate > 1 {
+		fmt.Fprintln(human, "Cannot combine -depth with -paginate; a crawl already follows archive page links.")
+		os.Exit(1)
+	}
+
+	if *inlineImagesBelow < 0 {
+		fmt.Fprintln(human, "Inline images threshold cannot be negative.")
+		os.Exit(1)
+	}
+
+	if *srcsetTargetWidth < 1 || *srcsetDPR <= 0 || *srcsetMaxWidth < 0 {
+		fmt.Fprintln(human, "Srcset target width must be at least 1, DPR positive, and max width not negative.")
+		os.Exit(1)
+	}
+
+	if *maxConcurrencyTotal < 0 {
+		fmt.Fprintln(human, "Max concurrency total cannot be negative.")
+		os.Exit(1)
+	}
+
+	if *maxTotalBytes < 0 {
+		fmt.Fprintln(human, "Max total bytes cannot be negative.")
+		os.Exit(1)
+	}
+
+	if *maxFileSize < 0 {
+		fmt.Fprintln(human, "Max file size cannot be negative.")
+		os.Exit(1)
+	}
+
+	if *largestAssets < 0 {
+		fmt.Fprintln(human, "Largest assets count cannot be negative.")
+		os.Exit(1)
+	}
+
+	if *pageRetries < 0 {
+		fmt.Fprintln(human, "Page fetch retries cannot be negative.")
+		os.Exit(1)
+	}
+
+	if *imageQuality < 0 || *imageQuality > 100 {
+		fmt.Fprintln(human, "Image quality must be between 1 and 100 (0 disables recompression).")
+		os.Exit(1)
+	}
+
+	if *maxRuntime < 0 {
+		fmt.Fprintln(human, "Max runtime cannot be negative.")
+		os.Exit(1)
+	}
+
+	if *fragmentSelector != "" && !*htmlFragment {
+		fmt.Fprintln(human, "-selector requires -html-fragment.")
+		os.Exit(1)
+	}
+
+	if *stripScriptsAll {
+		fmt.Fprintln(human, "WARNING: -strip-scripts-all removes all JavaScript; menus, sliders, forms, and other interactive features will not work in the snapshot.")
+	}
+
+	fileModeValue, err := utils.ParseFileMode(*fileMode)
 	if err != nil {
-		fmt.Printf("Invalid base URL: %v\n", err)
+		fmt.Fprintf(human, "Invalid -file-mode: %v\n", err)
+		os.Exit(1)
+	}
+	dirModeValue, err := utils.ParseFileMode(*dirMode)
+	if err != nil {
+		fmt.Fprintf(human, "Invalid -dir-mode: %v\n", err)
+		os.Exit(1)
+	}
+	output := utils.NewOutput(fileModeValue, dirModeValue)
+
+	if *outputToS3 != "" {
+		bucket, prefix, _ := strings.Cut(strings.TrimPrefix(*outputToS3, "s3://"), "/")
+		secretKey := *s3SecretKey
+		if secretKey == "" {
+			secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		}
+		if bucket == "" || *s3AccessKey == "" || secretKey == "" {
+			fmt.Fprintln(human, "-output-to-s3 needs a bucket and credentials (-s3-access-key/-s3-secret-key or AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY).")
+			os.Exit(1)
+		}
+		endpoint := *s3Endpoint
+		if endpoint == "" {
+			endpoint = "https://s3." + *s3Region + ".amazonaws.com"
+		}
+		output.SetUploader(&utils.S3Uploader{
+			Endpoint:     endpoint,
+			Bucket:       bucket,
+			Prefix:       prefix,
+			Region:       *s3Region,
+			AccessKey:    *s3AccessKey,
+			SecretKey:    secretKey,
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		}, *s3KeepLocal, outDir)
+		fmt.Fprintf(human, "Uploading output to s3://%s/%s via %s\n", bucket, prefix, endpoint)
+		// Reused files would have to be on disk, and would never be uploaded
+		if output.UploadOnly() && *skipExisting {
+			fmt.Fprintln(human, "-skip-existing reuses files on disk, so it cannot be combined with -s3-keep-local=false.")
+			os.Exit(1)
+		}
+	}
+
+	// Clean up old files before starting new scrape, unless reusing them
+	if !*skipExisting && !*noClean {
+		utils.CleanupOldFiles(outDir)
+	}
+
+	// Ensure output directories exist
+	if err := output.EnsureDirectories(outDir); err != nil {
+		fmt.Fprintf(human, "Failed to create directories: %v\n", err)
 		os.Exit(1)
 	}
 
-	updatedHTML, err := assets.LocalizeAssets(string(body), base, *concurrency)
+	base, err := url.Parse(*inputURL)
 	if err != nil {
-		fmt.Printf("Failed to localize assets: %v\n", err)
+		fmt.Fprintf(human, "Invalid base URL: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Add script to suppress localhost development server errors
-	updatedHTML = html.AddErrorSuppressionScript(updatedHTML)
+	// Asset references may assume another host than the one fetched (an IP, a staging domain)
+	var assetBaseURL *url.URL
+	if *assetBase != "" {
+		assetBaseURL, err = url.Parse(*assetBase)
+		if err != nil || (assetBaseURL.Scheme != "http" && assetBaseURL.Scheme != "https") || assetBaseURL.Host == "" {
+			fmt.Fprintln(human, "Asset base must be an absolute http or https URL.")
+			os.Exit(1)
+		}
+	}
+
+	opts := assets.Options{
+		Concurrency:          *concurrency,
+		MaxTotalBytes:        *maxTotalBytes,
+		MaxFileSize:          *maxFileSize,
+		InlineImagesBelow:    *inlineImagesBelow,
+		ImageQuality:         *imageQuality,
+		PageRetries:          *pageRetries,
+		FailFast:             *failFast,
+		SkipExisting:         *skipExisting,
+		Dedupe:               *dedupe,
+		DedupeReport:         *dedupeReport,
+		WriteLimit:           *parallelWrites,
+		RetryEmpty:           *retryEmpty,
+		PreserveMTime:        *preserveMTime,
+		BackoffOnErrors:      *backoffOnErrors,
+		Backoff:              assets.BackoffConfig{ErrorRate: *backoffErrorRate, Window: *backoffWindow},
+		ParseSourceMaps:      *parseSourceMaps,
+		ConcurrentCSSRewrite: *concurrentCSS,
+		StripAdminBar:        *stripAdminBar,
+		StripScripts:         *stripScriptsAll,
+		Prefetch:             *downloadPrefetch,
+		DropFailedRefs:       *dropFailedRefs,
+		InlineStyleFonts:     *inlineStyleFonts,
+		VerboseSkips:         *verboseSkips,
+		LargestAssets:        *largestAssets,
+		LazyIframes:          *lazyIframes,
+		PromoteIframeDataSrc: *promoteIframes,
+		RenderEndpoint:       *renderEndpoint,
+		RequestIDHeader:      *requestIDHeader,
+		BasicAuth:            *basicAuth,
+		SiteHosts:            []string{base.Host},
+		UserAgent:            *userAgent,
+		Headers:              headers,
+		AcceptEncoding:       *acceptEncoding,
+		PageProxy:            *pageProxy,
+		LineEndings:          *lineEndings,
+		AssetCase:            *assetCase,
+		OriginAliases:        aliases,
+		AssetRules:           assetRules,
+		ExtensionLimits:      extLimits,
+		MaxRedirects:         *maxRedirects,
+		AssetProxy:           *assetProxy,
+		OutDir:               outDir,
+		Output:               output,
+		PreservePaths:        *preservePaths,
+		HashNames:            *hashNames,
+		Log:                  human,
+	}
+	if *manifest {
+		opts.ManifestPath = outDir + "/manifest.json"
+	}
+	if *scrapeHeaders {
+		opts.HeadersPath = outDir + "/_headers.json"
+	}
+	if *imageInventory {
+		opts.ImageInventoryPath = outDir + "/images.json"
+	}
+	if *reportHTML {
+		opts.ReportPath = outDir + "/_report.html"
+	}
+	if *progressJSON == "-" {
+		opts.ProgressJSON = os.Stdout
+	} else if *progressJSON != "" {
+		progressFile, err := output.Create(*progressJSON)
+		if err != nil {
+			fmt.Fprintf(human, "Failed to create progress file: %v\n", err)
+			os.Exit(1)
+		}
+		defer progressFile.Close()
+		opts.ProgressJSON = progressFile
+	}
+	if !*quiet {
+		opts.Progress = os.Stderr
+	}
+	if assetBaseURL != nil {
+		opts.SiteHosts = append(opts.SiteHosts, assetBaseURL.Host)
+	}
+	if *singleSrc {
+		opts.SingleSrc = &assets.SrcsetPolicy{TargetWidth: *srcsetTargetWidth, DPR: *srcsetDPR, MaxWidth: *srcsetMaxWidth}
+	}
+	if *maxConcurrencyTotal > 0 {
+		opts.RequestLimiter = assets.NewRequestLimiter(*maxConcurrencyTotal)
+	}
+	if *maxRuntime > 0 {
+		opts.Deadline = startTime.Add(*maxRuntime)
+	}
+	for _, host := range strings.Split(*crawlHosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			opts.CrawlHosts = append(opts.CrawlHosts, host)
+		}
+	}
+	for _, ext := range strings.Split(*excludeExt, ",") {
+		if ext = strings.TrimSpace(ext); ext != "" {
+			opts.ExcludeExtensions = append(opts.ExcludeExtensions, ext)
+		}
+	}
+	if *localizeState {
+		for _, name := range strings.Split(*stateVars, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				opts.StateVars = append(opts.StateVars, name)
+			}
+		}
+	}
+	if *trimTracking {
+		for _, param := range strings.Split(*trackingParams, ",") {
+			if param = strings.TrimSpace(param); param != "" {
+				opts.TrackingParams = append(opts.TrackingParams, param)
+			}
+		}
+	}
+	if *cssURLAbsolute {
+		opts.CSSURLBase = *cssURLBase
+	}
+	if *fontAutoprefix {
+		opts.FontURLPrefix = *fontURLPrefix
+	}
+	if *rewriteProtocolRelative {
+		opts.ProtocolRelativeScheme = *protocolRelativeScheme
+	}
+
+	// Refuse at the entry point rather than scraping a page the site asks robots to avoid
+	if *respectRobots {
+		allowed, err := assets.RobotsAllowed(*inputURL, opts)
+		if err != nil {
+			fmt.Fprintf(human, "Could not check robots.txt, aborting (-respect-robots-strict): %v\n", err)
+			os.Exit(1)
+		}
+		if !allowed {
+			fmt.Fprintf(human, "robots.txt disallows %s for %s; aborting (-respect-robots-strict).\n", *inputURL, assets.RobotsUserAgent)
+			os.Exit(1)
+		}
+	}
 
-	err = os.WriteFile("output/"+*outputFile, []byte(updatedHTML), 0644)
+	body, contentType, err := assets.FetchDocument(*inputURL, opts)
 	if err != nil {
-		fmt.Printf("Failed to write output file: %v\n", err)
+		fmt.Fprintf(human, "Failed to fetch URL: %v\n", err)
 		os.Exit(1)
 	}
 
+	// Feeds and other XML documents would be mangled by the HTML parser; archive them raw
+	if assets.IsFeedContentType(contentType) {
+		feedFile := *outputFile
+		if !flagWasSet(scrapeFlags, "out") {
+			feedFile = "feed.xml"
+		}
+		feedBase := base
+		if assetBaseURL != nil {
+			feedBase = assetBaseURL
+		}
+		err := assets.SaveFeed(body, feedBase, outDir+"/"+feedFile, *localizeEnclosures, opts)
+		if err != nil && !errors.Is(err, assets.ErrRuntimeExceeded) {
+			fmt.Fprintf(human, "Failed to save feed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(human, "XML document (%s) saved raw to %s/%s\n", contentType, outDir, feedFile)
+		fmt.Fprintf(human, "Total execution time: %.2fs\n", time.Since(startTime).Seconds())
+		if err != nil {
+			fmt.Fprintf(human, "Max runtime of %s exceeded; output is partial.\n", *maxRuntime)
+			os.Exit(exitCodeTimeout)
+		}
+		return
+	}
+
+	pages := []assets.Page{{URL: base, OutPath: *outputFile, Body: body}}
+	if *paginate > 1 {
+		pages = assets.FetchPaginatedPages(pages[0], *paginate, opts)
+	} else if *depth > 0 {
+		pages = assets.CrawlPages(pages[0], *depth, opts)
+	}
+	pageLinks := assets.PageLinkMap(pages)
+
+	// Judge CSS rules against every page before any stylesheet is saved, so integrity hashes,
+	// content-hashed names, and the manifest all describe the purged files
+	if *purgeCSS {
+		bodies := make([]string, 0, len(pages))
+		for _, page := range pages {
+			bodies = append(bodies, string(page.Body))
+		}
+		opts.PurgeCSS, err = assets.NewCSSPurge(bodies)
+		if err != nil {
+			fmt.Fprintf(human, "Failed to purge unused CSS: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var validationFailed, timedOut bool
+	for i, page := range pages {
+		if timedOut {
+			break
+		}
+		pageOpts := opts
+		pageOpts.PagePath = page.OutPath
+		if i > 0 {
+			// Manifests and reports describe the first page only
+			pageOpts.ManifestPath = ""
+			pageOpts.HeadersPath = ""
+			pageOpts.ReportPath = ""
+			pageOpts.ImageInventoryPath = ""
+		}
+
+		// Resolve assets against -asset-base; later pages keep their own path on its host
+		pageBase := page.URL
+		if assetBaseURL != nil {
+			if i == 0 {
+				pageBase = assetBaseURL
+			} else {
+				rebased := *page.URL
+				rebased.Scheme, rebased.Host = assetBaseURL.Scheme, assetBaseURL.Host
+				pageBase = &rebased
+			}
+		}
+
+		// Past the deadline the partially localized page is still saved before stopping
+		updatedHTML, err := assets.LocalizeAssets(string(page.Body), pageBase, pageOpts)
+		if errors.Is(err, assets.ErrRuntimeExceeded) {
+			timedOut = true
+		} else if err != nil {
+			fmt.Fprintf(human, "Failed to localize assets: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Link paginated or crawled pages to each other's local copies
+		if len(pages) > 1 {
+			updatedHTML, err = assets.RewritePageLinks(updatedHTML, page.URL, page.OutPath, pageLinks)
+			if err != nil {
+				fmt.Fprintf(human, "Failed to rewrite page links: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		// Keep navigation to the rest of the site inside the snapshot
+		if *relativeLinks {
+			updatedHTML, err = assets.RewriteInternalLinks(updatedHTML, page.URL, page.OutPath)
+			if err != nil {
+				fmt.Fprintf(human, "Failed to rewrite internal links: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		// Apply user-supplied metadata for the re-hosted copy
+		updatedHTML, err = html.ApplyMetadataOverrides(updatedHTML, html.MetadataOverrides{
+			Title:           *title,
+			MetaDescription: *metaDescription,
+		})
+		if err != nil {
+			fmt.Fprintf(human, "Failed to apply metadata overrides: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Conservative size reduction that leaves the document structure alone
+		if *collapseWhitespace {
+			updatedHTML, err = html.CollapseWhitespace(updatedHTML)
+			if err != nil {
+				fmt.Fprintf(human, "Failed to collapse whitespace: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		// Content for another CMS, taken before the page-level error suppression script is added
+		var fragment string
+		if *htmlFragment {
+			fragment, err = assets.ExtractFragment(updatedHTML, *fragmentSelector)
+			if err != nil {
+				fmt.Fprintf(human, "Failed to extract HTML fragment from %s/%s: %v\n", outDir, page.OutPath, err)
+				os.Exit(1)
+			}
+		}
+
+		// Add script to suppress localhost development server errors, unless the snapshot must stay script-free
+		if !*stripScriptsAll {
+			updatedHTML = html.AddErrorSuppressionScript(updatedHTML)
+		}
+
+		outPath := filepath.Join(outDir, filepath.FromSlash(page.OutPath))
+		if err := output.MkdirAll(filepath.Dir(outPath)); err != nil {
+			fmt.Fprintf(human, "Failed to create page directory: %v\n", err)
+			os.Exit(1)
+		}
+		err = output.WriteFile(outPath, utils.NormalizeLineEndings([]byte(updatedHTML), *lineEndings))
+		if err != nil {
+			fmt.Fprintf(human, "Failed to write output file: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(human, "Static HTML with local assets saved to %s/%s\n", outDir, page.OutPath)
+		if *htmlFragment {
+			fragmentPath := assets.FragmentPath(page.OutPath)
+			err = output.WriteFile(filepath.Join(outDir, filepath.FromSlash(fragmentPath)), utils.NormalizeLineEndings([]byte(fragment), *lineEndings))
+			if err != nil {
+				fmt.Fprintf(human, "Failed to write HTML fragment: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintf(human, "HTML fragment saved to %s/%s\n", outDir, fragmentPath)
+		}
+
+		// Guard against markup damaged by the rewriting passes
+		if *validateHTML != "off" {
+			issues := html.ValidateHTML(updatedHTML)
+			for _, issue := range issues {
+				fmt.Fprintf(human, "HTML VALIDATION: %s: %s\n", page.OutPath, issue)
+			}
+			if len(issues) > 0 {
+				validationFailed = true
+			}
+		}
+	}
+
+	if opts.PurgeCSS != nil {
+		fmt.Fprintf(human, "Removed %d unused CSS rules\n", opts.PurgeCSS.Removed())
+	}
+
 	totalTime := time.Since(startTime)
-	fmt.Printf("Static HTML with local assets saved to output/%s\n", *outputFile)
-	fmt.Printf("Total execution time: %.2fs\n", totalTime.Seconds())
+	fmt.Fprintf(human, "Total execution time: %.2fs\n", totalTime.Seconds())
+
+	if timedOut {
+		fmt.Fprintf(human, "Max runtime of %s exceeded; output is partial.\n", *maxRuntime)
+		os.Exit(exitCodeTimeout)
+	}
+
+	if validationFailed && *validateHTML == "strict" {
+		fmt.Fprintln(human, "Output HTML failed validation.")
+		os.Exit(1)
+	}
 }
\ No newline at end of file
@@ -4,45 +4,217 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
 	"strconv"
+	"strings"
+
+	"wp-static-scraper/utils"
 )
 
+// ServeOptions configures the HTTP handler that serves scraped content
+type ServeOptions struct {
+	Reloader     *LiveReloader // Injects a live-reload script and pushes change events when set
+	CacheHeaders bool          // Adds Cache-Control and ETag headers like a production static host
+	BasicAuth    string        // Requires these "user:pass" HTTP basic auth credentials when set
+	Banner       string        // Shows this notice in a fixed banner at the top of served HTML pages when set
+	Dir          string        // Directory of scraped files (empty means utils.DefaultOutDir)
+}
+
+// outputDir returns the directory the scraped files are served from
+func (o ServeOptions) outputDir() string {
+	if o.Dir == "" {
+		return utils.DefaultOutDir
+	}
+	return o.Dir
+}
+
+// serveRoute maps a URL prefix to a directory of scraped files, relative to the served directory
+type serveRoute struct {
+	prefix string
+	dir    string
+}
+
+var serveRoutes = []serveRoute{
+	// Static assets (CSS, JS, fonts, images)
+	{prefix: "/assets/", dir: "assets"},
+	// Direct /webfonts/ requests (for CSS files that reference absolute webfonts paths)
+	{prefix: "/webfonts/", dir: "assets/fonts"},
+	// Direct /fonts/ requests (for CSS files that reference fonts/ paths)
+	{prefix: "/fonts/", dir: "assets/fonts"},
+	// Direct /images/ requests for downloaded images
+	{prefix: "/images/", dir: "assets/images"},
+}
+
+// autoPortAttempts is how many ports after a busy one -port-auto tries before asking the OS for any free port
+const autoPortAttempts = 10
+
+// ListenPort binds the serve port. With auto set, a busy port falls back to the next few ports and
+// then to an OS-assigned one; check the listener's address for the port actually used.
+func ListenPort(port int, auto bool) (net.Listener, error) {
+	ln, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err == nil || !auto {
+		return ln, err
+	}
+	for next := port + 1; next <= port+autoPortAttempts && next <= 65535; next++ {
+		if ln, nextErr := net.Listen("tcp", ":"+strconv.Itoa(next)); nextErr == nil {
+			return ln, nil
+		}
+	}
+	return net.Listen("tcp", ":0")
+}
+
 // ServeCommand starts an HTTP server to serve scraped content
 func ServeCommand() {
 	serveFlags := flag.NewFlagSet("serve", flag.ExitOnError)
 	port := serveFlags.Int("port", 8080, "Port for HTTP server")
+	dir := serveFlags.String("dir", utils.DefaultOutDir, "Directory to serve (the -outdir used when scraping)")
+	watch := serveFlags.Bool("watch", false, "Reload the browser when files in the served directory change")
+	cacheHeaders := serveFlags.Bool("http-cache-headers", false, "Send Cache-Control, ETag, and Last-Modified headers")
+	serveAuth := serveFlags.String("serve-auth", "", "Require HTTP basic auth credentials, given as user:pass")
+	portAuto := serveFlags.Bool("port-auto", false, "Fall back to a free port when -port is already in use")
+	serveBanner := serveFlags.Bool("serve-banner", false, "Show a \"static snapshot\" banner with the scrape date on served pages")
 	serveFlags.Parse(os.Args[2:])
 
+	if *serveAuth != "" && !strings.Contains(*serveAuth, ":") {
+		fmt.Println("Serve auth must be given as user:pass.")
+		os.Exit(1)
+	}
+
 	// Check if output directory and index.html exists
-	if _, err := os.Stat("output/index.html"); os.IsNotExist(err) {
-		fmt.Println("output/index.html not found. Please run 'scrape' command first.")
+	index, err := os.Stat(filepath.Join(*dir, "index.html"))
+	if os.IsNotExist(err) {
+		fmt.Printf("%s/index.html not found. Please run 'scrape' command first.\n", *dir)
 		os.Exit(1)
 	}
 
-	// Set up file server for static assets
-	http.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.Dir("output/assets"))))
+	opts := ServeOptions{CacheHeaders: *cacheHeaders, BasicAuth: *serveAuth, Dir: *dir}
+	if *serveBanner && index != nil {
+		// The page was written at the end of the scrape, so its mtime dates the snapshot
+		opts.Banner = "This is a static snapshot from " + index.ModTime().Format("January 2, 2006")
+	}
+	if *watch {
+		reloader, err := NewLiveReloader(*dir)
+		if err != nil {
+			fmt.Printf("Failed to watch output directory: %v\n", err)
+			os.Exit(1)
+		}
+		defer reloader.Close()
+		opts.Reloader = reloader
+		fmt.Printf("Watching %s/ for changes\n", *dir)
+	}
+
+	ln, err := ListenPort(*port, *portAuto)
+	if err != nil {
+		log.Fatal(err)
+	}
+	actualPort := ln.Addr().(*net.TCPAddr).Port
+	if actualPort != *port {
+		fmt.Printf("Port %d is in use\n", *port)
+	}
+
+	fmt.Printf("Starting server on http://localhost:%d\n", actualPort)
+	fmt.Println("Press Ctrl+C to stop the server")
+	log.Fatal(http.Serve(ln, NewServeHandler(opts)))
+}
 
-	// Handle direct /webfonts/ requests (for CSS files that reference absolute webfonts paths)
-	http.Handle("/webfonts/", http.StripPrefix("/webfonts/", http.FileServer(http.Dir("output/assets/fonts"))))
+// NewServeHandler builds the routing for the scraped output directory
+func NewServeHandler(opts ServeOptions) http.Handler {
+	mux := http.NewServeMux()
+	dir := opts.outputDir()
 
-	// Handle direct /fonts/ requests (for CSS files that reference fonts/ paths)
-	http.Handle("/fonts/", http.StripPrefix("/fonts/", http.FileServer(http.Dir("output/assets/fonts"))))
+	for _, route := range serveRoutes {
+		mux.Handle(route.prefix, http.StripPrefix(route.prefix, versionedFileServer(filepath.Join(dir, route.dir))))
+	}
 
-	// Handle direct /images/ requests for downloaded images
-	http.Handle("/images/", http.StripPrefix("/images/", http.FileServer(http.Dir("output/assets/images"))))
+	// Live reload event stream
+	if opts.Reloader != nil {
+		mux.Handle(liveReloadPath, opts.Reloader)
+	}
 
-	// Serve index.html at root
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/" {
-			http.ServeFile(w, r, "output/index.html")
-		} else {
+	// Serve index.html at root, and every other saved page (page/2/, crawled pages) or file
+	// (feed.xml, frames) from where it was written
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		file, ok := pageFileForPath(dir, r.URL.Path)
+		if !ok {
 			http.NotFound(w, r)
+			return
+		}
+		// Pages reference their assets relative to their directory, so /page/2 must become /page/2/
+		if strings.HasSuffix(file, "index.html") && !strings.HasSuffix(r.URL.Path, "/") && path.Base(r.URL.Path) != "index.html" {
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+			return
 		}
+		if opts.Reloader != nil && (strings.HasSuffix(file, ".html") || strings.HasSuffix(file, ".htm")) {
+			opts.Reloader.ServeHTML(w, r, file)
+			return
+		}
+		http.ServeFile(w, r, file)
 	})
 
-	fmt.Printf("Starting server on http://localhost:%d\n", *port)
-	fmt.Println("Press Ctrl+C to stop the server")
-	log.Fatal(http.ListenAndServe(":"+strconv.Itoa(*port), nil))
-}
\ No newline at end of file
+	var handler http.Handler = mux
+	if opts.Banner != "" {
+		handler = bannerMiddleware(handler, opts.Banner, dir)
+	}
+	if opts.CacheHeaders {
+		handler = cacheHeadersMiddleware(handler, dir)
+	}
+	if opts.BasicAuth != "" {
+		handler = basicAuthMiddleware(handler, opts.BasicAuth)
+	}
+	return handler
+}
+
+// versionedFileServer serves files from dir ignoring version query strings. A real query
+// (/assets/app.js?ver=2) never reaches the file lookup, but one that was percent-encoded into the
+// path (/assets/app.js%3Fver=2), as some rewritten references are, would 404; when no file by that
+// name exists the encoded query is dropped and the base file is served.
+func versionedFileServer(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if base, _, ok := strings.Cut(r.URL.Path, "?"); ok {
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))); err != nil {
+				r2 := new(http.Request)
+				*r2 = *r
+				r2.URL = new(url.URL)
+				*r2.URL = *r.URL
+				r2.URL.Path = base
+				r2.URL.RawPath = ""
+				r = r2
+			}
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// localFileForPath returns the file on disk under dir that a request path is served from
+func localFileForPath(dir, urlPath string) (string, bool) {
+	for _, route := range serveRoutes {
+		if strings.HasPrefix(urlPath, route.prefix) {
+			rel := path.Clean("/" + strings.TrimPrefix(urlPath, route.prefix))
+			return filepath.Join(dir, route.dir, filepath.FromSlash(rel)), true
+		}
+	}
+	return pageFileForPath(dir, urlPath)
+}
+
+// pageFileForPath returns the saved file a request outside the asset routes is served from: the
+// file itself, or the index.html of a page directory (output/index.html for /)
+func pageFileForPath(dir, urlPath string) (string, bool) {
+	file := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+urlPath)))
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", false
+	}
+	if info.IsDir() {
+		file = filepath.Join(file, "index.html")
+		if info, err := os.Stat(file); err != nil || info.IsDir() {
+			return "", false
+		}
+	}
+	return file, true
+}
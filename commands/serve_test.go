@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestListenPortAutoFallback(t *testing.T) {
+	busy, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Failed to occupy a port: %v", err)
+	}
+	defer busy.Close()
+	port := busy.Addr().(*net.TCPAddr).Port
+
+	if ln, err := ListenPort(port, false); err == nil {
+		ln.Close()
+		t.Fatal("expected an error binding a busy port without -port-auto")
+	}
+
+	ln, err := ListenPort(port, true)
+	if err != nil {
+		t.Fatalf("ListenPort with auto returned error: %v", err)
+	}
+	defer ln.Close()
+	if got := ln.Addr().(*net.TCPAddr).Port; got == port || got == 0 {
+		t.Errorf("expected an alternate port, got %d (busy: %d)", got, port)
+	}
+}
+
+func TestServeIgnoresVersionQuery(t *testing.T) {
+	chdirOutput(t)
+	os.WriteFile("output/index.html", []byte("<html></html>"), 0644)
+	os.WriteFile("output/assets/app.js", []byte("console.log('app');"), 0644)
+
+	server := httptest.NewServer(NewServeHandler(ServeOptions{}))
+	defer server.Close()
+
+	for _, path := range []string{"/assets/app.js?ver=2", "/assets/app.js%3Fver=6.4.1", "/assets/app.js"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != "console.log('app');" {
+			t.Errorf("%s: status %d, body %q; want the base file", path, resp.StatusCode, body)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/assets/missing.js%3Fver=2")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing versioned file should 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestServeNestedPages(t *testing.T) {
+	chdirOutput(t)
+	os.MkdirAll("output/page/2", 0755)
+	os.WriteFile("output/index.html", []byte("<html>home</html>"), 0644)
+	os.WriteFile("output/page/2/index.html", []byte("<html>page two</html>"), 0644)
+	os.WriteFile("output/feed.xml", []byte("<rss></rss>"), 0644)
+
+	handler := NewServeHandler(ServeOptions{})
+	for path, want := range map[string]string{
+		"/":         "<html>home</html>",
+		"/page/2/":  "<html>page two</html>",
+		"/feed.xml": "<rss></rss>",
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("%s: status %d, body %q; want %q", path, rec.Code, rec.Body.String(), want)
+		}
+	}
+
+	// Relative asset references only resolve below the page's directory
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/page/2", nil))
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/page/2/" {
+		t.Errorf("/page/2 should redirect to /page/2/, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	for _, path := range []string{"/page/3/", "/missing.html"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s should 404 instead of falling back to index.html, got %d", path, rec.Code)
+		}
+	}
+}
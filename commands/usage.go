@@ -7,18 +7,111 @@ func PrintUsage() {
 	fmt.Println("wp-static-scraper - Web scraper with local server")
 	fmt.Println("")
 	fmt.Println("Usage:")
-	fmt.Println("  wp-static-scraper scrape -url <URL> [-out <filename>]")
-	fmt.Println("  wp-static-scraper serve [-port <port>]")
+	fmt.Println("  wp-static-scraper scrape -url <URL> [-out <filename>] [-outdir <dir>]")
+	fmt.Println("  wp-static-scraper serve [-port <port>] [-watch] [-dir <dir>]")
+	fmt.Println("  wp-static-scraper list -url <URL> [-format table|json]")
+	fmt.Println("  wp-static-scraper clean [-dir <dir>]")
 	fmt.Println("")
 	fmt.Println("Commands:")
 	fmt.Println("  scrape    Download and localize a website")
 	fmt.Println("  serve     Start HTTP server to serve scraped content")
+	fmt.Println("  list      Print the assets a page references without downloading them")
+	fmt.Println("  clean     Remove the output directory (-dir, default: output) without scraping")
 	fmt.Println("")
 	fmt.Println("Scrape options:")
 	fmt.Println("  -url         URL of the website to scrape (required)")
 	fmt.Println("  -out         Output HTML file (default: index.html)")
+	fmt.Println("  -outdir      Directory the page and its assets are written to (default: output)")
+	fmt.Println("  -content-hash-names Name saved assets by a hash of their content (e.g. 3f9a2b1c0d.css)")
+	fmt.Println("  -preserve-paths Mirror asset URL paths under assets/ instead of flattening them by filename")
+	fmt.Println("  -config      Load options from a JSON file keyed by flag name; flags override it")
+	fmt.Println("  -localize-feed-enclosures Download enclosure media when -url is a feed (feeds are always saved raw)")
+	fmt.Println("  -paginate    Follow rel=next pagination up to this many pages into page/N/ under -outdir (default: 0, off)")
+	fmt.Println("  -relative-internal-links Rewrite same-host page links to relative paths in the output layout")
+	fmt.Println("  -depth       Follow same-host links up to this many clicks, saving pages at their URL paths (default: 0, off)")
+	fmt.Println("  -crawl-hosts Extra hosts whose page links are followed, comma-separated (e.g. blog.example.com)")
 	fmt.Println("  -concurrency Number of concurrent downloads (default: 100, range: 1-100)")
+	fmt.Println("  -concurrency-backoff-on-errors Halve concurrency while 429/5xx responses spike, then ramp back up")
+	fmt.Println("  -backoff-error-rate Error rate (0-1) that triggers a backoff (default: 0.5)")
+	fmt.Println("  -backoff-window Number of recent responses the error rate is measured over (default: 20)")
+	fmt.Println("  -max-redirect-per-asset Redirects followed per asset before it is left remote (default: 10)")
+	fmt.Println("  -exclude-ext Comma-separated extensions to leave remote, e.g. .mp4,.zip")
+	fmt.Println("  -download-concurrency-per-extension Per-extension download limits, e.g. mp4=2,jpg=10")
+	fmt.Println("  -output-to-s3 Upload the output to an S3-compatible bucket (bucket or bucket/prefix)")
+	fmt.Println("  -s3-endpoint / -s3-region / -s3-access-key / -s3-secret-key  Upload target and credentials (AWS_* env vars by default)")
+	fmt.Println("  -s3-keep-local Also keep the -outdir directory on disk when uploading (default: true)")
+	fmt.Println("  -file-mode   Octal permissions for written files (default: 0644)")
+	fmt.Println("  -dir-mode    Octal permissions for created directories (default: 0755)")
+	fmt.Println("  -parallel-writes-limit Maximum concurrent disk writes (default: 0, unlimited)")
+	fmt.Println("  -inline-images-below Embed images smaller than this many bytes as data URIs (default: 0, off)")
+	fmt.Println("  -max-runtime Wall-clock cap (e.g. 10m); keeps partial output and exits with code 124 (default: 0, no limit)")
+	fmt.Println("  -progress-json Stream JSON progress events (one per line) to a file, or - for stdout (other output then goes to stderr)")
+	fmt.Println("  -quiet       Don't print the asset download progress line to stderr")
+	fmt.Println("  -max-concurrency-total Cap simultaneous requests across all pages and assets (default: 0, no cap)")
+	fmt.Println("  -fetch-retries-separate-page Retry the page fetch after a network error or 429/5xx (default: 3)")
+	fmt.Println("  -image-quality Re-encode JPEGs at this quality (1-100) when it makes them smaller (default: 0, off)")
+	fmt.Println("  -max-file-size Leave <a download> files larger than this many bytes remote (default: 0, unlimited)")
+	fmt.Println("  -max-total-bytes Stop downloading after this many bytes (default: 0, unlimited)")
+	fmt.Println("  -manifest    Write manifest.json in -outdir listing every downloaded asset")
+	fmt.Println("  -scrape-headers-to-file Write _headers.json in -outdir with every asset's status and response headers")
+	fmt.Println("  -image-inventory Write images.json in -outdir with the dimensions, size, and format of every image")
+	fmt.Println("  -output-report-html Write _report.html in -outdir, a browsable summary of the scrape")
+	fmt.Println("  -skip-existing Keep the -outdir directory and reuse assets that were already downloaded")
+	fmt.Println("  -no-clean Keep the existing -outdir directory instead of removing it before scraping")
+	fmt.Println("  -parse-js-sourcemaps-for-assets Fetch JS source maps to find images and fonts hidden by minification")
+	fmt.Println("  -report-largest-assets Print the N largest downloaded assets by size (default: 0, off)")
+	fmt.Println("  -verbose-skip-reasons Print every asset left remote with the reason it was skipped")
+	fmt.Println("  -drop-failed-references Remove scripts/links whose asset failed and blank other failed src/href (default: false)")
+	fmt.Println("  -download-prefetch Also download <link rel=prefetch> targets (default: false)")
+	fmt.Println("  -strip-scripts-all Remove every script, inline event handler, and javascript: URL for a pure HTML+CSS snapshot (default: false)")
+	fmt.Println("  -strip-admin-bar Remove the WordPress admin bar and its assets (default: true)")
+	fmt.Println("  -concurrent-css-rewrite Fetch CSS-referenced assets in the shared worker pool")
+	fmt.Println("  -css-url-rewrite-absolute Make CSS url() references absolute under -css-url-base (default base: /)")
+	fmt.Println("  -css-autoprefix-local-fonts Point CSS font url()s at -font-url-prefix (default prefix: /assets/fonts/)")
+	fmt.Println("  -asset-rule Download the URL in an attribute of matching elements, selector@attr[:type] (repeatable)")
+	fmt.Println("  -origin-alias Treat an origin as an alias of another, old=new (repeatable)")
+	fmt.Println("  -dedupe Collapse downloads with identical content onto one file")
+	fmt.Println("  -dedupe-report Report identical downloads and the bytes -dedupe saves (does not collapse them by itself)")
+	fmt.Println("  -rewrite-inline-style-fonts Localize font url() references in style attributes (default: true)")
+	fmt.Println("  -lazy-iframes Fetch and localize same-origin iframes deferred via data-src")
+	fmt.Println("  -promote-iframe-data-src Copy data-src into src for cross-origin deferred iframes")
+	fmt.Println("  -purge-css Remove CSS rules whose class/id selectors match nothing in the scraped pages")
+	fmt.Println("  -html-fragment Also save each page's <body> inner HTML as a .fragment.html file")
+	fmt.Println("  -selector    With -html-fragment, save only the first element matching tag, .class, or #id")
+	fmt.Println("  -collapse-whitespace-text-nodes Collapse whitespace in text outside pre/textarea/script/style")
+	fmt.Println("  -normalize-asset-case Save asset filenames in one case (lower or upper) and dedupe URLs differing only in case")
+	fmt.Println("  -normalize-line-endings Normalize saved HTML, CSS, JS, and JSON to lf or crlf")
+	fmt.Println("  -validate-html Check output markup: off, warn, or strict (default: off)")
+	fmt.Println("  -title       Replace the page <title> in the output")
+	fmt.Println("  -meta-description Replace or insert the meta description in the output")
+	fmt.Println("  -respect-robots-strict Abort when robots.txt disallows the -url path")
+	fmt.Println("  -asset-base Resolve and download assets against this base URL instead of -url")
+	fmt.Println("  -render-endpoint Fetch the page through a headless-render service instead of directly")
+	fmt.Println("  -accept-encoding Set to br to also request and decode Brotli responses (default: gzip only)")
+	fmt.Println("  -request-id-header Send a unique ID under this header with every request (e.g. X-Request-ID)")
+	fmt.Println("  -basic-auth  HTTP basic auth credentials for the page and assets on the site's hosts, given as user:pass")
+	fmt.Println("  -header      Extra request header for the page and its assets, \"Name: Value\" (repeatable)")
+	fmt.Println("  -user-agent  User-Agent header for the page and its assets")
+	fmt.Println("  -page-proxy  Proxy URL used only for the top-level page fetch")
+	fmt.Println("  -asset-proxy Proxy URL used only for asset downloads")
+	fmt.Println("  -rewrite-srcset-to-single-src Collapse img srcset to one src for email/AMP (see -srcset-target-width)")
+	fmt.Println("  -localize-json-state Localize asset URLs inside inline JSON state blobs (see -json-state-vars)")
+	fmt.Println("  -trim-tracking-params Strip utm_*, fbclid, gclid, ... from remaining URLs (see -tracking-params)")
+	fmt.Println("  -preserve-mtime Set downloaded files' modification times from the Last-Modified header (default: false)")
+	fmt.Println("  -retry-on-empty-body Retry empty 200 image, media, and file responses instead of saving empty files (default: true)")
+	fmt.Println("  -fail-fast   Abort once a CSS, JS, or image download fails after its retries")
+	fmt.Println("  -rewrite-protocol-relative Rewrite remaining //host references to -protocol-relative-scheme (default: https)")
 	fmt.Println("")
 	fmt.Println("Serve options:")
 	fmt.Println("  -port     Port for HTTP server (default: 8080)")
+	fmt.Println("  -dir      Directory to serve, the -outdir used when scraping (default: output)")
+	fmt.Println("  -watch    Live-reload the browser when output files change")
+	fmt.Println("  -port-auto Use the next free port (or any free port) when -port is busy")
+	fmt.Println("  -serve-auth Require HTTP basic auth, given as user:pass")
+	fmt.Println("  -serve-banner Show a \"static snapshot from <date>\" banner on served pages")
+	fmt.Println("  -http-cache-headers Send Cache-Control, ETag, and Last-Modified headers")
+	fmt.Println("")
+	fmt.Println("List options:")
+	fmt.Println("  -url      URL of the page to inspect (required)")
+	fmt.Println("  -format   table (tab-separated: type, origin, URL) or json (default: table)")
 }
\ No newline at end of file
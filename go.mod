@@ -2,4 +2,11 @@ module wp-static-scraper
 
 go 1.24.0
 
-require golang.org/x/net v0.43.0
+require (
+	github.com/andybalholm/brotli v1.2.0
+	github.com/fsnotify/fsnotify v1.10.1
+	golang.org/x/image v0.25.0
+	golang.org/x/net v0.43.0
+)
+
+require golang.org/x/sys v0.35.0 // indirect
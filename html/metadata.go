@@ -0,0 +1,122 @@
+package html
+
+import (
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// MetadataOverrides replaces page metadata in the static copy; empty fields are left untouched
+type MetadataOverrides struct {
+	Title           string
+	MetaDescription string
+}
+
+// IsEmpty reports whether no override is set
+func (m MetadataOverrides) IsEmpty() bool {
+	return m.Title == "" && m.MetaDescription == ""
+}
+
+// ApplyMetadataOverrides sets the <title> and meta description, replacing existing elements or
+// inserting them into <head>. Matching og: and twitter: tags that already exist are updated too.
+func ApplyMetadataOverrides(htmlContent string, overrides MetadataOverrides) (string, error) {
+	if overrides.IsEmpty() {
+		return htmlContent, nil
+	}
+
+	doc, err := nethtml.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", err
+	}
+
+	var head, title, description *nethtml.Node
+	var traverse func(*nethtml.Node)
+	traverse = func(n *nethtml.Node) {
+		if n.Type == nethtml.ElementNode {
+			switch n.Data {
+			case "head":
+				if head == nil {
+					head = n
+				}
+			case "title":
+				if title == nil {
+					title = n
+				}
+			case "meta":
+				name := strings.ToLower(metaAttr(n, "name"))
+				property := strings.ToLower(metaAttr(n, "property"))
+				switch {
+				case name == "description" && description == nil:
+					description = n
+				case overrides.Title != "" && (property == "og:title" || name == "twitter:title"):
+					setMetaAttr(n, "content", overrides.Title)
+				case overrides.MetaDescription != "" && (property == "og:description" || name == "twitter:description"):
+					setMetaAttr(n, "content", overrides.MetaDescription)
+				}
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+
+	traverse(doc)
+
+	// html.Parse always synthesizes a <head>, but guard anyway
+	if head == nil {
+		return htmlContent, nil
+	}
+
+	if overrides.Title != "" {
+		if title == nil {
+			title = &nethtml.Node{Type: nethtml.ElementNode, Data: "title", DataAtom: atom.Title}
+			head.InsertBefore(title, head.FirstChild)
+		}
+		for title.FirstChild != nil {
+			title.RemoveChild(title.FirstChild)
+		}
+		title.AppendChild(&nethtml.Node{Type: nethtml.TextNode, Data: overrides.Title})
+	}
+
+	if overrides.MetaDescription != "" {
+		if description == nil {
+			description = &nethtml.Node{
+				Type:     nethtml.ElementNode,
+				Data:     "meta",
+				DataAtom: atom.Meta,
+				Attr:     []nethtml.Attribute{{Key: "name", Val: "description"}},
+			}
+			head.AppendChild(description)
+		}
+		setMetaAttr(description, "content", overrides.MetaDescription)
+	}
+
+	var buf strings.Builder
+	if err := nethtml.Render(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// metaAttr returns the value of an attribute, or "" when absent
+func metaAttr(n *nethtml.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// setMetaAttr sets an attribute value, adding the attribute when missing
+func setMetaAttr(n *nethtml.Node, key, val string) {
+	for i, attr := range n.Attr {
+		if attr.Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, nethtml.Attribute{Key: key, Val: val})
+}
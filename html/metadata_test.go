@@ -0,0 +1,24 @@
+package html
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyMetadataOverrides(t *testing.T) {
+	page := `<html><head><title>Old Title</title><meta property="og:title" content="Old Title"></head><body></body></html>`
+
+	result, err := ApplyMetadataOverrides(page, MetadataOverrides{Title: "New Title", MetaDescription: "Snapshot copy"})
+	if err != nil {
+		t.Fatalf("ApplyMetadataOverrides returned error: %v", err)
+	}
+	if !strings.Contains(result, "<title>New Title</title>") || strings.Contains(result, "Old Title") {
+		t.Errorf("title should be replaced, got %s", result)
+	}
+	if !strings.Contains(result, `<meta property="og:title" content="New Title"/>`) {
+		t.Errorf("og:title should be updated, got %s", result)
+	}
+	if !strings.Contains(result, `<meta name="description" content="Snapshot copy"/>`) {
+		t.Errorf("meta description should be inserted, got %s", result)
+	}
+}
@@ -0,0 +1,83 @@
+package html
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// voidElements never have closing tags
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true,
+	"img": true, "input": true, "link": true, "meta": true, "param": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// optionalEndElements may legitimately be left open
+var optionalEndElements = map[string]bool{
+	"html": true, "head": true, "body": true, "p": true, "li": true, "dt": true,
+	"dd": true, "option": true, "optgroup": true, "tr": true, "td": true, "th": true,
+	"thead": true, "tbody": true, "tfoot": true, "colgroup": true, "caption": true,
+	"rb": true, "rt": true, "rtc": true, "rp": true,
+}
+
+// ValidateHTML reports markup anomalies such as malformed attributes and unbalanced tags.
+// It is meant to catch damage introduced by rewriting, not to be a full conformance checker.
+func ValidateHTML(htmlContent string) []string {
+	var issues []string
+	var stack []string
+
+	tokenizer := nethtml.NewTokenizer(strings.NewReader(htmlContent))
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case nethtml.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				issues = append(issues, fmt.Sprintf("parse error: %v", err))
+			}
+			for i := len(stack) - 1; i >= 0; i-- {
+				if !optionalEndElements[stack[i]] {
+					issues = append(issues, fmt.Sprintf("unclosed <%s>", stack[i]))
+				}
+			}
+			return issues
+
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			token := tokenizer.Token()
+			for _, attr := range token.Attr {
+				if strings.ContainsAny(attr.Key, "\"'<=`") {
+					issues = append(issues, fmt.Sprintf("malformed attribute %q on <%s>", attr.Key, token.Data))
+				}
+			}
+			if tokenType == nethtml.StartTagToken && !voidElements[token.Data] {
+				stack = append(stack, token.Data)
+			}
+
+		case nethtml.EndTagToken:
+			token := tokenizer.Token()
+			if voidElements[token.Data] {
+				continue
+			}
+			// Pop back to the matching element, reporting anything left open in between
+			match := -1
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i] == token.Data {
+					match = i
+					break
+				}
+			}
+			if match == -1 {
+				issues = append(issues, fmt.Sprintf("unexpected </%s>", token.Data))
+				continue
+			}
+			for i := len(stack) - 1; i > match; i-- {
+				if !optionalEndElements[stack[i]] {
+					issues = append(issues, fmt.Sprintf("unclosed <%s> before </%s>", stack[i], token.Data))
+				}
+			}
+			stack = stack[:match]
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package html
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		contains string // Expected substring of the first issue, empty for valid markup
+	}{
+		{
+			name:  "valid document",
+			input: `<!DOCTYPE html><html><head><link rel="stylesheet" href="assets/a.css"></head><body><p>One<p>Two<img src="x.png"><br/></body></html>`,
+		},
+		{
+			name:  "script content is raw text",
+			input: `<html><body><script>if (a < b) { document.write("</div>"); }</script></body></html>`,
+		},
+		{
+			name:     "broken attribute quoting from rewrite",
+			input:    `<html><body><img src="assets/images/a.png"b.png"></body></html>`,
+			contains: "malformed attribute",
+		},
+		{
+			name:     "unclosed element",
+			input:    `<html><body><div><span>text</div></body></html>`,
+			contains: "unclosed <span>",
+		},
+		{
+			name:     "stray end tag",
+			input:    `<html><body></section></body></html>`,
+			contains: "unexpected </section>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := ValidateHTML(tt.input)
+			if tt.contains == "" {
+				if len(issues) != 0 {
+					t.Errorf("expected no issues, got %v", issues)
+				}
+				return
+			}
+			if len(issues) == 0 || !strings.Contains(issues[0], tt.contains) {
+				t.Errorf("expected an issue containing %q, got %v", tt.contains, issues)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package html
+
+import (
+	"regexp"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// whitespaceRunRe matches runs of HTML whitespace characters
+var whitespaceRunRe = regexp.MustCompile(`[ \t\n\r\f]+`)
+
+// preformattedElements keep their text exactly as written
+var preformattedElements = map[string]bool{
+	"pre": true, "textarea": true, "script": true, "style": true,
+}
+
+// CollapseWhitespace shrinks every run of whitespace in text nodes to a single space, leaving
+// <pre>, <textarea>, <script>, and <style> content untouched. The document structure is not changed.
+func CollapseWhitespace(htmlContent string) (string, error) {
+	doc, err := nethtml.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", err
+	}
+
+	var traverse func(*nethtml.Node)
+	traverse = func(n *nethtml.Node) {
+		if n.Type == nethtml.ElementNode && preformattedElements[n.Data] {
+			return
+		}
+		if n.Type == nethtml.TextNode {
+			n.Data = whitespaceRunRe.ReplaceAllString(n.Data, " ")
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+
+	traverse(doc)
+
+	var buf strings.Builder
+	if err := nethtml.Render(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
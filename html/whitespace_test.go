@@ -0,0 +1,31 @@
+package html
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCollapseWhitespace(t *testing.T) {
+	page := "<html><head><style>\n  body {\n    color: red;\n  }\n</style></head><body>\n" +
+		"<p>Hello,\n\t   world   !</p>\n\n<pre>  keep\n    this  </pre><textarea>  and\n  this</textarea>" +
+		"<script>\n  var  x = 1;\n</script></body></html>"
+
+	result, err := CollapseWhitespace(page)
+	if err != nil {
+		t.Fatalf("CollapseWhitespace returned error: %v", err)
+	}
+
+	if !strings.Contains(result, "<p>Hello, world !</p>") {
+		t.Errorf("text whitespace should be collapsed, got %q", result)
+	}
+	for _, preserved := range []string{
+		"<pre>  keep\n    this  </pre>",
+		"<textarea>  and\n  this</textarea>",
+		"<script>\n  var  x = 1;\n</script>",
+		"<style>\n  body {\n    color: red;\n  }\n</style>",
+	} {
+		if !strings.Contains(result, preserved) {
+			t.Errorf("preformatted content should be preserved: %q not in %q", preserved, result)
+		}
+	}
+}
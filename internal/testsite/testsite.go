@@ -0,0 +1,24 @@
+// Package testsite provides the fixed-route test server shared by the tests of every package
+package testsite
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// New starts a test server that serves fixed bodies by request path and answers anything else
+// with 404. It is closed when the test ends.
+func New(tb testing.TB, routes map[string]string) *httptest.Server {
+	tb.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	tb.Cleanup(server.Close)
+	return server
+}
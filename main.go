@@ -19,6 +19,10 @@ func main() {
 		commands.ScrapeCommand()
 	case "serve":
 		commands.ServeCommand()
+	case "list":
+		commands.ListCommand()
+	case "clean":
+		commands.CleanCommand()
 	default:
 		fmt.Printf("Unknown command: %s\n\n", command)
 		commands.PrintUsage()
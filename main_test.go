@@ -1,13 +1,26 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"wp-static-scraper/assets"
+	"wp-static-scraper/commands"
 	"wp-static-scraper/html"
+	"wp-static-scraper/internal/testsite"
 	"wp-static-scraper/utils"
 )
 
@@ -157,7 +170,7 @@ func TestLocalizeSrcset(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := assets.LocalizeSrcset(tt.input, base)
+			result, err := assets.LocalizeSrcset(tt.input, base, utils.DefaultOutDir)
 			if err != nil {
 				t.Errorf("LocalizeSrcset returned error: %v", err)
 			}
@@ -197,7 +210,7 @@ func TestLocalizeStyleBackgroundImages(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := assets.LocalizeStyleBackgroundImages(tt.input, base)
+			result, err := assets.LocalizeStyleBackgroundImages(tt.input, base, utils.DefaultOutDir)
 			if err != nil {
 				t.Errorf("LocalizeStyleBackgroundImages returned error: %v", err)
 			}
@@ -258,4 +271,600 @@ func TestAddErrorSuppressionScript(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+// TestMain runs the CLI itself when re-executed by runScraper
+func TestMain(m *testing.M) {
+	if args := os.Getenv("WP_STATIC_SCRAPER_ARGS"); args != "" {
+		os.Args = append([]string{"wp-static-scraper"}, strings.Fields(args)...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runScraper runs the CLI with space-separated args in dir as a child process, so its exit code
+// and output can be observed
+func runScraper(t *testing.T, dir, args string) ([]byte, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "WP_STATIC_SCRAPER_ARGS="+args)
+	output, err := cmd.CombinedOutput()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return output, exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("Failed to run scraper: %v", err)
+	}
+	return output, 0
+}
+
+func TestMaxRuntimePartialOutput(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><img src="/img/fast.png"><img src="/img/slow.png"></body></html>`))
+	})
+	mux.HandleFunc("/img/fast.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fast"))
+	})
+	mux.HandleFunc("/img/slow.png", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	dir := t.TempDir()
+	output, code := runScraper(t, dir, "scrape -url "+server.URL+"/ -concurrency 2 -manifest -max-runtime 500ms")
+	if code != 124 {
+		t.Fatalf("expected exit code 124, got %d: %s", code, output)
+	}
+
+	page, err := os.ReadFile(dir + "/output/index.html")
+	if err != nil {
+		t.Fatalf("partial page should still be written: %v", err)
+	}
+	if !strings.Contains(string(page), `src="assets/images/fast.png"`) || !strings.Contains(string(page), `src="/img/slow.png"`) {
+		t.Errorf("finished downloads should be local and the rest remote: %s", page)
+	}
+
+	data, err := os.ReadFile(dir + "/output/manifest.json")
+	if err != nil {
+		t.Fatalf("partial manifest should be written: %v", err)
+	}
+	var manifest assets.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("manifest should be valid JSON: %v", err)
+	}
+	if !manifest.Partial {
+		t.Errorf("manifest should be marked partial: %s", data)
+	}
+}
+
+func TestScrapeConfigFile(t *testing.T) {
+	server := testsite.New(t, map[string]string{
+		"/": `<html><head><script src="/app.js"></script></head><body><p>hello</p></body></html>`,
+	})
+
+	dir := t.TempDir()
+	config := `{"url": "` + server.URL + `/", "out": "from-config.html", "concurrency": 2, "strip-scripts-all": true}`
+	if err := os.WriteFile(filepath.Join(dir, "scrape.json"), []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	output, code := runScraper(t, dir, "scrape -config scrape.json -out from-flag.html")
+	if code != 0 {
+		t.Fatalf("scrape failed with exit code %d: %s", code, output)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "output", "from-config.html")); !os.IsNotExist(err) {
+		t.Error("the -out flag should override the config file")
+	}
+	page, err := os.ReadFile(filepath.Join(dir, "output", "from-flag.html"))
+	if err != nil {
+		t.Fatalf("page should be written to the flag's -out: %v", err)
+	}
+	if strings.Contains(string(page), "<script") {
+		t.Errorf("strip-scripts-all from the config file should apply: %s", page)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "typo.json"), []byte(`{"concurency": 2}`), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	output, code = runScraper(t, dir, "scrape -url "+server.URL+"/ -config typo.json")
+	if code != 1 || !strings.Contains(string(output), `unknown option "concurency"`) {
+		t.Errorf("unknown config keys should be rejected, got exit code %d: %s", code, output)
+	}
+}
+
+func TestCleanCommandAndNoClean(t *testing.T) {
+	dir := t.TempDir()
+	writeKeep := func() {
+		if err := os.MkdirAll(dir+"/output", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(dir+"/output/keep.txt", []byte("keep"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeKeep()
+	output, code := runScraper(t, dir, "clean")
+	if code != 0 {
+		t.Fatalf("clean failed with exit code %d: %s", code, output)
+	}
+	if _, err := os.Stat(dir + "/output"); !os.IsNotExist(err) {
+		t.Errorf("clean should remove output/, stat error: %v", err)
+	}
+
+	server := testsite.New(t, map[string]string{
+		"/": `<html><body><p>page</p></body></html>`,
+	})
+	writeKeep()
+	output, code = runScraper(t, dir, "scrape -url "+server.URL+"/ -no-clean")
+	if code != 0 {
+		t.Fatalf("scrape failed with exit code %d: %s", code, output)
+	}
+	if _, err := os.Stat(dir + "/output/keep.txt"); err != nil {
+		t.Errorf("-no-clean should keep existing files: %v", err)
+	}
+	if _, err := os.Stat(dir + "/output/index.html"); err != nil {
+		t.Errorf("page should still be written: %v", err)
+	}
+}
+
+func TestPurgeCSSKeepsIntegrityValid(t *testing.T) {
+	server := testsite.New(t, map[string]string{
+		"/": `<html><head><link rel="stylesheet" href="/css/site.css" integrity="sha384-fromtheorigin" crossorigin="anonymous"></head>` +
+			`<body><div class="used">Hi</div></body></html>`,
+		"/css/site.css": `.used{color:blue} .unused{color:red}`,
+	})
+
+	dir := t.TempDir()
+	output, code := runScraper(t, dir, "scrape -url "+server.URL+"/ -purge-css -manifest")
+	if code != 0 {
+		t.Fatalf("scraper exited with %d: %s", code, output)
+	}
+
+	css, err := os.ReadFile(filepath.Join(dir, "output", "assets", "site.css"))
+	if err != nil {
+		t.Fatalf("stylesheet not saved: %v", err)
+	}
+	if strings.Contains(string(css), ".unused") {
+		t.Errorf("unused rule should be purged: %s", css)
+	}
+	sum := sha512.Sum384(css)
+	integrity := "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+
+	index, _ := os.ReadFile(filepath.Join(dir, "output", "index.html"))
+	if !strings.Contains(string(index), `integrity="`+integrity+`"`) {
+		t.Errorf("page integrity should match the purged stylesheet %s: %s", integrity, index)
+	}
+	manifest, _ := os.ReadFile(filepath.Join(dir, "output", "manifest.json"))
+	if !strings.Contains(string(manifest), `"integrity": "`+integrity+`"`) {
+		t.Errorf("manifest integrity should match the purged stylesheet %s: %s", integrity, manifest)
+	}
+}
+
+func TestAssetBaseOverride(t *testing.T) {
+	// The page is fetched from one host while its assets only exist on the domain it assumes
+	pageServer := testsite.New(t, map[string]string{
+		"/": `<html><body><img src="/img/logo.png"><img src="img/hero.png"></body></html>`,
+	})
+	assetServer := testsite.New(t, map[string]string{
+		"/img/logo.png":      "logo",
+		"/site/img/hero.png": "hero",
+	})
+
+	dir := t.TempDir()
+	output, code := runScraper(t, dir, "scrape -url "+pageServer.URL+"/ -asset-base "+assetServer.URL+"/site/")
+	if code != 0 {
+		t.Fatalf("scrape failed with exit code %d: %s", code, output)
+	}
+
+	for name, want := range map[string]string{"logo.png": "logo", "hero.png": "hero"} {
+		data, err := os.ReadFile(dir + "/output/assets/images/" + name)
+		if err != nil || string(data) != want {
+			t.Errorf("%s should be downloaded from the asset base, got %q (err %v)", name, data, err)
+		}
+	}
+	page, _ := os.ReadFile(dir + "/output/index.html")
+	if !strings.Contains(string(page), `src="assets/images/logo.png"`) || !strings.Contains(string(page), `src="assets/images/hero.png"`) {
+		t.Errorf("references should point at the local copies: %s", page)
+	}
+}
+
+func TestRespectRobotsStrictAborts(t *testing.T) {
+	server := testsite.New(t, map[string]string{
+		"/robots.txt": "User-agent: *\nDisallow: /private/\nAllow: /private/press/\n\nUser-agent: Googlebot\nDisallow: /\n",
+		"/private/":   `<html><body>secret</body></html>`,
+		"/":           `<html><body>home</body></html>`,
+	})
+
+	dir := t.TempDir()
+	output, code := runScraper(t, dir, "scrape -url "+server.URL+"/private/ -respect-robots-strict")
+	if code != 1 {
+		t.Fatalf("disallowed entry URL should abort with exit code 1, got %d: %s", code, output)
+	}
+	if !strings.Contains(string(output), "robots.txt disallows") {
+		t.Errorf("abort should explain the robots.txt rule: %s", output)
+	}
+	if _, err := os.Stat(dir + "/output/index.html"); !os.IsNotExist(err) {
+		t.Errorf("nothing should be scraped when robots.txt disallows the page")
+	}
+
+	for path, want := range map[string]bool{"/": true, "/private/": false, "/private/press/kit": true} {
+		allowed, err := assets.RobotsAllowed(server.URL+path, assets.Options{})
+		if err != nil || allowed != want {
+			t.Errorf("RobotsAllowed(%s) = %v, %v; want %v", path, allowed, err, want)
+		}
+	}
+}
+
+func TestHTMLFragmentOutput(t *testing.T) {
+	server := testsite.New(t, map[string]string{
+		"/": `<!DOCTYPE html><html><head><title>Post</title><link rel="stylesheet" href="/css/site.css"></head>` +
+			`<body><header>Site nav</header><article class="post"><h1>Hello</h1><img src="/img/photo.png"></article></body></html>`,
+		"/css/site.css":  "body { color: red; }",
+		"/img/photo.png": "png",
+	})
+
+	dir := t.TempDir()
+	output, code := runScraper(t, dir, "scrape -url "+server.URL+"/ -html-fragment")
+	if code != 0 {
+		t.Fatalf("scrape failed with exit code %d: %s", code, output)
+	}
+	fragment, err := os.ReadFile(dir + "/output/index.fragment.html")
+	if err != nil {
+		t.Fatalf("fragment file should be saved: %v", err)
+	}
+	for _, wrapper := range []string{"<!DOCTYPE", "<html", "<head>", "<body", "<title", "suppress"} {
+		if strings.Contains(strings.ToLower(string(fragment)), strings.ToLower(wrapper)) {
+			t.Errorf("fragment should not contain %s: %s", wrapper, fragment)
+		}
+	}
+	if !strings.Contains(string(fragment), "<header>Site nav</header>") || !strings.Contains(string(fragment), `src="assets/images/photo.png"`) {
+		t.Errorf("fragment should keep the body with localized image references: %s", fragment)
+	}
+	if page, _ := os.ReadFile(dir + "/output/index.html"); !strings.Contains(string(page), "<head>") {
+		t.Errorf("the full page should still be saved alongside the fragment")
+	}
+
+	dir = t.TempDir()
+	output, code = runScraper(t, dir, "scrape -url "+server.URL+"/ -html-fragment -selector article.post")
+	if code != 0 {
+		t.Fatalf("scrape with -selector failed with exit code %d: %s", code, output)
+	}
+	fragment, _ = os.ReadFile(dir + "/output/index.fragment.html")
+	if !strings.HasPrefix(string(fragment), `<article class="post">`) || strings.Contains(string(fragment), "Site nav") {
+		t.Errorf("selector fragment should contain only the matching region: %s", fragment)
+	}
+	if !strings.Contains(string(fragment), `src="assets/images/photo.png"`) {
+		t.Errorf("selector fragment should keep localized asset references: %s", fragment)
+	}
+
+	if _, err := assets.ExtractFragment(`<html><body><p>x</p></body></html>`, "div.missing"); err == nil {
+		t.Errorf("a selector matching nothing should be an error")
+	}
+}
+
+func TestOutDirConfigurable(t *testing.T) {
+	server := testsite.New(t, map[string]string{
+		"/": `<html><head><link rel="stylesheet" href="/css/site.css"></head>` +
+			`<body><img src="/img/photo.png"></body></html>`,
+		"/css/site.css":  `@font-face { font-family: "X"; src: url("/fonts/x.woff2"); }`,
+		"/fonts/x.woff2": "font",
+		"/img/photo.png": "png",
+	})
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(dir+"/output", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/output/keep.txt", []byte("other site"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, code := runScraper(t, dir, "scrape -url "+server.URL+"/ -outdir sites/a -manifest")
+	if code != 0 {
+		t.Fatalf("scrape failed with exit code %d: %s", code, output)
+	}
+	for _, file := range []string{"index.html", "manifest.json", "assets/site.css", "assets/images/photo.png", "assets/fonts/x.woff2"} {
+		if _, err := os.Stat(dir + "/sites/a/" + file); err != nil {
+			t.Errorf("%s should be written under the chosen directory: %v", file, err)
+		}
+	}
+	if _, err := os.Stat(dir + "/output/keep.txt"); err != nil {
+		t.Errorf("cleanup must only remove the configured directory, not output/: %v", err)
+	}
+	if _, err := os.Stat(dir + "/output/index.html"); !os.IsNotExist(err) {
+		t.Errorf("nothing should be written to output/ when -outdir is set")
+	}
+	page, _ := os.ReadFile(dir + "/sites/a/index.html")
+	if !strings.Contains(string(page), `href="assets/site.css"`) || !strings.Contains(string(page), `src="assets/images/photo.png"`) {
+		t.Errorf("references should stay relative to the chosen directory: %s", page)
+	}
+	manifestData, _ := os.ReadFile(dir + "/sites/a/manifest.json")
+	if !strings.Contains(string(manifestData), `"assets/site.css"`) || strings.Contains(string(manifestData), "sites/a") {
+		t.Errorf("manifest paths should be relative to the chosen directory: %s", manifestData)
+	}
+
+	t.Chdir(dir)
+	handler := commands.NewServeHandler(commands.ServeOptions{Dir: "sites/a"})
+	for _, path := range []string{"/", "/assets/site.css", "/images/photo.png"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("serve from -dir should find %s, got %d", path, rec.Code)
+		}
+	}
+
+	if err := utils.RemoveOutput("."); err == nil {
+		t.Errorf("removing the working directory must be refused")
+	}
+}
+
+func TestCrawlDepthScrape(t *testing.T) {
+	server := testsite.New(t, map[string]string{
+		"/":             `<html><body><a href="/about/">About</a><a href="https://example.org/">Elsewhere</a></body></html>`,
+		"/about/":       `<html><body><img src="/img/team.png"><a href="/">Home</a></body></html>`,
+		"/img/team.png": "png",
+	})
+
+	dir := t.TempDir()
+	output, code := runScraper(t, dir, "scrape -url "+server.URL+"/ -depth 1")
+	if code != 0 {
+		t.Fatalf("scraper exited with %d: %s", code, output)
+	}
+
+	index, err := os.ReadFile(filepath.Join(dir, "output", "index.html"))
+	if err != nil {
+		t.Fatalf("start page not saved: %v", err)
+	}
+	if !strings.Contains(string(index), `href="about/index.html"`) || !strings.Contains(string(index), `href="https://example.org/"`) {
+		t.Errorf("internal links should point at the local copy and external ones stay remote: %s", index)
+	}
+	about, err := os.ReadFile(filepath.Join(dir, "output", "about", "index.html"))
+	if err != nil {
+		t.Fatalf("crawled page not saved: %v", err)
+	}
+	if !strings.Contains(string(about), `src="../assets/images/team.png"`) || !strings.Contains(string(about), `href="../index.html"`) {
+		t.Errorf("crawled page should reach assets and the start page from its directory: %s", about)
+	}
+}
+
+func TestBasicAuthScrape(t *testing.T) {
+	routes := map[string]string{
+		"/":                  `<html><head><link rel="stylesheet" href="/css/site.css"></head><body><img src="/img/logo.png"></body></html>`,
+		"/css/site.css":      `@font-face { font-family: Brand; src: url(../fonts/brand.woff2) } .hero { background: url(../img/hero.png) }`,
+		"/fonts/brand.woff2": "woff2",
+		"/img/hero.png":      "hero",
+		"/img/logo.png":      "logo",
+	}
+	var rejected int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "editor" || pass != "s3cret" {
+			atomic.AddInt64(&rejected, 1)
+			w.Header().Set("WWW-Authenticate", `Basic realm="staging"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	output, code := runScraper(t, dir, "scrape -url "+server.URL+"/ -basic-auth editor:s3cret")
+	if code != 0 {
+		t.Fatalf("scraper exited with %d: %s", code, output)
+	}
+	if n := atomic.LoadInt64(&rejected); n != 0 {
+		t.Errorf("%d requests were sent without credentials", n)
+	}
+	for _, file := range []string{"index.html", "assets/site.css", "assets/fonts/brand.woff2", "assets/images/hero.png", "assets/images/logo.png"} {
+		if _, err := os.Stat(filepath.Join(dir, "output", filepath.FromSlash(file))); err != nil {
+			t.Errorf("%s should be saved with credentials: %v", file, err)
+		}
+	}
+
+	// Without credentials the server turns every request away
+	dir = t.TempDir()
+	runScraper(t, dir, "scrape -url "+server.URL+"/")
+	if atomic.LoadInt64(&rejected) == 0 {
+		t.Errorf("unauthenticated requests should be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "output", "assets", "images", "logo.png")); err == nil {
+		t.Errorf("nothing should be downloaded without credentials")
+	}
+
+	if output, code := runScraper(t, t.TempDir(), "scrape -url "+server.URL+"/ -basic-auth editor"); code == 0 {
+		t.Errorf("credentials without a password separator should be rejected: %s", output)
+	}
+}
+
+func TestBasicAuthStaysOnSiteHosts(t *testing.T) {
+	var foreignAuth, crawlAuth atomic.Value
+	foreignAuth.Store("")
+	crawlAuth.Store("")
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			foreignAuth.Store(auth)
+		}
+		w.Write([]byte("cdn"))
+	}))
+	defer foreign.Close()
+	multisite := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		crawlAuth.Store(r.Header.Get("Authorization"))
+		w.Write([]byte("blog"))
+	}))
+	defer multisite.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := r.BasicAuth(); !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<html><head><link rel="stylesheet" href="/css/site.css"></head><body>` +
+				`<img src="` + foreign.URL + `/img/cdn.png"><img src="` + multisite.URL + `/img/blog.png"></body></html>`))
+		case "/css/site.css":
+			w.Write([]byte(`.hero { background: url(` + foreign.URL + `/img/hero.png) }`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	multisiteHost := strings.TrimPrefix(multisite.URL, "http://")
+	output, code := runScraper(t, dir, "scrape -url "+server.URL+"/ -basic-auth editor:s3cret -crawl-hosts "+multisiteHost)
+	if code != 0 {
+		t.Fatalf("scraper exited with %d: %s", code, output)
+	}
+	if auth := foreignAuth.Load().(string); auth != "" {
+		t.Errorf("a third-party host should never receive the credentials, got Authorization %q", auth)
+	}
+	if auth := crawlAuth.Load().(string); !strings.HasPrefix(auth, "Basic ") {
+		t.Errorf("-crawl-hosts hosts should receive the credentials, got Authorization %q", auth)
+	}
+	for _, file := range []string{"assets/images/cdn.png", "assets/images/hero.png", "assets/images/blog.png"} {
+		if _, err := os.Stat(filepath.Join(dir, "output", filepath.FromSlash(file))); err != nil {
+			t.Errorf("%s should still be downloaded: %v", file, err)
+		}
+	}
+}
+
+func TestCustomRequestHeaders(t *testing.T) {
+	routes := map[string]string{
+		"/":                  `<html><head><link rel="stylesheet" href="/css/site.css"><script src="/js/app.js"></script></head><body><img src="/img/logo.png"></body></html>`,
+		"/css/site.css":      `@font-face { font-family: Brand; src: url(../fonts/brand.woff2) } .hero { background: url(../img/hero.png) }`,
+		"/js/app.js":         "console.log('app')",
+		"/fonts/brand.woff2": "woff2",
+		"/img/hero.png":      "hero",
+		"/img/logo.png":      "logo",
+	}
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	var missing []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.URL.Path] = true
+		if r.UserAgent() != "StagingBot/1.0" || r.Header.Get("X-Staging-Token") != "abc123" ||
+			strings.Join(r.Header.Values("X-Env"), ",") != "staging,preview" {
+			missing = append(missing, r.URL.Path)
+		}
+		mu.Unlock()
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	output, code := runScraper(t, dir, "scrape -url "+server.URL+"/ -header X-Staging-Token:abc123 -header X-Env:staging -header X-Env:preview -header User-Agent:Ignored -user-agent StagingBot/1.0")
+	if code != 0 {
+		t.Fatalf("scraper exited with %d: %s", code, output)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(missing) != 0 {
+		t.Errorf("requests without the custom headers: %v", missing)
+	}
+	for path := range routes {
+		if !seen[path] {
+			t.Errorf("%s was never requested", path)
+		}
+	}
+
+	if output, code := runScraper(t, t.TempDir(), "scrape -url "+server.URL+"/ -header X-Staging-Token"); code == 0 {
+		t.Errorf("a header without a colon should be rejected: %s", output)
+	}
+}
+
+func TestProgressOutputFlag(t *testing.T) {
+	site := testsite.New(t, map[string]string{
+		"/":             `<html><body><img src="/img/logo.png"></body></html>`,
+		"/img/logo.png": "logo",
+	})
+	output, code := runScraper(t, t.TempDir(), "scrape -url "+site.URL+"/")
+	if code != 0 || !strings.Contains(string(output), "Downloaded 1/1 assets (100%)") {
+		t.Errorf("the progress summary should be printed by default (exit %d): %s", code, output)
+	}
+	output, code = runScraper(t, t.TempDir(), "scrape -url "+site.URL+"/ -quiet")
+	if code != 0 || strings.Contains(string(output), "Downloaded 1/1") {
+		t.Errorf("-quiet should suppress progress output (exit %d): %s", code, output)
+	}
+}
+
+func TestProgressJSONStdoutCarriesOnlyEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<html><head><link rel="stylesheet" href="/css/site.css"></head><body><img src="/img/a.png"></body></html>`))
+		case "/css/site.css":
+			w.Write([]byte(`body { margin: 0 }`))
+		case "/img/a.png":
+			w.Write([]byte("png"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	cmd := exec.Command(os.Args[0])
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "WP_STATIC_SCRAPER_ARGS=scrape -url "+server.URL+"/ -progress-json -")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("scraper failed: %v: %s", err, stderr.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) < 4 {
+		t.Fatalf("expected started and completed events for both assets on stdout, got %q", stdout.String())
+	}
+	for _, line := range lines {
+		var event map[string]any
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Errorf("stdout line is not a JSON event: %q", line)
+		}
+	}
+	if !strings.Contains(stderr.String(), "Static HTML with local assets saved to") {
+		t.Errorf("human output should go to stderr, got %q", stderr.String())
+	}
+}
+
+func TestProgressJSONFileUsesFileMode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body><img src="/img/a.png"></body></html>`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	output, code := runScraper(t, dir, "scrape -url "+server.URL+"/ -progress-json events.ndjson -file-mode 0600")
+	if code != 0 {
+		t.Fatalf("scraper exited with %d: %s", code, output)
+	}
+	info, err := os.Stat(filepath.Join(dir, "events.ndjson"))
+	if err != nil {
+		t.Fatalf("progress file was not created: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("progress file mode = %o, want 600", mode)
+	}
+}
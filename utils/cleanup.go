@@ -1,23 +1,35 @@
 package utils
 
-import "os"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
 
-// CleanupOldFiles removes the entire output directory and all its contents
-func CleanupOldFiles(outputFile string) {
+// DefaultOutDir is where scrape writes and serve reads from unless told otherwise
+const DefaultOutDir = "output"
+
+// CleanupOldFiles removes the output directory and all its contents
+func CleanupOldFiles(outDir string) {
 	// Remove entire output directory and all its contents
-	os.RemoveAll("output")
+	RemoveOutput(outDir)
 }
 
-// EnsureDirectories creates necessary output directories
-func EnsureDirectories() error {
-	if err := os.MkdirAll("output/assets", 0755); err != nil {
-		return err
-	}
-	if err := os.MkdirAll("output/assets/images", 0755); err != nil {
-		return err
+// RemoveOutput deletes the output directory and everything in it. The working directory and the
+// filesystem root are refused, so a mistyped -outdir cannot wipe them.
+func RemoveOutput(outDir string) error {
+	if clean := filepath.Clean(outDir); outDir == "" || clean == "." || clean == ".." || clean == filepath.Dir(clean) {
+		return fmt.Errorf("refusing to remove %q", outDir)
 	}
-	if err := os.MkdirAll("output/assets/fonts", 0755); err != nil {
-		return err
-	}
-	return nil
-}
\ No newline at end of file
+	return os.RemoveAll(outDir)
+}
+
+// EnsureDirectories creates the default output directories
+func EnsureDirectories() error {
+	return EnsureOutputDirectories(DefaultOutDir)
+}
+
+// EnsureOutputDirectories creates the asset directories under outDir
+func EnsureOutputDirectories(outDir string) error {
+	return (*Output)(nil).EnsureDirectories(outDir)
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// Default permissions for scraped output
+const (
+	DefaultFileMode os.FileMode = 0644
+	DefaultDirMode  os.FileMode = 0755
+)
+
+// Output writes scraped files and directories with configured permissions, optionally uploading
+// them (see SetUploader). A nil *Output writes to disk with DefaultFileMode and DefaultDirMode.
+type Output struct {
+	FileMode os.FileMode // Permissions for written files (0 means DefaultFileMode)
+	DirMode  os.FileMode // Permissions for created directories (0 means DefaultDirMode)
+
+	mu         sync.Mutex
+	uploader   Uploader
+	uploadOnly bool              // Files under uploadRoot are uploaded without being written to disk
+	uploadRoot string            // The output directory upload keys are relative to
+	sizes      map[string]int64  // Sizes of upload-only files
+	retained   map[string][]byte // Contents of upload-only stylesheets and scripts
+}
+
+// NewOutput returns an Output writing files and directories with the given permissions
+func NewOutput(file, dir os.FileMode) *Output {
+	return &Output{FileMode: file, DirMode: dir}
+}
+
+// modes returns the file and directory permissions to use, and whether they were configured so
+// they are enforced regardless of the umask
+func (o *Output) modes() (file, dir os.FileMode, explicit bool) {
+	file, dir = DefaultFileMode, DefaultDirMode
+	if o == nil {
+		return file, dir, false
+	}
+	if o.FileMode != 0 {
+		file = o.FileMode
+	}
+	if o.DirMode != 0 {
+		dir = o.DirMode
+	}
+	return file, dir, file != DefaultFileMode || dir != DefaultDirMode
+}
+
+// ParseFileMode parses an octal permission string such as "0640" or "750"
+func ParseFileMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid octal permissions %q", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// WriteFile writes data with the configured file mode, uploading files under the output
+// directory first when an Uploader is set
+func (o *Output) WriteFile(path string, data []byte) error {
+	if local, err := o.upload(path, data); err != nil || !local {
+		return err
+	}
+	mode, _, explicit := o.modes()
+	if err := os.WriteFile(path, data, mode); err != nil {
+		return err
+	}
+	if explicit {
+		return os.Chmod(path, mode)
+	}
+	return nil
+}
+
+// Create creates or truncates a file for streaming with the configured file mode. The file is
+// written to disk only, never uploaded.
+func (o *Output) Create(path string) (*os.File, error) {
+	mode, _, explicit := o.modes()
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return nil, err
+	}
+	if explicit {
+		if err := file.Chmod(mode); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+// MkdirAll creates a directory tree with the configured directory mode
+func (o *Output) MkdirAll(path string) error {
+	_, mode, explicit := o.modes()
+	if err := os.MkdirAll(path, mode); err != nil {
+		return err
+	}
+	if explicit {
+		return os.Chmod(path, mode)
+	}
+	return nil
+}
+
+// EnsureDirectories creates the asset directories under outDir
+func (o *Output) EnsureDirectories(outDir string) error {
+	for _, dir := range []string{"assets", "assets/images", "assets/fonts", "assets/media"} {
+		if err := o.MkdirAll(filepath.Join(outDir, dir)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteFile writes data to disk with the default output file mode
+func WriteFile(path string, data []byte) error {
+	return (*Output)(nil).WriteFile(path, data)
+}
+
+// MkdirAll creates a directory tree with the default output directory mode
+func MkdirAll(path string) error {
+	return (*Output)(nil).MkdirAll(path)
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestS3UploaderSignsPut(t *testing.T) {
+	var method, path, auth, contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, path, auth, contentType, body = r.Method, r.URL.EscapedPath(), r.Header.Get("Authorization"), r.Header.Get("Content-Type"), string(data)
+	}))
+	defer server.Close()
+
+	uploader := &S3Uploader{Endpoint: server.URL, Bucket: "site", Prefix: "preview", Region: "us-east-1", AccessKey: "AKID", SecretKey: "secret"}
+	if err := uploader.Upload("assets/my file.css", []byte("body{}")); err != nil {
+		t.Fatalf("Upload returned error: %v", err)
+	}
+
+	if method != http.MethodPut || path != "/site/preview/assets/my%20file.css" {
+		t.Errorf("request = %s %s; want PUT /site/preview/assets/my%%20file.css", method, path)
+	}
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/us-east-1/s3/aws4_request") {
+		t.Errorf("unexpected Authorization header %q", auth)
+	}
+	if !strings.HasPrefix(contentType, "text/css") || body != "body{}" {
+		t.Errorf("unexpected upload: Content-Type %q, body %q", contentType, body)
+	}
+}
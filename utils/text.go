@@ -0,0 +1,20 @@
+package utils
+
+import "bytes"
+
+// NormalizeLineEndings rewrites every line ending in content to LF ("lf") or CRLF ("crlf").
+// Any other style returns the content unchanged.
+func NormalizeLineEndings(content []byte, style string) []byte {
+	if style != "lf" && style != "crlf" {
+		return content
+	}
+
+	// Collapse CRLF and lone CR to LF first so mixed input converges
+	normalized := bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	normalized = bytes.ReplaceAll(normalized, []byte("\r"), []byte("\n"))
+
+	if style == "crlf" {
+		normalized = bytes.ReplaceAll(normalized, []byte("\n"), []byte("\r\n"))
+	}
+	return normalized
+}
@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestNormalizeLineEndings(t *testing.T) {
+	mixed := []byte("a\r\nb\nc\rd")
+	if got := string(NormalizeLineEndings(mixed, "lf")); got != "a\nb\nc\nd" {
+		t.Errorf("lf normalization = %q", got)
+	}
+	if got := string(NormalizeLineEndings(mixed, "crlf")); got != "a\r\nb\r\nc\r\nd" {
+		t.Errorf("crlf normalization = %q", got)
+	}
+	if got := string(NormalizeLineEndings(mixed, "")); got != string(mixed) {
+		t.Errorf("empty style should leave content unchanged, got %q", got)
+	}
+}
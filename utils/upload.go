@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Uploader stores output files somewhere other than (or besides) local disk, keyed by their
+// path relative to the output directory (e.g. "assets/style.css"). Implementations must be safe for
+// concurrent use because download workers write in parallel.
+type Uploader interface {
+	Upload(key string, data []byte) error
+}
+
+// retainedExtensions are kept in memory in upload-only mode: stylesheets and scripts are hashed
+// again for Subresource Integrity after they are saved
+var retainedExtensions = map[string]bool{".css": true, ".js": true, ".mjs": true}
+
+// SetUploader routes every file o writes under outDir through u. With local set files are still
+// written to disk as well; otherwise they are only uploaded, and o remembers their sizes (and the
+// contents of stylesheets and scripts) so Size and ReadFile keep working. A nil u restores
+// disk-only output.
+func (o *Output) SetUploader(u Uploader, local bool, outDir string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.uploader = u
+	o.uploadOnly = u != nil && !local
+	o.uploadRoot = filepath.ToSlash(filepath.Clean(outDir))
+	o.sizes = make(map[string]int64)
+	o.retained = make(map[string][]byte)
+}
+
+// UploadOnly reports whether files are uploaded without being written to disk
+func (o *Output) UploadOnly() bool {
+	return o != nil && o.uploadOnly
+}
+
+// Size returns the size of a file written through o
+func (o *Output) Size(path string) (int64, error) {
+	if !o.UploadOnly() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	size, ok := o.sizes[outputKey(path)]
+	if !ok {
+		return 0, &fs.PathError{Op: "stat", Path: path, Err: fs.ErrNotExist}
+	}
+	return size, nil
+}
+
+// ReadFile reads back a file written through o. In upload-only mode only stylesheets and scripts
+// are kept, so other files report fs.ErrNotExist.
+func (o *Output) ReadFile(path string) ([]byte, error) {
+	if !o.UploadOnly() {
+		return os.ReadFile(path)
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	data, ok := o.retained[outputKey(path)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+	}
+	return data, nil
+}
+
+// outputKey normalizes a written path so writers and readers agree on it
+func outputKey(path string) string {
+	return filepath.ToSlash(filepath.Clean(strings.ReplaceAll(path, "\\", "/")))
+}
+
+// upload hands a file under the output directory to the configured uploader and reports whether
+// it should also be written locally
+func (o *Output) upload(path string, data []byte) (bool, error) {
+	if o == nil || o.uploader == nil {
+		return true, nil
+	}
+	key, underOutput := strings.CutPrefix(outputKey(path), o.uploadRoot+"/")
+	if !underOutput {
+		return true, nil
+	}
+	if err := o.uploader.Upload(key, data); err != nil {
+		return false, err
+	}
+	if o.uploadOnly {
+		o.mu.Lock()
+		o.sizes[outputKey(path)] = int64(len(data))
+		if retainedExtensions[strings.ToLower(filepath.Ext(path))] {
+			o.retained[outputKey(path)] = data
+		}
+		o.mu.Unlock()
+	}
+	return !o.uploadOnly, nil
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"net/url"
 	"regexp"
+	"strings"
 )
 
 // ResolveURL resolves a relative URL against a base URL
@@ -21,4 +22,57 @@ func RemoveSourceMapReferences(content string) string {
 	// JS: //# sourceMappingURL=file.js.map
 	re := regexp.MustCompile(`(/\*#\s*sourceMappingURL=.*?\*/|//#\s*sourceMappingURL=.*)`)
 	return re.ReplaceAllString(content, "")
-}
\ No newline at end of file
+}
+
+// StripQueryParams removes query parameters matching any pattern from a URL, keeping the rest in order.
+// A pattern ending in "*" matches by prefix, so "utm_*" covers utm_source, utm_medium, and so on.
+func StripQueryParams(rawURL string, patterns []string) string {
+	queryStart := strings.Index(rawURL, "?")
+	if queryStart == -1 {
+		return rawURL
+	}
+
+	prefix := rawURL[:queryStart]
+	query := rawURL[queryStart+1:]
+	fragment := ""
+	if hash := strings.Index(query, "#"); hash != -1 {
+		fragment = query[hash:]
+		query = query[:hash]
+	}
+
+	var kept []string
+	for _, pair := range strings.Split(query, "&") {
+		if pair == "" {
+			continue
+		}
+		key := pair
+		if eq := strings.Index(pair, "="); eq != -1 {
+			key = pair[:eq]
+		}
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+		if !matchesParamPattern(key, patterns) {
+			kept = append(kept, pair)
+		}
+	}
+
+	if len(kept) == 0 {
+		return prefix + fragment
+	}
+	return prefix + "?" + strings.Join(kept, "&") + fragment
+}
+
+// matchesParamPattern reports whether a query key matches an exact or prefix* pattern
+func matchesParamPattern(key string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(key, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if key == pattern {
+			return true
+		}
+	}
+	return false
+}
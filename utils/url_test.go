@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestStripQueryParams(t *testing.T) {
+	patterns := []string{"utm_*", "fbclid", "gclid"}
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "only tracking params",
+			input:    "https://example.com/post/?utm_source=x&utm_medium=email",
+			expected: "https://example.com/post/",
+		},
+		{
+			name:     "mixed params keep order",
+			input:    "https://example.com/?p=2&fbclid=abc&lang=en",
+			expected: "https://example.com/?p=2&lang=en",
+		},
+		{
+			name:     "fragment preserved",
+			input:    "/page?gclid=1#top",
+			expected: "/page#top",
+		},
+		{
+			name:     "no query",
+			input:    "https://example.com/",
+			expected: "https://example.com/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := StripQueryParams(tt.input, patterns)
+			if result != tt.expected {
+				t.Errorf("StripQueryParams(%q) = %q; want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}